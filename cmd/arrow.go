@@ -0,0 +1,240 @@
+package cmd
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/dbunt1tled/parquet2csv/internal/file"
+	"github.com/dbunt1tled/parquet2csv/internal/metrics"
+	"github.com/dbunt1tled/parquet2csv/internal/progress"
+	"github.com/dbunt1tled/parquet2csv/internal/report"
+	"github.com/dbunt1tled/parquet2csv/internal/schema"
+	"github.com/dbunt1tled/parquet2csv/pkg/convert"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/parquet"
+	parquetschema "github.com/xitongsys/parquet-go/schema"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+func isArrowFile(path string) bool {
+	switch filepath.Ext(path) {
+	case ".arrow", ".arrows", ".feather", ".ipc":
+		return true
+	default:
+		return false
+	}
+}
+
+// readArrow passes the record batches of an arrow file to fn one by one,
+// keeping the run report, metrics and --progress up to date.
+func readArrow(cmd *cobra.Command, ar *file.ArrowReader, input string, showProgress bool, fn func(rec array.Record) error) error {
+	var (
+		pg   *progress.Progress
+		read int64
+	)
+	rep := report.FromContext(cmd.Context())
+	mtr := metrics.FromContext(cmd.Context())
+
+	if showProgress {
+		size, err := inputSize(input)
+		if err != nil {
+			return err
+		}
+		pg = progress.New(os.Stderr, size, 0).Start(0, 0)
+		defer pg.Stop()
+	}
+	for {
+		phaseStart := time.Now()
+		rec, err := ar.Next()
+		rep.Timings.Read.Since(phaseStart)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		n := rec.NumRows()
+		rep.RowsRead += n
+		mtr.RowsRead.Add(float64(n))
+		mtr.BytesRead.Add(float64(ar.BytesRead() - read))
+		read = ar.BytesRead()
+		mtr.BatchesInFlight.Inc()
+		err = fn(rec)
+		mtr.BatchesInFlight.Dec()
+		if err != nil {
+			return err
+		}
+		rep.RowsWritten += n
+		mtr.RowsWritten.Add(float64(n))
+		if pg != nil {
+			pg.Set(rep.RowsRead, read)
+		}
+	}
+}
+
+// arrowToParquet writes an arrow ipc file or stream to parquet, the arrow schema is kept
+// in the ARROW:schema key-value metadata like arrow writers do.
+func arrowToParquet(cmd *cobra.Command, input, output string, compression int, showProgress, verify bool) error {
+	var (
+		sum     *convert.Checksum
+		written int64
+	)
+	rep := report.FromContext(cmd.Context())
+
+	ar, err := file.NewArrowReader(input)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = ar.Close()
+	}()
+	rows, err := schema.NewArrowRows(ar.Schema())
+	if err != nil {
+		return err
+	}
+	arrowSchema, err := schema.EncodeArrowSchema(ar.Schema())
+	if err != nil {
+		return err
+	}
+
+	fw, err := local.NewLocalFileWriter(output)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = fw.Close()
+	}()
+	pw, err := writer.NewParquetWriter(fw, rows.New(), 2) //nolint:mnd // maybe the number of threads
+	if err != nil {
+		return errors.Wrap(err, "can't create parquet writer")
+	}
+	pw.RowGroupSize = 128 * 1024 * 1024 //nolint:mnd // 128MB
+	pw.CompressionType = parquet.CompressionCodec(int32(compression))
+	pw.Footer.KeyValueMetadata = append(pw.Footer.KeyValueMetadata, &parquet.KeyValue{Key: schema.ArrowSchemaKey, Value: &arrowSchema})
+	rep.Schema = report.ColumnsFromParquet(pw.SchemaHandler)
+	if verify {
+		sum = convert.NewParquetChecksum(pw)
+	}
+
+	err = readArrow(cmd, ar, input, showProgress, func(rec array.Record) error {
+		for i := range int(rec.NumRows()) {
+			phaseStart := time.Now()
+			row := rows.Row(rec, i)
+			rep.Timings.Convert.Since(phaseStart)
+			if sum != nil {
+				if err = sum.Row(row); err != nil {
+					return err
+				}
+			}
+			phaseStart = time.Now()
+			err = pw.Write(row)
+			rep.Timings.Write.Since(phaseStart)
+			if err != nil {
+				return errors.Wrap(err, "write error")
+			}
+			written++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	phaseStart := time.Now()
+	err = pw.WriteStop()
+	rep.Timings.Flush.Since(phaseStart)
+	if err != nil {
+		return errors.Wrap(err, "write stop error")
+	}
+	if err = fw.Close(); err != nil {
+		return errors.Wrap(err, "close writer error")
+	}
+	return verifySum(cmd, output, sum, convert.Options{}, convert.Stats{RowsWritten: written}, convert.VerifyParquet)
+}
+
+// arrowToCSV writes the top-level columns of an arrow ipc file or stream to csv,
+// values are formatted like the json export and nested ones are written as json.
+func arrowToCSV(
+	cmd *cobra.Command, input, output, delimiter string, dialect file.CSVDialect, flush int, showProgress, verify bool,
+) error {
+	var (
+		sum     *convert.Checksum
+		written int64
+	)
+	rep := report.FromContext(cmd.Context())
+
+	ar, err := file.NewArrowReader(input)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = ar.Close()
+	}()
+	rows, err := schema.NewArrowRows(ar.Schema())
+	if err != nil {
+		return err
+	}
+	sh, err := parquetschema.NewSchemaHandlerFromStruct(rows.New())
+	if err != nil {
+		return errors.Wrap(err, "error build schema")
+	}
+	rep.Schema = report.ColumnsFromParquet(sh)
+	tree := schema.NewTree(sh)
+
+	fw, err := file.NewCSVWriter(output, delimiter, dialect, flush)
+	if err != nil {
+		return errors.Wrap(err, "error open file writer")
+	}
+	header := make([]string, 0, len(tree.Children))
+	for _, c := range tree.Children {
+		header = append(header, c.Name)
+	}
+	if err = fw.WriteS(header); err != nil {
+		_ = fw.Close()
+		return errors.Wrap(err, "error write header")
+	}
+	if verify {
+		sum = convert.NewChecksum()
+	}
+
+	record := make([]string, len(header))
+	err = readArrow(cmd, ar, input, showProgress, func(rec array.Record) error {
+		for i := range int(rec.NumRows()) {
+			phaseStart := time.Now()
+			obj, _ := tree.Value(reflect.ValueOf(rows.Row(rec, i))).(schema.Object)
+			for j, f := range obj {
+				record[j] = schema.Text(f.Value)
+			}
+			rep.Timings.Convert.Since(phaseStart)
+			if sum != nil {
+				sum.Record(record)
+			}
+			phaseStart = time.Now()
+			err = fw.WriteS(record)
+			rep.Timings.Write.Since(phaseStart)
+			if err != nil {
+				return errors.Wrap(err, "error write row")
+			}
+			written++
+		}
+		return nil
+	})
+	if err != nil {
+		_ = fw.Close()
+		return err
+	}
+	phaseStart := time.Now()
+	err = fw.Close()
+	rep.Timings.Flush.Since(phaseStart)
+	if err != nil {
+		return errors.Wrap(err, "error close file writer")
+	}
+	opts := convert.Options{Delimiter: delimiter, Dialect: convertDialect(dialect)}
+	return verifySum(cmd, output, sum, opts, convert.Stats{RowsWritten: written, Header: header}, convert.VerifyCSV)
+}
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dbunt1tled/parquet2csv/internal/file"
+	"github.com/dbunt1tled/parquet2csv/internal/metrics"
+	"github.com/dbunt1tled/parquet2csv/internal/progress"
+	"github.com/dbunt1tled/parquet2csv/internal/report"
+	"github.com/dbunt1tled/parquet2csv/internal/schema"
+	"github.com/dbunt1tled/parquet2csv/pkg/convert"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+func isAvroFile(path string) bool {
+	return filepath.Ext(path) == ".avro"
+}
+
+// avroToParquet writes an avro container file to parquet, the avro schema is kept
+// in the parquet.avro.schema key-value metadata like parquet-avro does.
+func avroToParquet(cmd *cobra.Command, input, output string, compression int, showProgress, verify bool) error {
+	var (
+		pg      *progress.Progress
+		sum     *convert.Checksum
+		written int64
+	)
+	rep := report.FromContext(cmd.Context())
+	mtr := metrics.FromContext(cmd.Context())
+
+	ar, err := file.NewAvroReader(input)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = ar.Close()
+	}()
+	avroSchema := ar.Schema()
+	rows, err := schema.NewAvroRows(avroSchema)
+	if err != nil {
+		return err
+	}
+
+	fw, err := local.NewLocalFileWriter(output)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = fw.Close()
+	}()
+	pw, err := writer.NewParquetWriter(fw, rows.New(), 2) //nolint:mnd // maybe the number of threads
+	if err != nil {
+		return errors.Wrap(err, "can't create parquet writer")
+	}
+	pw.RowGroupSize = 128 * 1024 * 1024 //nolint:mnd // 128MB
+	pw.CompressionType = parquet.CompressionCodec(int32(compression))
+	pw.Footer.KeyValueMetadata = append(pw.Footer.KeyValueMetadata, &parquet.KeyValue{Key: schema.AvroSchemaKey, Value: &avroSchema})
+	rep.Schema = report.ColumnsFromParquet(pw.SchemaHandler)
+	if verify {
+		sum = convert.NewParquetChecksum(pw)
+	}
+
+	if showProgress {
+		size, err := inputSize(input)
+		if err != nil {
+			return err
+		}
+		pg = progress.New(os.Stderr, size, 0).Start(0, 0)
+		defer pg.Stop()
+	}
+	var read int64
+	for {
+		phaseStart := time.Now()
+		datum, nextErr := ar.Next()
+		rep.Timings.Read.Since(phaseStart)
+		if errors.Is(nextErr, io.EOF) {
+			break
+		}
+		if nextErr != nil {
+			return nextErr
+		}
+		rep.RowsRead++
+		mtr.RowsRead.Inc()
+		mtr.BytesRead.Add(float64(ar.BytesRead() - read))
+		read = ar.BytesRead()
+
+		phaseStart = time.Now()
+		row := rows.Row(datum)
+		rep.Timings.Convert.Since(phaseStart)
+		if sum != nil {
+			if err = sum.Row(row); err != nil {
+				return err
+			}
+		}
+		phaseStart = time.Now()
+		err = pw.Write(row)
+		rep.Timings.Write.Since(phaseStart)
+		if err != nil {
+			return errors.Wrap(err, "write error")
+		}
+		written++
+		rep.RowsWritten++
+		mtr.RowsWritten.Inc()
+		if pg != nil {
+			pg.Set(rep.RowsRead, read)
+		}
+	}
+
+	phaseStart := time.Now()
+	err = pw.WriteStop()
+	rep.Timings.Flush.Since(phaseStart)
+	if err != nil {
+		return errors.Wrap(err, "write stop error")
+	}
+	if err = fw.Close(); err != nil {
+		return errors.Wrap(err, "close writer error")
+	}
+	return verifySum(cmd, output, sum, convert.Options{}, convert.Stats{RowsWritten: written}, convert.VerifyParquet)
+}
@@ -0,0 +1,408 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/dbunt1tled/parquet2csv/internal/file"
+	"github.com/dbunt1tled/parquet2csv/internal/metrics"
+	"github.com/dbunt1tled/parquet2csv/internal/progress"
+	"github.com/dbunt1tled/parquet2csv/internal/report"
+	"github.com/dbunt1tled/parquet2csv/internal/storage"
+	"github.com/dbunt1tled/parquet2csv/pkg/convert"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type convertFunc func(ctx context.Context, r io.Reader, w io.Writer, opts convert.Options) (convert.Stats, error)
+
+// verifyFunc checks the output of a conversion run with Options.Verify, convert.VerifyParquet or convert.VerifyCSV.
+type verifyFunc func(ctx context.Context, r io.Reader, opts convert.Options, stats convert.Stats) error
+
+// runConvert runs a convert package conversion from input to output, keeping the run report,
+// metrics and --progress up to date. Rows rejected by the conversion go to badRowsPath if set.
+// With opts.Verify the output is read back by verify and a local one is deleted when it doesn't hold
+// the rows written.
+func runConvert(
+	cmd *cobra.Command,
+	input, output, badRowsPath string,
+	showProgress bool,
+	opts convert.Options,
+	fn convertFunc,
+	verify verifyFunc,
+) error {
+	rep := report.FromContext(cmd.Context())
+	in, size, err := openInput(cmd, input)
+	if err != nil {
+		return err
+	}
+	defer func(in io.ReadCloser) {
+		_ = in.Close()
+	}(in)
+	out, err := createOutput(cmd.Context(), output)
+	if err != nil {
+		return err
+	}
+	// a no-op once the output is closed, a failed upload leaves no object behind
+	defer out.Abort()
+	rep.Input.Size = size
+	written := &countingWriter{w: out}
+
+	badRows, err := openBadRows(cmd, badRowsPath, 0, &opts)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if badRows != nil {
+			_ = badRows.Close()
+		}
+	}()
+	stop := watchConversion(cmd, &opts, size, showProgress, 0, 0)
+	defer stop()
+
+	stats, err := fn(cmd.Context(), in, written, opts)
+	addStats(rep, stats)
+	if err != nil {
+		return conversionError(err, badRows)
+	}
+	if badRows != nil {
+		err = badRows.Close()
+		badRows = nil
+		if err != nil {
+			return errors.Wrap(err, "error close bad rows file")
+		}
+	}
+	rep.Output.Size = written.n
+	if err = out.Close(); err != nil {
+		return errors.Wrap(err, "error close file "+output)
+	}
+	if opts.Verify {
+		return verifyOutput(cmd, output, opts, stats, verify)
+	}
+	return nil
+}
+
+// runPartitions converts csv or fixed-width input to parquet with opts.PartitionBy, the rows of every
+// value go to partitionPath of output and are reported as parts. With opts.Verify every partition
+// is read back.
+func runPartitions(cmd *cobra.Command, input, output, badRowsPath string, showProgress bool, opts convert.Options) error {
+	rep := report.FromContext(cmd.Context())
+	in, size, err := openInput(cmd, input)
+	if err != nil {
+		return err
+	}
+	defer func(in io.ReadCloser) {
+		_ = in.Close()
+	}(in)
+	rep.Input.Size = size
+
+	badRows, err := openBadRows(cmd, badRowsPath, 0, &opts)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if badRows != nil {
+			_ = badRows.Close()
+		}
+	}()
+	stop := watchConversion(cmd, &opts, size, showProgress, 0, 0)
+	defer stop()
+
+	var (
+		paths   []string
+		outs    []outputFile
+		written []*countingWriter
+	)
+	defer func() {
+		for _, out := range outs {
+			out.Abort()
+		}
+	}()
+	opts.Hooks.Partition = func(value string) (io.Writer, error) {
+		name := partitionPath(output, opts.PartitionBy, value)
+		if !storage.IsS3(name) {
+			if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil { //nolint:mnd // default dir permissions
+				return nil, errors.Wrap(err, "error create dir "+filepath.Dir(name))
+			}
+		}
+		out, err := createOutput(cmd.Context(), name)
+		if err != nil {
+			return nil, err
+		}
+		w := &countingWriter{w: out}
+		paths, outs, written = append(paths, name), append(outs, out), append(written, w)
+		return w, nil
+	}
+
+	stats, err := convert.CSVToParquet(cmd.Context(), in, nil, opts)
+	addStats(rep, stats)
+	if err != nil {
+		return conversionError(err, badRows)
+	}
+	if badRows != nil {
+		err = badRows.Close()
+		badRows = nil
+		if err != nil {
+			return errors.Wrap(err, "error close bad rows file")
+		}
+	}
+	for i, out := range outs {
+		if err = out.Close(); err != nil {
+			return errors.Wrap(err, "error close file "+paths[i])
+		}
+		rep.Parts = append(rep.Parts, report.File{Path: paths[i], Size: written[i].n})
+		rep.Output.Size += written[i].n
+	}
+	if !opts.Verify {
+		return nil
+	}
+	for i, p := range stats.Partitions {
+		pStats := convert.Stats{RowsWritten: p.Rows, Checksum: p.Checksum}
+		if err = verifyOutput(cmd, paths[i], opts, pStats, convert.VerifyParquet); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// partitionPath is the output of the rows of a --partition-by value in hive style,
+// those of region EU of out/orders.parquet go to out/orders/region=EU/data.parquet.
+func partitionPath(output, column, value string) string {
+	ext := path.Ext(output)
+	return strings.TrimSuffix(output, ext) + "/" + hiveEscape(column) + "=" + hiveEscape(value) + "/data" + ext
+}
+
+// hiveEscape escapes a partition column or value like hive does, an empty value is hive's default partition.
+func hiveEscape(s string) string {
+	if s == "" {
+		return "__HIVE_DEFAULT_PARTITION__"
+	}
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		if c < 0x20 || c == 0x7f || strings.IndexByte(`"#%'*/:=?\{[]^`, c) >= 0 {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// openBadRows opens the --bad-rows file keeping its first keep bytes and sends the rows rejected
+// by the conversion there, nil without a path.
+func openBadRows(cmd *cobra.Command, path string, keep int64, opts *convert.Options) (*file.BadRowWriter, error) {
+	if path == "" {
+		return nil, nil //nolint:nilnil // no bad rows file
+	}
+	badRows, err := file.NewBadRowWriter(path, keep)
+	if err != nil {
+		return nil, err
+	}
+	mtr := metrics.FromContext(cmd.Context())
+	opts.Hooks.BadRow = func(bad convert.BadRow) error {
+		mtr.RowsRejected.Inc()
+		return errors.Wrap(badRows.Write(file.BadRow{Line: bad.Line, Raw: bad.Raw, Err: bad.Err}), "error write bad row")
+	}
+	return badRows, nil
+}
+
+// watchConversion sets the hooks keeping the run report, metrics and --progress of a conversion
+// of an input of size bytes up to date. doneRows and doneBytes are the rows and input bytes
+// converted by the run it resumes. The returned func stops the progress.
+func watchConversion(
+	cmd *cobra.Command, opts *convert.Options, size int64, showProgress bool, doneRows, doneBytes int64,
+) func() {
+	var (
+		last   = convert.Stats{BytesRead: doneBytes}
+		queued atomic.Int64
+		pg     *progress.Progress
+	)
+	rep := report.FromContext(cmd.Context())
+	mtr := metrics.FromContext(cmd.Context())
+	mtr.WatchQueue(func() int {
+		return int(queued.Load())
+	})
+	opts.Hooks.Start = func(stats convert.Stats) error {
+		rep.Schema = reportColumns(stats.Schema)
+		if showProgress {
+			pg = progress.New(os.Stderr, size, stats.RowsTotal).Start(doneRows, doneBytes)
+		}
+		return nil
+	}
+	opts.Hooks.BatchRead = func(stats convert.Stats) {
+		queued.Store(int64(stats.Queued))
+		mtr.BatchesInFlight.Inc()
+		mtr.BytesRead.Add(float64(stats.BytesRead - last.BytesRead))
+	}
+	opts.Hooks.BatchWritten = func(stats convert.Stats) {
+		mtr.BatchesInFlight.Dec()
+		mtr.RowsRead.Add(float64(stats.RowsRead - last.RowsRead))
+		mtr.RowsWritten.Add(float64(stats.RowsWritten - last.RowsWritten))
+		last = stats
+		if pg != nil {
+			read := stats.BytesRead
+			if stats.RowsTotal > 0 {
+				// parquet input is read by column chunks, the share of rows tells the progress better
+				read = size * stats.RowsRead / stats.RowsTotal
+			}
+			pg.Set(doneRows+stats.RowsRead, read)
+		}
+	}
+	return func() {
+		if pg != nil {
+			pg.Stop()
+		}
+	}
+}
+
+// addStats adds the totals of a conversion to the run report.
+func addStats(rep *report.Report, stats convert.Stats) {
+	rep.RowsRead += stats.RowsRead
+	rep.RowsWritten += stats.RowsWritten
+	rep.RowsRejected += stats.RowsRejected
+	rep.RowsFiltered += stats.RowsFiltered
+	rep.Timings.Read += report.Duration(stats.Timings.Read)
+	rep.Timings.Convert += report.Duration(stats.Timings.Convert)
+	rep.Timings.Write += report.Duration(stats.Timings.Write)
+	rep.Timings.Flush += report.Duration(stats.Timings.Flush)
+}
+
+// conversionError points at --bad-rows when a bad row stopped a conversion without it.
+func conversionError(err error, badRows *file.BadRowWriter) error {
+	var bad convert.BadRow
+	if badRows == nil && errors.As(err, &bad) {
+		return errors.Wrap(err, "bad row, set --bad-rows to skip it")
+	}
+	return err
+}
+
+// verifyOutput reads an output back with verify. A local output that fails is deleted,
+// an s3 object is kept for a look at what was uploaded.
+func verifyOutput(cmd *cobra.Command, output string, opts convert.Options, stats convert.Stats, verify verifyFunc) error {
+	f, _, err := openInput(cmd, output)
+	if err == nil {
+		err = verify(cmd.Context(), f, opts, stats)
+		_ = f.Close()
+	}
+	if err == nil {
+		return nil
+	}
+	if storage.IsS3(output) {
+		return errors.Wrap(err, output+" not deleted")
+	}
+	if rErr := os.Remove(output); rErr != nil {
+		return errors.Wrapf(err, "%s not deleted: %v", output, rErr)
+	}
+	return errors.Wrap(err, output+" deleted")
+}
+
+// verifySum reads an output written outside the convert package back with verify against the rows
+// summed by sum, nil without --verify. stats has the rows and header written.
+func verifySum(
+	cmd *cobra.Command, output string, sum *convert.Checksum, opts convert.Options, stats convert.Stats, verify verifyFunc,
+) error {
+	if sum == nil {
+		return nil
+	}
+	var err error
+	if stats.Checksum, err = sum.Sum(); err != nil {
+		return err
+	}
+	return verifyOutput(cmd, output, opts, stats, verify)
+}
+
+// countingWriter counts the bytes written, the report size of outputs that can't be stat'ed.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// addToParquetFlags adds the options of a conversion to parquet, the flags of the parquet command
+// serve takes as query parameters too.
+func addToParquetFlags(cmd *cobra.Command) {
+	cmd.Flags().IntP("compression", "c", 0, "Type of compression")
+	cmd.Flags().IntP("flush", "f", file.FlushCount, "number of rows to flush")
+	cmd.Flags().StringP("delimiter", "d", ",", delimiterUsage)
+	addCSVInputFlags(cmd)
+	cmd.Flags().StringArray("schema", nil,
+		"Csv input: column type as name=type, the type string, int, double, boolean, date or decimal(p,s), repeatable")
+	cmd.Flags().StringArray("filter", nil,
+		"Csv and fixed-width input: keep the rows where column<op>value, op one of = != < <= > >=, repeatable")
+}
+
+// toParquetOptions reads the flags of addToParquetFlags, delimiter and dialect are those of the input.
+func toParquetOptions(cmd *cobra.Command, delimiter string, dialect file.CSVDialect) (convert.Options, error) {
+	opts := convert.Options{Delimiter: delimiter, Dialect: convertDialect(dialect)}
+	compression, err := cmd.Flags().GetInt("compression")
+	if err != nil {
+		return opts, errors.Wrap(err, "error read compression")
+	}
+	opts.Compression = convert.Compression(compression)
+	if opts.FlushRows, err = cmd.Flags().GetInt("flush"); err != nil {
+		return opts, errors.Wrap(err, "error read flush")
+	}
+	if opts.Schema, err = csvSchema(cmd); err != nil {
+		return opts, err
+	}
+	opts.Filter, err = rowFilters(cmd)
+	return opts, err
+}
+
+// addToCSVFlags adds the options of a conversion to csv, the flags of the csv command serve takes
+// as query parameters too.
+func addToCSVFlags(cmd *cobra.Command) {
+	cmd.Flags().IntP("compression", "c", 0, "Type of compression")
+	cmd.Flags().IntP("flush", "f", file.FlushCount, "number of rows to flush")
+	cmd.Flags().StringP("delimiter", "d", ",", delimiterUsage)
+	addCSVOutputFlags(cmd)
+	cmd.Flags().StringArray("filter", nil, filterUsage)
+}
+
+// toCSVOptions reads the flags of addToCSVFlags, delimiter and dialect are those of the output.
+func toCSVOptions(cmd *cobra.Command, delimiter string, dialect file.CSVDialect) (convert.Options, error) {
+	opts := convert.Options{Delimiter: delimiter, Dialect: convertDialect(dialect)}
+	flush, err := cmd.Flags().GetInt("flush")
+	if err != nil {
+		return opts, errors.Wrap(err, "error read flush")
+	}
+	opts.BatchSize, opts.FlushRows = flush, flush
+	opts.Filter, err = rowFilters(cmd)
+	return opts, err
+}
+
+// convertDialect is the dialect of the csv flags as a convert option.
+func convertDialect(d file.CSVDialect) convert.Dialect {
+	return convert.Dialect{
+		Quote:            d.Quote,
+		Escape:           d.Escape,
+		Encoding:         d.Encoding,
+		Comment:          d.Comment,
+		LazyQuotes:       d.LazyQuotes,
+		TrimLeadingSpace: d.TrimLeadingSpace,
+		FieldsPerRecord:  d.FieldsPerRecord,
+		NoHeader:         d.NoHeader,
+		QuoteMode:        d.QuoteMode,
+		CRLF:             d.CRLF,
+		BOM:              d.BOM,
+	}
+}
+
+func reportColumns(fields []convert.Field) []report.Column {
+	columns := make([]report.Column, 0, len(fields))
+	for _, f := range fields {
+		columns = append(columns, report.Column{Name: f.Name, Type: f.Type, ConvertedType: f.ConvertedType})
+	}
+	return columns
+}
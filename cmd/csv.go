@@ -0,0 +1,268 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dbunt1tled/parquet2csv/internal/file"
+	"github.com/dbunt1tled/parquet2csv/internal/schema"
+	"github.com/dbunt1tled/parquet2csv/pkg/convert"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const delimiterUsage = `Delimiter for csv file: a character like ; or ¦, a name like tab or pipe, ` +
+	`an escape like \t or \u00a6, several characters like || or auto to sniff it from csv input`
+
+const filterUsage = "Keep the rows where column<op>value, op one of = != < <= > >=, repeatable"
+
+// csvDelimiter reads the delimiter flag, see file.ParseDelimiter. Auto is returned as file.DelimiterAuto.
+func csvDelimiter(cmd *cobra.Command) (string, error) {
+	spec, err := cmd.Flags().GetString("delimiter")
+	if err != nil {
+		return "", errors.Wrap(err, "error read delimiter")
+	}
+	if strings.EqualFold(spec, file.DelimiterAuto) {
+		return file.DelimiterAuto, nil
+	}
+	return file.ParseDelimiter(spec)
+}
+
+// addCSVInputFlags adds the csv dialect flags of the commands reading csv.
+func addCSVInputFlags(cmd *cobra.Command) {
+	cmd.Flags().String("quote", `"`, "Csv input: quote character")
+	cmd.Flags().String("escape", "double", `Csv input: escape of the quote in quoted fields, double or a character like \`)
+	cmd.Flags().String("comment", "", "Csv input: skip lines starting with this character")
+	cmd.Flags().Bool("lazy-quotes", false, "Csv input: allow quotes in unquoted fields and stray quotes in quoted ones")
+	cmd.Flags().Bool("trim-leading-space", false, "Csv input: ignore leading white space of fields")
+	cmd.Flags().Int("fields-per-record", 0, "Csv input: fields in every row, 0 takes the header count, -1 allows any")
+	cmd.Flags().String("input-encoding", file.EncodingAuto,
+		"Text input character set like windows-1251 or utf-16le, auto detects utf-16 and utf-8 by the byte order mark")
+}
+
+// addCSVOutputFlags adds the csv dialect flags of the commands writing csv.
+func addCSVOutputFlags(cmd *cobra.Command) {
+	cmd.Flags().String("quote", `"`, "Quote character")
+	cmd.Flags().String("escape", "double", `Escape of the quote in quoted fields, double or a character like \`)
+	cmd.Flags().String("quote-style", file.QuoteMinimal, "Quote fields: minimal, all or never")
+	cmd.Flags().Bool("crlf", false, "End lines with \\r\\n instead of \\n")
+	cmd.Flags().String("output-encoding", file.EncodingUTF8, "Character set like windows-1251 or utf-16le")
+	cmd.Flags().Bool("bom", false, "Start the file with a byte order mark")
+}
+
+// addCSVOutputStyleFlags adds the csv output flags besides the quote and escape, for commands with csv input flags too.
+func addCSVOutputStyleFlags(cmd *cobra.Command) {
+	cmd.Flags().String("quote-style", file.QuoteMinimal, "Csv output: quote fields, minimal, all or never")
+	cmd.Flags().Bool("crlf", false, "Csv output: end lines with \\r\\n instead of \\n")
+	cmd.Flags().String("output-encoding", file.EncodingUTF8, "Csv output: character set like windows-1251 or utf-16le")
+	cmd.Flags().Bool("bom", false, "Csv output: start the file with a byte order mark")
+}
+
+// csvSchema reads the schema flag, name=type pairs typing csv columns, see convert.Column.
+func csvSchema(cmd *cobra.Command) ([]convert.Column, error) {
+	specs, err := cmd.Flags().GetStringArray("schema")
+	if err != nil {
+		return nil, errors.Wrap(err, "error read schema")
+	}
+	columns := make([]convert.Column, 0, len(specs))
+	for _, spec := range specs {
+		i := strings.LastIndex(spec, "=")
+		if i < 1 || i == len(spec)-1 {
+			return nil, errors.New("--schema " + spec + " is not name=type")
+		}
+		if _, err = schema.ParseTextType(spec[i+1:]); err != nil {
+			return nil, errors.Wrap(err, "--schema "+spec)
+		}
+		columns = append(columns, convert.Column{Name: spec[:i], Type: spec[i+1:]})
+	}
+	return columns, nil
+}
+
+// rowFilters reads the --filter flags, rows are kept when they match all of them.
+func rowFilters(cmd *cobra.Command) ([]convert.Filter, error) {
+	specs, err := cmd.Flags().GetStringArray("filter")
+	if err != nil {
+		return nil, errors.Wrap(err, "error read filter")
+	}
+	filters := make([]convert.Filter, 0, len(specs))
+	for _, spec := range specs {
+		f, err := convert.ParseFilter(spec)
+		if err != nil {
+			return nil, errors.Wrap(err, "--filter")
+		}
+		filters = append(filters, f)
+	}
+	return filters, nil
+}
+
+// csvInputDialect reads the flags added by addCSVInputFlags, an auto encoding is detected from the input.
+// An auto delimiter is sniffed from the input with the header and the quote, escape and encoding left at
+// defaults. A sniffed input that isn't utf-8 needs --input-encoding.
+func csvInputDialect(cmd *cobra.Command, input, delimiter string) (string, file.CSVDialect, error) {
+	sniff := func() (*file.Sniff, error) {
+		return sniffInput(cmd, input, file.SniffSize)
+	}
+	if isRemote(input) {
+		return csvReaderDialect(cmd, delimiter, sniff, "")
+	}
+	return csvReaderDialect(cmd, delimiter, sniff, input)
+}
+
+// csvReaderDialect is csvInputDialect for an input sniffed by sniffCSV. An auto encoding is detected
+// from the file at path, without one the conversion tells utf-16 by the byte order mark as it reads.
+func csvReaderDialect(
+	cmd *cobra.Command,
+	delimiter string,
+	sniffCSV func() (*file.Sniff, error),
+	path string,
+) (string, file.CSVDialect, error) {
+	var (
+		d     file.CSVDialect
+		sniff *file.Sniff
+		err   error
+	)
+	if delimiter == file.DelimiterAuto {
+		if sniff, err = sniffCSV(); err != nil {
+			return "", d, err
+		}
+		delimiter = sniff.Delimiter
+		d = sniff.Dialect()
+		if verbose, _ := cmd.Flags().GetBool("verbose"); verbose {
+			fmt.Fprintf(os.Stderr, "sniffed delimiter %q, quote %q, escape %s, header %t, encoding %s, confidence %.2f\n",
+				sniff.Delimiter, sniff.Quote, sniff.Escape, sniff.Header, sniff.Encoding, sniff.Confidence)
+		}
+	}
+	quote, escape, err := csvQuoteFlags(cmd)
+	if err != nil {
+		return "", d, err
+	}
+	if sniff == nil || cmd.Flags().Changed("quote") {
+		d.Quote = quote
+	}
+	if sniff == nil || cmd.Flags().Changed("escape") {
+		d.Escape = escape
+	}
+	comment, err := cmd.Flags().GetString("comment")
+	if err != nil {
+		return "", d, errors.Wrap(err, "error read comment")
+	}
+	if comment != "" {
+		if d.Comment, err = flagRune("comment", comment); err != nil {
+			return "", d, err
+		}
+	}
+	lazyQuotes, err := cmd.Flags().GetBool("lazy-quotes")
+	if err != nil {
+		return "", d, errors.Wrap(err, "error read lazy quotes")
+	}
+	d.LazyQuotes = d.LazyQuotes || lazyQuotes
+	d.TrimLeadingSpace, err = cmd.Flags().GetBool("trim-leading-space")
+	if err != nil {
+		return "", d, errors.Wrap(err, "error read trim leading space")
+	}
+	d.FieldsPerRecord, err = cmd.Flags().GetInt("fields-per-record")
+	if err != nil {
+		return "", d, errors.Wrap(err, "error read fields per record")
+	}
+	encoding, err := cmd.Flags().GetString("input-encoding")
+	if err != nil {
+		return "", d, errors.Wrap(err, "error read input encoding")
+	}
+	switch {
+	case sniff != nil && strings.EqualFold(encoding, file.EncodingAuto) && sniff.Encoding == file.EncodingUnknown:
+		return "", d, errors.New("the input isn't utf-8 and its encoding can't be sniffed, set --input-encoding like windows-1252")
+	case sniff != nil && strings.EqualFold(encoding, file.EncodingAuto):
+	case path == "":
+		d.Encoding = encoding
+		if !strings.EqualFold(encoding, file.EncodingAuto) {
+			err = file.CheckEncoding(encoding)
+		}
+	default:
+		d.Encoding, err = file.DetectEncoding(path, encoding)
+	}
+	if err != nil {
+		return "", d, err
+	}
+	return delimiter, d, d.Validate(delimiter)
+}
+
+// sniffInput guesses the dialect of a local file, an s3:// object or an http(s):// url from its first size bytes.
+func sniffInput(cmd *cobra.Command, input string, size int) (*file.Sniff, error) {
+	if !isRemote(input) {
+		return file.SniffCSV(input, size)
+	}
+	r, _, err := openInput(cmd, input)
+	if err != nil {
+		return nil, err
+	}
+	defer func(r io.ReadCloser) {
+		_ = r.Close()
+	}(r)
+	s, err := file.SniffReader(r, size)
+	return s, errors.Wrap(err, "error sniff "+input)
+}
+
+// csvOutputDialect reads the flags added by addCSVOutputFlags.
+func csvOutputDialect(cmd *cobra.Command, delimiter string) (file.CSVDialect, error) {
+	var (
+		d   file.CSVDialect
+		err error
+	)
+	if d.Quote, d.Escape, err = csvQuoteFlags(cmd); err != nil {
+		return d, err
+	}
+	d.QuoteMode, err = cmd.Flags().GetString("quote-style")
+	if err != nil {
+		return d, errors.Wrap(err, "error read quote style")
+	}
+	d.CRLF, err = cmd.Flags().GetBool("crlf")
+	if err != nil {
+		return d, errors.Wrap(err, "error read crlf")
+	}
+	d.Encoding, err = cmd.Flags().GetString("output-encoding")
+	if err != nil {
+		return d, errors.Wrap(err, "error read output encoding")
+	}
+	d.BOM, err = cmd.Flags().GetBool("bom")
+	if err != nil {
+		return d, errors.Wrap(err, "error read bom")
+	}
+	if err = file.CheckEncoding(d.Encoding); err != nil {
+		return d, err
+	}
+	if delimiter == file.DelimiterAuto {
+		return d, errors.New("--delimiter auto is for csv input only")
+	}
+	return d, d.Validate(delimiter)
+}
+
+func csvQuoteFlags(cmd *cobra.Command) (quote, escape rune, err error) {
+	q, err := cmd.Flags().GetString("quote")
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "error read quote")
+	}
+	if quote, err = flagRune("quote", q); err != nil {
+		return 0, 0, err
+	}
+	e, err := cmd.Flags().GetString("escape")
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "error read escape")
+	}
+	if e != file.EscapeDouble {
+		if escape, err = flagRune("escape", e); err != nil {
+			return 0, 0, err
+		}
+	}
+	return quote, escape, nil
+}
+
+func flagRune(name, value string) (rune, error) {
+	if utf8.RuneCountInString(value) != 1 {
+		return 0, errors.New("--" + name + " must be a single character")
+	}
+	r, _ := utf8.DecodeRuneInString(value)
+	return r, nil
+}
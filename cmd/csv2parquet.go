@@ -2,153 +2,343 @@ package cmd
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/dbunt1tled/parquet2csv/internal/file"
 	"github.com/dbunt1tled/parquet2csv/internal/helper"
-	"github.com/dbunt1tled/parquet2csv/internal/schema"
+	"github.com/dbunt1tled/parquet2csv/internal/report"
+	"github.com/dbunt1tled/parquet2csv/internal/storage"
+	"github.com/dbunt1tled/parquet2csv/pkg/convert"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
-	"github.com/xitongsys/parquet-go-source/local"
-	"github.com/xitongsys/parquet-go/parquet"
-	"github.com/xitongsys/parquet-go/source"
-	"github.com/xitongsys/parquet-go/writer"
 )
 
 var csv2parquet = &cobra.Command{ //nolint:gochecknoglobals // need for init command
 	Use:   "parquet <input> <output>",
-	Short: "Convert csv to parquet",
-	Long:  "Convert file from csv to parquet",
+	Short: "Convert csv, json, arrow, avro or fixed-width text to parquet",
+	Long:  "Convert file from csv, json array, newline-delimited json, arrow ipc, avro or fixed-width text with --layout to parquet",
 	Args:  cobra.RangeArgs(1, 2), //nolint:mnd // args count
-	RunE: func(cmd *cobra.Command, args []string) error {
+	RunE: withReport(func(cmd *cobra.Command, args []string) error {
 		var (
 			err                error
 			input, output, ext string
-			compression        int
 			delimiter          string
-			flush              int
+			dialect            file.CSVDialect
 			verbose            bool
-			header             []string
-			structType         interface{}
-			processor          schema.Processor
-			fw                 source.ParquetFile
-			pw                 *writer.ParquetWriter
+			checkpoint         string
+			checkpointRows     int
+			showProgress       bool
+			layoutPath         string
+			badRowsPath        string
+			partitionBy        string
+			verify             bool
 		)
 		startTime := time.Now()
+		rep := report.FromContext(cmd.Context())
 
-		input = args[0]
-		ext = filepath.Ext(input)
-		if ext != ".csv" {
-			return errors.New("file is not csv file")
+		layoutPath, err = cmd.Flags().GetString("layout")
+		if err != nil {
+			return errors.Wrap(err, "error read layout")
+		}
+		checkpoint, err = cmd.Flags().GetString("checkpoint")
+		if err != nil {
+			return errors.Wrap(err, "error read checkpoint")
+		}
+		checkpointRows, err = cmd.Flags().GetInt("checkpoint-rows")
+		if err != nil {
+			return errors.Wrap(err, "error read checkpoint rows")
+		}
+		badRowsPath, err = cmd.Flags().GetString("bad-rows")
+		if err != nil {
+			return errors.Wrap(err, "error read bad rows")
+		}
+		if verify, err = cmd.Flags().GetBool("verify"); err != nil {
+			return errors.Wrap(err, "error read verify")
 		}
-		if _, err = file.IsExist(input); err != nil {
-			return errors.Wrap(err, "input file "+input+" not exist")
+		if partitionBy, err = cmd.Flags().GetString("partition-by"); err != nil {
+			return errors.Wrap(err, "error read partition by")
 		}
-		output = strings.TrimSuffix(input, ext) + ".parquet"
 
+		input = args[0]
+		rep.Input.Path = input
+		ext = inputExt(input)
+		output = defaultOutput(input, ".parquet")
 		if len(args) == 2 { //nolint:mnd // args count
 			output = args[1]
 			output = strings.TrimSuffix(output, ".parquet") + ".parquet"
 		}
+		if isRemote(input) || isRemote(output) {
+			if layoutPath != "" || checkpoint != "" || ext != ".csv" {
+				return errors.New(errRemoteUnsupported)
+			}
+			if input, output, err = objectPaths([]string{input, output}, ".parquet"); err != nil {
+				return err
+			}
+		} else {
+			if layoutPath == "" && ext != ".csv" && !isJSONFile(input) && !isArrowFile(input) && !isAvroFile(input) {
+				return errors.New("file is not csv, json, arrow or avro file")
+			}
+			if _, err = file.IsExist(input); err != nil {
+				return errors.Wrap(err, "input file "+input+" not exist")
+			}
+		}
 
-		compression, err = cmd.Flags().GetInt("compression")
-		if err != nil {
-			return errors.Wrap(err, "error read compression")
+		if delimiter, err = csvDelimiter(cmd); err != nil {
+			return err
 		}
-		flush, err = cmd.Flags().GetInt("flush")
+		verbose, err = cmd.Flags().GetBool("verbose")
 		if err != nil {
-			return errors.Wrap(err, "error read flush")
+			return errors.Wrap(err, "error read verbose")
+		}
+		if delimiter, dialect, err = csvInputDialect(cmd, input, delimiter); err != nil {
+			return err
 		}
-		delimiter, err = cmd.Flags().GetString("delimiter")
+		opts, err := toParquetOptions(cmd, delimiter, dialect)
 		if err != nil {
-			return errors.Wrap(err, "error read delimiter")
+			return err
 		}
-		verbose, err = cmd.Flags().GetBool("verbose")
+		opts.Verify, opts.PartitionBy = verify, partitionBy
+
+		showProgress, err = cmd.Flags().GetBool("progress")
 		if err != nil {
-			return errors.Wrap(err, "error read verbose")
+			return errors.Wrap(err, "error read progress")
 		}
 
-		if _, err = file.IsWritable(filepath.Dir(output)); err != nil {
-			return err
+		rep.Output.Path = output
+
+		if !storage.IsS3(output) {
+			if _, err = file.IsWritable(filepath.Dir(output)); err != nil {
+				return err
+			}
 		}
 
-		fw, err = local.NewLocalFileWriter(output)
-		if err != nil {
-			return err
+		if len(opts.Schema) > 0 && (layoutPath != "" || checkpoint != "" || ext != ".csv") {
+			return errors.New("--schema is supported for csv input without --layout and --checkpoint only")
 		}
-		defer func(fw source.ParquetFile) {
-			err = fw.Close()
+		if partitionBy != "" && checkpoint != "" {
+			return errors.New("--partition-by can't be used with --checkpoint")
+		}
+		if layoutPath == "" && (isJSONFile(input) || isArrowFile(input) || isAvroFile(input)) {
+			if checkpoint != "" {
+				return errors.New("--checkpoint is supported for csv input only")
+			}
+			if len(opts.Filter) > 0 || partitionBy != "" {
+				return errors.New("--filter and --partition-by are supported for csv and fixed-width input only")
+			}
+			switch {
+			case isJSONFile(input):
+				err = jsonToParquet(cmd, input, output, badRowsPath, int(opts.Compression), showProgress, verify)
+			case isArrowFile(input):
+				err = arrowToParquet(cmd, input, output, int(opts.Compression), showProgress, verify)
+			default:
+				err = avroToParquet(cmd, input, output, int(opts.Compression), showProgress, verify)
+			}
 			if err != nil {
-				err = errors.Wrap(err, "close writer error")
+				return err
+			}
+			if verbose {
+				fmt.Printf("%s\n", helper.RuntimeStatistics(startTime, input)) //nolint:forbidigo  // verbose output
 			}
-		}(fw)
+			return nil
+		}
+		if layoutPath != "" {
+			if opts.Layout, err = convert.LoadLayout(layoutPath); err != nil {
+				return err
+			}
+		}
+		switch {
+		case checkpoint != "":
+			if !file.IsUTF8(dialect.Encoding) {
+				return errors.New("--checkpoint is supported for utf-8 input only")
+			}
+			err = runCheckpoint(cmd, input, output, checkpoint, checkpointRows, badRowsPath, showProgress, opts)
+		case partitionBy != "":
+			err = runPartitions(cmd, input, output, badRowsPath, showProgress, opts)
+		default:
+			err = runConvert(cmd, input, output, badRowsPath, showProgress, opts, convert.CSVToParquet, convert.VerifyParquet)
+		}
 		if err != nil {
 			return err
 		}
-		i := 0
-		bp := file.NewBatchProcessor(input, file.FlushCount, []rune(delimiter)[0], false)
-		bCh, eCh := bp.Reader()
+		if verbose {
+			fmt.Printf("%s\n", helper.RuntimeStatistics(startTime, input)) //nolint:forbidigo  // verbose output
+		}
+		return nil
+	}),
+}
 
-		dataPool := &sync.Pool{
-			New: func() interface{} {
-				data := make(map[string]interface{})
-				return &data
-			},
+// runCheckpoint converts csv or fixed-width input to parquet parts of about rows rows next to output,
+// recording every part in the checkpoint directory so that a rerun goes on after the last one.
+func runCheckpoint(
+	cmd *cobra.Command,
+	input, output, checkpoint string,
+	rows int,
+	badRowsPath string,
+	showProgress bool,
+	opts convert.Options,
+) error {
+	rep := report.FromContext(cmd.Context())
+	cp, err := file.LoadCheckpoint(checkpoint)
+	if err != nil {
+		return err
+	}
+	var keep, doneRows int64
+	if cp == nil {
+		if cp, err = file.NewCheckpoint(input, output); err != nil {
+			return err
+		}
+	} else {
+		if err = cp.Validate(input, output); err != nil {
+			return errors.Wrap(err, "can't resume from checkpoint "+checkpoint)
+		}
+		if cp.Done {
+			return nil
+		}
+		opts.Resume = &convert.Resume{
+			Position: convert.Position{Offset: cp.Offset, Line: cp.Line, Row: cp.Row, Batch: cp.BatchID},
+			Header:   cp.Header,
 		}
+		if opts.Verify {
+			if err = verifyParts(cmd, cp, opts); err != nil {
+				return err
+			}
+		}
+		keep = cp.BadRows
+		// the rows read by the checkpoint count the csv header
+		doneRows = int64(cp.Row)
+		if len(opts.Layout) == 0 && !opts.Dialect.NoHeader {
+			doneRows--
+		}
+	}
 
-		for rows := range bCh {
-			select {
-			case err = <-eCh:
-				return errors.Wrap(err, "write error")
-			default:
-				for _, rec := range rows.Rows {
-					if i == 0 {
-						header = rec
-						structType, processor = schema.ProcessDefault(header)
-						pw, err = writer.NewParquetWriter(fw, structType, 2) //nolint:mnd // maybe the number of threads
-						if err != nil {
-							return errors.Wrap(err, "can't create parquet writer")
-						}
-						pw.RowGroupSize = 128 * 1024 * 1024 //nolint:mnd // 128MB
-						pw.CompressionType = parquet.CompressionCodec(int32(compression))
-						i++
-						continue
-					}
-
-					eData := processor(rec, structType, header, dataPool)
-					if err = pw.Write(eData); err != nil {
-						return errors.Wrap(err, "write error")
-					}
-
-					if i == flush {
-						if err = pw.Flush(true); err != nil {
-							return errors.Wrap(err, "write flush error")
-						}
-						i = 0
-					}
-					i++
-				}
+	in, err := os.Open(input)
+	if err != nil {
+		return errors.Wrap(err, "error opening file "+input)
+	}
+	defer func(in *os.File) {
+		_ = in.Close()
+	}(in)
+	if _, err = in.Seek(cp.Offset, io.SeekStart); err != nil {
+		return errors.Wrap(err, "error seeking file "+input)
+	}
+	if rep.Input.Size, err = inputSize(input); err != nil {
+		return err
+	}
+
+	badRows, err := openBadRows(cmd, badRowsPath, keep, &opts)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if badRows != nil {
+			_ = badRows.Close()
+		}
+	}()
+	stop := watchConversion(cmd, &opts, rep.Input.Size, showProgress, doneRows, cp.Offset)
+	defer stop()
+
+	var (
+		part string
+		f    *os.File
+	)
+	defer func() {
+		if f != nil {
+			_ = f.Close()
+		}
+	}()
+	start := opts.Hooks.Start
+	opts.Hooks.Start = func(stats convert.Stats) error {
+		cp.Header = stats.Header
+		return start(stats)
+	}
+	opts.PartRows = rows
+	opts.Hooks.Part = func() (io.Writer, error) {
+		part = file.PartName(output, len(cp.Parts))
+		var err error
+		if f, err = os.Create(part); err != nil {
+			return nil, errors.Wrap(err, "error create file "+part)
+		}
+		return f, nil
+	}
+	// every part is committed with the bad rows rejected up to its last row
+	opts.Hooks.PartDone = func(p convert.Part) error {
+		err := f.Close()
+		f = nil
+		if err != nil {
+			return errors.Wrap(err, "close writer error")
+		}
+		if opts.Verify {
+			stats := convert.Stats{RowsWritten: p.Rows, Checksum: p.Checksum}
+			if err = verifyOutput(cmd, part, opts, stats, convert.VerifyParquet); err != nil {
+				return err
+			}
+		}
+		if badRows != nil {
+			if cp.BadRows, err = badRows.Sync(); err != nil {
+				return err
 			}
 		}
+		pos, sum := p.Position, file.PartSum{Rows: p.Rows, Checksum: p.Checksum}
+		return errors.Wrap(cp.Commit(checkpoint, part, sum, pos.Offset, pos.Line, pos.Row, pos.Batch), "checkpoint error")
+	}
 
-		if err = pw.WriteStop(); err != nil {
-			return errors.Wrap(err, "write stop error")
+	stats, err := convert.CSVToParquet(cmd.Context(), in, nil, opts)
+	addStats(rep, stats)
+	if err != nil {
+		return conversionError(err, badRows)
+	}
+	if badRows != nil {
+		err = badRows.Close()
+		badRows = nil
+		if err != nil {
+			return errors.Wrap(err, "error close bad rows file")
 		}
-		if verbose {
-			fmt.Printf("%s\n", helper.RuntimeStatistics(startTime, input)) //nolint:forbidigo  // verbose output
+	}
+	for _, p := range cp.Parts {
+		rep.Parts = append(rep.Parts, report.File{Path: p})
+	}
+	cp.Done = true
+	return errors.Wrap(cp.Save(checkpoint), "checkpoint error")
+}
+
+// verifyParts reads the parts written before a resume back against the rows the checkpoint recorded for them.
+func verifyParts(cmd *cobra.Command, cp *file.Checkpoint, opts convert.Options) error {
+	if len(cp.Sums) != len(cp.Parts) {
+		return errors.New("the checkpoint has no checksums of its parts, resume it without --verify")
+	}
+	for i, part := range cp.Parts {
+		sum := cp.Sums[i]
+		if sum.Checksum == "" {
+			return errors.New("part " + part + " was written without --verify, resume it without --verify")
 		}
-		return nil
-	},
+		stats := convert.Stats{RowsWritten: sum.Rows, Checksum: sum.Checksum}
+		if err := verifyOutput(cmd, part, opts, stats, convert.VerifyParquet); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 //nolint:gochecknoinits // need for init command
 func init() {
 	rootCmd.AddCommand(csv2parquet)
-	csv2parquet.Flags().IntP("compression", "c", 0, "Type of compression")
-	csv2parquet.Flags().IntP("flush", "f", file.FlushCount, "number of rows to flush")
-	csv2parquet.Flags().StringP("delimiter", "d", ",", "Delimiter for csv file")
+	addToParquetFlags(csv2parquet)
+	addHTTPInputFlags(csv2parquet)
 	csv2parquet.Flags().BoolP("verbose", "v", false, "Show debug information")
+	csv2parquet.Flags().Bool("flatten", false, "Json input: collapse nested objects into dotted top-level columns")
+	csv2parquet.Flags().Int("infer-rows", 0, "Json input: number of records to infer the schema from, 0 for all")
+	csv2parquet.Flags().Bool("progress", false, "Show progress on stderr")
+	csv2parquet.Flags().String("checkpoint", "", "Directory to store progress, rerun with it to resume")
+	csv2parquet.Flags().Int("checkpoint-rows", file.CheckpointRows,
+		"Rows per output part with --checkpoint, approximate: a part ends with the read batch of "+
+			strconv.Itoa(file.FlushCount)+" lines holding its last row")
+	csv2parquet.Flags().String("layout", "", "Layout json (name, start, length, type, trim per column) to read the input as fixed-width text")
+	csv2parquet.Flags().String("partition-by", "", "Csv and fixed-width input: write the rows of every value of this column to <output>/<column>=<value>/data.parquet")
+	csv2parquet.Flags().String("bad-rows", "", "File to write rejected input lines to, without it the first bad line fails the run")
+	csv2parquet.Flags().Bool("verify", false, "Read the output back and fail unless it holds the rows written, a local output is deleted then")
 }
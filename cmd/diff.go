@@ -0,0 +1,298 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/dbunt1tled/parquet2csv/internal/diff"
+	"github.com/dbunt1tled/parquet2csv/internal/file"
+	"github.com/dbunt1tled/parquet2csv/internal/schema"
+	"github.com/dbunt1tled/parquet2csv/pkg/convert"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/source"
+)
+
+var diffCmd = &cobra.Command{ //nolint:gochecknoglobals // need for init command
+	Use:   "diff <a> <b>",
+	Short: "Compare two csv or parquet files",
+	Long: "Compare the schemas, row counts and values of two csv or parquet files, a csv and the parquet it was " +
+		"converted to or two versions of a table. Rows are matched by the --key columns or, without a key, " +
+		"by all their values in any order. Csv values are compared with parquet ones by the parquet type: " +
+		"numbers by value, dates and timestamps by instant. The run fails when the files differ",
+	Args: cobra.ExactArgs(2), //nolint:mnd // args count
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var (
+			opts diff.Options
+			err  error
+		)
+		if opts.Key, err = cmd.Flags().GetStringSlice("key"); err != nil {
+			return errors.Wrap(err, "error read key")
+		}
+		if opts.Tolerance, err = cmd.Flags().GetFloat64("tolerance"); err != nil {
+			return errors.Wrap(err, "error read tolerance")
+		}
+		if opts.Tolerance > 0 && len(opts.Key) == 0 {
+			return errors.New("--tolerance needs --key")
+		}
+		if opts.MaxRows, err = cmd.Flags().GetInt("max-rows"); err != nil {
+			return errors.Wrap(err, "error read max rows")
+		}
+		delimiter, err := csvDelimiter(cmd)
+		if err != nil {
+			return err
+		}
+		tables := make([]diff.Table, len(args))
+		for i, input := range args {
+			if tables[i], err = diffTable(cmd, input, delimiter); err != nil {
+				return err
+			}
+		}
+
+		r, err := diff.Compare(tables[0], tables[1], opts)
+		if err != nil {
+			return err
+		}
+		printDiff(cmd.OutOrStdout(), r, args[0], args[1])
+		if r.Equal() {
+			return nil
+		}
+		// the differences are the output, not a usage error
+		cmd.SilenceUsage = true
+		return errors.Errorf("%s and %s differ", args[0], args[1])
+	},
+}
+
+// diffTable opens a parquet file or a local or remote csv file as a table to compare.
+func diffTable(cmd *cobra.Command, input, delimiter string) (diff.Table, error) {
+	if inputExt(input) == ".parquet" {
+		if isRemote(input) {
+			return diff.Table{}, errors.New(errRemoteUnsupported)
+		}
+		return parquetTable(cmd, input)
+	}
+	if !isRemote(input) {
+		if _, err := file.IsExist(input); err != nil {
+			return diff.Table{}, errors.Wrap(err, "input file "+input+" not exist")
+		}
+	}
+	delimiter, dialect, err := csvInputDialect(cmd, input, delimiter)
+	if err != nil {
+		return diff.Table{}, err
+	}
+	// the header is read ahead for the columns, the rows when the table is compared
+	header, err := csvHeader(cmd, input, delimiter, dialect)
+	if err != nil {
+		return diff.Table{}, err
+	}
+	t := diff.Table{Name: input, Columns: make([]diff.Column, len(header))}
+	for i, name := range header {
+		t.Columns[i] = diff.Column{Name: name}
+	}
+	t.Read = func(fn func(line int64, values []string) error) error {
+		return readCSVRows(cmd, input, delimiter, dialect, func(line int64, record []string) error {
+			if line == 0 && !dialect.NoHeader {
+				return nil
+			}
+			fitted, err := file.FitRecord(record, len(header))
+			if err != nil {
+				return errors.Wrapf(err, "line %d", line)
+			}
+			return fn(line, fitted)
+		})
+	}
+	return t, nil
+}
+
+// csvHeader reads the column names of a csv input, column_1, column_2, ... without a header.
+func csvHeader(cmd *cobra.Command, input, delimiter string, dialect file.CSVDialect) ([]string, error) {
+	var header []string
+	errStop := errors.New("stop")
+	err := readCSVRows(cmd, input, delimiter, dialect, func(_ int64, record []string) error {
+		header = record
+		if dialect.NoHeader {
+			header = file.ColumnNames(len(record))
+		}
+		return errStop
+	})
+	if err != nil && !errors.Is(err, errStop) {
+		return nil, err
+	}
+	if header == nil {
+		return nil, errors.New(input + ": csv file is empty")
+	}
+	return header, nil
+}
+
+// readCSVRows passes the records of a csv input to fn with their lines, 0 for the header.
+func readCSVRows(
+	cmd *cobra.Command, input, delimiter string, dialect file.CSVDialect, fn func(line int64, record []string) error,
+) error {
+	// the reader stops at the first error of fn
+	ctx, cancel := context.WithCancel(cmd.Context())
+	defer cancel()
+	bp := file.NewBatchProcessor(input, file.FlushCount, delimiter, false).
+		Dialect(dialect).
+		Context(ctx)
+	if isRemote(input) {
+		r, _, err := openInput(cmd, input)
+		if err != nil {
+			return err
+		}
+		defer func(r io.ReadCloser) {
+			_ = r.Close()
+		}(r)
+		bp.Source(r)
+	}
+	bCh, eCh := bp.Reader()
+	var err error
+	first := true
+	for batch := range bCh {
+		for n, rec := range batch.Rows {
+			if err != nil {
+				break
+			}
+			line := int64(batch.Lines[n])
+			if first && !dialect.NoHeader {
+				line = 0
+			}
+			first = false
+			if err = fn(line, rec); err != nil {
+				cancel()
+			}
+		}
+	}
+	if readErr := <-eCh; readErr != nil && err == nil {
+		err = errors.Wrap(readErr, "read error")
+	}
+	return err
+}
+
+// parquetTable opens a parquet file as a table, values formatted as the csv command writes them.
+func parquetTable(cmd *cobra.Command, input string) (diff.Table, error) {
+	fr, err := local.NewLocalFileReader(input)
+	if err != nil {
+		return diff.Table{}, errors.Wrap(err, "error open file reader")
+	}
+	defer func(fr source.ParquetFile) {
+		_ = fr.Close()
+	}(fr)
+	pr, err := reader.NewParquetReader(fr, nil, 1)
+	if err != nil {
+		return diff.Table{}, errors.Wrap(err, "error open parquet reader")
+	}
+	nodes := schema.NewTree(pr.SchemaHandler).Children
+	pr.ReadStop()
+
+	t := diff.Table{Name: input, Columns: make([]diff.Column, len(nodes))}
+	for i, n := range nodes {
+		t.Columns[i] = parquetColumn(n)
+	}
+	t.Read = func(fn func(line int64, values []string) error) error {
+		f, _, err := openInput(cmd, input)
+		if err != nil {
+			return err
+		}
+		defer func(f io.ReadCloser) {
+			_ = f.Close()
+		}(f)
+		var line int64
+		for values, err := range convert.ParquetRows[[]string](cmd.Context(), f, convert.Options{}) {
+			if err != nil {
+				return err
+			}
+			line++
+			if err = fn(line, values); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return t, nil
+}
+
+// parquetColumn describes a top level parquet column by its converted or logical type,
+// its physical type without one.
+func parquetColumn(n *schema.Node) diff.Column {
+	el := n.Element
+	c := diff.Column{Name: n.Name, Type: "GROUP"}
+	if len(n.Children) > 0 {
+		if el.IsSetConvertedType() {
+			c.Type = el.GetConvertedType().String()
+		}
+		return c
+	}
+	c.Type = el.GetType().String()
+	switch {
+	case el.IsSetConvertedType():
+		c.Type = el.GetConvertedType().String()
+	case el.IsSetLogicalType() && el.GetLogicalType().IsSetTIMESTAMP():
+		c.Type = "TIMESTAMP"
+	}
+	switch c.Type {
+	case "INT32", "INT64", "FLOAT", "DOUBLE", "DECIMAL", "INT_8", "INT_16", "INT_32", "INT_64",
+		"UINT_8", "UINT_16", "UINT_32", "UINT_64":
+		c.Kind = diff.Number
+	case "BOOLEAN":
+		c.Kind = diff.Bool
+	case "DATE", "TIMESTAMP", "TIMESTAMP_MILLIS", "TIMESTAMP_MICROS", parquet.Type_INT96.String():
+		c.Kind = diff.Time
+	}
+	if c.Type == parquet.ConvertedType_DECIMAL.String() {
+		c.Type += "(" + strconv.Itoa(int(el.GetPrecision())) + "," + strconv.Itoa(int(el.GetScale())) + ")"
+	}
+	return c
+}
+
+// printDiff writes the summary of r and its differing rows.
+func printDiff(w io.Writer, r diff.Result, a, b string) {
+	for _, s := range r.Schema {
+		fmt.Fprintf(w, "schema: %s\n", s)
+	}
+	fmt.Fprintf(w, "rows: %d in %s, %d in %s\n", r.RowsA, a, r.RowsB, b)
+	fmt.Fprintf(w, "rows: %d only in %s, %d only in %s, %d changed\n", r.OnlyA, a, r.OnlyB, b, r.Changed)
+	for i, n := range r.Cells {
+		if n > 0 {
+			fmt.Fprintf(w, "column %s: %d values changed\n", r.Columns[i], n)
+		}
+	}
+	for _, d := range r.Rows {
+		switch d.Kind {
+		case diff.OnlyA:
+			fmt.Fprintf(w, "%s:%d only in %s: %s\n", a, d.LineA, a, diffValues(r.Columns, d.Values))
+		case diff.OnlyB:
+			fmt.Fprintf(w, "%s:%d only in %s: %s\n", b, d.LineB, b, diffValues(r.Columns, d.Values))
+		case diff.Changed:
+			cells := make([]string, len(d.Cells))
+			for i, c := range d.Cells {
+				cells[i] = fmt.Sprintf("%s %q != %q", c.Column, c.A, c.B)
+			}
+			fmt.Fprintf(w, "%s:%d %s:%d %s: %s\n", a, d.LineA, b, d.LineB, d.Key, strings.Join(cells, ", "))
+		}
+	}
+}
+
+func diffValues(columns, values []string) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = fmt.Sprintf("%s=%q", columns[i], v)
+	}
+	return strings.Join(parts, " ")
+}
+
+//nolint:gochecknoinits // need for init command
+func init() {
+	rootCmd.AddCommand(diffCmd)
+	diffCmd.Flags().StringSlice("key", nil, "Columns matching the rows of both files, comma separated")
+	diffCmd.Flags().Float64("tolerance", 0, "Absolute difference under which numbers are equal, csv values too when both parse as numbers, with --key")
+	diffCmd.Flags().Int("max-rows", diff.MaxRows, "Differing rows printed, 0 prints all")
+	diffCmd.Flags().StringP("delimiter", "d", ",", delimiterUsage)
+	addCSVInputFlags(diffCmd)
+	addHTTPInputFlags(diffCmd)
+}
@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/dbunt1tled/parquet2csv/internal/file"
+	"github.com/dbunt1tled/parquet2csv/internal/metrics"
+	"github.com/dbunt1tled/parquet2csv/internal/progress"
+	"github.com/dbunt1tled/parquet2csv/internal/report"
+	"github.com/dbunt1tled/parquet2csv/internal/schema"
+	"github.com/dbunt1tled/parquet2csv/pkg/convert"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/xitongsys/parquet-go-source/writerfile"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// errInferDone stops the schema inference pass once --infer-rows records are seen.
+var errInferDone = errors.New("infer done") //nolint:gochecknoglobals // sentinel error
+
+func isJSONFile(path string) bool {
+	switch filepath.Ext(path) {
+	case ".json", ".ndjson", ".jsonl":
+		return true
+	default:
+		return false
+	}
+}
+
+// jsonToParquet infers a nested parquet schema from all (or --infer-rows) json records
+// in a first pass and writes the records with it in a second one. Records that don't fit the
+// schema go to badRowsPath, without it the first one fails the run.
+func jsonToParquet(cmd *cobra.Command, input, output, badRowsPath string, compression int, showProgress, verify bool) error {
+	var (
+		sum        *convert.Checksum
+		opts       convert.Options
+		written    int64
+		err        error
+		flatten    bool
+		inferRows  int
+		jsonSchema string
+		pg         *progress.Progress
+	)
+	rep := report.FromContext(cmd.Context())
+	mtr := metrics.FromContext(cmd.Context())
+
+	flatten, err = cmd.Flags().GetBool("flatten")
+	if err != nil {
+		return errors.Wrap(err, "error read flatten")
+	}
+	inferRows, err = cmd.Flags().GetInt("infer-rows")
+	if err != nil {
+		return errors.Wrap(err, "error read infer rows")
+	}
+
+	phaseStart := time.Now()
+	recordType := schema.NewJSONType()
+	seen := 0
+	err = file.ReadJSON(input, func(record file.JSONRecord) error {
+		value := record.Value
+		if flatten {
+			value = schema.Flatten(value)
+		}
+		recordType.Merge(value)
+		seen++
+		if inferRows > 0 && seen >= inferRows {
+			return errInferDone
+		}
+		return nil
+	})
+	rep.Timings.Convert.Since(phaseStart)
+	if err != nil && !errors.Is(err, errInferDone) {
+		return err
+	}
+	if flatten {
+		recordType.DropNullParents()
+	}
+	jsonSchema, err = recordType.ParquetSchema()
+	if err != nil {
+		return err
+	}
+
+	out, err := createOutput(cmd.Context(), output)
+	if err != nil {
+		return err
+	}
+	// a no-op once the output is closed, a failed run leaves no broken file behind
+	defer out.Abort()
+	fw := &countingWriter{w: out}
+	pw, err := writer.NewJSONWriter(jsonSchema, writerfile.NewWriterFile(fw), 2) //nolint:mnd // maybe the number of threads
+	if err != nil {
+		return errors.Wrap(err, "can't create parquet writer")
+	}
+	pw.RowGroupSize = 128 * 1024 * 1024 //nolint:mnd // 128MB
+	pw.CompressionType = parquet.CompressionCodec(int32(compression))
+	rep.Schema = report.ColumnsFromParquet(pw.SchemaHandler)
+	if verify {
+		sum = convert.NewParquetChecksum(&pw.ParquetWriter)
+	}
+
+	badRows, err := openBadRows(cmd, badRowsPath, 0, &opts)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if badRows != nil {
+			_ = badRows.Close()
+		}
+	}()
+
+	if showProgress {
+		size, err := inputSize(input)
+		if err != nil {
+			return err
+		}
+		pg = progress.New(os.Stderr, size, 0).Start(0, 0)
+		defer pg.Stop()
+	}
+
+	var offset int64
+	readStart := time.Now()
+	err = file.ReadJSON(input, func(record file.JSONRecord) error {
+		rep.Timings.Read.Since(readStart)
+		rep.RowsRead++
+		mtr.RowsRead.Inc()
+		mtr.BytesRead.Add(float64(record.Offset - offset))
+		offset = record.Offset
+		if pg != nil {
+			pg.Set(rep.RowsRead, record.Offset)
+		}
+		defer func() {
+			readStart = time.Now()
+		}()
+
+		phaseStart = time.Now()
+		value := record.Value
+		if flatten {
+			value = schema.Flatten(value)
+		}
+		coerced, cErr := recordType.Coerce(value)
+		rep.Timings.Convert.Since(phaseStart)
+		if cErr != nil {
+			bad := convert.BadRow{Line: record.Line, Raw: record.Raw, Err: cErr}
+			if opts.Hooks.BadRow == nil {
+				return bad
+			}
+			rep.RowsRejected++
+			return opts.Hooks.BadRow(bad)
+		}
+		data, mErr := sonic.ConfigStd.MarshalToString(coerced)
+		if mErr != nil {
+			return errors.Wrap(mErr, "error convert record on line "+strconv.Itoa(record.Line))
+		}
+		if sum != nil {
+			if sErr := sum.Row(data); sErr != nil {
+				return sErr
+			}
+		}
+		phaseStart = time.Now()
+		if wErr := pw.Write(data); wErr != nil {
+			return errors.Wrap(wErr, "write error on line "+strconv.Itoa(record.Line))
+		}
+		rep.Timings.Write.Since(phaseStart)
+		written++
+		rep.RowsWritten++
+		mtr.RowsWritten.Inc()
+		return nil
+	})
+	if err != nil {
+		return conversionError(err, badRows)
+	}
+
+	phaseStart = time.Now()
+	err = pw.WriteStop()
+	rep.Timings.Flush.Since(phaseStart)
+	if err != nil {
+		return errors.Wrap(err, "write stop error")
+	}
+	if badRows != nil {
+		err = badRows.Close()
+		badRows = nil
+		if err != nil {
+			return errors.Wrap(err, "error close bad rows file")
+		}
+	}
+	rep.Output.Size = fw.n
+	if err = out.Close(); err != nil {
+		return errors.Wrap(err, "close writer error")
+	}
+	return verifySum(cmd, output, sum, convert.Options{}, convert.Stats{RowsWritten: written}, convert.VerifyParquet)
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/dbunt1tled/parquet2csv/internal/file"
+	"github.com/dbunt1tled/parquet2csv/internal/storage"
+	"github.com/pkg/errors"
+)
+
+// parquetPaths validates the parquet input and resolves the output path with the given extension.
+func parquetPaths(args []string, outExt string) (string, string, error) {
+	if filepath.Ext(args[0]) != ".parquet" {
+		return "", "", errors.New("file is not parquet file")
+	}
+	return resolvePaths(args, outExt)
+}
+
+// resolvePaths checks the input exists and resolves the output path with the given extension.
+func resolvePaths(args []string, outExt string) (string, string, error) {
+	input, output, err := objectPaths(args, outExt)
+	if err == nil && (isRemote(input) || storage.IsS3(output)) {
+		return input, output, errors.New(errRemoteUnsupported)
+	}
+	return input, output, err
+}
+
+// objectPaths is resolvePaths for commands reading s3:// objects and http(s):// urls
+// and writing s3:// objects too.
+func objectPaths(args []string, outExt string) (string, string, error) {
+	input := args[0]
+	switch {
+	case storage.IsS3(input):
+		if _, _, err := storage.ParseS3(input); err != nil {
+			return "", "", err
+		}
+	case storage.IsHTTP(input):
+	default:
+		if _, err := file.IsExist(input); err != nil {
+			return "", "", errors.Wrap(err, "input file "+input+" not exist")
+		}
+	}
+	output := defaultOutput(input, outExt)
+	if len(args) == 2 { //nolint:mnd // args count
+		output = args[1]
+		if ext := filepath.Ext(output); ext != outExt {
+			// out.json with --ndjson is out.ndjson, not out.json.ndjson
+			output = strings.TrimSuffix(output, ext) + outExt
+		}
+	}
+	switch {
+	case storage.IsHTTP(output):
+		return "", "", errors.New("http(s):// urls are supported for input only")
+	case storage.IsS3(output):
+		_, _, err := storage.ParseS3(output)
+		return input, output, err
+	}
+	if _, err := file.IsWritable(filepath.Dir(output)); err != nil {
+		return "", "", err
+	}
+	return input, output, nil
+}
@@ -0,0 +1,233 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/dbunt1tled/parquet2csv/internal/file"
+	"github.com/dbunt1tled/parquet2csv/internal/helper"
+	"github.com/dbunt1tled/parquet2csv/internal/metrics"
+	"github.com/dbunt1tled/parquet2csv/internal/progress"
+	"github.com/dbunt1tled/parquet2csv/internal/report"
+	"github.com/dbunt1tled/parquet2csv/internal/schema"
+	"github.com/dbunt1tled/parquet2csv/pkg/convert"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var parquet2arrow = &cobra.Command{ //nolint:gochecknoglobals // need for init command
+	Use:   "arrow <input> <output>",
+	Short: "Convert parquet or csv to arrow",
+	Long:  "Convert file from parquet or csv to an arrow ipc file (feather v2) or stream",
+	Args:  cobra.RangeArgs(1, 2), //nolint:mnd // args count
+	RunE: withReport(func(cmd *cobra.Command, args []string) error {
+		var (
+			err           error
+			input, output string
+			ext           string
+			delimiter     string
+			flush         int
+			verbose       bool
+			stream        bool
+			showProgress  bool
+			filters       []convert.Filter
+		)
+		startTime := time.Now()
+		rep := report.FromContext(cmd.Context())
+
+		flush, err = cmd.Flags().GetInt("flush")
+		if err != nil {
+			return errors.Wrap(err, "error read flush")
+		}
+		if delimiter, err = csvDelimiter(cmd); err != nil {
+			return err
+		}
+		verbose, err = cmd.Flags().GetBool("verbose")
+		if err != nil {
+			return errors.Wrap(err, "error read verbose")
+		}
+		stream, err = cmd.Flags().GetBool("stream")
+		if err != nil {
+			return errors.Wrap(err, "error read stream")
+		}
+		showProgress, err = cmd.Flags().GetBool("progress")
+		if err != nil {
+			return errors.Wrap(err, "error read progress")
+		}
+		if filters, err = rowFilters(cmd); err != nil {
+			return err
+		}
+
+		ext = ".arrow"
+		if stream {
+			ext = ".arrows"
+		}
+		if len(args) == 2 && isArrowFile(args[1]) { //nolint:mnd // args count
+			ext = filepath.Ext(args[1])
+		}
+		if filepath.Ext(args[0]) == ".csv" {
+			input, output, err = resolvePaths(args, ext)
+		} else {
+			input, output, err = parquetPaths(args, ext)
+		}
+		rep.Input.Path, rep.Output.Path = args[0], output
+		if err != nil {
+			return err
+		}
+
+		if filepath.Ext(input) == ".csv" && len(filters) > 0 {
+			return errors.New("--filter is supported for parquet input only")
+		}
+		if filepath.Ext(input) == ".csv" {
+			delimiter, dialect, dialectErr := csvInputDialect(cmd, input, delimiter)
+			if dialectErr != nil {
+				return dialectErr
+			}
+			err = csvToArrow(cmd, input, output, delimiter, dialect, flush, stream, showProgress)
+		} else {
+			opts := convert.Options{BatchSize: flush, FlushRows: flush, Filter: filters}
+			ao := convert.ArrowOptions{Stream: stream}
+			err = runConvert(cmd, input, output, "", showProgress, opts,
+				func(ctx context.Context, r io.Reader, w io.Writer, opts convert.Options) (convert.Stats, error) {
+					return convert.ParquetToArrow(ctx, r, w, opts, ao)
+				}, nil)
+		}
+		if err != nil {
+			return err
+		}
+		if verbose {
+			fmt.Printf("%s\n", helper.RuntimeStatistics(startTime, input)) //nolint:forbidigo // verbose output
+		}
+		return nil
+	}),
+}
+
+// csvToArrow writes csv rows as utf8 columns named after the header, like the parquet conversion does.
+func csvToArrow(
+	cmd *cobra.Command,
+	input, output string,
+	delimiter string,
+	dialect file.CSVDialect,
+	flush int,
+	stream, showProgress bool,
+) error {
+	var (
+		header []string
+		fw     *file.ArrowWriter
+		pg     *progress.Progress
+		offset int64
+	)
+	rep := report.FromContext(cmd.Context())
+	mtr := metrics.FromContext(cmd.Context())
+
+	out, err := createOutput(cmd.Context(), output)
+	if err != nil {
+		return err
+	}
+	// a no-op once the output is closed
+	defer out.Abort()
+	written := &countingWriter{w: out}
+
+	bCh, eCh := file.NewBatchProcessor(input, flush, delimiter, false).Dialect(dialect).Reader()
+	mtr.WatchQueue(func() int {
+		return len(bCh)
+	})
+	if showProgress {
+		size, err := inputSize(input)
+		if err != nil {
+			return err
+		}
+		pg = progress.New(os.Stderr, size, 0).Start(0, 0)
+		defer pg.Stop()
+	}
+	defer func() {
+		if fw != nil {
+			// the output is discarded, closing releases the builder
+			_ = fw.Close()
+		}
+	}()
+
+	readStart := time.Now()
+	for rows := range bCh {
+		rep.Timings.Read.Since(readStart)
+		mtr.BytesRead.Add(float64(rows.Offset - offset))
+		offset = rows.Offset
+		for n, rec := range rows.Rows {
+			if header == nil {
+				header = rec
+				if dialect.NoHeader {
+					header = file.ColumnNames(len(rec))
+				}
+				fields := make([]arrow.Field, 0, len(header))
+				for _, name := range header {
+					fields = append(fields, arrow.Field{Name: name, Type: arrow.BinaryTypes.String})
+				}
+				rep.Schema = report.ColumnsFromStruct(schema.MakeDefaultSchema(header))
+				if fw, err = file.NewArrowWriter(written, arrow.NewSchema(fields, nil), stream, flush); err != nil {
+					return errors.Wrap(err, "error open file writer")
+				}
+				if !dialect.NoHeader {
+					continue
+				}
+			}
+			rep.RowsRead++
+			mtr.RowsRead.Inc()
+			if len(rec) != len(header) {
+				if rec, err = file.FitRecord(rec, len(header)); err != nil {
+					return errors.Wrap(err, "row "+strconv.Itoa(rows.Start+n))
+				}
+			}
+			phaseStart := time.Now()
+			for i := range header {
+				fw.Builder.Field(i).(*array.StringBuilder).Append(rec[i])
+			}
+			rep.Timings.Convert.Since(phaseStart)
+			phaseStart = time.Now()
+			err = fw.Added()
+			rep.Timings.Write.Since(phaseStart)
+			if err != nil {
+				return err
+			}
+			rep.RowsWritten++
+			mtr.RowsWritten.Inc()
+		}
+		if pg != nil {
+			pg.Set(rep.RowsRead, rows.Offset)
+		}
+		readStart = time.Now()
+	}
+	if err = <-eCh; err != nil {
+		return errors.Wrap(err, "read error")
+	}
+	if fw == nil {
+		return errors.New("csv file is empty")
+	}
+	phaseStart := time.Now()
+	err = fw.Close()
+	fw = nil
+	rep.Timings.Flush.Since(phaseStart)
+	if err != nil {
+		return err
+	}
+	rep.Output.Size = written.n
+	return errors.Wrap(out.Close(), "error close file "+output)
+}
+
+//nolint:gochecknoinits // need for init command
+func init() {
+	rootCmd.AddCommand(parquet2arrow)
+	parquet2arrow.Flags().IntP("flush", "f", file.FlushCount, "number of rows per record batch")
+	parquet2arrow.Flags().StringP("delimiter", "d", ",", delimiterUsage)
+	addCSVInputFlags(parquet2arrow)
+	parquet2arrow.Flags().Bool("stream", false, "Write the arrow ipc stream format instead of the file format")
+	parquet2arrow.Flags().BoolP("verbose", "v", false, "Show debug information")
+	parquet2arrow.Flags().Bool("progress", false, "Show progress on stderr")
+	parquet2arrow.Flags().StringArray("filter", nil, "Parquet input: keep the rows where column<op>value, op one of = != < <= > >=, repeatable")
+}
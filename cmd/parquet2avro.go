@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/dbunt1tled/parquet2csv/internal/file"
+	"github.com/dbunt1tled/parquet2csv/internal/helper"
+	"github.com/dbunt1tled/parquet2csv/internal/report"
+	"github.com/dbunt1tled/parquet2csv/pkg/convert"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var parquet2avro = &cobra.Command{ //nolint:gochecknoglobals // need for init command
+	Use:   "avro <input> <output>",
+	Short: "Convert parquet to avro",
+	Long:  "Convert file from parquet to an avro object container file",
+	Args:  cobra.RangeArgs(1, 2), //nolint:mnd // args count
+	RunE: withReport(func(cmd *cobra.Command, args []string) error {
+		var (
+			err           error
+			input, output string
+			codec         string
+			flush         int
+			verbose       bool
+			showProgress  bool
+			filters       []convert.Filter
+		)
+		startTime := time.Now()
+		rep := report.FromContext(cmd.Context())
+
+		flush, err = cmd.Flags().GetInt("flush")
+		if err != nil {
+			return errors.Wrap(err, "error read flush")
+		}
+		codec, err = cmd.Flags().GetString("codec")
+		if err != nil {
+			return errors.Wrap(err, "error read codec")
+		}
+		verbose, err = cmd.Flags().GetBool("verbose")
+		if err != nil {
+			return errors.Wrap(err, "error read verbose")
+		}
+		showProgress, err = cmd.Flags().GetBool("progress")
+		if err != nil {
+			return errors.Wrap(err, "error read progress")
+		}
+		if filters, err = rowFilters(cmd); err != nil {
+			return err
+		}
+
+		input, output, err = parquetPaths(args, ".avro")
+		rep.Input.Path, rep.Output.Path = args[0], output
+		if err != nil {
+			return err
+		}
+
+		opts := convert.Options{BatchSize: flush, FlushRows: flush, Filter: filters}
+		ao := convert.AvroOptions{Codec: codec}
+		err = runConvert(cmd, input, output, "", showProgress, opts,
+			func(ctx context.Context, r io.Reader, w io.Writer, opts convert.Options) (convert.Stats, error) {
+				return convert.ParquetToAvro(ctx, r, w, opts, ao)
+			}, nil)
+		if err != nil {
+			return err
+		}
+		if verbose {
+			fmt.Printf("%s\n", helper.RuntimeStatistics(startTime, input)) //nolint:forbidigo // verbose output
+		}
+		return nil
+	}),
+}
+
+//nolint:gochecknoinits // need for init command
+func init() {
+	rootCmd.AddCommand(parquet2avro)
+	parquet2avro.Flags().IntP("flush", "f", file.FlushCount, "number of records per avro block")
+	parquet2avro.Flags().String("codec", "deflate", "Avro block compression: null, deflate or snappy")
+	parquet2avro.Flags().BoolP("verbose", "v", false, "Show debug information")
+	parquet2avro.Flags().Bool("progress", false, "Show progress on stderr")
+	parquet2avro.Flags().StringArray("filter", nil, filterUsage)
+}
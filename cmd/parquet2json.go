@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/dbunt1tled/parquet2csv/internal/file"
+	"github.com/dbunt1tled/parquet2csv/internal/helper"
+	"github.com/dbunt1tled/parquet2csv/internal/report"
+	"github.com/dbunt1tled/parquet2csv/pkg/convert"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var parquet2json = &cobra.Command{ //nolint:gochecknoglobals // need for init command
+	Use:   "json <input> <output>",
+	Short: "Convert parquet to json",
+	Long:  "Convert file from parquet to a json array or newline-delimited json",
+	Args:  cobra.RangeArgs(1, 2), //nolint:mnd // args count
+	RunE: withReport(func(cmd *cobra.Command, args []string) error {
+		var (
+			err            error
+			input, output  string
+			ext            string
+			flush          int
+			verbose        bool
+			showProgress   bool
+			filters        []convert.Filter
+			ndjson, pretty bool
+		)
+		startTime := time.Now()
+		rep := report.FromContext(cmd.Context())
+
+		flush, err = cmd.Flags().GetInt("flush")
+		if err != nil {
+			return errors.Wrap(err, "error read flush")
+		}
+		verbose, err = cmd.Flags().GetBool("verbose")
+		if err != nil {
+			return errors.Wrap(err, "error read verbose")
+		}
+		showProgress, err = cmd.Flags().GetBool("progress")
+		if err != nil {
+			return errors.Wrap(err, "error read progress")
+		}
+		if filters, err = rowFilters(cmd); err != nil {
+			return err
+		}
+		ndjson, err = cmd.Flags().GetBool("ndjson")
+		if err != nil {
+			return errors.Wrap(err, "error read ndjson")
+		}
+		pretty, err = cmd.Flags().GetBool("pretty")
+		if err != nil {
+			return errors.Wrap(err, "error read pretty")
+		}
+		if ndjson && pretty {
+			return errors.New("--pretty can't be used with --ndjson")
+		}
+
+		ext = ".json"
+		if ndjson {
+			ext = ".ndjson"
+		}
+		input, output, err = parquetPaths(args, ext)
+		rep.Input.Path, rep.Output.Path = args[0], output
+		if err != nil {
+			return err
+		}
+
+		opts := convert.Options{BatchSize: flush, FlushRows: flush, Filter: filters}
+		jo := convert.JSONOptions{NDJSON: ndjson, Pretty: pretty}
+		err = runConvert(cmd, input, output, "", showProgress, opts,
+			func(ctx context.Context, r io.Reader, w io.Writer, opts convert.Options) (convert.Stats, error) {
+				return convert.ParquetToJSON(ctx, r, w, opts, jo)
+			}, nil)
+		if err != nil {
+			return err
+		}
+		if verbose {
+			fmt.Printf("%s\n", helper.RuntimeStatistics(startTime, input)) //nolint:forbidigo // verbose output
+		}
+		return nil
+	}),
+}
+
+//nolint:gochecknoinits // need for init command
+func init() {
+	rootCmd.AddCommand(parquet2json)
+	parquet2json.Flags().IntP("flush", "f", file.FlushCount, "number of rows to read at once")
+	parquet2json.Flags().Bool("ndjson", false, "Write newline-delimited json objects instead of an array")
+	parquet2json.Flags().Bool("pretty", false, "Indent the json array")
+	parquet2json.Flags().BoolP("verbose", "v", false, "Show debug information")
+	parquet2json.Flags().Bool("progress", false, "Show progress on stderr")
+	parquet2json.Flags().StringArray("filter", nil, filterUsage)
+}
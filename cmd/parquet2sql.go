@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dbunt1tled/parquet2csv/internal/file"
+	"github.com/dbunt1tled/parquet2csv/internal/helper"
+	"github.com/dbunt1tled/parquet2csv/internal/report"
+	"github.com/dbunt1tled/parquet2csv/pkg/convert"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var parquet2sql = &cobra.Command{ //nolint:gochecknoglobals // need for init command
+	Use:   "sql <input> <output>",
+	Short: "Convert parquet to sql",
+	Long:  "Convert file from parquet to a sql dump with CREATE TABLE and INSERT or COPY statements",
+	Args:  cobra.RangeArgs(1, 2), //nolint:mnd // args count
+	RunE: withReport(func(cmd *cobra.Command, args []string) error {
+		var (
+			err            error
+			input, output  string
+			dialect, table string
+			flush          int
+			copyFrom       bool
+			verbose        bool
+			showProgress   bool
+			filters        []convert.Filter
+		)
+		startTime := time.Now()
+		rep := report.FromContext(cmd.Context())
+
+		flush, err = cmd.Flags().GetInt("flush")
+		if err != nil {
+			return errors.Wrap(err, "error read flush")
+		}
+		dialect, err = cmd.Flags().GetString("dialect")
+		if err != nil {
+			return errors.Wrap(err, "error read dialect")
+		}
+		table, err = cmd.Flags().GetString("table")
+		if err != nil {
+			return errors.Wrap(err, "error read table")
+		}
+		copyFrom, err = cmd.Flags().GetBool("copy")
+		if err != nil {
+			return errors.Wrap(err, "error read copy")
+		}
+		verbose, err = cmd.Flags().GetBool("verbose")
+		if err != nil {
+			return errors.Wrap(err, "error read verbose")
+		}
+		showProgress, err = cmd.Flags().GetBool("progress")
+		if err != nil {
+			return errors.Wrap(err, "error read progress")
+		}
+		if filters, err = rowFilters(cmd); err != nil {
+			return err
+		}
+
+		input, output, err = parquetPaths(args, ".sql")
+		rep.Input.Path, rep.Output.Path = args[0], output
+		if err != nil {
+			return err
+		}
+		if table == "" {
+			table = sqlTableName(output)
+		}
+
+		opts := convert.Options{BatchSize: flush, FlushRows: flush, Filter: filters}
+		so := convert.SQLOptions{Dialect: dialect, Table: table, Copy: copyFrom}
+		err = runConvert(cmd, input, output, "", showProgress, opts,
+			func(ctx context.Context, r io.Reader, w io.Writer, opts convert.Options) (convert.Stats, error) {
+				return convert.ParquetToSQL(ctx, r, w, opts, so)
+			}, nil)
+		if err != nil {
+			return err
+		}
+		if verbose {
+			fmt.Printf("%s\n", helper.RuntimeStatistics(startTime, input)) //nolint:forbidigo // verbose output
+		}
+		return nil
+	}),
+}
+
+// sqlTableName is the output file name without extension with anything but letters,
+// digits and underscores replaced by underscores.
+func sqlTableName(path string) string {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+//nolint:gochecknoinits // need for init command
+func init() {
+	rootCmd.AddCommand(parquet2sql)
+	parquet2sql.Flags().IntP("flush", "f", file.FlushCount, "number of rows per INSERT statement")
+	parquet2sql.Flags().String("dialect", file.SQLPostgres, "SQL dialect: postgres, mysql or sqlite")
+	parquet2sql.Flags().String("table", "", "Table name (default: output file name)")
+	parquet2sql.Flags().Bool("copy", false, "Write rows as a postgres COPY ... FROM stdin block instead of INSERTs")
+	parquet2sql.Flags().BoolP("verbose", "v", false, "Show debug information")
+	parquet2sql.Flags().Bool("progress", false, "Show progress on stderr")
+	parquet2sql.Flags().StringArray("filter", nil, filterUsage)
+}
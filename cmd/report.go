@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/dbunt1tled/parquet2csv/internal/report"
+	"github.com/spf13/cobra"
+)
+
+type runE func(cmd *cobra.Command, args []string) error
+
+// withReport collects a report.Report during the run and writes it to --report / --report-log,
+// also when the command fails.
+func withReport(run runE) runE {
+	return func(cmd *cobra.Command, args []string) error {
+		rep := report.New(cmd.Name(), rootCmd.Version)
+		cmd.SetContext(report.NewContext(cmd.Context(), rep))
+		err := run(cmd, args)
+
+		path, _ := cmd.Flags().GetString("report")
+		logPath, _ := cmd.Flags().GetString("report-log")
+		if path == "" && logPath == "" {
+			return err
+		}
+		rep.Finish(err)
+		if path != "" {
+			if wErr := rep.Write(path); wErr != nil {
+				fmt.Fprintln(os.Stderr, wErr) //nolint:forbidigo // report error must not hide the run result
+			}
+		}
+		if logPath != "" {
+			if wErr := rep.Append(logPath); wErr != nil {
+				fmt.Fprintln(os.Stderr, wErr) //nolint:forbidigo // report error must not hide the run result
+			}
+		}
+		return err
+	}
+}
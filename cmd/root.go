@@ -3,19 +3,89 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
 
+	"github.com/dbunt1tled/parquet2csv/internal/metrics"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 var rootCmd = &cobra.Command{ //nolint:gochecknoglobals // need for init commands
 	Use:   "csv2parquet",
 	Short: "Converter CLI",
 	Long:  "Converter parquet ⇄ csv",
+	PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+		if err := envDefaults(cmd.Flags()); err != nil {
+			return err
+		}
+		addr, err := cmd.Flags().GetString("metrics-addr")
+		if err != nil {
+			return errors.Wrap(err, "error read metrics address")
+		}
+		if addr == "" {
+			return nil
+		}
+		m := metrics.New(cmd.Name())
+		if err = m.Serve(addr); err != nil {
+			return err
+		}
+		cobra.OnFinalize(func() {
+			_ = m.Close()
+		})
+		cmd.SetContext(metrics.NewContext(cmd.Context(), m))
+		return nil
+	},
 }
 
-func Execute() {
+// envPrefix starts the environment variables giving flag defaults, CSV2PARQUET_FLUSH for --flush.
+const envPrefix = "CSV2PARQUET_"
+
+// envDefaults sets the flags not given on the command line from their environment variables.
+// The flags aren't marked changed, a value sniffed from the input still wins over them.
+func envDefaults(flags *pflag.FlagSet) error {
+	var err error
+	flags.VisitAll(func(f *pflag.Flag) {
+		if err != nil || f.Changed || f.Name == "help" || f.Name == "version" {
+			return
+		}
+		name := envPrefix + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return
+		}
+		if list, isList := f.Value.(pflag.SliceValue); isList {
+			err = list.Replace([]string{value})
+		} else {
+			err = f.Value.Set(value)
+		}
+		err = errors.Wrap(err, "invalid "+name)
+	})
+	return err
+}
+
+// exitError makes Execute exit with code instead of 1.
+type exitError struct {
+	error
+	code int
+}
+
+func Execute(version, commit, date string) {
+	rootCmd.Version = version
+	rootCmd.SetVersionTemplate("{{.Version}} (" + commit + ", " + date + ")\n")
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err) //nolint:forbidigo // print error
+		var exit exitError
+		if errors.As(err, &exit) {
+			os.Exit(exit.code)
+		}
 		os.Exit(1)
 	}
 }
+
+//nolint:gochecknoinits // need for init command
+func init() {
+	rootCmd.PersistentFlags().String("report", "", "Write a JSON run report to the file")
+	rootCmd.PersistentFlags().String("metrics-addr", "", "Serve Prometheus /metrics and /debug/pprof on the address, e.g. :9090")
+	rootCmd.PersistentFlags().String("report-log", "", "Append the run report as a NDJSON line to the file")
+}
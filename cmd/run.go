@@ -0,0 +1,224 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dbunt1tled/parquet2csv/internal/file"
+	"github.com/dbunt1tled/parquet2csv/internal/job"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var runCmd = &cobra.Command{ //nolint:gochecknoglobals // need for init command
+	Use:   "run <job file>",
+	Short: "Run the conversions of a yaml or toml job file",
+	Long: "Run the jobs of a .yaml, .yml or .toml file in order, stopping at the first failure. A job has a name, " +
+		"an input, an optional output and command, and the flags of the command as options; ${VAR} and ${VAR:-default} " +
+		"are replaced from the environment. Flags given to run override the options of every job, " +
+		"CSV2PARQUET_* environment variables like CSV2PARQUET_COMPRESSION give the defaults. " +
+		"With several jobs --report r.json writes a report per job as r.<job>.json",
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		names, err := cmd.Flags().GetStringArray("job")
+		if err != nil {
+			return errors.Wrap(err, "error read job")
+		}
+		jobs, err := job.Load(args[0], os.LookupEnv)
+		if err != nil {
+			return err
+		}
+		if jobs, err = job.Select(jobs, names); err != nil {
+			return err
+		}
+		reportPath, err := cmd.Flags().GetString("report")
+		if err != nil {
+			return errors.Wrap(err, "error read report")
+		}
+		for _, j := range jobs {
+			start := time.Now()
+			report := ""
+			if reportPath != "" && len(jobs) > 1 {
+				report = jobReport(reportPath, j.Name)
+			}
+			if err = runJob(cmd, j, report); err != nil {
+				return errors.Wrap(err, "job "+j.Name)
+			}
+			fmt.Fprintf(os.Stderr, "job %s: done in %s\n", j.Name, time.Since(start).Round(time.Millisecond)) //nolint:forbidigo // job log
+		}
+		return nil
+	},
+}
+
+// runJob runs the command of a job with the job options as flags, the flags changed on run on top.
+// A report path other than "" replaces the --report given to run.
+func runJob(cmd *cobra.Command, j job.Job, report string) error {
+	name := jobCommand(j)
+	commands := map[string]*cobra.Command{}
+	for _, c := range []*cobra.Command{csv2parquet, parquet2csv, parquet2json, parquet2arrow, parquet2avro, parquet2sql} {
+		commands[c.Name()] = c
+	}
+	sub, ok := commands[name]
+	switch {
+	case name == "":
+		return errors.New("can't tell the command from output " + j.Output + ", set command")
+	case !ok:
+		return errors.New("unknown command " + name + ", use parquet, csv, json, arrow, avro or sql")
+	}
+	flags := sub.Flags()
+	// merges the persistent flags like --report into flags, they are shared with run
+	sub.InheritedFlags()
+	saved := saveFlags(flags)
+	defer restoreFlags(flags, saved)
+
+	if err := envDefaults(flags); err != nil {
+		return err
+	}
+	for key, value := range j.Options {
+		f := flags.Lookup(key)
+		if f == nil {
+			return errors.New("unknown option " + key + " of the " + name + " command")
+		}
+		if f.Changed {
+			// a persistent flag given to run
+			continue
+		}
+		if err := setFlag(flags, f, value); err != nil {
+			return err
+		}
+	}
+	var err error
+	cmd.Flags().Visit(func(given *pflag.Flag) {
+		if f := flags.Lookup(given.Name); err == nil && f != nil && f != given {
+			err = setFlag(flags, f, flagValue(given))
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if report != "" {
+		if err = flags.Set("report", report); err != nil {
+			return errors.Wrap(err, "invalid option report")
+		}
+	}
+
+	args := []string{j.Input}
+	if j.Output != "" {
+		args = append(args, j.Output)
+	}
+	sub.SetContext(cmd.Context())
+	return sub.RunE(sub, args)
+}
+
+// jobReport is the report of a job when --report is given to run for several jobs:
+// r.json becomes r.<job>.json, so the jobs don't overwrite each other's report.
+func jobReport(path, name string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + name + ext
+}
+
+// jobCommand is the command of a job, told by the output extension, or by the input one without an output.
+func jobCommand(j job.Job) string {
+	if j.Command != "" {
+		return j.Command
+	}
+	switch inputExt(j.Output) {
+	case ".parquet":
+		return csv2parquet.Name()
+	case ".csv":
+		return parquet2csv.Name()
+	case ".json", ".ndjson", ".jsonl":
+		return parquet2json.Name()
+	case ".arrow", ".arrows", ".feather", ".ipc":
+		return parquet2arrow.Name()
+	case ".avro":
+		return parquet2avro.Name()
+	case ".sql":
+		return parquet2sql.Name()
+	case "":
+		if j.Output != "" {
+			return ""
+		}
+		if inputExt(j.Input) == ".parquet" {
+			return parquet2csv.Name()
+		}
+		return csv2parquet.Name()
+	}
+	return ""
+}
+
+// flagValue is the value of a flag for setFlag, a []string for list flags.
+func flagValue(f *pflag.Flag) any {
+	if list, ok := f.Value.(pflag.SliceValue); ok {
+		return list.GetSlice()
+	}
+	return f.Value.String()
+}
+
+// setFlag sets a flag to a job option value and marks it changed, as if it was given on the command line.
+func setFlag(flags *pflag.FlagSet, f *pflag.Flag, value any) error {
+	list, isList := f.Value.(pflag.SliceValue)
+	switch v := value.(type) {
+	case []string:
+		if !isList {
+			return errors.New("option " + f.Name + " takes a single value")
+		}
+		if err := list.Replace(v); err != nil {
+			return errors.Wrap(err, "invalid option "+f.Name)
+		}
+		f.Changed = true
+	case string:
+		if isList {
+			return setFlag(flags, f, []string{v})
+		}
+		return errors.Wrap(flags.Set(f.Name, v), "invalid option "+f.Name)
+	}
+	return nil
+}
+
+type flagState struct {
+	value   any
+	changed bool
+}
+
+// saveFlags keeps the values of flags for restoreFlags, the next job starts from them.
+func saveFlags(flags *pflag.FlagSet) map[string]flagState {
+	saved := map[string]flagState{}
+	flags.VisitAll(func(f *pflag.Flag) {
+		saved[f.Name] = flagState{value: flagValue(f), changed: f.Changed}
+	})
+	return saved
+}
+
+func restoreFlags(flags *pflag.FlagSet, saved map[string]flagState) {
+	flags.VisitAll(func(f *pflag.Flag) {
+		s := saved[f.Name]
+		if list, ok := s.value.([]string); ok {
+			_ = f.Value.(pflag.SliceValue).Replace(list)
+		} else {
+			_ = f.Value.Set(s.value.(string))
+		}
+		f.Changed = s.changed
+	})
+}
+
+//nolint:gochecknoinits // need for init command
+func init() {
+	rootCmd.AddCommand(runCmd)
+	runCmd.Flags().StringArray("job", nil, "Run only the job of this name, repeatable")
+	runCmd.Flags().IntP("compression", "c", 0, "Type of compression")
+	runCmd.Flags().IntP("flush", "f", file.FlushCount, "number of rows to flush")
+	runCmd.Flags().StringP("delimiter", "d", ",", delimiterUsage)
+	addCSVInputFlags(runCmd)
+	addCSVOutputStyleFlags(runCmd)
+	addHTTPInputFlags(runCmd)
+	runCmd.Flags().StringArray("schema", nil, "Csv input: column type as name=type, repeatable")
+	runCmd.Flags().String("bad-rows", "", "File to write rejected input lines to")
+	runCmd.Flags().Bool("verify", false, "Read the outputs of csv and parquet jobs back and fail unless they hold the rows written, local ones are deleted then")
+	runCmd.Flags().BoolP("verbose", "v", false, "Show debug information")
+	runCmd.Flags().Bool("progress", false, "Show progress on stderr")
+}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+
+	"github.com/dbunt1tled/parquet2csv/internal/file"
+	"github.com/dbunt1tled/parquet2csv/internal/metrics"
+	"github.com/dbunt1tled/parquet2csv/internal/server"
+	"github.com/dbunt1tled/parquet2csv/pkg/convert"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var serve = &cobra.Command{ //nolint:gochecknoglobals // need for init command
+	Use:   "serve",
+	Short: "Serve conversions over HTTP",
+	Long: "Convert request bodies with POST /convert?to=parquet (csv input) and POST /convert?to=csv (parquet input), " +
+		"the options of the parquet and csv commands are query parameters like ?to=parquet&delimiter=auto&compression=1",
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		addr, err := cmd.Flags().GetString("addr")
+		if err != nil {
+			return errors.Wrap(err, "error read addr")
+		}
+		maxBody, err := cmd.Flags().GetInt64("max-body")
+		if err != nil {
+			return errors.Wrap(err, "error read max body")
+		}
+		concurrency, err := cmd.Flags().GetInt("concurrency")
+		if err != nil {
+			return errors.Wrap(err, "error read concurrency")
+		}
+		shutdownTimeout, err := cmd.Flags().GetDuration("shutdown-timeout")
+		if err != nil {
+			return errors.Wrap(err, "error read shutdown timeout")
+		}
+		if maxBody < 1 || concurrency < 1 {
+			return errors.New("--max-body and --concurrency must be positive")
+		}
+
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			return errors.Wrap(err, "error listen on "+addr)
+		}
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		fmt.Fprintf(os.Stderr, "serving on %s\n", listener.Addr()) //nolint:forbidigo // server log
+		return server.New(server.Config{
+			MaxBody:     maxBody,
+			Concurrency: concurrency,
+			Options:     requestOptions,
+			Metrics:     metrics.FromContext(cmd.Context()),
+			Log:         os.Stderr,
+		}).Serve(ctx, listener, shutdownTimeout)
+	},
+}
+
+// requestOptions reads the options of a serve request converting to parquet or csv: the conversion
+// flags of the parquet or csv command as query parameters, and bad-rows to skip the rows that don't fit.
+func requestOptions(to string, query url.Values, body *bufio.Reader) (convert.Options, error) {
+	var opts convert.Options
+	cmd := &cobra.Command{Use: to}
+	if to == "parquet" {
+		addToParquetFlags(cmd)
+		cmd.Flags().Bool("bad-rows", false, "")
+	} else {
+		addToCSVFlags(cmd)
+	}
+	for name, values := range query {
+		flag := cmd.Flags().Lookup(name)
+		if flag == nil {
+			return opts, errors.New("unknown option " + name)
+		}
+		for _, value := range values {
+			if value == "" && flag.Value.Type() == "bool" {
+				// ?bad-rows is ?bad-rows=true
+				value = flag.NoOptDefVal
+			}
+			if err := cmd.Flags().Set(name, value); err != nil {
+				return opts, errors.Wrap(err, "invalid option "+name)
+			}
+		}
+	}
+
+	delimiter, err := csvDelimiter(cmd)
+	if err != nil {
+		return opts, err
+	}
+	if to == "csv" {
+		dialect, err := csvOutputDialect(cmd, delimiter)
+		if err != nil {
+			return opts, err
+		}
+		return toCSVOptions(cmd, delimiter, dialect)
+	}
+
+	delimiter, dialect, err := csvReaderDialect(cmd, delimiter, func() (*file.Sniff, error) {
+		head, err := body.Peek(file.SniffSize + 1)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, errors.Wrap(err, "error read body")
+		}
+		return file.SniffReader(bytes.NewReader(head), file.SniffSize)
+	}, "")
+	if err != nil {
+		return opts, err
+	}
+	if opts, err = toParquetOptions(cmd, delimiter, dialect); err != nil {
+		return opts, err
+	}
+	skip, err := cmd.Flags().GetBool("bad-rows")
+	if err != nil {
+		return opts, errors.Wrap(err, "error read bad rows")
+	}
+	if skip {
+		opts.Hooks.BadRow = func(convert.BadRow) error {
+			return nil
+		}
+	}
+	return opts, nil
+}
+
+//nolint:gochecknoinits // need for init command
+func init() {
+	rootCmd.AddCommand(serve)
+	serve.Flags().String("addr", ":8080", "Address to listen on")
+	serve.Flags().Int64("max-body", server.MaxBody, "Request body limit in bytes")
+	serve.Flags().Int("concurrency", runtime.NumCPU(), "Conversions run at once, more requests get 503")
+	serve.Flags().Duration("shutdown-timeout", server.ShutdownTimeout, "Wait for running conversions on SIGINT or SIGTERM")
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/bytedance/sonic"
+	"github.com/dbunt1tled/parquet2csv/internal/file"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var sniff = &cobra.Command{ //nolint:gochecknoglobals // need for init command
+	Use:   "sniff <input>",
+	Short: "Guess the csv dialect of a file",
+	Long: "Guess the delimiter, quote, escape, header, line ending and encoding of a csv file from its first bytes, " +
+		"the dialect --delimiter auto uses",
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		size, err := cmd.Flags().GetInt("size")
+		if err != nil {
+			return errors.Wrap(err, "error read size")
+		}
+		if size < 1 {
+			return errors.New("--size must be positive")
+		}
+		asJSON, err := cmd.Flags().GetBool("json")
+		if err != nil {
+			return errors.Wrap(err, "error read json")
+		}
+		if !isRemote(args[0]) {
+			if _, err = file.IsExist(args[0]); err != nil {
+				return errors.Wrap(err, "input file "+args[0]+" not exist")
+			}
+		}
+		s, err := sniffInput(cmd, args[0], size)
+		if err != nil {
+			return err
+		}
+
+		out := cmd.OutOrStdout()
+		if asJSON {
+			data, err := sonic.ConfigStd.MarshalIndent(s, "", "  ")
+			if err != nil {
+				return errors.Wrap(err, "error marshal dialect")
+			}
+			_, err = fmt.Fprintln(out, string(data))
+			return err
+		}
+		_, err = fmt.Fprintf(out,
+			"delimiter:    %q\nquote:        %q\nescape:       %s\nheader:       %t\nline ending:  %q\nencoding:     %s\n"+
+				"columns:      %d\nrows sampled: %d\nconfidence:   %.2f\n",
+			s.Delimiter, s.Quote, s.Escape, s.Header, s.LineEnding, s.Encoding, s.Columns, s.Rows, s.Confidence)
+		return err
+	},
+}
+
+//nolint:gochecknoinits // need for init command
+func init() {
+	rootCmd.AddCommand(sniff)
+	sniff.Flags().Int("size", file.SniffSize, "Number of bytes from the start of the file to look at")
+	sniff.Flags().Bool("json", false, "Print the dialect as JSON")
+	addHTTPInputFlags(sniff)
+}
@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/dbunt1tled/parquet2csv/internal/storage"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const errRemoteUnsupported = "s3:// and http(s):// paths are supported by the parquet and csv commands " +
+	"for csv and parquet files"
+
+// addHTTPInputFlags adds the request options of http(s) inputs.
+func addHTTPInputFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArray("header", nil, `HTTP input: request header like "Authorization: Bearer ...", repeatable`)
+	cmd.Flags().Duration("timeout", storage.HTTPTimeout, "HTTP input: time to wait for the response to a request")
+	cmd.Flags().Int("retries", storage.HTTPRetries, "HTTP input: retries of failed requests and dropped connections")
+}
+
+// httpOptions reads the flags added by addHTTPInputFlags.
+func httpOptions(cmd *cobra.Command) (storage.HTTPOptions, error) {
+	var opts storage.HTTPOptions
+	headers, err := cmd.Flags().GetStringArray("header")
+	if err != nil {
+		return opts, errors.Wrap(err, "error read header")
+	}
+	if opts.Header, err = storage.ParseHeaders(headers); err != nil {
+		return opts, err
+	}
+	if opts.Timeout, err = cmd.Flags().GetDuration("timeout"); err != nil {
+		return opts, errors.Wrap(err, "error read timeout")
+	}
+	if opts.Retries, err = cmd.Flags().GetInt("retries"); err != nil {
+		return opts, errors.Wrap(err, "error read retries")
+	}
+	if opts.Timeout < 0 || opts.Retries < 0 {
+		return opts, errors.New("--timeout and --retries can't be negative")
+	}
+	return opts, nil
+}
+
+// isRemote tells if path is an s3:// or http(s):// url.
+func isRemote(path string) bool {
+	return storage.IsS3(path) || storage.IsHTTP(path)
+}
+
+// inputSize is the size of a local input file, the --progress total.
+func inputSize(input string) (int64, error) {
+	info, err := os.Stat(input)
+	if err != nil {
+		return 0, errors.Wrap(err, "error stat file "+input)
+	}
+	return info.Size(), nil
+}
+
+// inputExt is the extension of a path, of the path of an http(s) url without its query.
+func inputExt(input string) string {
+	if u, err := url.Parse(input); err == nil && storage.IsHTTP(input) {
+		return path.Ext(u.Path)
+	}
+	return filepath.Ext(input)
+}
+
+// defaultOutput is the input with the extension ext, a file in the current directory for http(s) urls.
+func defaultOutput(input, ext string) string {
+	if u, err := url.Parse(input); err == nil && storage.IsHTTP(input) {
+		name := path.Base(u.Path)
+		if name == "/" || name == "." {
+			name = u.Hostname()
+		}
+		return strings.TrimSuffix(name, path.Ext(name)) + ext
+	}
+	return strings.TrimSuffix(input, filepath.Ext(input)) + ext
+}
+
+// outputFile is an output that can be dropped on failure.
+type outputFile interface {
+	io.WriteCloser
+	// Abort gives up the output if it wasn't closed, an s3 upload leaves no object, a local file is removed.
+	Abort()
+}
+
+// localFile is a local output, removed by Abort unless it was closed.
+type localFile struct {
+	*os.File
+	closed bool
+}
+
+// Close closes the file, a failed close leaves it to Abort.
+func (f *localFile) Close() error {
+	if f.closed {
+		return nil
+	}
+	err := f.File.Close()
+	f.closed = err == nil
+	return err
+}
+
+func (f *localFile) Abort() {
+	if f.closed {
+		return
+	}
+	f.closed = true
+	_ = f.File.Close()
+	_ = os.Remove(f.Name())
+}
+
+// openInput opens a local file, an s3:// object or an http(s):// url and returns its size.
+func openInput(cmd *cobra.Command, path string) (io.ReadCloser, int64, error) {
+	if storage.IsHTTP(path) {
+		opts, err := httpOptions(cmd)
+		if err != nil {
+			return nil, 0, err
+		}
+		return storage.OpenHTTP(cmd.Context(), path, opts)
+	}
+	if !storage.IsS3(path) {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, 0, errors.Wrap(err, "error opening file "+path)
+		}
+		info, err := f.Stat()
+		if err != nil {
+			_ = f.Close()
+			return nil, 0, errors.Wrap(err, "error stat file "+path)
+		}
+		return f, info.Size(), nil
+	}
+	client, err := storage.NewS3Client(cmd.Context())
+	if err != nil {
+		return nil, 0, err
+	}
+	return storage.OpenS3(cmd.Context(), client, path)
+}
+
+// createOutput creates a local file or starts an upload to an s3:// object.
+func createOutput(ctx context.Context, path string) (outputFile, error) {
+	if !storage.IsS3(path) {
+		f, err := os.Create(path)
+		if err != nil {
+			return nil, errors.Wrap(err, "error create file "+path)
+		}
+		return &localFile{File: f}, nil
+	}
+	client, err := storage.NewS3Client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return storage.CreateS3(ctx, client, path)
+}
@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/dbunt1tled/parquet2csv/internal/file"
+	"github.com/dbunt1tled/parquet2csv/internal/helper"
+	"github.com/dbunt1tled/parquet2csv/internal/validate"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var validateCmd = &cobra.Command{ //nolint:gochecknoglobals // need for init command
+	Use:   "validate <input>",
+	Short: "Check a csv file against a schema",
+	Long: "Check the header and rows of a csv file against the column types and constraints of a yaml or toml " +
+		"schema file without writing output: types, required values, uniqueness, allowed values, min and max, " +
+		"patterns and lengths. Every violation is printed with its line and column, the run fails when there is one",
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		startTime := time.Now()
+		schemaPath, err := cmd.Flags().GetString("schema")
+		if err != nil {
+			return errors.Wrap(err, "error read schema")
+		}
+		if schemaPath == "" {
+			return errors.New("--schema is required")
+		}
+		limit, err := cmd.Flags().GetInt("max-violations")
+		if err != nil {
+			return errors.Wrap(err, "error read max violations")
+		}
+		verbose, err := cmd.Flags().GetBool("verbose")
+		if err != nil {
+			return errors.Wrap(err, "error read verbose")
+		}
+		delimiter, err := csvDelimiter(cmd)
+		if err != nil {
+			return err
+		}
+		input := args[0]
+		if !isRemote(input) {
+			if _, err = file.IsExist(input); err != nil {
+				return errors.Wrap(err, "input file "+input+" not exist")
+			}
+		}
+		s, err := validate.Load(schemaPath)
+		if err != nil {
+			return err
+		}
+		v, err := validate.New(s, limit)
+		if err != nil {
+			return err
+		}
+		delimiter, dialect, err := csvInputDialect(cmd, input, delimiter)
+		if err != nil {
+			return err
+		}
+		if !cmd.Flags().Changed("fields-per-record") && dialect.FieldsPerRecord == 0 {
+			// rows with another number of fields than the header are violations, not read errors
+			dialect.FieldsPerRecord = -1
+		}
+		if err = validateCSV(cmd, v, input, delimiter, dialect); err != nil {
+			return err
+		}
+
+		r := v.Result()
+		out := cmd.OutOrStdout()
+		for _, violation := range r.Violations {
+			fmt.Fprintln(out, violation)
+		}
+		if verbose {
+			// timing of valid and invalid files alike
+			fmt.Fprintln(out, helper.RuntimeStatistics(startTime, input))
+		}
+		if r.Total() == 0 {
+			_, err = fmt.Fprintf(out, "%s: %d rows, valid\n", input, r.Rows)
+			return err
+		}
+		for _, c := range r.Counts {
+			if limit <= 0 || c.Count <= limit {
+				continue
+			}
+			if c.Column == "" {
+				fmt.Fprintf(out, "%s: %d more violations\n", c.Rule, c.Count-limit)
+			} else {
+				fmt.Fprintf(out, "column %s: %s: %d more violations\n", c.Column, c.Rule, c.Count-limit)
+			}
+		}
+		// the violations are the output, not a usage error
+		cmd.SilenceUsage = true
+		return errors.Errorf("%s: %d rows, %d violations", input, r.Rows, r.Total())
+	},
+}
+
+// validateCSV feeds the header and rows of the csv input to the validator batch by batch.
+func validateCSV(cmd *cobra.Command, v *validate.Validator, input, delimiter string, dialect file.CSVDialect) error {
+	bp := file.NewBatchProcessor(input, file.FlushCount, delimiter, false).
+		Dialect(dialect).
+		Context(cmd.Context())
+	if isRemote(input) {
+		r, _, err := openInput(cmd, input)
+		if err != nil {
+			return err
+		}
+		defer func(r io.ReadCloser) {
+			_ = r.Close()
+		}(r)
+		bp.Source(r)
+	}
+	bCh, eCh := bp.Reader()
+	header := false
+	for batch := range bCh {
+		for n, rec := range batch.Rows {
+			if !header {
+				header = true
+				if dialect.NoHeader {
+					v.Header(0, file.ColumnNames(len(rec)))
+				} else {
+					v.Header(batch.Lines[n], rec)
+					continue
+				}
+			}
+			v.Row(batch.Lines[n], rec)
+		}
+	}
+	if err := <-eCh; err != nil {
+		return errors.Wrap(err, "read error")
+	}
+	if !header {
+		return errors.New("csv file is empty")
+	}
+	return nil
+}
+
+//nolint:gochecknoinits // need for init command
+func init() {
+	rootCmd.AddCommand(validateCmd)
+	validateCmd.Flags().String("schema", "", "Yaml or toml file with the columns and their constraints")
+	validateCmd.Flags().Int("max-violations", validate.MaxViolations,
+		"Violations printed per column and rule, 0 prints all")
+	validateCmd.Flags().StringP("delimiter", "d", ",", delimiterUsage)
+	addCSVInputFlags(validateCmd)
+	addHTTPInputFlags(validateCmd)
+	validateCmd.Flags().BoolP("verbose", "v", false, "Show debug information")
+}
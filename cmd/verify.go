@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/dbunt1tled/parquet2csv/internal/verify"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// exitCorrupt is the exit code of verify for a corrupt file, 1 is left to other errors.
+const exitCorrupt = 2
+
+var verifyCmd = &cobra.Command{ //nolint:gochecknoglobals // need for init command
+	Use:   "verify <input>",
+	Short: "Check the integrity of a parquet file",
+	Long: "Check the magic bytes and footer of a parquet file, decode every page of every column chunk " +
+		"checking page crcs when present, compare row counts and column statistics with the data. " +
+		"Every corrupt row group and column is printed and the exit code is 2",
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		input := args[0]
+		if isRemote(input) {
+			return errors.New("verify reads local files only, download " + input + " first")
+		}
+		f, err := os.Open(input)
+		if err != nil {
+			return errors.Wrap(err, "error opening file "+input)
+		}
+		defer func(f *os.File) {
+			_ = f.Close()
+		}(f)
+		info, err := f.Stat()
+		if err != nil {
+			return errors.Wrap(err, "error stat file "+input)
+		}
+
+		r, err := verify.File(f, info.Size())
+		if err != nil {
+			return err
+		}
+		out := cmd.OutOrStdout()
+		for _, problem := range r.Problems {
+			fmt.Fprintln(out, problem)
+		}
+		if !r.OK() {
+			// the problems are the output, not a usage error
+			cmd.SilenceUsage = true
+			return exitError{
+				error: errors.Errorf("%s: corrupt, %d problems", input, len(r.Problems)),
+				code:  exitCorrupt,
+			}
+		}
+		_, err = fmt.Fprintf(out, "%s: ok, %d row groups, %d columns, %d rows, %d pages\n",
+			input, r.RowGroups, r.Columns, r.Rows, r.Pages)
+		return err
+	},
+}
+
+//nolint:gochecknoinits // need for init command
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+}
@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/dbunt1tled/parquet2csv/internal/file"
+	"github.com/dbunt1tled/parquet2csv/internal/report"
+	"github.com/dbunt1tled/parquet2csv/internal/watch"
+	"github.com/dbunt1tled/parquet2csv/pkg/convert"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var watchCmd = &cobra.Command{ //nolint:gochecknoglobals // need for init command
+	Use:   "watch",
+	Short: "Convert the csv and parquet files dropped into a directory",
+	Long: "Watch --in for .csv and .parquet files, convert each to parquet or csv in --out once it is stable " +
+		"(unchanged for --settle or with a <name>.done marker), then move it to --archive or --failed " +
+		"with a <name>.report.json run report beside it",
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		cfg, err := watchConfig(cmd)
+		if err != nil {
+			return err
+		}
+		once, err := cmd.Flags().GetBool("once")
+		if err != nil {
+			return errors.Wrap(err, "error read once")
+		}
+		delimiter, err := csvDelimiter(cmd)
+		if err != nil {
+			return err
+		}
+		compression, err := cmd.Flags().GetInt("compression")
+		if err != nil {
+			return errors.Wrap(err, "error read compression")
+		}
+		flush, err := cmd.Flags().GetInt("flush")
+		if err != nil {
+			return errors.Wrap(err, "error read flush")
+		}
+		outDelimiter := delimiter
+		if delimiter == file.DelimiterAuto {
+			outDelimiter = ","
+		}
+		outDialect, err := csvOutputDialect(cmd, outDelimiter)
+		if err != nil {
+			return err
+		}
+
+		var rep *report.Report
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		base := cmd.Context()
+		cfg.Convert = func(ctx context.Context, input, output string) error {
+			rep = report.New(cmd.Name(), rootCmd.Version)
+			cmd.SetContext(report.NewContext(ctx, rep))
+			defer cmd.SetContext(base)
+			if strings.EqualFold(filepath.Ext(input), ".parquet") {
+				return runConvert(cmd, input, output, "", false, convert.Options{
+					Delimiter: outDelimiter,
+					Dialect:   convertDialect(outDialect),
+					BatchSize: flush,
+					FlushRows: flush,
+				}, convert.ParquetToCSV, nil)
+			}
+			inDelimiter, dialect, err := csvInputDialect(cmd, input, delimiter)
+			if err != nil {
+				return err
+			}
+			return runConvert(cmd, input, output, "", false, convert.Options{
+				Delimiter:   inDelimiter,
+				Dialect:     convertDialect(dialect),
+				Compression: convert.Compression(compression),
+				FlushRows:   flush,
+			}, convert.CSVToParquet, nil)
+		}
+		cfg.Done = func(res watch.Result) {
+			writeWatchReport(cmd, rep, res)
+		}
+
+		w := watch.New(cfg)
+		if once {
+			return w.Once(ctx)
+		}
+		fmt.Fprintf(os.Stderr, "watching %s\n", cfg.In) //nolint:forbidigo // daemon log
+		return w.Run(ctx)
+	},
+}
+
+// watchConfig reads the directory and timing flags of watch.
+func watchConfig(cmd *cobra.Command) (watch.Config, error) {
+	cfg := watch.Config{Log: os.Stderr}
+	dirs := []struct {
+		flag string
+		dir  *string
+	}{{"in", &cfg.In}, {"out", &cfg.Out}, {"archive", &cfg.Archive}, {"failed", &cfg.Failed}}
+	for i, d := range dirs {
+		dir, err := cmd.Flags().GetString(d.flag)
+		if err != nil {
+			return cfg, errors.Wrap(err, "error read "+d.flag)
+		}
+		if dir == "" {
+			return cfg, errors.New("--" + d.flag + " is required")
+		}
+		*d.dir = filepath.Clean(dir)
+		if i > 0 && *d.dir == cfg.In {
+			return cfg, errors.New("--" + d.flag + " must be another directory than --in")
+		}
+	}
+	var err error
+	if cfg.Settle, err = cmd.Flags().GetDuration("settle"); err != nil {
+		return cfg, errors.Wrap(err, "error read settle")
+	}
+	if cfg.Interval, err = cmd.Flags().GetDuration("interval"); err != nil {
+		return cfg, errors.Wrap(err, "error read interval")
+	}
+	if cfg.Poll, err = cmd.Flags().GetBool("poll"); err != nil {
+		return cfg, errors.Wrap(err, "error read poll")
+	}
+	if cfg.Settle < 0 || cfg.Interval <= 0 {
+		return cfg, errors.New("--settle can't be negative and --interval must be positive")
+	}
+	return cfg, nil
+}
+
+// writeWatchReport writes the report of a processed file beside the original, and appends it to --report-log.
+func writeWatchReport(cmd *cobra.Command, rep *report.Report, res watch.Result) {
+	if rep == nil {
+		rep = report.New(cmd.Name(), rootCmd.Version)
+	}
+	rep.Input.Path = res.Original
+	if rep.Input.Path == "" {
+		rep.Input.Path = res.Name
+	}
+	rep.Output.Path = res.Output
+	rep.Finish(res.Err)
+	if res.Original != "" {
+		if err := rep.Write(res.Original + ".report.json"); err != nil {
+			fmt.Fprintln(os.Stderr, err) //nolint:forbidigo // report error must not stop the watch
+		}
+	}
+	if logPath, _ := cmd.Flags().GetString("report-log"); logPath != "" {
+		if err := rep.Append(logPath); err != nil {
+			fmt.Fprintln(os.Stderr, err) //nolint:forbidigo // report error must not stop the watch
+		}
+	}
+}
+
+//nolint:gochecknoinits // need for init command
+func init() {
+	rootCmd.AddCommand(watchCmd)
+	watchCmd.Flags().String("in", "", "Directory partners drop files into")
+	watchCmd.Flags().String("out", "", "Directory to write the converted files to")
+	watchCmd.Flags().String("archive", "", "Directory to move converted originals and their reports to")
+	watchCmd.Flags().String("failed", "", "Directory to move originals that failed to convert and their reports to")
+	watchCmd.Flags().Duration("settle", watch.Settle, "Time a file must stay unchanged before it is converted")
+	watchCmd.Flags().Duration("interval", watch.Interval, "Time between scans of --in")
+	watchCmd.Flags().Bool("poll", false, "Only scan every --interval, for file systems without change notifications like NFS")
+	watchCmd.Flags().Bool("once", false, "Convert the files in --in and exit once none is left")
+	watchCmd.Flags().IntP("compression", "c", 0, "Type of compression")
+	watchCmd.Flags().IntP("flush", "f", file.FlushCount, "number of rows to flush")
+	watchCmd.Flags().StringP("delimiter", "d", ",", delimiterUsage)
+	addCSVInputFlags(watchCmd)
+	addCSVOutputStyleFlags(watchCmd)
+}
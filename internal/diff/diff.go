@@ -0,0 +1,548 @@
+// Package diff compares two tables, csv or parquet, by schema, row count and cell values.
+//
+// Rows are matched by key columns or, without a key, by their values in any order. Both sides are
+// spilled to bucket files on disk by the hash of the key or the row, then compared bucket by
+// bucket. A bucket over Options.BucketSize is split again before it's read, so memory holds
+// about one bucket of that size at a time, more only when many rows share a key.
+package diff
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/gob"
+	"hash/maphash"
+	"io"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dbunt1tled/parquet2csv/internal/schema"
+	"github.com/pkg/errors"
+)
+
+// Buckets is the default number of bucket files per side.
+const Buckets = 64
+
+// BucketSize is the default size in bytes of the bucket files of both sides read at once.
+const BucketSize = 64 << 20
+
+// maxSplits bounds the splitting of buckets whose rows share their hash.
+const maxSplits = 4
+
+// MaxRows is the default number of differing rows kept.
+const MaxRows = 10
+
+// Kind is how the values of a column are normalised before they are compared.
+type Kind int
+
+const (
+	// Text values are compared as they are.
+	Text Kind = iota
+	// Number values are compared by value: 1.50, 1.5 and 15e-1 are equal.
+	Number
+	// Bool values are compared by value: true, TRUE, t, 1, Y and YES are equal.
+	Bool
+	// Time values are compared by instant: dates, YYYYMMDD too, and timestamps of any layout and zone.
+	Time
+)
+
+// Kinds of a RowDiff.
+const (
+	OnlyA   = "only_a"
+	OnlyB   = "only_b"
+	Changed = "changed"
+)
+
+// Column is a column of a Table. Type describes a typed column, parquet INT64 or DATE, and is empty
+// for untyped text like csv, whose values take the Kind of the other side.
+type Column struct {
+	Name string
+	Type string
+	Kind Kind
+}
+
+// Table is one side of a comparison. Read calls fn with the values of every row in the order of
+// Columns and the line or row number it was read from.
+type Table struct {
+	Name    string
+	Columns []Column
+	Read    func(fn func(line int64, values []string) error) error
+}
+
+// Options of Compare.
+type Options struct {
+	// Key are the columns matching rows, rows are matched by all their values without them.
+	Key []string
+	// Tolerance is the absolute difference under which numbers are equal, with Key only. Values of
+	// columns untyped on both sides are compared with it when both parse as numbers.
+	Tolerance float64
+	// MaxRows is the number of differing rows kept, 0 keeps all.
+	MaxRows int
+	// Buckets is the number of bucket files per side, Buckets when 0.
+	Buckets int
+	// BucketSize is the size of a bucket of both sides over which it's split into Buckets more,
+	// BucketSize when 0.
+	BucketSize int64
+	// TempDir holds the bucket files, the default temporary directory when empty.
+	TempDir string
+}
+
+// Cell is a value that changed.
+type Cell struct {
+	Column string
+	A, B   string
+}
+
+// RowDiff is a row only in one of the tables or, with a key, a row whose values changed.
+// LineA and LineB are the lines or row numbers of the row in each table, 0 when it isn't there.
+type RowDiff struct {
+	Kind         string
+	LineA, LineB int64
+	// Key describes the key of the row, id=5.
+	Key string
+	// Values are the row of the table it's only in.
+	Values []string
+	Cells  []Cell
+}
+
+// Result of Compare.
+type Result struct {
+	// Schema are the differences of the columns: columns of one table only and type mismatches.
+	Schema []string
+	// Columns are the columns compared, those of both tables.
+	Columns      []string
+	RowsA, RowsB int64
+	OnlyA, OnlyB int64
+	Changed      int64
+	// Cells counts the changed values of each of Columns.
+	Cells []int64
+	// Rows are the first differing rows by line.
+	Rows []RowDiff
+}
+
+// Equal reports whether the tables have the same schema and rows.
+func (r Result) Equal() bool {
+	return len(r.Schema) == 0 && r.OnlyA == 0 && r.OnlyB == 0 && r.Changed == 0
+}
+
+// comparison is the state of one Compare call.
+type comparison struct {
+	opts    Options
+	seed    maphash.Seed
+	columns []Column
+	kinds   []Kind
+	idxA    []int
+	idxB    []int
+	key     []int
+	dir     string
+	result  Result
+}
+
+// Compare compares the tables a and b.
+func Compare(a, b Table, opts Options) (Result, error) {
+	if opts.Buckets <= 0 {
+		opts.Buckets = Buckets
+	}
+	if opts.BucketSize <= 0 {
+		opts.BucketSize = BucketSize
+	}
+	c := &comparison{opts: opts, seed: maphash.MakeSeed()}
+	if err := c.schema(a, b); err != nil {
+		return Result{}, err
+	}
+	dir, err := os.MkdirTemp(opts.TempDir, "diff-")
+	if err != nil {
+		return Result{}, errors.Wrap(err, "error create bucket directory")
+	}
+	c.dir = dir
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	if c.result.RowsA, err = c.spill(a, "a", c.idxA); err != nil {
+		return Result{}, err
+	}
+	if c.result.RowsB, err = c.spill(b, "b", c.idxB); err != nil {
+		return Result{}, err
+	}
+	for i := range opts.Buckets {
+		if err = c.bucket(strconv.Itoa(i), 0); err != nil {
+			return Result{}, err
+		}
+	}
+	slices.SortFunc(c.result.Rows, compareLines)
+	if opts.MaxRows > 0 && len(c.result.Rows) > opts.MaxRows {
+		c.result.Rows = c.result.Rows[:opts.MaxRows]
+	}
+	return c.result, nil
+}
+
+// schema matches the columns of a and b by name and resolves the key. Names match in any case,
+// the csv a parquet file is converted to has them in lower case.
+func (c *comparison) schema(a, b Table) error {
+	inB := make(map[string]int, len(b.Columns))
+	for i, col := range b.Columns {
+		inB[strings.ToLower(col.Name)] = i
+	}
+	inA := make(map[string]bool, len(a.Columns))
+	for i, col := range a.Columns {
+		inA[strings.ToLower(col.Name)] = true
+		j, ok := inB[strings.ToLower(col.Name)]
+		if !ok {
+			c.result.Schema = append(c.result.Schema, "column "+col.Name+" only in "+a.Name)
+			continue
+		}
+		other := b.Columns[j]
+		if col.Type != "" && other.Type != "" && col.Type != other.Type {
+			c.result.Schema = append(c.result.Schema,
+				"column "+col.Name+": "+col.Type+" in "+a.Name+", "+other.Type+" in "+b.Name)
+		}
+		kind := col.Kind
+		if kind == Text {
+			kind = other.Kind
+		} else if other.Kind != Text && other.Kind != kind {
+			kind = Text
+		}
+		c.columns = append(c.columns, col)
+		c.kinds = append(c.kinds, kind)
+		c.idxA = append(c.idxA, i)
+		c.idxB = append(c.idxB, j)
+		c.result.Columns = append(c.result.Columns, col.Name)
+	}
+	for _, col := range b.Columns {
+		if !inA[strings.ToLower(col.Name)] {
+			c.result.Schema = append(c.result.Schema, "column "+col.Name+" only in "+b.Name)
+		}
+	}
+	if len(c.columns) == 0 {
+		return errors.Errorf("%s and %s have no column in common", a.Name, b.Name)
+	}
+	c.result.Cells = make([]int64, len(c.columns))
+
+	for _, name := range c.opts.Key {
+		i := slices.IndexFunc(c.columns, func(col Column) bool {
+			return strings.EqualFold(col.Name, name)
+		})
+		if i < 0 {
+			return errors.Errorf("key column %s isn't in both %s and %s", name, a.Name, b.Name)
+		}
+		c.key = append(c.key, i)
+	}
+	return nil
+}
+
+// spill writes the compared columns of the rows of t to its bucket files.
+func (c *comparison) spill(t Table, side string, idx []int) (int64, error) {
+	w := c.newBuckets(side, 0)
+	defer w.close()
+	var rows int64
+	r := row{Values: make([]string, len(idx))}
+	err := t.Read(func(line int64, values []string) error {
+		rows++
+		r.Line = line
+		for i, j := range idx {
+			r.Values[i] = values[j]
+		}
+		return w.write(&r)
+	})
+	if err != nil {
+		return rows, errors.Wrap(err, t.Name)
+	}
+	return rows, w.flush()
+}
+
+// buckets writes rows to the bucket files prefix0, prefix1, ... by the hash of their id at depth.
+type buckets struct {
+	c        *comparison
+	prefix   string
+	depth    int
+	files    []*os.File
+	buffers  []*bufio.Writer
+	encoders []*gob.Encoder
+}
+
+func (c *comparison) newBuckets(prefix string, depth int) *buckets {
+	return &buckets{
+		c:        c,
+		prefix:   prefix,
+		depth:    depth,
+		files:    make([]*os.File, c.opts.Buckets),
+		buffers:  make([]*bufio.Writer, c.opts.Buckets),
+		encoders: make([]*gob.Encoder, c.opts.Buckets),
+	}
+}
+
+func (w *buckets) write(r *row) error {
+	n := int(w.c.hash(r.Values, w.depth) % uint64(w.c.opts.Buckets)) //nolint:gosec // bucket count is positive
+	if w.encoders[n] == nil {
+		f, err := os.Create(filepath.Join(w.c.dir, w.prefix+strconv.Itoa(n)))
+		if err != nil {
+			return errors.Wrap(err, "error create bucket file")
+		}
+		w.files[n] = f
+		w.buffers[n] = bufio.NewWriter(f)
+		w.encoders[n] = gob.NewEncoder(w.buffers[n])
+	}
+	return errors.Wrap(w.encoders[n].Encode(r), "error write bucket file")
+}
+
+func (w *buckets) flush() error {
+	for _, b := range w.buffers {
+		if b == nil {
+			continue
+		}
+		if err := b.Flush(); err != nil {
+			return errors.Wrap(err, "error write bucket file")
+		}
+	}
+	return nil
+}
+
+func (w *buckets) close() {
+	for _, f := range w.files {
+		if f != nil {
+			_ = f.Close()
+		}
+	}
+}
+
+// row is a row of a bucket file.
+type row struct {
+	Line   int64
+	Values []string
+}
+
+// bucket compares the rows of bucket name of both tables, split into smaller buckets first when
+// it's over BucketSize.
+func (c *comparison) bucket(name string, depth int) error {
+	if depth < maxSplits && c.size("a"+name)+c.size("b"+name) > c.opts.BucketSize {
+		for _, side := range []string{"a", "b"} {
+			if err := c.split(side+name, depth+1); err != nil {
+				return err
+			}
+		}
+		for i := range c.opts.Buckets {
+			if err := c.bucket(name+"."+strconv.Itoa(i), depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	rowsA, err := c.readBucket("a" + name)
+	if err != nil {
+		return err
+	}
+	pending := make(map[string][]row, len(rowsA))
+	for _, r := range rowsA {
+		id := c.id(r.Values)
+		pending[id] = append(pending[id], r)
+	}
+	rowsB, err := c.readBucket("b" + name)
+	if err != nil {
+		return err
+	}
+	var diffs []RowDiff
+	for _, rb := range rowsB {
+		id := c.id(rb.Values)
+		matches := pending[id]
+		if len(matches) == 0 {
+			c.result.OnlyB++
+			diffs = append(diffs, RowDiff{Kind: OnlyB, LineB: rb.Line, Key: c.describeKey(rb.Values), Values: rb.Values})
+			continue
+		}
+		ra := matches[0]
+		if len(matches) == 1 {
+			delete(pending, id)
+		} else {
+			pending[id] = matches[1:]
+		}
+		if cells := c.changed(ra.Values, rb.Values); len(cells) > 0 {
+			c.result.Changed++
+			diffs = append(diffs, RowDiff{
+				Kind: Changed, LineA: ra.Line, LineB: rb.Line, Key: c.describeKey(ra.Values), Cells: cells,
+			})
+		}
+	}
+	for _, matches := range pending {
+		for _, ra := range matches {
+			c.result.OnlyA++
+			diffs = append(diffs, RowDiff{Kind: OnlyA, LineA: ra.Line, Key: c.describeKey(ra.Values), Values: ra.Values})
+		}
+	}
+	slices.SortFunc(diffs, compareLines)
+	if c.opts.MaxRows > 0 && len(diffs) > c.opts.MaxRows {
+		diffs = diffs[:c.opts.MaxRows]
+	}
+	c.result.Rows = append(c.result.Rows, diffs...)
+	return nil
+}
+
+func (c *comparison) readBucket(name string) ([]row, error) {
+	var rows []row
+	err := c.scanBucket(name, func(r *row) error {
+		rows = append(rows, *r)
+		return nil
+	})
+	return rows, err
+}
+
+// split moves the rows of bucket file name to the files name.0, name.1, ... by their hash at depth.
+func (c *comparison) split(name string, depth int) error {
+	w := c.newBuckets(name+".", depth)
+	defer w.close()
+	if err := c.scanBucket(name, w.write); err != nil {
+		return err
+	}
+	if err := w.flush(); err != nil {
+		return err
+	}
+	return errors.Wrap(os.Remove(filepath.Join(c.dir, name)), "error remove bucket file")
+}
+
+// scanBucket calls fn with the rows of a bucket file, none when there is no file.
+func (c *comparison) scanBucket(name string, fn func(r *row) error) error {
+	f, err := os.Open(filepath.Join(c.dir, name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "error open bucket file")
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	dec := gob.NewDecoder(bufio.NewReader(f))
+	for {
+		var r row
+		if err = dec.Decode(&r); errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "error read bucket file")
+		}
+		if err = fn(&r); err != nil {
+			return err
+		}
+	}
+}
+
+// size is the size of a bucket file, 0 when there is none.
+func (c *comparison) size(name string) int64 {
+	info, err := os.Stat(filepath.Join(c.dir, name))
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// hash picks the bucket of a row by its id, every depth of splitting spreads the rows anew.
+func (c *comparison) hash(values []string, depth int) uint64 {
+	var h maphash.Hash
+	h.SetSeed(c.seed)
+	_ = h.WriteByte(byte(depth)) //nolint:gosec // depth is at most maxSplits
+	_, _ = h.WriteString(c.id(values))
+	return h.Sum64()
+}
+
+// id is what matches rows: the normalised key values or, without a key, all normalised values.
+func (c *comparison) id(values []string) string {
+	var b strings.Builder
+	if len(c.key) == 0 {
+		for i, v := range values {
+			b.WriteString(normalize(v, c.kinds[i]))
+			b.WriteByte(0)
+		}
+		return b.String()
+	}
+	for _, i := range c.key {
+		b.WriteString(normalize(values[i], c.kinds[i]))
+		b.WriteByte(0)
+	}
+	return b.String()
+}
+
+func (c *comparison) describeKey(values []string) string {
+	parts := make([]string, len(c.key))
+	for n, i := range c.key {
+		parts[n] = c.columns[i].Name + "=" + values[i]
+	}
+	return strings.Join(parts, ", ")
+}
+
+// changed returns the values of matched rows that differ and counts them by column.
+func (c *comparison) changed(a, b []string) []Cell {
+	var cells []Cell
+	for i := range a {
+		if c.equal(a[i], b[i], c.kinds[i]) {
+			continue
+		}
+		c.result.Cells[i]++
+		cells = append(cells, Cell{Column: c.columns[i].Name, A: a[i], B: b[i]})
+	}
+	return cells
+}
+
+// equal compares two values of a column, with a tolerance numbers of untyped columns too.
+func (c *comparison) equal(a, b string, kind Kind) bool {
+	if a == b || normalize(a, kind) == normalize(b, kind) {
+		return true
+	}
+	if kind != Number && kind != Text || c.opts.Tolerance <= 0 {
+		return false
+	}
+	x, errA := strconv.ParseFloat(strings.TrimSpace(a), 64)
+	y, errB := strconv.ParseFloat(strings.TrimSpace(b), 64)
+	return errA == nil && errB == nil && math.Abs(x-y) <= c.opts.Tolerance
+}
+
+// timeLayouts are the layouts Time values are parsed with.
+var timeLayouts = []string{ //nolint:gochecknoglobals // read only
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	time.DateOnly,
+	"20060102",
+}
+
+// normalize returns the canonical text of a value of the kind, the value itself when it doesn't
+// parse as one.
+func normalize(v string, kind Kind) string {
+	switch kind {
+	case Number:
+		if r, ok := new(big.Rat).SetString(strings.TrimSpace(v)); ok {
+			return r.RatString()
+		}
+	case Bool:
+		// the values the csv → parquet conversion reads as booleans
+		if b, err := (schema.TextType{Name: "boolean"}).Parse(strings.TrimSpace(v)); err == nil && b != nil {
+			return strconv.FormatBool(b.(bool)) //nolint:forcetypeassert // booleans parse to bool
+		}
+	case Time:
+		for _, layout := range timeLayouts {
+			if t, err := time.Parse(layout, strings.TrimSpace(v)); err == nil {
+				return t.UTC().Format(time.RFC3339Nano)
+			}
+		}
+	case Text:
+	}
+	return v
+}
+
+// compareLines orders differing rows by their line in a, then rows only in b by their line in b.
+func compareLines(x, y RowDiff) int {
+	switch {
+	case x.LineA != 0 && y.LineA != 0:
+		return cmp.Compare(x.LineA, y.LineA)
+	case x.LineA != 0:
+		return -1
+	case y.LineA != 0:
+		return 1
+	}
+	return cmp.Compare(x.LineB, y.LineB)
+}
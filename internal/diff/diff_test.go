@@ -0,0 +1,211 @@
+package diff
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/dbunt1tled/parquet2csv/pkg/convert"
+)
+
+func table(name string, columns []Column, rows ...string) Table {
+	return Table{
+		Name:    name,
+		Columns: columns,
+		Read: func(fn func(line int64, values []string) error) error {
+			for i, r := range rows {
+				if err := fn(int64(i+2), strings.Split(r, ",")); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func compare(t *testing.T, a, b Table, opts Options) Result {
+	t.Helper()
+	opts.TempDir = t.TempDir()
+	r, err := Compare(a, b, opts)
+	if err != nil {
+		t.Fatalf("Compare() error = %v", err)
+	}
+	return r
+}
+
+var csvColumns = []Column{{Name: "id"}, {Name: "amount"}, {Name: "day"}, {Name: "paid"}} //nolint:gochecknoglobals // test data
+
+var parquetColumns = []Column{ //nolint:gochecknoglobals // test data
+	{Name: "id", Type: "INT64", Kind: Number},
+	{Name: "amount", Type: "DECIMAL", Kind: Number},
+	{Name: "day", Type: "DATE", Kind: Time},
+	{Name: "paid", Type: "BOOLEAN", Kind: Bool},
+}
+
+func TestCompareNormalised(t *testing.T) {
+	a := table("a.csv", csvColumns, "1,10.5,2024-01-02,TRUE", "2,3,2024-01-03,0")
+	b := table("b.parquet", parquetColumns, "1,10.50,2024-01-02T00:00:00Z,true", "2,3.00,2024-01-03,false")
+	for _, key := range [][]string{{"id"}, nil} {
+		r := compare(t, a, b, Options{Key: key})
+		if !r.Equal() || r.RowsA != 2 || r.RowsB != 2 {
+			t.Errorf("key %v: result = %+v; want equal, 2 rows each", key, r)
+		}
+	}
+}
+
+func TestCompareRoundTrip(t *testing.T) {
+	rows := []string{"1,10.5,20240102,Y", "2,3,20240103,NO", "3,0.25,2024-01-04,yes"}
+	in := "id,amount,day,paid\n" + strings.Join(rows, "\n") + "\n"
+	opts := convert.Options{Schema: []convert.Column{
+		{Name: "id", Type: "int"}, {Name: "amount", Type: "decimal(9,2)"}, {Name: "day", Type: "date"}, {Name: "paid", Type: "boolean"},
+	}}
+	var pq, out bytes.Buffer
+	if _, err := convert.CSVToParquet(context.Background(), strings.NewReader(in), &pq, opts); err != nil {
+		t.Fatalf("CSVToParquet() error = %v", err)
+	}
+	if _, err := convert.ParquetToCSV(context.Background(), bytes.NewReader(pq.Bytes()), &out, convert.Options{}); err != nil {
+		t.Fatalf("ParquetToCSV() error = %v", err)
+	}
+	converted := strings.Split(strings.TrimSpace(out.String()), "\n")[1:]
+	for _, key := range [][]string{{"id"}, nil} {
+		r := compare(t, table("a.csv", csvColumns, rows...), table("a.parquet", parquetColumns, converted...), Options{Key: key})
+		if !r.Equal() || r.RowsA != 3 || r.RowsB != 3 {
+			t.Errorf("key %v: result = %+v of %q; want equal, 3 rows each", key, r, converted)
+		}
+	}
+}
+
+func TestCompareRoundTripNames(t *testing.T) {
+	columns := []Column{{Name: "ID", Type: "INT64", Kind: Number}, {Name: "Name", Type: "UTF8"}}
+	rows := []string{"1,x", "2,y"}
+	var pq, out bytes.Buffer
+	opts := convert.Options{Schema: []convert.Column{{Name: "ID", Type: "int"}}}
+	if _, err := convert.CSVToParquet(context.Background(), strings.NewReader("ID,Name\n1,x\n2,y\n"), &pq, opts); err != nil {
+		t.Fatalf("CSVToParquet() error = %v", err)
+	}
+	stats, err := convert.ParquetToCSV(context.Background(), bytes.NewReader(pq.Bytes()), &out, convert.Options{})
+	if err != nil {
+		t.Fatalf("ParquetToCSV() error = %v", err)
+	}
+	header := make([]Column, len(stats.Header))
+	for i, name := range stats.Header {
+		header[i] = Column{Name: name}
+	}
+	converted := strings.Split(strings.TrimSpace(out.String()), "\n")[1:]
+	converted[1] = "2,z"
+
+	r := compare(t, table("a.parquet", columns, rows...), table("a.csv", header, converted...), Options{Key: []string{"id"}})
+	if len(r.Schema) != 0 || len(r.Columns) != 2 || r.Changed != 1 || r.Equal() {
+		t.Errorf("result = %+v of %v; want ID and Name compared, id=2 changed", r, stats.Header)
+	}
+
+	other := table("b.csv", []Column{{Name: "code"}}, "1", "2")
+	if _, err = Compare(table("a.parquet", columns, rows...), other, Options{TempDir: t.TempDir()}); err == nil {
+		t.Error("no common column: no error")
+	}
+}
+
+func TestCompareKey(t *testing.T) {
+	a := table("a.csv", csvColumns, "1,10.5,2024-01-02,true", "2,3,2024-01-03,false", "3,1,2024-01-04,true")
+	b := table("b.parquet", parquetColumns, "2,3.01,2024-01-03,false", "1,10.6,2024-01-02,true", "4,1,2024-01-05,true")
+
+	r := compare(t, a, b, Options{Key: []string{"id"}, MaxRows: MaxRows})
+	if r.OnlyA != 1 || r.OnlyB != 1 || r.Changed != 2 {
+		t.Fatalf("result = %+v; want 1 only in a, 1 only in b, 2 changed", r)
+	}
+	if r.Cells[1] != 2 {
+		t.Errorf("cells = %v; want 2 amounts changed", r.Cells)
+	}
+	want := []string{"changed 2 3 id=1 [{amount 10.5 10.6}]", "changed 3 2 id=2 [{amount 3 3.01}]", "only_a 4 0 id=3 []", "only_b 0 4 id=4 []"}
+	for i, d := range r.Rows {
+		if got := d.Kind + " " + strconv.FormatInt(d.LineA, 10) + " " + strconv.FormatInt(d.LineB, 10) + " " + d.Key + " " + cellsString(d.Cells); got != want[i] {
+			t.Errorf("row %d = %q; want %q", i, got, want[i])
+		}
+	}
+
+	r = compare(t, a, b, Options{Key: []string{"id"}, Tolerance: 0.05})
+	if r.Changed != 1 || r.Rows[0].Key != "id=1" {
+		t.Errorf("tolerance 0.05: result = %+v; want id=1 changed only", r)
+	}
+
+	csvB := table("b.csv", csvColumns, "2,3.01,2024-01-03,false", "1,10.5001,2024-01-02,true", "3,x,2024-01-04,true")
+	r = compare(t, table("a.csv", csvColumns, "1,10.5,2024-01-02,true", "2,3,2024-01-03,false", "3,1,2024-01-04,true"), csvB,
+		Options{Key: []string{"id"}, Tolerance: 0.05})
+	if r.Changed != 1 || r.Rows[0].Key != "id=3" {
+		t.Errorf("csv tolerance 0.05: result = %+v; want id=3 changed only", r)
+	}
+
+	r = compare(t, a, b, Options{Key: []string{"id"}, MaxRows: 1})
+	if len(r.Rows) != 1 || r.Rows[0].Key != "id=1" {
+		t.Errorf("max rows 1: rows = %+v; want id=1", r.Rows)
+	}
+}
+
+func cellsString(cells []Cell) string {
+	parts := make([]string, len(cells))
+	for i, c := range cells {
+		parts[i] = "{" + c.Column + " " + c.A + " " + c.B + "}"
+	}
+	return "[" + strings.Join(parts, " ") + "]"
+}
+
+func TestCompareUnordered(t *testing.T) {
+	var rowsA, rowsB []string
+	for i := range 1000 {
+		rowsA = append(rowsA, strconv.Itoa(i)+",1,2024-01-02,true")
+		rowsB = append(rowsB, strconv.Itoa(999-i)+",1,2024-01-02,true")
+	}
+	// a duplicate row must be matched as many times as it's there
+	rowsA = append(rowsA, "7,1,2024-01-02,true")
+	rowsB[500] = "500,2,2024-01-02,true"
+
+	r := compare(t, table("a.csv", csvColumns, rowsA...), table("b.csv", csvColumns, rowsB...), Options{Buckets: 4})
+	if r.RowsA != 1001 || r.RowsB != 1000 || r.OnlyA != 2 || r.OnlyB != 1 || r.Changed != 0 {
+		t.Fatalf("result = %+v; want 1001 and 1000 rows, 2 only in a, 1 only in b", r)
+	}
+	if d := r.Rows[0]; d.Kind != OnlyA || d.LineA != 501 {
+		t.Errorf("first row = %+v; want line 501 only in a", d)
+	}
+	if d := r.Rows[2]; d.Kind != OnlyB || d.LineB != 502 || d.Values[1] != "2" {
+		t.Errorf("last row = %+v; want line 502 only in b", d)
+	}
+}
+
+func TestCompareSplit(t *testing.T) {
+	var rowsA, rowsB, same []string
+	for i := range 1000 {
+		rowsA = append(rowsA, strconv.Itoa(i)+",1,2024-01-02,true")
+		rowsB = append(rowsB, strconv.Itoa(999-i)+",1,2024-01-02,true")
+		same = append(same, "1,1,2024-01-02,true")
+	}
+	rowsB[0] = "999,2,2024-01-02,true"
+	// buckets of a few rows are split down to the last level, rows of one id can't be split
+	opts := Options{Key: []string{"id"}, Buckets: 2, BucketSize: 512}
+	r := compare(t, table("a.csv", csvColumns, rowsA...), table("b.csv", csvColumns, rowsB...), opts)
+	if r.RowsA != 1000 || r.RowsB != 1000 || r.OnlyA != 0 || r.OnlyB != 0 || r.Changed != 1 || r.Rows[0].Key != "id=999" {
+		t.Errorf("result = %+v; want id=999 changed only", r)
+	}
+	r = compare(t, table("a.csv", csvColumns, same...), table("b.csv", csvColumns, same[1:]...), Options{Buckets: 2, BucketSize: 512})
+	if r.OnlyA != 1 || r.OnlyB != 0 {
+		t.Errorf("same rows: result = %+v; want 1 only in a", r)
+	}
+}
+
+func TestCompareSchema(t *testing.T) {
+	a := table("a.parquet", []Column{{Name: "id", Type: "INT64", Kind: Number}, {Name: "name", Type: "UTF8"}}, "1,x")
+	b := table("b.parquet", []Column{{Name: "id", Type: "INT32", Kind: Number}, {Name: "code", Type: "UTF8"}}, "1,y")
+	r := compare(t, a, b, Options{Key: []string{"id"}})
+	want := []string{"column id: INT64 in a.parquet, INT32 in b.parquet", "column name only in a.parquet", "column code only in b.parquet"}
+	if strings.Join(r.Schema, "; ") != strings.Join(want, "; ") {
+		t.Errorf("schema = %q; want %q", r.Schema, want)
+	}
+	if r.Equal() || r.Changed != 0 || len(r.Columns) != 1 {
+		t.Errorf("result = %+v; want schema differences only, id compared", r)
+	}
+
+	if _, err := Compare(a, b, Options{Key: []string{"name"}, TempDir: t.TempDir()}); err == nil {
+		t.Error("key name of a only: no error")
+	}
+}
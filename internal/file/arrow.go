@@ -0,0 +1,189 @@
+package file
+
+import (
+	"bytes"
+	"io"
+	"os"
+
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/apache/arrow/go/arrow/ipc"
+	"github.com/apache/arrow/go/arrow/memory"
+	"github.com/pkg/errors"
+)
+
+// arrowMagic starts and ends an arrow ipc file (feather v2), streams have no magic.
+var arrowMagic = []byte("ARROW1") //nolint:gochecknoglobals // format constant
+
+// ArrowReader reads the record batches of an arrow ipc file or stream, the format is detected from the content.
+type ArrowReader struct {
+	file   *countingFile
+	fr     *ipc.FileReader
+	sr     *ipc.Reader
+	Stream bool
+}
+
+// countingFile counts the bytes the ipc readers pull from the file.
+type countingFile struct {
+	*os.File
+	n int64
+}
+
+func (f *countingFile) Read(p []byte) (int, error) {
+	n, err := f.File.Read(p)
+	f.n += int64(n)
+	return n, err
+}
+
+func (f *countingFile) ReadAt(p []byte, off int64) (int, error) {
+	n, err := f.File.ReadAt(p, off)
+	f.n += int64(n)
+	return n, err
+}
+
+func NewArrowReader(path string) (*ArrowReader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	magic := make([]byte, len(arrowMagic))
+	if _, err = io.ReadFull(f, magic); err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		_ = f.Close()
+		return nil, err
+	}
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	r := &ArrowReader{file: &countingFile{File: f}}
+	if bytes.Equal(magic, arrowMagic) {
+		r.fr, err = ipc.NewFileReader(r.file)
+	} else {
+		r.Stream = true
+		r.sr, err = ipc.NewReader(r.file)
+	}
+	if err != nil {
+		_ = f.Close()
+		return nil, errors.Wrap(err, "file is not an arrow ipc file or stream")
+	}
+	return r, nil
+}
+
+func (r *ArrowReader) Schema() *arrow.Schema {
+	if r.fr != nil {
+		return r.fr.Schema()
+	}
+	return r.sr.Schema()
+}
+
+// Next returns the next record batch or io.EOF, the record is valid until the next call.
+func (r *ArrowReader) Next() (array.Record, error) {
+	var (
+		rec array.Record
+		err error
+	)
+	if r.fr != nil {
+		rec, err = r.fr.Read()
+	} else {
+		rec, err = r.sr.Read()
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, errors.Wrap(err, "error read record batch")
+	}
+	return rec, err
+}
+
+// BytesRead is the number of bytes read from the file so far.
+func (r *ArrowReader) BytesRead() int64 {
+	return r.file.n
+}
+
+func (r *ArrowReader) Close() error {
+	if r.fr != nil {
+		if err := r.fr.Close(); err != nil {
+			_ = r.file.Close()
+			return err
+		}
+	} else {
+		r.sr.Release()
+	}
+	return r.file.Close()
+}
+
+// ArrowWriter writes the rows appended to Builder as a record batch every batch rows to an arrow
+// ipc file, or a stream when stream is set.
+type ArrowWriter struct {
+	Builder *array.RecordBuilder
+	fw      *ipc.FileWriter
+	sw      *ipc.Writer
+	batch   int
+	rows    int
+}
+
+// NewArrowWriter writes to w, Close doesn't close it.
+func NewArrowWriter(w io.Writer, schema *arrow.Schema, stream bool, batch int) (*ArrowWriter, error) {
+	aw := &ArrowWriter{Builder: array.NewRecordBuilder(memory.NewGoAllocator(), schema), batch: batch}
+	if stream {
+		aw.sw = ipc.NewWriter(w, ipc.WithSchema(schema))
+		return aw, nil
+	}
+	var err error
+	// the file writer only seeks to learn its position
+	if aw.fw, err = ipc.NewFileWriter(&positionWriter{w: w}, ipc.WithSchema(schema)); err != nil {
+		aw.Builder.Release()
+		return nil, errors.Wrap(err, "error create arrow writer")
+	}
+	return aw, nil
+}
+
+// Added is called after a row is appended to Builder.
+func (w *ArrowWriter) Added() error {
+	w.rows++
+	if w.rows < w.batch {
+		return nil
+	}
+	return w.write()
+}
+
+func (w *ArrowWriter) write() error {
+	rec := w.Builder.NewRecord()
+	defer rec.Release()
+	w.rows = 0
+	if w.fw != nil {
+		return errors.Wrap(w.fw.Write(rec), "error write record batch")
+	}
+	return errors.Wrap(w.sw.Write(rec), "error write record batch")
+}
+
+// Close writes the remaining rows and ends the file or stream.
+func (w *ArrowWriter) Close() error {
+	defer w.Builder.Release()
+	if w.rows > 0 {
+		if err := w.write(); err != nil {
+			return err
+		}
+	}
+	if w.fw != nil {
+		return errors.Wrap(w.fw.Close(), "error close arrow writer")
+	}
+	return errors.Wrap(w.sw.Close(), "error close arrow writer")
+}
+
+// positionWriter is an io.WriteSeeker over a writer that can only tell its position.
+type positionWriter struct {
+	w   io.Writer
+	pos int64
+}
+
+func (p *positionWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.pos += int64(n)
+	return n, err
+}
+
+func (p *positionWriter) Seek(offset int64, whence int) (int64, error) {
+	if offset != 0 || whence != io.SeekCurrent {
+		return p.pos, errors.New("arrow output can't seek")
+	}
+	return p.pos, nil
+}
@@ -0,0 +1,100 @@
+package file
+
+import (
+	"bufio"
+	"io"
+	"os"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/pkg/errors"
+)
+
+// AvroReader reads the records of an avro object container file one by one.
+type AvroReader struct {
+	file *countingFile
+	ocf  *goavro.OCFReader
+}
+
+func NewAvroReader(path string) (*AvroReader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &AvroReader{file: &countingFile{File: f}}
+	r.ocf, err = goavro.NewOCFReader(bufio.NewReader(r.file))
+	if err != nil {
+		_ = f.Close()
+		return nil, errors.Wrap(err, "file is not an avro container file")
+	}
+	return r, nil
+}
+
+// Schema is the avro schema of the file as written in its header.
+func (r *AvroReader) Schema() string {
+	if schema, ok := r.ocf.MetaData()["avro.schema"]; ok {
+		return string(schema)
+	}
+	return r.ocf.Codec().Schema()
+}
+
+// Next returns the next record decoded to goavro native values or io.EOF.
+func (r *AvroReader) Next() (any, error) {
+	if !r.ocf.Scan() {
+		if err := r.ocf.Err(); err != nil {
+			return nil, errors.Wrap(err, "error read avro block")
+		}
+		return nil, io.EOF
+	}
+	datum, err := r.ocf.Read()
+	if err != nil {
+		return nil, errors.Wrap(err, "error read avro record")
+	}
+	return datum, nil
+}
+
+// BytesRead is the number of bytes read from the file so far.
+func (r *AvroReader) BytesRead() int64 {
+	return r.file.n
+}
+
+func (r *AvroReader) Close() error {
+	return r.file.Close()
+}
+
+// AvroWriter writes records to an avro object container file, a block every flush records.
+type AvroWriter struct {
+	ocf   *goavro.OCFWriter
+	block []any
+	flush int
+}
+
+// NewAvroWriter writes to w, compression is one of null, deflate or snappy. Close doesn't close w.
+func NewAvroWriter(w io.Writer, schema, compression string, flush int) (*AvroWriter, error) {
+	ocf, err := goavro.NewOCFWriter(goavro.OCFConfig{W: w, Schema: schema, CompressionName: compression})
+	if err != nil {
+		return nil, errors.Wrap(err, "error create avro writer")
+	}
+	return &AvroWriter{ocf: ocf, block: make([]any, 0, flush), flush: flush}, nil
+}
+
+func (w *AvroWriter) Write(datum any) error {
+	w.block = append(w.block, datum)
+	if len(w.block) < w.flush {
+		return nil
+	}
+	return w.writeBlock()
+}
+
+func (w *AvroWriter) writeBlock() error {
+	if len(w.block) == 0 {
+		return nil
+	}
+	err := w.ocf.Append(w.block)
+	w.block = w.block[:0]
+	return err
+}
+
+// Close writes the remaining records.
+func (w *AvroWriter) Close() error {
+	return w.writeBlock()
+}
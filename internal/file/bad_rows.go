@@ -0,0 +1,76 @@
+package file
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// BadRow is an input line that can't be converted.
+type BadRow struct {
+	Line int
+	Raw  string
+	Err  error
+}
+
+func (r BadRow) Error() string {
+	return "line " + strconv.Itoa(r.Line) + ": " + r.Err.Error()
+}
+
+// BadRowWriter writes rejected lines unchanged, one per line, so they can be fixed and converted again.
+type BadRowWriter struct {
+	file   *os.File
+	writer *bufio.Writer
+}
+
+// NewBadRowWriter creates the file, or keeps its first keep bytes when resuming a conversion:
+// the rows rejected up to its checkpoint, those rejected after it are read again.
+func NewBadRowWriter(path string, keep int64) (*BadRowWriter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0o644) //nolint:mnd,gosec // same mode as os.Create
+	if err != nil {
+		return nil, errors.Wrap(err, "error create bad rows file "+path)
+	}
+	info, err := f.Stat()
+	if err == nil && info.Size() < keep {
+		err = errors.Errorf("%d bytes, %d at the checkpoint", info.Size(), keep)
+	}
+	if err == nil {
+		err = f.Truncate(keep)
+	}
+	if err == nil {
+		_, err = f.Seek(keep, io.SeekStart)
+	}
+	if err != nil {
+		_ = f.Close()
+		return nil, errors.Wrap(err, "error resume bad rows file "+path)
+	}
+	return &BadRowWriter{file: f, writer: bufio.NewWriter(f)}, nil
+}
+
+func (w *BadRowWriter) Write(r BadRow) error {
+	_, err := w.writer.WriteString(r.Raw + "\n")
+	return err
+}
+
+// Sync flushes the rows written to stable storage and returns the size of the file.
+func (w *BadRowWriter) Sync() (int64, error) {
+	if err := w.writer.Flush(); err != nil {
+		return 0, errors.Wrap(err, "error write bad rows file")
+	}
+	if err := w.file.Sync(); err != nil {
+		return 0, errors.Wrap(err, "error sync bad rows file")
+	}
+	size, err := w.file.Seek(0, io.SeekCurrent)
+	return size, errors.Wrap(err, "error seek bad rows file")
+}
+
+func (w *BadRowWriter) Close() error {
+	if err := w.writer.Flush(); err != nil {
+		_ = w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
@@ -0,0 +1,146 @@
+package file
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/pkg/errors"
+)
+
+const (
+	CheckpointFile = "checkpoint.json"
+	CheckpointRows = 1000000
+)
+
+// Checkpoint is the last durable point of a csv → parquet conversion.
+// Every entry of Parts is a closed parquet file holding the rows up to Row,
+// Offset and Line point right after the last of these rows in the input,
+// BadRows is the size of the bad rows file then. Sums[i] are the rows of Parts[i].
+type Checkpoint struct {
+	Input     string    `json:"input"`
+	InputSize int64     `json:"input_size"`
+	Output    string    `json:"output"`
+	Header    []string  `json:"header"`
+	Offset    int64     `json:"offset"`
+	Line      int       `json:"line"`
+	Row       int       `json:"row"`
+	BatchID   int       `json:"batch_id"`
+	Parts     []string  `json:"parts"`
+	Sums      []PartSum `json:"sums"`
+	BadRows   int64     `json:"bad_rows"`
+	Done      bool      `json:"done"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// PartSum is the row count of a part and, when it was written with --verify, their checksum.
+type PartSum struct {
+	Rows     int64  `json:"rows"`
+	Checksum string `json:"checksum,omitempty"`
+}
+
+func NewCheckpoint(input, output string) (*Checkpoint, error) {
+	info, err := os.Stat(input)
+	if err != nil {
+		return nil, errors.Wrap(err, "error stat input "+input)
+	}
+	return &Checkpoint{
+		Input:     input,
+		InputSize: info.Size(),
+		Output:    output,
+	}, nil
+}
+
+// LoadCheckpoint reads the checkpoint stored in dir, nil is returned when there is none yet.
+func LoadCheckpoint(dir string) (*Checkpoint, error) {
+	data, err := os.ReadFile(filepath.Join(dir, CheckpointFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil //nolint:nilnil // no checkpoint yet
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "error read checkpoint")
+	}
+	cp := &Checkpoint{}
+	if err = sonic.ConfigStd.Unmarshal(data, cp); err != nil {
+		return nil, errors.Wrap(err, "error parse checkpoint")
+	}
+	return cp, nil
+}
+
+// Validate checks that the checkpoint belongs to the same conversion and that its parts are still in place.
+func (c *Checkpoint) Validate(input, output string) error {
+	if c.Input != input || c.Output != output {
+		return errors.New("checkpoint belongs to " + c.Input + " → " + c.Output)
+	}
+	info, err := os.Stat(input)
+	if err != nil {
+		return errors.Wrap(err, "error stat input "+input)
+	}
+	if info.Size() != c.InputSize {
+		return errors.New("input " + input + " changed since checkpoint")
+	}
+	for _, part := range c.Parts {
+		if _, err = os.Stat(part); err != nil {
+			return errors.Wrap(err, "checkpoint part "+part+" is missing")
+		}
+	}
+	return nil
+}
+
+// Commit records a closed part and its rows together with the position right after the last of them:
+// the input offset and line, the row and the batch it was read in.
+func (c *Checkpoint) Commit(dir, part string, sum PartSum, offset int64, line, row, batchID int) error {
+	if err := Sync(part); err != nil {
+		return err
+	}
+	c.Parts = append(c.Parts, part)
+	c.Sums = append(c.Sums, sum)
+	c.Offset = offset
+	c.Line = line
+	c.Row = row
+	c.BatchID = batchID
+	return c.Save(dir)
+}
+
+// Save atomically replaces the checkpoint file in dir.
+func (c *Checkpoint) Save(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil { //nolint:mnd // default dir permissions
+		return errors.Wrap(err, "error create checkpoint dir")
+	}
+	c.UpdatedAt = time.Now()
+	data, err := sonic.ConfigStd.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "error marshal checkpoint")
+	}
+	path := filepath.Join(dir, CheckpointFile)
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o644); err != nil { //nolint:mnd,gosec // default file permissions
+		return errors.Wrap(err, "error write checkpoint")
+	}
+	if err = Sync(tmp); err != nil {
+		return err
+	}
+	return errors.Wrap(os.Rename(tmp, path), "error replace checkpoint")
+}
+
+// PartName returns the name of the n-th output part, e.g. data.part-00001.parquet.
+func PartName(output string, n int) string {
+	ext := filepath.Ext(output)
+	return fmt.Sprintf("%s.part-%05d%s", strings.TrimSuffix(output, ext), n, ext)
+}
+
+// Sync flushes a closed file to stable storage.
+func Sync(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "error open "+path)
+	}
+	if err = f.Sync(); err != nil {
+		_ = f.Close()
+		return errors.Wrap(err, "error sync "+path)
+	}
+	return f.Close()
+}
@@ -0,0 +1,158 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestPartName(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		n      int
+		want   string
+	}{
+		{"first part", "data.parquet", 0, "data.part-00000.parquet"},
+		{"with dir", "out/data.parquet", 12, "out/data.part-00012.parquet"},
+		{"no extension", "data", 1, "data.part-00001"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := PartName(tt.output, tt.n)
+			if got != tt.want {
+				t.Errorf("PartName(%q, %d) = %q; want %q", tt.output, tt.n, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckpointSaveLoad(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.csv")
+	output := filepath.Join(dir, "out.parquet")
+	if err := os.WriteFile(input, []byte("a,b\n1,2\n"), 0o600); err != nil {
+		t.Fatalf("Failed to create input: %v", err)
+	}
+
+	cp, err := LoadCheckpoint(dir)
+	if err != nil || cp != nil {
+		t.Fatalf("LoadCheckpoint() on empty dir = %v, %v; want nil, nil", cp, err)
+	}
+
+	cp, err = NewCheckpoint(input, output)
+	if err != nil {
+		t.Fatalf("NewCheckpoint() error: %v", err)
+	}
+	cp.Header = []string{"a", "b"}
+	part := PartName(output, 0)
+	if err = os.WriteFile(part, []byte("PAR1"), 0o600); err != nil {
+		t.Fatalf("Failed to create part: %v", err)
+	}
+	sum := PartSum{Rows: 1, Checksum: "abc"}
+	if err = cp.Commit(dir, part, sum, 8, 2, 2, 0); err != nil {
+		t.Fatalf("Commit() error: %v", err)
+	}
+
+	got, err := LoadCheckpoint(dir)
+	if err != nil {
+		t.Fatalf("LoadCheckpoint() error: %v", err)
+	}
+	if got.Offset != 8 || got.Line != 2 || got.Row != 2 || got.BatchID != 0 {
+		t.Errorf("LoadCheckpoint() position = %d/%d/%d/%d; want 8/2/2/0", got.Offset, got.Line, got.Row, got.BatchID)
+	}
+	if !reflect.DeepEqual(got.Parts, []string{part}) || !reflect.DeepEqual(got.Header, cp.Header) {
+		t.Errorf("LoadCheckpoint() = %+v; want parts %v and header %v", got, []string{part}, cp.Header)
+	}
+	if !reflect.DeepEqual(got.Sums, []PartSum{sum}) {
+		t.Errorf("LoadCheckpoint() sums = %+v; want %+v", got.Sums, []PartSum{sum})
+	}
+	if err = got.Validate(input, output); err != nil {
+		t.Errorf("Validate() error: %v", err)
+	}
+	if err = got.Validate(input, "other.parquet"); err == nil {
+		t.Errorf("Validate() with other output should fail")
+	}
+	if err = os.Remove(part); err != nil {
+		t.Fatalf("Failed to remove part: %v", err)
+	}
+	if err = got.Validate(input, output); err == nil {
+		t.Errorf("Validate() with missing part should fail")
+	}
+}
+
+func TestBatchProcessorResume(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString("id,note\n")
+	for i := range 10 {
+		sb.WriteString(strconv.Itoa(i) + ",\"line\n" + strconv.Itoa(i) + "\"\n")
+	}
+	input := filepath.Join(t.TempDir(), "in.csv")
+	if err := os.WriteFile(input, []byte(sb.String()), 0o600); err != nil {
+		t.Fatalf("Failed to create input: %v", err)
+	}
+
+	read := func(bp *BatchProcessor) []Batch {
+		var batches []Batch
+		bCh, eCh := bp.Reader()
+		for b := range bCh {
+			batches = append(batches, b)
+		}
+		if err := <-eCh; err != nil {
+			t.Fatalf("Reader() error: %v", err)
+		}
+		return batches
+	}
+
+	full := read(NewBatchProcessor(input, 4, ",", false))
+	if len(full) != 3 {
+		t.Fatalf("Reader() returned %d batches; want 3", len(full))
+	}
+	if full[0].Line != 7 || full[0].Start != 1 {
+		t.Errorf("first batch Line/Start = %d/%d; want 7/1", full[0].Line, full[0].Start)
+	}
+
+	first := full[0]
+	resumed := read(NewBatchProcessor(input, 4, ",", false).
+		Resume(first.Offset, first.Line, first.Start+len(first.Rows)-1, first.Id+1))
+	if !reflect.DeepEqual(resumed, full[1:]) {
+		t.Errorf("resumed batches = %+v; want %+v", resumed, full[1:])
+	}
+}
+
+func TestBadRowWriterResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.csv")
+	w, err := NewBadRowWriter(path, 0)
+	if err != nil {
+		t.Fatalf("NewBadRowWriter() error: %v", err)
+	}
+	_ = w.Write(BadRow{Line: 2, Raw: "1,2,3"})
+	size, err := w.Sync()
+	if err != nil || size != 6 {
+		t.Fatalf("Sync() = %d, %v; want 6", size, err)
+	}
+	// rejected after the checkpoint, rejected again on resume
+	_ = w.Write(BadRow{Line: 9, Raw: "x"})
+	if err = w.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+
+	if w, err = NewBadRowWriter(path, size); err != nil {
+		t.Fatalf("NewBadRowWriter() resume error: %v", err)
+	}
+	_ = w.Write(BadRow{Line: 9, Raw: "x"})
+	if err = w.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "1,2,3\nx\n" {
+		t.Errorf("bad rows = %q; want the row after the checkpoint once", data)
+	}
+
+	if _, err = NewBadRowWriter(path, 100); err == nil {
+		t.Error("NewBadRowWriter() of a file shorter than the checkpoint: no error")
+	}
+}
@@ -0,0 +1,488 @@
+package file
+
+import (
+	"bufio"
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	QuoteMinimal = "minimal"
+	QuoteAll     = "all"
+	QuoteNever   = "never"
+)
+
+//nolint:gochecknoglobals // constant table
+var delimiterNames = map[string]string{
+	"tab":       "\t",
+	"comma":     ",",
+	"semicolon": ";",
+	"pipe":      "|",
+	"space":     " ",
+}
+
+// ParseDelimiter turns a delimiter spec into the delimiter: a name like tab or pipe,
+// an escape like \t, \x1f or \u00a6, or the characters themselves such as ¦ or ~|~.
+func ParseDelimiter(spec string) (string, error) {
+	if d, ok := delimiterNames[strings.ToLower(spec)]; ok {
+		return d, nil
+	}
+	if len(spec) > 1 && strings.Contains(spec, `\`) {
+		d, err := strconv.Unquote(`"` + strings.ReplaceAll(spec, `"`, `\"`) + `"`)
+		if err != nil {
+			return "", errors.New("invalid escape in delimiter " + spec)
+		}
+		spec = d
+	}
+	if spec == "" {
+		return "", errors.New("delimiter can't be empty")
+	}
+	return spec, nil
+}
+
+// CSVDialect is the csv flavour of a file besides its delimiter, the zero value is RFC 4180 in utf-8.
+// Quote is '"' when zero, Escape zero means a quote inside a quoted field is doubled.
+type CSVDialect struct {
+	Quote    rune
+	Escape   rune
+	Encoding string
+	// input
+	Comment          rune
+	LazyQuotes       bool
+	TrimLeadingSpace bool
+	FieldsPerRecord  int
+	// NoHeader means the first row is data, the columns are named by ColumnNames
+	NoHeader bool
+	// output
+	QuoteMode string
+	CRLF      bool
+	BOM       bool
+}
+
+// ColumnNames are the names of n columns of a file without a header: column_1, column_2 and so on.
+func ColumnNames(n int) []string {
+	names := make([]string, n)
+	for i := range names {
+		names[i] = "column_" + strconv.Itoa(i+1)
+	}
+	return names
+}
+
+// Validate checks the dialect can be used with the delimiter, which may be several characters.
+func (d CSVDialect) Validate(comma string) error {
+	quote := d.quote()
+	switch {
+	case comma == "":
+		return errors.New("delimiter can't be empty")
+	case strings.ContainsRune(comma, quote):
+		return errors.New("quote character can't be in the delimiter")
+	case d.Escape != 0 && strings.ContainsRune(comma, d.Escape):
+		return errors.New("escape character can't be in the delimiter")
+	case d.Comment != 0 && (strings.ContainsRune(comma, d.Comment) || d.Comment == quote):
+		return errors.New("comment character can't be in the delimiter or the quote")
+	case strings.IndexFunc(comma, func(r rune) bool { return !validCSVRune(r) }) >= 0 ||
+		!validCSVRune(quote) || d.Escape != 0 && !validCSVRune(d.Escape):
+		return errors.New("delimiter, quote and escape must be printable characters other than a newline")
+	}
+	switch d.QuoteMode {
+	case "", QuoteMinimal, QuoteAll, QuoteNever:
+	default:
+		return errors.New("unknown quote style " + d.QuoteMode + ", use minimal, all or never")
+	}
+	return nil
+}
+
+func validCSVRune(r rune) bool {
+	return r != '\r' && r != '\n' && r != utf8.RuneError && (r == '\t' || unicode.IsPrint(r))
+}
+
+func (d CSVDialect) quote() rune {
+	if d.Quote == 0 {
+		return '"'
+	}
+	return d.Quote
+}
+
+func (d CSVDialect) escape() rune {
+	if d.Escape == d.quote() {
+		return 0
+	}
+	return d.Escape
+}
+
+// standard reports whether encoding/csv can read and write the dialect with the delimiter.
+func (d CSVDialect) standard(comma string) bool {
+	return utf8.RuneCountInString(comma) == 1 && d.quote() == '"' && d.escape() == 0
+}
+
+// csvRecordReader is the part of csv.Reader the batch processor needs.
+type csvRecordReader interface {
+	Read() ([]string, error)
+	FieldPos(field int) (line, column int)
+	InputOffset() int64
+}
+
+func newCSVRecordReader(r io.Reader, comma string, d CSVDialect) csvRecordReader {
+	if d.standard(comma) {
+		reader := csv.NewReader(r)
+		reader.Comma, _ = utf8.DecodeRuneInString(comma)
+		reader.Comment = d.Comment
+		reader.LazyQuotes = d.LazyQuotes
+		reader.TrimLeadingSpace = d.TrimLeadingSpace
+		reader.FieldsPerRecord = d.FieldsPerRecord
+		return reader
+	}
+	return &dialectReader{r: bufio.NewReader(r), comma: comma, quote: d.quote(), escape: d.escape(), d: d, line: 1, column: 1}
+}
+
+// dialectReader reads csv with another quote character, an escape character or a delimiter
+// of several characters, it follows encoding/csv in everything else.
+type dialectReader struct {
+	r      *bufio.Reader
+	comma  string
+	quote  rune
+	escape rune
+	d      CSVDialect
+	offset int64
+	line   int
+	column int
+	lines  []int
+	// pending is a rune given back by unread
+	pending     rune
+	pendingSize int
+}
+
+// next returns the next rune, \r\n is returned as \n.
+func (r *dialectReader) next() (rune, error) {
+	if r.pendingSize > 0 {
+		ch := r.pending
+		r.offset += int64(r.pendingSize)
+		r.pendingSize = 0
+		r.column++
+		return ch, nil
+	}
+	ch, size, err := r.r.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	r.offset += int64(size)
+	if ch == '\r' {
+		if b, _ := r.r.Peek(1); len(b) == 1 && b[0] == '\n' {
+			_, _ = r.r.ReadByte()
+			r.offset++
+			ch = '\n'
+		}
+	}
+	if ch == '\n' {
+		r.line++
+		r.column = 1
+	} else {
+		r.column++
+	}
+	return ch, nil
+}
+
+// unread gives back a rune other than a newline.
+func (r *dialectReader) unread(ch rune) {
+	r.pending = ch
+	r.pendingSize = utf8.RuneLen(ch)
+	r.offset -= int64(r.pendingSize)
+	r.column--
+}
+
+// atComma reports whether the delimiter starts with ch and the input after it.
+func (r *dialectReader) atComma(ch rune) bool {
+	first, size := utf8.DecodeRuneInString(r.comma)
+	if ch != first {
+		return false
+	}
+	rest := r.comma[size:]
+	b, _ := r.r.Peek(len(rest))
+	return string(b) == rest
+}
+
+// isComma is atComma that also consumes the rest of the delimiter.
+func (r *dialectReader) isComma(ch rune) bool {
+	if !r.atComma(ch) {
+		return false
+	}
+	_, size := utf8.DecodeRuneInString(r.comma)
+	rest := r.comma[size:]
+	_, _ = r.r.Discard(len(rest))
+	r.offset += int64(len(rest))
+	r.column += utf8.RuneCountInString(rest)
+	return true
+}
+
+func (r *dialectReader) parseError(start int, err error) error {
+	return &csv.ParseError{StartLine: start, Line: r.line, Column: r.column, Err: err}
+}
+
+func (r *dialectReader) Read() ([]string, error) {
+	// skip empty lines and comments
+	for {
+		ch, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+		if ch == '\n' {
+			continue
+		}
+		if r.d.Comment != 0 && ch == r.d.Comment {
+			for ch != '\n' {
+				if ch, err = r.next(); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+		r.unread(ch)
+		break
+	}
+
+	start := r.line
+	record := make([]string, 0, max(r.d.FieldsPerRecord, 1))
+	r.lines = r.lines[:0]
+	var field strings.Builder
+	for done := false; !done; {
+		field.Reset()
+		r.lines = append(r.lines, r.line)
+		ch, err := r.next()
+		if r.d.TrimLeadingSpace {
+			for err == nil && !r.atComma(ch) && ch != '\n' && unicode.IsSpace(ch) {
+				ch, err = r.next()
+			}
+		}
+		if err == nil && ch == r.quote {
+			done, err = r.quoted(&field, start)
+		} else {
+			done, err = r.unquoted(&field, ch, err, start)
+		}
+		if err != nil {
+			return nil, err
+		}
+		record = append(record, field.String())
+	}
+
+	if r.d.FieldsPerRecord == 0 {
+		r.d.FieldsPerRecord = len(record)
+	} else if r.d.FieldsPerRecord > 0 && len(record) != r.d.FieldsPerRecord {
+		return record, &csv.ParseError{StartLine: start, Line: start, Column: 1, Err: csv.ErrFieldCount}
+	}
+	return record, nil
+}
+
+// quoted reads a quoted field after its opening quote, done is set at the end of the record.
+func (r *dialectReader) quoted(field *strings.Builder, start int) (done bool, err error) {
+	for {
+		ch, err := r.next()
+		if errors.Is(err, io.EOF) {
+			if r.d.LazyQuotes {
+				return true, nil
+			}
+			return false, r.parseError(start, csv.ErrQuote)
+		}
+		if err != nil {
+			return false, err
+		}
+		switch {
+		case r.escape != 0 && ch == r.escape:
+			next, nextErr := r.next()
+			if nextErr != nil {
+				field.WriteRune(ch)
+				continue
+			}
+			field.WriteRune(next)
+		case ch == r.quote:
+			next, nextErr := r.next()
+			switch {
+			case errors.Is(nextErr, io.EOF), nextErr == nil && next == '\n':
+				return true, nil
+			case nextErr != nil:
+				return false, nextErr
+			case r.isComma(next):
+				return false, nil
+			case next == r.quote && r.escape == 0:
+				field.WriteRune(r.quote)
+			case r.d.LazyQuotes:
+				field.WriteRune(ch)
+				r.unread(next)
+			default:
+				return false, r.parseError(start, csv.ErrQuote)
+			}
+		default:
+			field.WriteRune(ch)
+		}
+	}
+}
+
+// unquoted reads a field starting with ch, the escape character makes the next one literal.
+func (r *dialectReader) unquoted(field *strings.Builder, ch rune, err error, start int) (done bool, _ error) {
+	for {
+		switch {
+		case errors.Is(err, io.EOF):
+			return true, nil
+		case err != nil:
+			return false, err
+		case r.isComma(ch):
+			return false, nil
+		case ch == '\n':
+			return true, nil
+		case r.escape != 0 && ch == r.escape:
+			next, nextErr := r.next()
+			if nextErr != nil {
+				field.WriteRune(ch)
+				return true, nil
+			}
+			field.WriteRune(next)
+		case ch == r.quote && !r.d.LazyQuotes:
+			return false, r.parseError(start, csv.ErrBareQuote)
+		default:
+			field.WriteRune(ch)
+		}
+		ch, err = r.next()
+	}
+}
+
+// FieldPos is the line a field of the last record starts at, the column is not tracked.
+func (r *dialectReader) FieldPos(field int) (line, column int) {
+	return r.lines[field], 1
+}
+
+func (r *dialectReader) InputOffset() int64 {
+	return r.offset
+}
+
+// csvRecordWriter is the part of csv.Writer CSVWriter needs.
+type csvRecordWriter interface {
+	Write(record []string) error
+	Flush()
+	Error() error
+}
+
+func newCSVRecordWriter(w io.Writer, comma string, d CSVDialect) csvRecordWriter {
+	if d.standard(comma) && (d.QuoteMode == "" || d.QuoteMode == QuoteMinimal) {
+		writer := csv.NewWriter(w)
+		writer.Comma, _ = utf8.DecodeRuneInString(comma)
+		writer.UseCRLF = d.CRLF
+		return writer
+	}
+	return &dialectWriter{w: bufio.NewWriter(w), comma: comma, quote: d.quote(), escape: d.escape(), d: d}
+}
+
+// dialectWriter writes csv with another quote character, escape character, quote style
+// or a delimiter of several characters.
+type dialectWriter struct {
+	w      *bufio.Writer
+	comma  string
+	quote  rune
+	escape rune
+	d      CSVDialect
+	err    error
+}
+
+func (w *dialectWriter) Write(record []string) error {
+	for i, field := range record {
+		if i > 0 {
+			_, _ = w.w.WriteString(w.comma)
+		}
+		switch {
+		case w.d.QuoteMode == QuoteAll || w.d.QuoteMode != QuoteNever && w.needsQuotes(field):
+			w.writeQuoted(field)
+		case w.escape != 0:
+			// never quoted, special characters are escaped instead
+			for i, ch := range field {
+				if strings.HasPrefix(field[i:], w.comma) || ch == w.quote || ch == w.escape || ch == '\n' || ch == '\r' {
+					_, _ = w.w.WriteRune(w.escape)
+				}
+				_, _ = w.w.WriteRune(ch)
+			}
+		default:
+			_, _ = w.w.WriteString(field)
+		}
+	}
+	var err error
+	if w.d.CRLF {
+		_, err = w.w.WriteString("\r\n")
+	} else {
+		err = w.w.WriteByte('\n')
+	}
+	return err
+}
+
+func (w *dialectWriter) writeQuoted(field string) {
+	_, _ = w.w.WriteRune(w.quote)
+	for _, ch := range field {
+		switch {
+		case w.escape != 0 && (ch == w.quote || ch == w.escape):
+			_, _ = w.w.WriteRune(w.escape)
+		case w.escape == 0 && ch == w.quote:
+			_, _ = w.w.WriteRune(w.quote)
+		case ch == '\n' && w.d.CRLF:
+			_ = w.w.WriteByte('\r')
+		}
+		_, _ = w.w.WriteRune(ch)
+	}
+	_, _ = w.w.WriteRune(w.quote)
+}
+
+// needsQuotes follows csv.Writer: fields with the delimiter, quote, escape, a line break
+// or a leading space are quoted.
+func (w *dialectWriter) needsQuotes(field string) bool {
+	if field == "" {
+		return false
+	}
+	if field == `\.` || strings.Contains(field, w.comma) {
+		return true
+	}
+	for _, ch := range field {
+		if ch == w.quote || ch == w.escape && w.escape != 0 || ch == '\n' || ch == '\r' {
+			return true
+		}
+	}
+	r, _ := utf8.DecodeRuneInString(field)
+	return unicode.IsSpace(r)
+}
+
+func (w *dialectWriter) Flush() {
+	if err := w.w.Flush(); err != nil && w.err == nil {
+		w.err = err
+	}
+}
+
+func (w *dialectWriter) Error() error {
+	return w.err
+}
+
+// FitRecord pads a row shorter than the header with empty fields, rows only differ in length
+// with FieldsPerRecord -1. Longer rows may only have empty extra fields.
+func FitRecord(record []string, n int) ([]string, error) {
+	if len(record) > n {
+		for _, v := range record[n:] {
+			if v != "" {
+				return nil, errors.Errorf("row has %d fields, header %d", len(record), n)
+			}
+		}
+		return record[:n], nil
+	}
+	for len(record) < n {
+		record = append(record, "")
+	}
+	return record, nil
+}
+
+// FormatRecord is the csv line of a record without the line ending, e.g. for a bad rows file.
+func FormatRecord(record []string, comma string, d CSVDialect) string {
+	var b strings.Builder
+	d.CRLF = false
+	w := newCSVRecordWriter(&b, comma, d)
+	_ = w.Write(record)
+	w.Flush()
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,236 @@
+package file
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func readAllCSV(r csvRecordReader) ([][]string, error) {
+	var records [][]string
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return records, err
+		}
+		records = append(records, record)
+	}
+}
+
+func TestCSVRecordReader(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		comma   string
+		dialect CSVDialect
+		want    [][]string
+		wantErr error
+	}{
+		{
+			"standard with comments", "# note\na,b\n\"x,\"\"y\",z\r\n", ",",
+			CSVDialect{Comment: '#'},
+			[][]string{{"a", "b"}, {`x,"y`, "z"}}, nil,
+		},
+		{
+			"single quote", "a;'b;c'\n'd''e';'f\ng'\n", ";",
+			CSVDialect{Quote: '\''},
+			[][]string{{"a", "b;c"}, {"d'e", "f\ng"}}, nil,
+		},
+		{
+			"backslash escape", "\"a\\\"b\",c\\,d\r\n\"\\\\\",\n", ",",
+			CSVDialect{Escape: '\\'},
+			[][]string{{`a"b`, "c,d"}, {`\`, ""}}, nil,
+		},
+		{
+			"trim and comment", "# x\n a,  'b'\n\n", ",",
+			CSVDialect{Quote: '\'', TrimLeadingSpace: true, Comment: '#'},
+			[][]string{{"a", "b"}}, nil,
+		},
+		{
+			"lazy quotes", "'a'b',c'd\n", ",",
+			CSVDialect{Quote: '\'', LazyQuotes: true},
+			[][]string{{"a'b", "c'd"}}, nil,
+		},
+		{
+			"bare quote", "a,b'c\n", ",",
+			CSVDialect{Quote: '\''},
+			nil, csv.ErrBareQuote,
+		},
+		{
+			"missing quote", "'a,b\n", ",",
+			CSVDialect{Quote: '\''},
+			nil, csv.ErrQuote,
+		},
+		{
+			"field count", "a,b\nc\n", ",",
+			CSVDialect{Quote: '\''},
+			[][]string{{"a", "b"}}, csv.ErrFieldCount,
+		},
+		{
+			"any field count", "a,b\nc\n", ",",
+			CSVDialect{Quote: '\'', FieldsPerRecord: -1},
+			[][]string{{"a", "b"}, {"c"}}, nil,
+		},
+		{
+			"multi-character", "a||b|c||\"d||e\"\n|x||\n", "||",
+			CSVDialect{FieldsPerRecord: -1},
+			[][]string{{"a", "b|c", "d||e"}, {"|x", ""}}, nil,
+		},
+		{
+			"multi-character escaped", "a~|~b\\~|~c~|\n", "~|~",
+			CSVDialect{Escape: '\\', TrimLeadingSpace: true},
+			[][]string{{"a", "b~|~c~|"}}, nil,
+		},
+		{
+			"unicode", "a¦b\n\"¦\"¦c\n", "¦",
+			CSVDialect{},
+			[][]string{{"a", "b"}, {"¦", "c"}}, nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newCSVRecordReader(strings.NewReader(tt.input), tt.comma, tt.dialect)
+			got, err := readAllCSV(r)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Read() error = %v; want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("records = %q; want %q", got, tt.want)
+			}
+			if tt.wantErr == nil && r.InputOffset() != int64(len(tt.input)) {
+				t.Errorf("InputOffset() = %d; want %d", r.InputOffset(), len(tt.input))
+			}
+		})
+	}
+}
+
+func TestCSVRecordWriter(t *testing.T) {
+	records := [][]string{{"a", "b,c", `d"e`}, {"", " f", "g\nh"}}
+	tests := []struct {
+		name    string
+		dialect CSVDialect
+		want    string
+	}{
+		{"standard", CSVDialect{}, "a,\"b,c\",\"d\"\"e\"\n,\" f\",\"g\nh\"\n"},
+		{"crlf", CSVDialect{CRLF: true}, "a,\"b,c\",\"d\"\"e\"\r\n,\" f\",\"g\r\nh\"\r\n"},
+		{"quote all", CSVDialect{QuoteMode: QuoteAll}, "\"a\",\"b,c\",\"d\"\"e\"\n\"\",\" f\",\"g\nh\"\n"},
+		{"never", CSVDialect{QuoteMode: QuoteNever}, "a,b,c,d\"e\n, f,g\nh\n"},
+		{"never escaped", CSVDialect{QuoteMode: QuoteNever, Escape: '\\'}, "a,b\\,c,d\\\"e\n, f,g\\\nh\n"},
+		{"single quote backslash", CSVDialect{Quote: '\'', Escape: '\\'}, "a,'b,c',d\"e\n,' f','g\nh'\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := newCSVRecordWriter(&buf, ",", tt.dialect)
+			for _, record := range records {
+				if err := w.Write(record); err != nil {
+					t.Fatalf("Write() error: %v", err)
+				}
+			}
+			w.Flush()
+			if err := w.Error(); err != nil {
+				t.Fatalf("Flush() error: %v", err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("output = %q; want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestCSVRecordWriterDelimiter(t *testing.T) {
+	tests := []struct {
+		name    string
+		comma   string
+		dialect CSVDialect
+		want    string
+	}{
+		{"unicode", "¦", CSVDialect{}, "a¦\"b¦c\"¦d||e\n"},
+		{"multi-character", "||", CSVDialect{}, "a||b¦c||\"d||e\"\n"},
+		{"multi-character escaped", "||", CSVDialect{QuoteMode: QuoteNever, Escape: '\\'}, "a||b¦c||d\\||e\n"},
+	}
+	record := []string{"a", "b¦c", "d||e"}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := newCSVRecordWriter(&buf, tt.comma, tt.dialect)
+			if err := w.Write(record); err != nil {
+				t.Fatalf("Write() error: %v", err)
+			}
+			w.Flush()
+			if buf.String() != tt.want {
+				t.Errorf("output = %q; want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDelimiter(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    string
+		wantErr bool
+	}{
+		{",", ",", false},
+		{"tab", "\t", false},
+		{"PIPE", "|", false},
+		{`\t`, "\t", false},
+		{`\u00a6`, "¦", false},
+		{`\x1f`, "\x1f", false},
+		{"¦", "¦", false},
+		{"~|~", "~|~", false},
+		{`\`, `\`, false},
+		{"", "", true},
+		{`\q`, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseDelimiter(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDelimiter() error = %v; wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseDelimiter() = %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSVDialectValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect CSVDialect
+		comma   string
+		wantErr bool
+	}{
+		{"default", CSVDialect{}, ",", false},
+		{"multi-character", CSVDialect{}, "~|~", false},
+		{"empty", CSVDialect{}, "", true},
+		{"quote is comma", CSVDialect{Quote: ';'}, ";", true},
+		{"quote in comma", CSVDialect{}, `|"|`, true},
+		{"escape is comma", CSVDialect{Escape: ','}, ",", true},
+		{"comment is quote", CSVDialect{Comment: '"'}, ",", true},
+		{"newline quote", CSVDialect{Quote: '\n'}, ",", true},
+		{"newline in comma", CSVDialect{}, "|\n", true},
+		{"unknown quote style", CSVDialect{QuoteMode: "some"}, ",", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.dialect.Validate(tt.comma); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v; wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+const (
+	EncodingAuto = "auto"
+	EncodingUTF8 = "utf-8"
+)
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF} //nolint:gochecknoglobals // constant bytes
+
+// lookupEncoding returns the encoding of a WHATWG name or label like windows-1251, cp1251,
+// latin1 or utf-16le, nil for utf-8.
+func lookupEncoding(name string) (encoding.Encoding, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || name == EncodingUTF8 || name == "utf8" {
+		return nil, nil //nolint:nilnil // utf-8 needs no conversion
+	}
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, errors.New("unknown encoding " + name)
+	}
+	if enc == unicode.UTF8 {
+		return nil, nil //nolint:nilnil // utf-8 needs no conversion
+	}
+	return enc, nil
+}
+
+// DetectEncoding resolves EncodingAuto by the byte order mark of the file: utf-16le, utf-16be or utf-8.
+// Other names are checked and returned as they are.
+func DetectEncoding(path, name string) (string, error) {
+	if !strings.EqualFold(name, EncodingAuto) {
+		return name, CheckEncoding(name)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", errors.Wrap(err, "error opening file "+path)
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+	bom := make([]byte, 2) //nolint:mnd // utf-16 bom length
+	n, _ := io.ReadFull(f, bom)
+	return BOMEncoding(bom[:n]), nil
+}
+
+// BOMEncoding is the encoding of text starting with b: utf-16le, utf-16be or utf-8.
+func BOMEncoding(b []byte) string {
+	switch {
+	case len(b) >= 2 && b[0] == 0xFF && b[1] == 0xFE:
+		return "utf-16le"
+	case len(b) >= 2 && b[0] == 0xFE && b[1] == 0xFF:
+		return "utf-16be"
+	}
+	return EncodingUTF8
+}
+
+// CheckEncoding returns an error for an unknown encoding name.
+func CheckEncoding(name string) error {
+	_, err := lookupEncoding(name)
+	return err
+}
+
+// IsUTF8 reports whether the encoding name is utf-8, the only one input offsets are file offsets for.
+func IsUTF8(name string) bool {
+	enc, err := lookupEncoding(name)
+	return err == nil && enc == nil
+}
+
+// textReader decodes r from the named encoding to utf-8 and drops a byte order mark at the start.
+// skipped is the number of bytes of a utf-8 byte order mark dropped from the input.
+func textReader(r io.Reader, name string, atStart bool) (_ io.Reader, skipped int64, err error) {
+	enc, err := lookupEncoding(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	if enc != nil {
+		r = transform.NewReader(r, enc.NewDecoder())
+	}
+	if !atStart {
+		return r, 0, nil
+	}
+	br := bufio.NewReader(r)
+	if b, _ := br.Peek(len(utf8BOM)); bytes.Equal(b, utf8BOM) {
+		_, _ = br.Discard(len(utf8BOM))
+		if enc == nil {
+			skipped = int64(len(utf8BOM))
+		}
+	}
+	return br, skipped, nil
+}
+
+// textWriter encodes utf-8 written to w in the named encoding, with a byte order mark first if bom is set.
+// Close flushes the encoder but doesn't close w.
+func textWriter(w io.Writer, name string, bom bool) (io.WriteCloser, error) {
+	enc, err := lookupEncoding(name)
+	if err != nil {
+		return nil, err
+	}
+	var out io.WriteCloser = nopWriteCloser{w}
+	if enc != nil {
+		out = transform.NewWriter(w, enc.NewEncoder())
+	}
+	if bom {
+		if enc != nil && !isUnicode(enc) {
+			return nil, errors.New("byte order mark needs a unicode output encoding")
+		}
+		if _, err = out.Write(utf8BOM); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func isUnicode(enc encoding.Encoding) bool {
+	name, _ := htmlindex.Name(enc)
+	return strings.HasPrefix(name, "utf-")
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,111 @@
+package file
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectEncoding(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		enc     string
+		want    string
+		wantErr bool
+	}{
+		{name: "utf-8", data: []byte("a,b\n"), enc: EncodingAuto, want: EncodingUTF8},
+		{name: "utf-8 bom", data: []byte("\xEF\xBB\xBFa,b\n"), enc: EncodingAuto, want: EncodingUTF8},
+		{name: "utf-16le", data: []byte{0xFF, 0xFE, 'a', 0}, enc: EncodingAuto, want: "utf-16le"},
+		{name: "utf-16be", data: []byte{0xFE, 0xFF, 0, 'a'}, enc: EncodingAuto, want: "utf-16be"},
+		{name: "empty", enc: EncodingAuto, want: EncodingUTF8},
+		{name: "explicit", data: []byte{0xFF, 0xFE}, enc: "windows-1251", want: "windows-1251"},
+		{name: "unknown", enc: "klingon", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "in.csv")
+			if err := os.WriteFile(path, tt.data, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			got, err := DetectEncoding(path, tt.enc)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DetectEncoding() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("DetectEncoding() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTextReader(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		enc     string
+		atStart bool
+		want    string
+		skipped int64
+	}{
+		{name: "utf-8", data: []byte("имя\n"), enc: EncodingUTF8, atStart: true, want: "имя\n"},
+		{name: "utf-8 bom", data: []byte("\xEF\xBB\xBFname\n"), enc: EncodingUTF8, atStart: true, want: "name\n", skipped: 3},
+		{name: "bom kept after start", data: []byte("\xEF\xBB\xBFname\n"), enc: EncodingUTF8, want: "\xEF\xBB\xBFname\n"},
+		{name: "windows-1251", data: []byte{0xE8, 0xEC, 0xFF, '\n'}, enc: "windows-1251", atStart: true, want: "имя\n"},
+		{name: "utf-16le bom", data: []byte{0xFF, 0xFE, 'a', 0, ',', 0, 0x16, 0x04}, enc: "utf-16le", atStart: true, want: "a,Ж"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, skipped, err := textReader(bytes.NewReader(tt.data), tt.enc, tt.atStart)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want || skipped != tt.skipped {
+				t.Errorf("textReader() = %q, %d, want %q, %d", got, skipped, tt.want, tt.skipped)
+			}
+		})
+	}
+}
+
+func TestTextWriter(t *testing.T) {
+	tests := []struct {
+		name    string
+		enc     string
+		bom     bool
+		want    []byte
+		wantErr bool
+	}{
+		{name: "utf-8", enc: EncodingUTF8, want: []byte("имя")},
+		{name: "utf-8 bom", enc: EncodingUTF8, bom: true, want: []byte("\xEF\xBB\xBFимя")},
+		{name: "windows-1251", enc: "cp1251", want: []byte{0xE8, 0xEC, 0xFF}},
+		{name: "utf-16le bom", enc: "utf-16le", bom: true, want: []byte{0xFF, 0xFE, 0x38, 0x04, 0x3C, 0x04, 0x4F, 0x04}},
+		{name: "bom needs unicode", enc: "windows-1251", bom: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := textWriter(&buf, tt.enc, tt.bom)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("textWriter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if _, err = w.Write([]byte("имя")); err != nil {
+				t.Fatal(err)
+			}
+			if err = w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(buf.Bytes(), tt.want) {
+				t.Errorf("textWriter() wrote % x, want % x", buf.Bytes(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package file
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bytedance/sonic"
+	"github.com/dbunt1tled/parquet2csv/internal/schema"
+	"github.com/pkg/errors"
+)
+
+// FixedWidthColumn is a column of a fixed-width record, Start is the 1-based character position.
+// Trim is right (the default for strings), left, both or none, other types are always trimmed.
+type FixedWidthColumn struct {
+	Name   string
+	Start  int
+	Length int
+	Type   schema.TextType
+	Trim   string
+}
+
+// FixedWidthLayout describes the columns of a fixed-width file, every record is RecordLength characters.
+type FixedWidthLayout struct {
+	Columns      []FixedWidthColumn
+	RecordLength int
+}
+
+// FixedWidthSpec is a column of a layout as written, Type is a type name like decimal(9,2).
+type FixedWidthSpec struct {
+	Name   string `json:"name"`
+	Start  int    `json:"start"`
+	Length int    `json:"length"`
+	Type   string `json:"type"`
+	Trim   string `json:"trim"`
+}
+
+// ReadFixedWidthSpecs reads the columns of a layout json file as written.
+func ReadFixedWidthSpecs(path string) ([]FixedWidthSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading layout "+path)
+	}
+	var specs []FixedWidthSpec
+	if err = sonic.ConfigStd.Unmarshal(data, &specs); err != nil {
+		return nil, errors.Wrap(err, "error parsing layout "+path)
+	}
+	return specs, nil
+}
+
+// NewFixedWidthLayout checks the columns of a layout and parses their types.
+func NewFixedWidthLayout(specs []FixedWidthSpec) (*FixedWidthLayout, error) {
+	if len(specs) == 0 {
+		return nil, errors.New("layout has no columns")
+	}
+	layout := &FixedWidthLayout{}
+	names := map[string]bool{}
+	for n, spec := range specs {
+		c := FixedWidthColumn{Name: spec.Name, Start: spec.Start, Length: spec.Length, Trim: spec.Trim}
+		at := "column " + strconv.Itoa(n+1)
+		if c.Name == "" || names[c.Name] {
+			return nil, errors.New(at + ": empty or duplicate name " + c.Name)
+		}
+		names[c.Name] = true
+		if c.Start < 1 {
+			return nil, errors.New(at + ": start must be a position from 1")
+		}
+		if c.Length < 1 {
+			return nil, errors.New(at + ": length must be positive")
+		}
+		var err error
+		if c.Type, err = schema.ParseTextType(spec.Type); err != nil {
+			return nil, errors.Wrap(err, at)
+		}
+		switch c.Trim = strings.ToLower(c.Trim); c.Trim {
+		case "":
+			c.Trim = "right"
+		case "right", "left", "both", "none":
+		default:
+			return nil, errors.New(at + ": unknown trim " + c.Trim + ", use right, left, both or none")
+		}
+		layout.Columns = append(layout.Columns, c)
+		layout.RecordLength = max(layout.RecordLength, c.Start+c.Length-1)
+	}
+	return layout, nil
+}
+
+// Names are the column names in layout order.
+func (l *FixedWidthLayout) Names() []string {
+	names := make([]string, 0, len(l.Columns))
+	for _, c := range l.Columns {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+// Types are the column types in layout order.
+func (l *FixedWidthLayout) Types() []schema.TextType {
+	types := make([]schema.TextType, 0, len(l.Columns))
+	for _, c := range l.Columns {
+		types = append(types, c.Type)
+	}
+	return types
+}
+
+// Split cuts a line into trimmed column values. Lines of another length or with values
+// that don't parse as their column type are rejected.
+func (l *FixedWidthLayout) Split(line string) ([]string, error) {
+	runes := []rune(line)
+	if len(runes) != l.RecordLength {
+		return nil, errors.New("record length " + strconv.Itoa(len(runes)) + ", want " + strconv.Itoa(l.RecordLength))
+	}
+	record := make([]string, len(l.Columns))
+	for i, c := range l.Columns {
+		value := string(runes[c.Start-1 : c.Start-1+c.Length])
+		switch {
+		case c.Type.Name != "string", c.Trim == "both":
+			value = strings.TrimSpace(value)
+		case c.Trim == "right":
+			value = strings.TrimRight(value, " ")
+		case c.Trim == "left":
+			value = strings.TrimLeft(value, " ")
+		}
+		if _, err := c.Type.Parse(value); err != nil {
+			return nil, errors.Wrap(err, "column "+c.Name)
+		}
+		record[i] = value
+	}
+	return record, nil
+}
+
+// FixedWidthProcessor reads a fixed-width file in batches like BatchProcessor,
+// lines that don't fit the layout are passed on in Batch.Rejected.
+type FixedWidthProcessor struct {
+	batchSize int
+	inputFile string
+	layout    *FixedWidthLayout
+	encoding  string
+	source    io.Reader
+	ctx       context.Context
+	offset    int64
+	line      int
+	row       int
+	batchID   int
+}
+
+func NewFixedWidthProcessor(inputFile string, layout *FixedWidthLayout, batchSize int) *FixedWidthProcessor {
+	return &FixedWidthProcessor{
+		batchSize: batchSize,
+		inputFile: inputFile,
+		layout:    layout,
+		ctx:       context.Background(),
+	}
+}
+
+// Encoding sets the character set of the file, utf-8 by default.
+func (fp *FixedWidthProcessor) Encoding(name string) *FixedWidthProcessor {
+	fp.encoding = name
+	return fp
+}
+
+// Source reads the text from r instead of opening the input file, r is read from the start
+// or, after Resume, from the resume offset on.
+func (fp *FixedWidthProcessor) Source(r io.Reader) *FixedWidthProcessor {
+	fp.source = r
+	return fp
+}
+
+// Context stops reading when ctx is done, the error of ctx is sent to the error channel.
+func (fp *FixedWidthProcessor) Context(ctx context.Context) *FixedWidthProcessor {
+	fp.ctx = ctx
+	return fp
+}
+
+// Resume continues reading after the given Batch.Offset, Batch.Line, last row and Batch.Id.
+func (fp *FixedWidthProcessor) Resume(offset int64, line, row, batchID int) *FixedWidthProcessor {
+	fp.offset = offset
+	fp.line = line
+	fp.row = row
+	fp.batchID = batchID
+	return fp
+}
+
+func (fp *FixedWidthProcessor) Reader() (batchChan chan Batch, errorChan chan error) {
+	batchChan = make(chan Batch, 2)
+	errorChan = make(chan error, 2)
+	go func() {
+		defer close(errorChan)
+		defer close(batchChan)
+		input := fp.source
+		if input == nil {
+			file, err := os.Open(fp.inputFile)
+			if err != nil {
+				errorChan <- errors.Wrap(err, "error opening file "+fp.inputFile)
+				return
+			}
+			defer func(file *os.File) {
+				_ = file.Close()
+			}(file)
+			if fp.offset > 0 {
+				if _, err = file.Seek(fp.offset, io.SeekStart); err != nil {
+					errorChan <- errors.Wrap(err, "error seeking file "+fp.inputFile)
+					return
+				}
+			}
+			input = file
+		}
+
+		text, skipped, err := textReader(input, fp.encoding, fp.offset == 0)
+		if err != nil {
+			errorChan <- err
+			return
+		}
+		reader := bufio.NewReader(text)
+		offset, line, row, batchID := fp.offset+skipped, fp.line, fp.row, fp.batchID
+		for eof := false; !eof; {
+			batch := Batch{Rows: make([][]string, 0, fp.batchSize), Start: row + 1, Id: batchID}
+			for len(batch.Rows) < fp.batchSize {
+				text, readErr := reader.ReadString('\n')
+				if readErr != nil && !errors.Is(readErr, io.EOF) {
+					errorChan <- errors.Wrap(readErr, "error reading line "+strconv.Itoa(line+1))
+					return
+				}
+				offset += int64(len(text))
+				if text != "" {
+					line++
+				}
+				if text = strings.TrimRight(text, "\r\n"); text != "" {
+					if !utf8.ValidString(text) {
+						batch.Rejected = append(batch.Rejected, BadRow{Line: line, Raw: text, Err: errors.New("invalid utf-8")})
+					} else if record, splitErr := fp.layout.Split(text); splitErr != nil {
+						batch.Rejected = append(batch.Rejected, BadRow{Line: line, Raw: text, Err: splitErr})
+					} else {
+						batch.Rows = append(batch.Rows, record)
+						batch.Lines = append(batch.Lines, line)
+					}
+				}
+				if readErr != nil {
+					eof = true
+					break
+				}
+			}
+			if len(batch.Rows) == 0 && len(batch.Rejected) == 0 {
+				break
+			}
+			row += len(batch.Rows)
+			batch.Offset, batch.Line = offset, line
+			select {
+			case batchChan <- batch:
+			case <-fp.ctx.Done():
+				errorChan <- fp.ctx.Err()
+				return
+			}
+			batchID++
+		}
+	}()
+	return batchChan, errorChan
+}
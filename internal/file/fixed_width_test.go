@@ -0,0 +1,133 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testLayout = `[
+  {"name": "id", "start": 1, "length": 3, "type": "int"},
+  {"name": "name", "start": 4, "length": 6, "trim": "both"},
+  {"name": "amount", "start": 10, "length": 6, "type": "decimal(5,2)"}
+]`
+
+// loadLayout reads the layout json file at path.
+func loadLayout(path string) (*FixedWidthLayout, error) {
+	specs, err := ReadFixedWidthSpecs(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewFixedWidthLayout(specs)
+}
+
+func TestNewFixedWidthLayout(t *testing.T) {
+	tests := []struct {
+		name    string
+		layout  string
+		wantErr string
+	}{
+		{"valid", testLayout, ""},
+		{"empty", `[]`, "no columns"},
+		{"duplicate name", `[{"name":"a","start":1,"length":1},{"name":"a","start":2,"length":1}]`, "duplicate"},
+		{"zero start", `[{"name":"a","start":0,"length":1}]`, "start"},
+		{"zero length", `[{"name":"a","start":1,"length":0}]`, "length"},
+		{"unknown type", `[{"name":"a","start":1,"length":1,"type":"money"}]`, "unknown type"},
+		{"unknown trim", `[{"name":"a","start":1,"length":1,"trim":"all"}]`, "unknown trim"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "layout.json")
+			if err := os.WriteFile(path, []byte(tt.layout), 0o600); err != nil {
+				t.Fatalf("Failed to create layout: %v", err)
+			}
+			layout, err := loadLayout(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("NewFixedWidthLayout() error = %v; want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewFixedWidthLayout() error: %v", err)
+			}
+			if layout.RecordLength != 15 || !reflect.DeepEqual(layout.Names(), []string{"id", "name", "amount"}) {
+				t.Errorf("layout = %d %v; want 15 [id name amount]", layout.RecordLength, layout.Names())
+			}
+			if layout.Columns[0].Trim != "right" {
+				t.Errorf("default trim = %q; want right", layout.Columns[0].Trim)
+			}
+		})
+	}
+}
+
+func TestFixedWidthProcessor(t *testing.T) {
+	dir := t.TempDir()
+	layoutPath := filepath.Join(dir, "layout.json")
+	input := filepath.Join(dir, "in.dat")
+	data := "001 Ann  012.50\r\n" +
+		"short\n" +
+		"\n" +
+		"002Zoë   -00.01\n" +
+		"00XBob   001.00\n" +
+		"003       \n" +
+		"003        9.99"
+	if err := os.WriteFile(layoutPath, []byte(testLayout), 0o600); err != nil {
+		t.Fatalf("Failed to create layout: %v", err)
+	}
+	if err := os.WriteFile(input, []byte(data), 0o600); err != nil {
+		t.Fatalf("Failed to create input: %v", err)
+	}
+	layout, err := loadLayout(layoutPath)
+	if err != nil {
+		t.Fatalf("NewFixedWidthLayout() error: %v", err)
+	}
+
+	bCh, eCh := NewFixedWidthProcessor(input, layout, 2).Reader()
+	var (
+		rows     [][]string
+		rejected []int
+		batches  []Batch
+	)
+	for batch := range bCh {
+		rows = append(rows, batch.Rows...)
+		for _, bad := range batch.Rejected {
+			rejected = append(rejected, bad.Line)
+		}
+		batches = append(batches, batch)
+	}
+	if err = <-eCh; err != nil {
+		t.Fatalf("Reader() error: %v", err)
+	}
+
+	wantRows := [][]string{{"001", "Ann", "012.50"}, {"002", "Zoë", "-00.01"}, {"003", "", "9.99"}}
+	if !reflect.DeepEqual(rows, wantRows) {
+		t.Errorf("rows = %q; want %q", rows, wantRows)
+	}
+	if want := []int{2, 5, 6}; !reflect.DeepEqual(rejected, want) {
+		t.Errorf("rejected lines = %v; want %v", rejected, want)
+	}
+	last := batches[len(batches)-1]
+	if last.Offset != int64(len(data)) || last.Line != 7 || last.Start+len(last.Rows)-1 != 3 {
+		t.Errorf("last batch offset %d, line %d, row %d; want %d, 7, 3",
+			last.Offset, last.Line, last.Start+len(last.Rows)-1, len(data))
+	}
+
+	// resuming after the first batch reads the same remaining rows
+	first := batches[0]
+	bCh, eCh = NewFixedWidthProcessor(input, layout, 2).
+		Resume(first.Offset, first.Line, first.Start+len(first.Rows)-1, first.Id+1).Reader()
+	var resumed [][]string
+	for batch := range bCh {
+		resumed = append(resumed, batch.Rows...)
+	}
+	if err = <-eCh; err != nil {
+		t.Fatalf("Reader() error: %v", err)
+	}
+	if !reflect.DeepEqual(resumed, wantRows[len(first.Rows):]) {
+		t.Errorf("resumed rows = %q; want %q", resumed, wantRows[len(first.Rows):])
+	}
+}
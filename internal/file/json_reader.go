@@ -0,0 +1,134 @@
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// JSONRecord is a json object of the input, Raw is its text on one line, Line the input line it starts on
+// and Offset the input byte offset right after it.
+type JSONRecord struct {
+	Value  map[string]any
+	Raw    string
+	Line   int
+	Offset int64
+}
+
+// ReadJSON decodes the records of a json array or of newline-delimited (or concatenated) json objects
+// one by one and passes every record to fn.
+func ReadJSON(path string, fn func(record JSONRecord) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "error opening file "+path)
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+
+	lines := &lineCounter{r: f}
+	br := bufio.NewReader(lines)
+	array, skipped, err := isJSONArray(br)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(br)
+	if array {
+		if _, err = decoder.Token(); err != nil {
+			return errors.Wrap(err, "error reading json array")
+		}
+	}
+
+	for n := 1; ; n++ {
+		if array && !decoder.More() {
+			break
+		}
+		var raw json.RawMessage
+		if err = decoder.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) && !array {
+				break
+			}
+			return errors.Wrap(err, "error reading record "+strconv.Itoa(n))
+		}
+		end := skipped + decoder.InputOffset()
+		line := lines.lineAt(end - int64(len(raw)))
+		var record any
+		d := json.NewDecoder(bytes.NewReader(raw))
+		d.UseNumber()
+		if err = d.Decode(&record); err != nil {
+			return errors.Wrap(err, "error reading record "+strconv.Itoa(n))
+		}
+		obj, ok := record.(map[string]any)
+		if !ok {
+			return errors.New("record " + strconv.Itoa(n) + " on line " + strconv.Itoa(line) + " is not a json object")
+		}
+		var compact bytes.Buffer
+		if err = json.Compact(&compact, raw); err != nil {
+			return errors.Wrap(err, "error reading record "+strconv.Itoa(n))
+		}
+		if err = fn(JSONRecord{Value: obj, Raw: compact.String(), Line: line, Offset: end}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isJSONArray skips the leading spaces and byte order mark and tells whether the input is an array,
+// skipped is the number of bytes skipped.
+func isJSONArray(br *bufio.Reader) (bool, int64, error) {
+	var skipped int64
+	for {
+		b, err := br.Peek(1)
+		if errors.Is(err, io.EOF) {
+			return false, skipped, nil
+		}
+		if err != nil {
+			return false, skipped, errors.Wrap(err, "error reading json")
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			_, _ = br.ReadByte()
+			skipped++
+		case 0xEF: // utf-8 bom
+			n, _ := br.Discard(3) //nolint:mnd // bom length
+			skipped += int64(n)
+		default:
+			return b[0] == '[', skipped, nil
+		}
+	}
+}
+
+// lineCounter keeps the offsets of the newlines read but not yet counted by lineAt.
+type lineCounter struct {
+	r        io.Reader
+	read     int64
+	newlines []int64
+	line     int
+}
+
+func (c *lineCounter) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	for i, b := range p[:n] {
+		if b == '\n' {
+			c.newlines = append(c.newlines, c.read+int64(i))
+		}
+	}
+	c.read += int64(n)
+	return n, err
+}
+
+// lineAt returns the 1-based line of an offset, offsets must not go back.
+func (c *lineCounter) lineAt(offset int64) int {
+	i := 0
+	for i < len(c.newlines) && c.newlines[i] < offset {
+		i++
+	}
+	c.line += i
+	c.newlines = c.newlines[i:]
+	return c.line + 1
+}
@@ -0,0 +1,63 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    int
+		lines   []int
+		wantErr bool
+	}{
+		{"array", `[{"a":1}, {"a":2}]`, 2, []int{1, 1}, false},
+		{"ndjson", "{\"a\":1}\n{\"a\":2}\n\n{\"a\":3}\n", 3, []int{1, 2, 4}, false},
+		{"bom and spaces", "\xEF\xBB\xBF \n[{\"a\":1}]", 1, []int{2}, false},
+		{"pretty", "[\n  {\n    \"a\": 1\n  },\n  {\"a\": 2}\n]", 2, []int{2, 5}, false},
+		{"empty", "", 0, nil, false},
+		{"empty array", "[]", 0, nil, false},
+		{"not object", `[1]`, 0, nil, true},
+		{"broken", "{\"a\":1}\n{\"a\":", 1, []int{1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "in.json")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatalf("Failed to create input: %v", err)
+			}
+			got := 0
+			var (
+				last  int64
+				lines []int
+			)
+			err := ReadJSON(path, func(record JSONRecord) error {
+				if record.Offset <= last {
+					t.Errorf("offset %d not after %d", record.Offset, last)
+				}
+				if !strings.HasPrefix(record.Raw, "{") || !strings.HasSuffix(record.Raw, "}") {
+					t.Errorf("raw %q is not the record", record.Raw)
+				}
+				last = record.Offset
+				lines = append(lines, record.Line)
+				got++
+				return nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReadJSON() error = %v; wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ReadJSON() records = %d; want %d", got, tt.want)
+			}
+			if !reflect.DeepEqual(lines, tt.lines) {
+				t.Errorf("ReadJSON() lines = %v; want %v", lines, tt.lines)
+			}
+		})
+	}
+}
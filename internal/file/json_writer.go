@@ -0,0 +1,76 @@
+package file
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/bytedance/sonic"
+	"github.com/pkg/errors"
+)
+
+// JSONWriter writes rows either as a JSON array or as newline-delimited JSON objects.
+type JSONWriter struct {
+	writer *bufio.Writer
+	ndjson bool
+	pretty bool
+	idx    int
+}
+
+// NewJSONWriter writes to w, Close doesn't close it.
+func NewJSONWriter(w io.Writer, ndjson, pretty bool) *JSONWriter {
+	jw := &JSONWriter{
+		writer: bufio.NewWriter(w),
+		ndjson: ndjson,
+		pretty: pretty,
+	}
+	if !ndjson {
+		_ = jw.writer.WriteByte('[')
+	}
+	return jw
+}
+
+func (w *JSONWriter) Write(row any) error {
+	var (
+		data []byte
+		err  error
+	)
+	if w.pretty {
+		data, err = sonic.ConfigStd.MarshalIndent(row, "  ", "  ")
+	} else {
+		data, err = sonic.ConfigStd.Marshal(row)
+	}
+	if err != nil {
+		return err
+	}
+	if !w.ndjson {
+		if w.idx > 0 {
+			_ = w.writer.WriteByte(',')
+		}
+		if w.pretty {
+			_, _ = w.writer.WriteString("\n  ")
+		}
+	}
+	if _, err = w.writer.Write(data); err != nil {
+		return err
+	}
+	if w.ndjson {
+		if err = w.writer.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+	w.idx++
+	return nil
+}
+
+func (w *JSONWriter) Close() error {
+	if !w.ndjson {
+		end := "]\n"
+		if w.pretty && w.idx > 0 {
+			end = "\n]\n"
+		}
+		if _, err := w.writer.WriteString(end); err != nil {
+			return err
+		}
+	}
+	return errors.Wrap(w.writer.Flush(), "error write json")
+}
@@ -0,0 +1,40 @@
+package file
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestJSONWriter(t *testing.T) {
+	rows := []any{map[string]any{"a": 1}, map[string]any{"a": nil}}
+	tests := []struct {
+		name           string
+		rows           []any
+		ndjson, pretty bool
+		want           string
+	}{
+		{"array", rows, false, false, "[{\"a\":1},{\"a\":null}]\n"},
+		{"empty array", nil, false, false, "[]\n"},
+		{"pretty", rows, false, true, "[\n  {\n    \"a\": 1\n  },\n  {\n    \"a\": null\n  }\n]\n"},
+		{"ndjson", rows, true, false, "{\"a\":1}\n{\"a\":null}\n"},
+		{"empty ndjson", nil, true, false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got bytes.Buffer
+			w := NewJSONWriter(&got, tt.ndjson, tt.pretty)
+			for _, row := range tt.rows {
+				if err := w.Write(row); err != nil {
+					t.Fatalf("Write() error: %v", err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("Close() error: %v", err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("output = %q; want %q", got.String(), tt.want)
+			}
+		})
+	}
+}
@@ -1,10 +1,11 @@
 package file
 
 import (
-	"encoding/csv"
+	"context"
 	"io"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/pkg/errors"
 )
@@ -13,7 +14,14 @@ type BatchProcessor struct {
 	batchSize  int
 	inputFile  string
 	skipHeader bool
-	delimiter  rune
+	delimiter  string
+	dialect    CSVDialect
+	source     io.Reader
+	ctx        context.Context
+	offset     int64
+	line       int
+	row        int
+	batchID    int
 	batchChan  chan Batch
 	resultChan chan []Row
 	errorChan  chan error
@@ -25,15 +33,20 @@ type Row struct {
 }
 
 type Batch struct {
-	Rows  [][]string
-	Start int
-	Id    int
+	Rows   [][]string
+	Start  int
+	Id     int
+	Offset int64 // input byte offset right after the last row of the batch
+	Line   int   // input line where the last row of the batch ends
+	Lines  []int // input line each row starts at
+	// Rejected are the lines of the batch that don't fit the input format, not counted in Rows
+	Rejected []BadRow
 }
 
 func NewBatchProcessor(
 	inputFile string,
 	batchSize int,
-	delimiter rune,
+	delimiter string,
 	skipHeader bool,
 ) *BatchProcessor {
 	return &BatchProcessor{
@@ -41,61 +54,118 @@ func NewBatchProcessor(
 		inputFile:  inputFile,
 		delimiter:  delimiter,
 		skipHeader: skipHeader,
+		ctx:        context.Background(),
 	}
 }
 
+// Source reads the csv from r instead of opening the input file, r is read from the start
+// or, after Resume, from the resume offset on.
+func (bp *BatchProcessor) Source(r io.Reader) *BatchProcessor {
+	bp.source = r
+	return bp
+}
+
+// Context stops reading when ctx is done, the error of ctx is sent to the error channel.
+func (bp *BatchProcessor) Context(ctx context.Context) *BatchProcessor {
+	bp.ctx = ctx
+	return bp
+}
+
+// Dialect sets the quote, escape and other csv options besides the delimiter.
+func (bp *BatchProcessor) Dialect(d CSVDialect) *BatchProcessor {
+	bp.dialect = d
+	return bp
+}
+
+// Resume continues reading after the given Batch.Offset, Batch.Line, last row and Batch.Id
+// instead of the beginning of the file. The header is not skipped in this case.
+func (bp *BatchProcessor) Resume(offset int64, line, row, batchID int) *BatchProcessor {
+	bp.offset = offset
+	bp.line = line
+	bp.row = row
+	bp.batchID = batchID
+	return bp
+}
+
 func (bp *BatchProcessor) Reader() (batchChan chan Batch, errorChan chan error) {
 	batchChan = make(chan Batch, 2)
 	errorChan = make(chan error, 2)
 	go func() {
-		file, err := os.Open(bp.inputFile)
-		if err != nil {
-			errorChan <- errors.Wrap(err, "error opening file "+bp.inputFile)
-			close(errorChan)
-			return
-		}
-		defer func(file *os.File) {
-			err := file.Close()
+		defer close(errorChan)
+		defer close(batchChan)
+		input := bp.source
+		if input == nil {
+			file, err := os.Open(bp.inputFile)
 			if err != nil {
-				errorChan <- errors.Wrap(err, "error closing file "+bp.inputFile)
-				close(errorChan)
+				errorChan <- errors.Wrap(err, "error opening file "+bp.inputFile)
 				return
 			}
-		}(file)
+			defer func(file *os.File) {
+				err := file.Close()
+				if err != nil {
+					errorChan <- errors.Wrap(err, "error closing file "+bp.inputFile)
+				}
+			}(file)
+
+			if bp.offset > 0 {
+				if _, err = file.Seek(bp.offset, io.SeekStart); err != nil {
+					errorChan <- errors.Wrap(err, "error seeking file "+bp.inputFile)
+					return
+				}
+			}
+			input = file
+		}
 
-		reader := csv.NewReader(file)
-		reader.Comma = bp.delimiter
-		if bp.skipHeader {
+		text, skipped, err := textReader(input, bp.dialect.Encoding, bp.offset == 0)
+		if err != nil {
+			errorChan <- err
+			return
+		}
+		reader := newCSVRecordReader(text, bp.delimiter, bp.dialect)
+		if bp.skipHeader && bp.offset == 0 {
 			if _, err := reader.Read(); err != nil {
 				errorChan <- errors.Wrap(err, "error reading header")
-				close(errorChan)
 				return
 			}
 		}
-		defer close(batchChan)
-		batchID := 0
+		batchID := bp.batchID
+		row := bp.row
 		for {
 			batch := make([][]string, 0, bp.batchSize)
-			startRow := batchID*bp.batchSize + 1
+			lines := make([]int, 0, bp.batchSize)
+			startRow := row + 1
 			for i := 0; i < bp.batchSize; i++ {
 				record, err := reader.Read()
 				if err != nil {
 					if err == io.EOF {
 						break
 					}
-					bp.errorChan <- errors.Wrap(err, "error reading row "+strconv.Itoa(startRow+i))
-					close(batchChan)
+					errorChan <- errors.Wrap(err, "error reading row "+strconv.Itoa(startRow+i))
 					return
 				}
 				batch = append(batch, record)
+				first, _ := reader.FieldPos(0)
+				lines = append(lines, bp.line+first)
 			}
 			if len(batch) == 0 {
 				break
 			}
-			batchChan <- Batch{
-				Rows:  batch,
-				Start: startRow,
-				Id:    batchID,
+			row += len(batch)
+			last := batch[len(batch)-1]
+			line, _ := reader.FieldPos(len(last) - 1)
+			line += bp.line + strings.Count(last[len(last)-1], "\n")
+			select {
+			case batchChan <- Batch{
+				Rows:   batch,
+				Start:  startRow,
+				Id:     batchID,
+				Offset: bp.offset + skipped + reader.InputOffset(),
+				Line:   line,
+				Lines:  lines,
+			}:
+			case <-bp.ctx.Done():
+				errorChan <- bp.ctx.Err()
+				return
 			}
 
 			batchID++
@@ -0,0 +1,321 @@
+package file
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DelimiterAuto asks to sniff the delimiter from the input.
+	DelimiterAuto = "auto"
+	// EscapeDouble is the escape of a dialect doubling quotes in quoted fields.
+	EscapeDouble = "double"
+	// EncodingUnknown is the sniffed encoding of a sample that has no byte order mark and isn't utf-8.
+	EncodingUnknown = "unknown"
+	// SniffSize is the number of bytes from the start of a file the dialect is guessed from.
+	SniffSize = 64 << 10
+	// sniffRows is the number of records the candidate dialects are tried on.
+	sniffRows = 1000
+	// sniffLengthRows is the number of values below a header name its length is compared with.
+	sniffLengthRows = 5
+	// lazyPenalty lowers the confidence of a dialect that needs stray quotes allowed.
+	lazyPenalty = 0.9
+	// sniffDelimiterLines is the number of lines searched for delimiters of several characters.
+	sniffDelimiterLines = 20
+	// maxDelimiterLength is the longest delimiter of several characters sniffed.
+	maxDelimiterLength = 4
+)
+
+//nolint:gochecknoglobals // constant tables, earlier entries win ties
+var (
+	sniffDelimiters = []string{",", ";", "\t", "|", ":", "^", "~", "¦"}
+	sniffQuotes     = []rune{'"', '\''}
+	sniffEscapes    = []rune{0, '\\'}
+)
+
+// Sniff is the csv dialect guessed from the start of a file. Confidence is the share of the
+// sampled rows with the same number of fields, lowered when stray quotes had to be allowed.
+// A file without a delimiter found gets "," with confidence 0. Escape is "double" when quotes
+// in quoted fields are doubled.
+type Sniff struct {
+	Delimiter  string  `json:"delimiter"`
+	Quote      string  `json:"quote"`
+	Escape     string  `json:"escape"`
+	LazyQuotes bool    `json:"lazy_quotes"`
+	Encoding   string  `json:"encoding"`
+	LineEnding string  `json:"line_ending"`
+	Header     bool    `json:"header"`
+	Columns    int     `json:"columns"`
+	Rows       int     `json:"rows_sampled"`
+	Confidence float64 `json:"confidence"`
+}
+
+// Dialect is the input dialect of the sniffed file.
+func (s *Sniff) Dialect() CSVDialect {
+	quote, _ := utf8.DecodeRuneInString(s.Quote)
+	var escape rune
+	if s.Escape != EscapeDouble {
+		escape, _ = utf8.DecodeRuneInString(s.Escape)
+	}
+	return CSVDialect{
+		Quote:      quote,
+		Escape:     escape,
+		Encoding:   s.Encoding,
+		LazyQuotes: s.LazyQuotes,
+		NoHeader:   !s.Header,
+	}
+}
+
+// SniffCSV guesses the delimiter, quote, escape, header, line ending and encoding of a csv file
+// from its first size bytes. The encoding is utf-16 or utf-8 by the byte order mark, and
+// EncodingUnknown for a sample that isn't valid utf-8: it's read as windows-1252 to find the
+// dialect, the bytes don't tell which single byte encoding it is.
+func SniffCSV(path string, size int) (*Sniff, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening file "+path)
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+	s, err := SniffReader(f, size)
+	return s, errors.Wrap(err, "error sniff file "+path)
+}
+
+// SniffReader is SniffCSV for the first size bytes of r.
+func SniffReader(r io.Reader, size int) (*Sniff, error) {
+	raw, err := io.ReadAll(io.LimitReader(r, int64(size)+1))
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading")
+	}
+	truncated := len(raw) > size
+	if truncated {
+		raw = raw[:size]
+	}
+
+	s := &Sniff{Delimiter: ",", Quote: `"`, Escape: EscapeDouble, Header: true, Encoding: BOMEncoding(raw)}
+	encoding := s.Encoding
+	if s.Encoding == EncodingUTF8 && !utf8.Valid(completeLines(raw, truncated)) {
+		s.Encoding, encoding = EncodingUnknown, "windows-1252"
+	}
+	text, _, err := textReader(bytes.NewReader(raw), encoding, true)
+	if err != nil {
+		return nil, err
+	}
+	decoded, err := io.ReadAll(text)
+	if err != nil {
+		return nil, errors.Wrap(err, "error decoding")
+	}
+	sample := string(completeLines(decoded, truncated))
+	s.LineEnding = lineEnding(sample)
+
+	var best [][]string
+	for _, delimiter := range append(slices.Clone(sniffDelimiters), longDelimiters(sample)...) {
+		for _, quote := range sniffQuotes {
+			for _, escape := range sniffEscapes {
+				records, lazy := sniffRecords(sample, delimiter, quote, escape)
+				columns, consistency := fieldCount(records)
+				if lazy {
+					consistency *= lazyPenalty
+				}
+				if columns < 2 || !s.better(delimiter, records, columns, consistency) {
+					continue
+				}
+				s.Delimiter, s.Quote, s.LazyQuotes = delimiter, string(quote), lazy
+				s.Escape = EscapeDouble
+				if escape != 0 {
+					s.Escape = string(escape)
+				}
+				s.Columns, s.Confidence, best = columns, consistency, records
+			}
+		}
+	}
+	if best == nil {
+		s.Columns, s.Rows = 1, strings.Count(sample, "\n")
+		return s, nil
+	}
+	s.Rows = len(best)
+	s.Header = hasHeader(best, s.Columns)
+	return s, nil
+}
+
+// better tells whether a delimiter reading records of columns with consistency beats the best one
+// so far: by consistency, then a delimiter of several characters over the one it contains unless
+// its fields still have that one, then by columns. Splitting a~|~b by ~ reads as many rows as by ~|~,
+// with more columns, a|b||c is split by | though.
+func (s *Sniff) better(delimiter string, records [][]string, columns int, consistency float64) bool {
+	switch {
+	case consistency != s.Confidence:
+		return consistency > s.Confidence
+	case len(delimiter) > len(s.Delimiter) && strings.Contains(delimiter, s.Delimiter):
+		return !slices.ContainsFunc(records, func(record []string) bool {
+			return slices.ContainsFunc(record, func(field string) bool {
+				return strings.Contains(field, s.Delimiter)
+			})
+		})
+	case len(delimiter) < len(s.Delimiter) && strings.Contains(s.Delimiter, delimiter):
+		return false
+	}
+	return columns > s.Columns
+}
+
+// longDelimiters are the runs of several punctuation characters found the same number of times
+// on each of the first lines of the sample, delimiters like || or ~|~.
+func longDelimiters(sample string) []string {
+	var (
+		found  []string
+		counts map[string]int
+	)
+	lines := strings.FieldsFunc(sample, func(r rune) bool { return r == '\n' || r == '\r' })
+	for n, line := range lines[:min(len(lines), sniffDelimiterLines)] {
+		runs := map[string]int{}
+		for _, run := range strings.FieldsFunc(line, func(r rune) bool { return !delimiterRune(r) }) {
+			if len(run) > 1 && utf8.RuneCountInString(run) <= maxDelimiterLength {
+				runs[run]++
+			}
+		}
+		if n == 0 {
+			counts = runs
+			continue
+		}
+		for run, c := range counts {
+			if runs[run] != c {
+				delete(counts, run)
+			}
+		}
+	}
+	for run := range counts {
+		found = append(found, run)
+	}
+	slices.Sort(found)
+	return found
+}
+
+// delimiterRune tells whether r can be part of a delimiter of several characters, quotes and escapes can't.
+func delimiterRune(r rune) bool {
+	return (unicode.IsPunct(r) || unicode.IsSymbol(r)) && !slices.Contains(sniffQuotes, r) && !slices.Contains(sniffEscapes, r)
+}
+
+// completeLines drops the last line of a truncated sample, it may be cut in the middle.
+func completeLines(sample []byte, truncated bool) []byte {
+	if i := bytes.LastIndexByte(sample, '\n'); truncated && i >= 0 {
+		return sample[:i+1]
+	}
+	return sample
+}
+
+func lineEnding(sample string) string {
+	crlf := strings.Count(sample, "\r\n")
+	lf := strings.Count(sample, "\n") - crlf
+	switch {
+	case crlf > lf:
+		return "\r\n"
+	case lf == 0 && strings.Contains(sample, "\r"):
+		return "\r"
+	}
+	return "\n"
+}
+
+// sniffRecords reads the sample with the delimiter, quote and escape, lazy is set when it only
+// reads with stray quotes allowed. No records are returned when it doesn't read at all.
+func sniffRecords(sample, delimiter string, quote, escape rune) (records [][]string, lazy bool) {
+	d := CSVDialect{Quote: quote, Escape: escape, FieldsPerRecord: -1}
+	if d.Validate(delimiter) != nil {
+		return nil, false
+	}
+	for _, lazy = range []bool{false, true} {
+		d.LazyQuotes = lazy
+		r := newCSVRecordReader(strings.NewReader(sample), delimiter, d)
+		records = records[:0]
+		var err error
+		for len(records) < sniffRows {
+			var record []string
+			if record, err = r.Read(); err != nil {
+				break
+			}
+			records = append(records, record)
+		}
+		if err == nil || errors.Is(err, io.EOF) {
+			return records, lazy
+		}
+	}
+	return nil, false
+}
+
+// fieldCount is the most common number of fields in the records and the share of records having it.
+func fieldCount(records [][]string) (columns int, consistency float64) {
+	counts := map[int]int{}
+	for _, record := range records {
+		counts[len(record)]++
+	}
+	most := 0
+	for n, c := range counts {
+		if c > most || c == most && n > columns {
+			columns, most = n, c
+		}
+	}
+	if most == 0 {
+		return 0, 0
+	}
+	return columns, float64(most) / float64(len(records))
+}
+
+// hasHeader votes column by column whether the first record looks unlike the rest:
+// a text above numbers or, with enough rows, a value of another length than all below it.
+// Empty and repeated names vote against, a tie is a header.
+func hasHeader(records [][]string, columns int) bool {
+	first := records[0]
+	if len(first) != columns || len(records) < 2 { //nolint:mnd // a header and a row
+		return true
+	}
+	votes := 0
+	seen := map[string]bool{}
+	for i, name := range first {
+		if name == "" || seen[name] {
+			votes--
+		}
+		seen[name] = true
+
+		numeric, sameLength, length, values := true, true, -1, 0
+		for _, record := range records[1:] {
+			if len(record) != columns || record[i] == "" {
+				continue
+			}
+			values++
+			if !isNumber(record[i]) {
+				numeric = false
+			}
+			if n := utf8.RuneCountInString(record[i]); length == -1 {
+				length = n
+			} else if n != length {
+				sameLength = false
+			}
+		}
+		switch {
+		case values == 0:
+		case numeric && isNumber(name):
+			votes--
+		case numeric:
+			votes++
+		case !sameLength || values < sniffLengthRows:
+		case utf8.RuneCountInString(name) != length:
+			votes++
+		default:
+			votes--
+		}
+	}
+	return votes >= 0
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return err == nil
+}
@@ -0,0 +1,100 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSniffCSV(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want Sniff
+	}{
+		{
+			name: "semicolon crlf",
+			data: []byte("id;name;price\r\n1;\"a;b\";1,5\r\n2;c;2,25\r\n"),
+			want: Sniff{Escape: EscapeDouble, Delimiter: ";", Quote: `"`, Encoding: EncodingUTF8, LineEnding: "\r\n", Header: true, Columns: 3},
+		},
+		{
+			name: "tab single quote",
+			data: []byte("id\tname\n1\t'x\ty'\n2\t'z'\n"),
+			want: Sniff{Escape: EscapeDouble, Delimiter: "\t", Quote: "'", Encoding: EncodingUTF8, LineEnding: "\n", Header: true, Columns: 2},
+		},
+		{
+			name: "no header",
+			data: []byte("1|2020-01-01|5\n2|2020-01-02|7\n"),
+			want: Sniff{Escape: EscapeDouble, Delimiter: "|", Quote: `"`, Encoding: EncodingUTF8, LineEnding: "\n", Columns: 3},
+		},
+		{
+			name: "text header",
+			data: []byte("name,city\nOlga,Kyiv\nBob,Rome\n"),
+			want: Sniff{Escape: EscapeDouble, Delimiter: ",", Quote: `"`, Encoding: EncodingUTF8, LineEnding: "\n", Header: true, Columns: 2},
+		},
+		{
+			name: "windows-1252",
+			data: []byte("nom,ville\nZo\xEB,Montr\xE9al\n"),
+			want: Sniff{Escape: EscapeDouble, Delimiter: ",", Quote: `"`, Encoding: EncodingUnknown, LineEnding: "\n", Header: true, Columns: 2},
+		},
+		{
+			name: "backslash escape",
+			data: []byte("id,note\n1,\"say \\\"hi\\\", ok\"\n2,\"plain\"\n3,\"a \\\"b\\\"\"\n"),
+			want: Sniff{Escape: `\`, Delimiter: ",", Quote: `"`, Encoding: EncodingUTF8, LineEnding: "\n", Header: true, Columns: 2},
+		},
+		{
+			name: "several characters",
+			data: []byte("id~|~name~|~city\n1~|~\"Olga\"~|~Kyiv\n2~|~Bob~|~\"Rome\"\n"),
+			want: Sniff{Escape: EscapeDouble, Delimiter: "~|~", Quote: `"`, Encoding: EncodingUTF8, LineEnding: "\n", Header: true, Columns: 3},
+		},
+		{
+			name: "empty fields around a single character",
+			data: []byte("1|x||4\n2|y||5\n3|z||6\n"),
+			want: Sniff{Escape: EscapeDouble, Delimiter: "|", Quote: `"`, Encoding: EncodingUTF8, LineEnding: "\n", Columns: 4},
+		},
+		{
+			name: "utf-16le",
+			data: []byte{0xFF, 0xFE, 'a', 0, ';', 0, 'b', 0, '\n', 0, '1', 0, ';', 0, '2', 0, '\n', 0},
+			want: Sniff{Escape: EscapeDouble, Delimiter: ";", Quote: `"`, Encoding: "utf-16le", LineEnding: "\n", Header: true, Columns: 2},
+		},
+		{
+			name: "single column",
+			data: []byte("name\nOlga\n"),
+			want: Sniff{Escape: EscapeDouble, Delimiter: ",", Quote: `"`, Encoding: EncodingUTF8, LineEnding: "\n", Header: true, Columns: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "in.csv")
+			if err := os.WriteFile(path, tt.data, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			got, err := SniffCSV(path, SniffSize)
+			if err != nil {
+				t.Fatalf("SniffCSV() error: %v", err)
+			}
+			got.Rows, got.Confidence, got.LazyQuotes = 0, 0, false
+			if *got != tt.want {
+				t.Errorf("SniffCSV() = %+v; want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSniffCSVTruncated(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "in.csv")
+	data := "a;b;c\n" + strings.Repeat("1;2;3\n", 100)
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// the sample ends in the middle of a row, which must not count against the delimiter
+	got, err := SniffCSV(path, len(data)-3)
+	if err != nil {
+		t.Fatalf("SniffCSV() error: %v", err)
+	}
+	if got.Delimiter != ";" || got.Confidence != 1 || got.Rows != 100 {
+		t.Errorf("SniffCSV() = %+v; want ; with confidence 1 over 100 rows", *got)
+	}
+}
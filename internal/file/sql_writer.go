@@ -0,0 +1,292 @@
+package file
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/dbunt1tled/parquet2csv/internal/schema"
+	"github.com/pkg/errors"
+)
+
+const (
+	SQLPostgres = "postgres"
+	SQLMySQL    = "mysql"
+	SQLSQLite   = "sqlite"
+)
+
+// maxMySQLDecimal is the largest DECIMAL precision mysql accepts.
+const maxMySQLDecimal = 65
+
+// SQLWriter writes a sql dump: CREATE TABLE followed by multi-row INSERTs of batch rows,
+// or for postgres optionally a COPY ... FROM stdin block.
+type SQLWriter struct {
+	writer  *bufio.Writer
+	dialect string
+	table   string
+	columns []schema.SQLColumn
+	batch   int
+	copy    bool
+	rows    int
+}
+
+// NewSQLWriter writes to w, Close doesn't close it.
+func NewSQLWriter(w io.Writer, dialect, table string, columns []schema.SQLColumn, batch int, copyFrom bool) (*SQLWriter, error) {
+	switch dialect {
+	case SQLPostgres, SQLMySQL, SQLSQLite:
+	default:
+		return nil, errors.New("unknown sql dialect " + dialect + ", use postgres, mysql or sqlite")
+	}
+	if copyFrom && dialect != SQLPostgres {
+		return nil, errors.New("COPY is supported for postgres only")
+	}
+	sw := &SQLWriter{
+		writer:  bufio.NewWriter(w),
+		dialect: dialect,
+		table:   table,
+		columns: columns,
+		batch:   batch,
+		copy:    copyFrom,
+	}
+	_, _ = sw.writer.WriteString(sw.createTable())
+	return sw, nil
+}
+
+func (w *SQLWriter) createTable() string {
+	var b strings.Builder
+	b.WriteString("CREATE TABLE " + w.quoteIdent(w.table) + " (\n")
+	for i, c := range w.columns {
+		b.WriteString("  " + w.quoteIdent(c.Name) + " " + w.columnType(c))
+		if !c.Nullable {
+			b.WriteString(" NOT NULL")
+		}
+		if i < len(w.columns)-1 {
+			b.WriteByte(',')
+		}
+		b.WriteByte('\n')
+	}
+	b.WriteString(");\n")
+	return b.String()
+}
+
+//nolint:gocyclo,cyclop // one case per column kind
+func (w *SQLWriter) columnType(c schema.SQLColumn) string {
+	if w.dialect == SQLSQLite {
+		switch c.Kind {
+		case schema.SQLBoolean, schema.SQLSmallInt, schema.SQLInt, schema.SQLBigInt, schema.SQLUnsignedBigInt:
+			return "INTEGER"
+		case schema.SQLReal, schema.SQLDouble:
+			return "REAL"
+		case schema.SQLDecimal:
+			return "NUMERIC"
+		case schema.SQLBinary:
+			return "BLOB"
+		default:
+			return "TEXT"
+		}
+	}
+	mysql := w.dialect == SQLMySQL
+	switch c.Kind {
+	case schema.SQLBoolean:
+		return "BOOLEAN"
+	case schema.SQLSmallInt:
+		return "SMALLINT"
+	case schema.SQLInt:
+		if mysql {
+			return "INT"
+		}
+		return "INTEGER"
+	case schema.SQLBigInt:
+		return "BIGINT"
+	case schema.SQLUnsignedBigInt:
+		if mysql {
+			return "BIGINT UNSIGNED"
+		}
+		return "NUMERIC(20)"
+	case schema.SQLReal:
+		if mysql {
+			return "FLOAT"
+		}
+		return "REAL"
+	case schema.SQLDouble:
+		if mysql {
+			return "DOUBLE"
+		}
+		return "DOUBLE PRECISION"
+	case schema.SQLDecimal:
+		if mysql {
+			if c.Precision > maxMySQLDecimal {
+				return "TEXT"
+			}
+			return "DECIMAL(" + strconv.Itoa(c.Precision) + "," + strconv.Itoa(c.Scale) + ")"
+		}
+		return "NUMERIC(" + strconv.Itoa(c.Precision) + "," + strconv.Itoa(c.Scale) + ")"
+	case schema.SQLDate:
+		return "DATE"
+	case schema.SQLTime:
+		if mysql {
+			return "TIME(6)"
+		}
+		return "TIME"
+	case schema.SQLTimestamp:
+		if mysql {
+			return "DATETIME(6)"
+		}
+		return "TIMESTAMP"
+	case schema.SQLTimestampTZ:
+		if mysql {
+			return "DATETIME(6)"
+		}
+		return "TIMESTAMP WITH TIME ZONE"
+	case schema.SQLJSON:
+		if mysql {
+			return "JSON"
+		}
+		return "JSONB"
+	case schema.SQLBinary:
+		if mysql {
+			return "LONGBLOB"
+		}
+		return "BYTEA"
+	case schema.SQLText:
+	}
+	if mysql {
+		return "LONGTEXT"
+	}
+	return "TEXT"
+}
+
+func (w *SQLWriter) quoteIdent(name string) string {
+	if w.dialect == SQLMySQL {
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// Write adds a row of the values returned by schema.Node.SQLRow.
+func (w *SQLWriter) Write(values []any) error {
+	if w.rows == 0 {
+		if _, err := w.writer.WriteString(w.statement()); err != nil {
+			return err
+		}
+	} else if !w.copy {
+		_, _ = w.writer.WriteString(",\n")
+	}
+	if w.copy {
+		w.copyRow(values)
+	} else {
+		w.insertRow(values)
+	}
+	w.rows++
+	if !w.copy && w.rows == w.batch {
+		w.rows = 0
+		_, err := w.writer.WriteString(";\n")
+		return err
+	}
+	return nil
+}
+
+func (w *SQLWriter) statement() string {
+	names := make([]string, 0, len(w.columns))
+	for _, c := range w.columns {
+		names = append(names, w.quoteIdent(c.Name))
+	}
+	if w.copy {
+		return "COPY " + w.quoteIdent(w.table) + " (" + strings.Join(names, ", ") + ") FROM stdin;\n"
+	}
+	return "INSERT INTO " + w.quoteIdent(w.table) + " (" + strings.Join(names, ", ") + ") VALUES\n"
+}
+
+func (w *SQLWriter) insertRow(values []any) {
+	_ = w.writer.WriteByte('(')
+	for i, v := range values {
+		if i > 0 {
+			_, _ = w.writer.WriteString(", ")
+		}
+		_, _ = w.writer.WriteString(w.literal(v, w.columns[i].Kind))
+	}
+	_ = w.writer.WriteByte(')')
+}
+
+func (w *SQLWriter) literal(v any, kind schema.SQLKind) string {
+	switch value := v.(type) {
+	case nil:
+		return "NULL"
+	case bool:
+		if w.dialect == SQLPostgres {
+			return strings.ToUpper(strconv.FormatBool(value))
+		}
+		if value {
+			return "1"
+		}
+		return "0"
+	case json.Number:
+		return value.String()
+	case []byte:
+		if w.dialect == SQLPostgres {
+			return `'\x` + hex.EncodeToString(value) + "'"
+		}
+		return "X'" + hex.EncodeToString(value) + "'"
+	case string:
+		if kind == schema.SQLTimestampTZ && w.dialect == SQLPostgres {
+			value += "+00"
+		}
+		return w.quoteString(value)
+	}
+	return "NULL"
+}
+
+func (w *SQLWriter) quoteString(s string) string {
+	if w.dialect == SQLMySQL {
+		// mysql treats backslash as an escape character by default
+		s = strings.NewReplacer(`\`, `\\`, "\x00", `\0`).Replace(s)
+	}
+	if w.dialect == SQLPostgres {
+		// postgres text can't hold NUL bytes
+		s = strings.ReplaceAll(s, "\x00", "")
+	}
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func (w *SQLWriter) copyRow(values []any) {
+	for i, v := range values {
+		if i > 0 {
+			_ = w.writer.WriteByte('\t')
+		}
+		switch value := v.(type) {
+		case nil:
+			_, _ = w.writer.WriteString(`\N`)
+		case bool:
+			_, _ = w.writer.WriteString(map[bool]string{true: "t", false: "f"}[value])
+		case json.Number:
+			_, _ = w.writer.WriteString(value.String())
+		case []byte:
+			_, _ = w.writer.WriteString(`\\x` + hex.EncodeToString(value))
+		case string:
+			if w.columns[i].Kind == schema.SQLTimestampTZ {
+				value += "+00"
+			}
+			_, _ = w.writer.WriteString(copyEscaper.Replace(value))
+		}
+	}
+	_ = w.writer.WriteByte('\n')
+}
+
+// copyEscaper escapes a value for the text format of postgres COPY.
+var copyEscaper = strings.NewReplacer( //nolint:gochecknoglobals // built once
+	`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`, "\x00", "",
+)
+
+// Close ends the last statement and flushes the dump.
+func (w *SQLWriter) Close() error {
+	switch {
+	case w.copy && w.rows > 0:
+		_, _ = w.writer.WriteString("\\.\n")
+	case w.rows > 0:
+		_, _ = w.writer.WriteString(";\n")
+	}
+	return errors.Wrap(w.writer.Flush(), "error write sql")
+}
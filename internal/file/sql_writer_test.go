@@ -0,0 +1,92 @@
+package file
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/dbunt1tled/parquet2csv/internal/schema"
+)
+
+func TestSQLWriter(t *testing.T) {
+	columns := []schema.SQLColumn{
+		{Name: "id", Kind: schema.SQLBigInt},
+		{Name: "ok", Kind: schema.SQLBoolean, Nullable: true},
+		{Name: "name", Kind: schema.SQLText, Nullable: true},
+		{Name: "data", Kind: schema.SQLBinary, Nullable: true},
+		{Name: "at", Kind: schema.SQLTimestampTZ, Nullable: true},
+	}
+	rows := [][]any{
+		{json.Number("1"), true, "it's a\\b\tc", []byte{0xca, 0xfe}, "2024-01-02 03:04:05"},
+		{json.Number("2"), false, nil, nil, nil},
+		{json.Number("3"), nil, "x", nil, nil},
+	}
+	tests := []struct {
+		name     string
+		dialect  string
+		batch    int
+		copyFrom bool
+		rows     [][]any
+		want     string
+	}{
+		{
+			"postgres", SQLPostgres, 2, false, rows,
+			"CREATE TABLE \"t\" (\n  \"id\" BIGINT NOT NULL,\n  \"ok\" BOOLEAN,\n  \"name\" TEXT,\n  \"data\" BYTEA,\n  \"at\" TIMESTAMP WITH TIME ZONE\n);\n" +
+				"INSERT INTO \"t\" (\"id\", \"ok\", \"name\", \"data\", \"at\") VALUES\n" +
+				"(1, TRUE, 'it''s a\\b\tc', '\\xcafe', '2024-01-02 03:04:05+00'),\n(2, FALSE, NULL, NULL, NULL);\n" +
+				"INSERT INTO \"t\" (\"id\", \"ok\", \"name\", \"data\", \"at\") VALUES\n(3, NULL, 'x', NULL, NULL);\n",
+		},
+		{
+			"postgres copy", SQLPostgres, 2, true, rows,
+			"CREATE TABLE \"t\" (\n  \"id\" BIGINT NOT NULL,\n  \"ok\" BOOLEAN,\n  \"name\" TEXT,\n  \"data\" BYTEA,\n  \"at\" TIMESTAMP WITH TIME ZONE\n);\n" +
+				"COPY \"t\" (\"id\", \"ok\", \"name\", \"data\", \"at\") FROM stdin;\n" +
+				"1\tt\tit's a\\\\b\\tc\t\\\\xcafe\t2024-01-02 03:04:05+00\n2\tf\t\\N\t\\N\t\\N\n3\t\\N\tx\t\\N\t\\N\n\\.\n",
+		},
+		{
+			"mysql", SQLMySQL, 10, false, rows[:1],
+			"CREATE TABLE `t` (\n  `id` BIGINT NOT NULL,\n  `ok` BOOLEAN,\n  `name` LONGTEXT,\n  `data` LONGBLOB,\n  `at` DATETIME(6)\n);\n" +
+				"INSERT INTO `t` (`id`, `ok`, `name`, `data`, `at`) VALUES\n" +
+				"(1, 1, 'it''s a\\\\b\tc', X'cafe', '2024-01-02 03:04:05');\n",
+		},
+		{
+			"sqlite", SQLSQLite, 10, false, rows[1:2],
+			"CREATE TABLE \"t\" (\n  \"id\" INTEGER NOT NULL,\n  \"ok\" INTEGER,\n  \"name\" TEXT,\n  \"data\" BLOB,\n  \"at\" TEXT\n);\n" +
+				"INSERT INTO \"t\" (\"id\", \"ok\", \"name\", \"data\", \"at\") VALUES\n(2, 0, NULL, NULL, NULL);\n",
+		},
+		{
+			"no rows", SQLSQLite, 10, false, nil,
+			"CREATE TABLE \"t\" (\n  \"id\" INTEGER NOT NULL,\n  \"ok\" INTEGER,\n  \"name\" TEXT,\n  \"data\" BLOB,\n  \"at\" TEXT\n);\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got bytes.Buffer
+			w, err := NewSQLWriter(&got, tt.dialect, "t", columns, tt.batch, tt.copyFrom)
+			if err != nil {
+				t.Fatalf("NewSQLWriter() error: %v", err)
+			}
+			for _, row := range tt.rows {
+				if err = w.Write(row); err != nil {
+					t.Fatalf("Write() error: %v", err)
+				}
+			}
+			if err = w.Close(); err != nil {
+				t.Fatalf("Close() error: %v", err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("output = %q; want %q", got.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestNewSQLWriterInvalid(t *testing.T) {
+	if _, err := NewSQLWriter(io.Discard, "oracle", "t", nil, 1, false); err == nil {
+		t.Error("expected error for unknown dialect")
+	}
+	if _, err := NewSQLWriter(io.Discard, SQLMySQL, "t", nil, 1, true); err == nil {
+		t.Error("expected error for COPY with mysql")
+	}
+}
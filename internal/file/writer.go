@@ -1,28 +1,43 @@
 package file
 
 import (
-	"encoding/csv"
+	"io"
 	"os"
 )
 
 type CSVWriter struct {
-	file      *os.File
-	writer    *csv.Writer
+	file      io.Closer
+	text      io.WriteCloser
+	writer    csvRecordWriter
 	delimiter string
 	flush     int
 	idx       int
 }
 
-func NewCSVWriter(path string, delimiter string, flush int) (*CSVWriter, error) {
+func NewCSVWriter(path string, delimiter string, dialect CSVDialect, flush int) (*CSVWriter, error) {
 	f, err := os.Create(path)
 	if err != nil {
 		return nil, err
 	}
-	w := csv.NewWriter(f)
-	w.Comma = rune(delimiter[0])
+	w, err := NewCSVStreamWriter(f, delimiter, dialect, flush)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	w.file = f
+	return w, nil
+}
+
+// NewCSVStreamWriter writes csv to w, Close flushes it but leaves w open.
+func NewCSVStreamWriter(w io.Writer, delimiter string, dialect CSVDialect, flush int) (*CSVWriter, error) {
+	text, err := textWriter(w, dialect.Encoding, dialect.BOM)
+	if err != nil {
+		return nil, err
+	}
 	return &CSVWriter{
-		file:      f,
-		writer:    w,
+		file:      nopWriteCloser{},
+		text:      text,
+		writer:    newCSVRecordWriter(text, delimiter, dialect),
 		delimiter: delimiter,
 		idx:       0,
 		flush:     flush,
@@ -42,7 +57,12 @@ func (w *CSVWriter) WriteS(row []string) error {
 
 func (w *CSVWriter) Close() error {
 	w.writer.Flush()
-	if err := w.file.Close(); err != nil {
+	if err := w.writer.Error(); err != nil {
+		_ = w.file.Close()
+		return err
+	}
+	if err := w.text.Close(); err != nil {
+		_ = w.file.Close()
 		return err
 	}
 	return w.file.Close()
@@ -1,6 +1,7 @@
 package helper
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"reflect"
@@ -58,17 +59,27 @@ func RuntimeStatistics(startTime time.Time, inputFile string) string {
 	funcObj := runtime.FuncForPC(pc)
 	runtimeFunc := regexp.MustCompile(`^.*\.(.*)$`)
 	name := runtimeFunc.ReplaceAllString(funcObj.Name(), "$1")
-	fInfo, _ := os.Stat(inputFile)
+	var size int64
+	if fInfo, err := os.Stat(inputFile); err == nil {
+		size = fInfo.Size()
+	}
 	return fmt.Sprintf(
 		"%s (%s): %s Processed %s (%s)",
 		inputFile,
-		GetFileSize(fInfo.Size()),
+		GetFileSize(size),
 		name,
 		time.Since(startTime).Round(time.Second).String(),
 		MemoryUsage(),
 	)
 }
 
+func AppHelp(help bool) {
+	if help {
+		flag.Usage()
+		os.Exit(0)
+	}
+}
+
 func MemoryUsage() string {
 	var memStats runtime.MemStats
 	runtime.ReadMemStats(&memStats)
@@ -94,34 +105,6 @@ func GetFileSize(size int64) string {
 	}
 }
 
-func StructToMap(obj interface{}) (map[string]interface{}, error) {
-	val := reflect.ValueOf(obj)
-	typ := reflect.TypeOf(obj)
-
-	if val.Kind() == reflect.Ptr {
-		val = val.Elem()
-		typ = typ.Elem()
-	}
-
-	if val.Kind() != reflect.Struct {
-		return nil, fmt.Errorf("expected struct, got %v", val.Kind())
-	}
-
-	result := make(map[string]interface{})
-	for i := 0; i < val.NumField(); i++ {
-		field := typ.Field(i)
-		fieldValue := val.Field(i)
-
-		if !fieldValue.CanInterface() {
-			continue
-		}
-
-		result[field.Name] = fieldValue.Interface()
-	}
-
-	return result, nil
-}
-
 func AnyToString(a any) string {
 	switch value := a.(type) {
 	case nil:
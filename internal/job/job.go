@@ -0,0 +1,306 @@
+// Package job reads job files, yaml or toml files describing the conversions of the run command.
+//
+//	defaults:
+//	  compression: 1
+//	jobs:
+//	  - name: orders
+//	    input: ${DATA_DIR}/orders.csv
+//	    output: out/orders.parquet
+//	    delimiter: auto
+//	    schema:
+//	      amount: decimal(9,2)
+//	    filter:
+//	      status: paid
+//	      amount: ">=100"
+//	    partition-by: region
+package job
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Keys of a job that aren't options.
+const (
+	keyName    = "name"
+	keyCommand = "command"
+	keyInput   = "input"
+	keyOutput  = "output"
+	keySchema  = "schema"
+	keyFilter  = "filter"
+)
+
+// Job is a conversion of a job file.
+type Job struct {
+	Name string
+	// Command is the converter command like parquet or csv, empty when the file doesn't set it.
+	Command string
+	Input   string
+	// Output is optional, the command default applies when it is empty.
+	Output string
+	// Options are flag values by flag name, a string or a []string for lists. The defaults
+	// of the file are merged in, a schema map is turned into name=type strings and a filter map
+	// into column=value or column<op>value ones.
+	Options map[string]any
+}
+
+// LookupFunc looks up the variables interpolated into the strings of a job file, like os.LookupEnv.
+type LookupFunc func(name string) (string, bool)
+
+type document struct {
+	Defaults map[string]any   `yaml:"defaults" toml:"defaults"`
+	Jobs     []map[string]any `yaml:"jobs"     toml:"jobs"`
+}
+
+// Load reads a job file, yaml for .yaml and .yml, toml for .toml.
+func Load(path string, lookup LookupFunc) ([]Job, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "error read job file")
+	}
+	jobs, err := Parse(data, filepath.Ext(path), lookup)
+	return jobs, errors.Wrap(err, "job file "+path)
+}
+
+// Parse reads the jobs of a job file in the format of the extension ext.
+func Parse(data []byte, ext string, lookup LookupFunc) ([]Job, error) {
+	var doc document
+	switch strings.ToLower(ext) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&doc); err != nil {
+			return nil, errors.Wrap(err, "error parse yaml")
+		}
+	case ".toml":
+		md, err := toml.Decode(string(data), &doc)
+		if err != nil {
+			return nil, errors.Wrap(err, "error parse toml")
+		}
+		for _, key := range md.Undecoded() {
+			// the keys below defaults and jobs are options, only the top level is fixed
+			if len(key) == 1 {
+				return nil, errors.New("unknown key " + key.String())
+			}
+		}
+	default:
+		return nil, errors.New("job file must be .yaml, .yml or .toml")
+	}
+	if len(doc.Jobs) == 0 {
+		return nil, errors.New("no jobs")
+	}
+
+	jobs := make([]Job, 0, len(doc.Jobs))
+	names := make(map[string]bool, len(doc.Jobs))
+	for i, fields := range doc.Jobs {
+		j, err := newJob(doc.Defaults, fields, lookup)
+		if err != nil {
+			if j.Name == "" {
+				return nil, errors.Wrap(err, "job "+strconv.Itoa(i+1))
+			}
+			return nil, errors.Wrap(err, "job "+j.Name)
+		}
+		if names[j.Name] {
+			return nil, errors.New("job " + j.Name + " is defined twice")
+		}
+		names[j.Name] = true
+		jobs = append(jobs, j)
+	}
+	return jobs, nil
+}
+
+// Select returns the jobs of the names, in the order of the file, or all jobs without names.
+func Select(jobs []Job, names []string) ([]Job, error) {
+	if len(names) == 0 {
+		return jobs, nil
+	}
+	want := make(map[string]bool, len(names))
+	for _, name := range names {
+		want[name] = true
+	}
+	selected := make([]Job, 0, len(names))
+	for _, j := range jobs {
+		if want[j.Name] {
+			selected = append(selected, j)
+			delete(want, j.Name)
+		}
+	}
+	for _, name := range names {
+		if want[name] {
+			all := make([]string, 0, len(jobs))
+			for _, j := range jobs {
+				all = append(all, j.Name)
+			}
+			return nil, errors.New("no job " + name + ", the jobs are " + strings.Join(all, ", "))
+		}
+	}
+	return selected, nil
+}
+
+func newJob(defaults, fields map[string]any, lookup LookupFunc) (Job, error) {
+	var j Job
+	merged := make(map[string]any, len(defaults)+len(fields))
+	for key, value := range defaults {
+		merged[key] = value
+	}
+	for key, value := range fields {
+		merged[key] = value
+	}
+	// the name first, to tell which job an error is about
+	name, err := option(keyName, merged[keyName], lookup)
+	if err != nil {
+		return j, err
+	}
+	j.Name, _ = name.(string)
+	if j.Name == "" {
+		return j, errors.New("name is required")
+	}
+
+	j.Options = make(map[string]any, len(merged))
+	for key, value := range merged {
+		v, err := option(key, value, lookup)
+		if err != nil {
+			return j, err
+		}
+		s, isString := v.(string)
+		if !isString && (key == keyCommand || key == keyInput || key == keyOutput) {
+			return j, errors.New(key + " must be a string")
+		}
+		switch key {
+		case keyName:
+		case keyCommand:
+			j.Command = s
+		case keyInput:
+			j.Input = s
+		case keyOutput:
+			j.Output = s
+		default:
+			j.Options[key] = v
+		}
+	}
+	if j.Input == "" {
+		return j, errors.New("input is required")
+	}
+	return j, nil
+}
+
+// option is the value of a job key with the variables interpolated: a string, a []string for
+// a list, or for the schema and filter maps a []string sorted by name: name=type of the schema,
+// column=value of the filter or column<op>value when the value starts with an operator like >=.
+func option(key string, value any, lookup LookupFunc) (any, error) {
+	switch v := value.(type) {
+	case []any:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			s, err := scalar(key, item, lookup)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, s)
+		}
+		return list, nil
+	case map[string]any:
+		if key != keySchema && key != keyFilter {
+			return nil, errors.New(key + " can't be a map")
+		}
+		list := make([]string, 0, len(v))
+		for name, item := range v {
+			s, err := scalar(key+"."+name, item, lookup)
+			if err != nil {
+				return nil, err
+			}
+			if key == keyFilter && s != "" && strings.IndexByte("!<>=", s[0]) >= 0 {
+				list = append(list, name+s)
+				continue
+			}
+			list = append(list, name+"="+s)
+		}
+		sort.Strings(list)
+		return list, nil
+	default:
+		return scalar(key, value, lookup)
+	}
+}
+
+func scalar(key string, value any, lookup LookupFunc) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		s, err := Expand(v, lookup)
+		return s, errors.Wrap(err, key)
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	default:
+		return "", errors.Errorf("%s has an unsupported value %v, quote it", key, value)
+	}
+}
+
+// Expand interpolates ${NAME} and ${NAME:-default} into s, the default applies when NAME is unset
+// or empty. $$ is a literal $ and a $ not followed by { or $ is kept. An unset NAME without
+// a default is an error, a job must not quietly read or write the wrong path.
+func Expand(s string, lookup LookupFunc) (string, error) {
+	var b strings.Builder
+	rest := s
+	for {
+		i := strings.IndexByte(rest, '$')
+		if i < 0 || i == len(rest)-1 {
+			b.WriteString(rest)
+			return b.String(), nil
+		}
+		b.WriteString(rest[:i])
+		switch rest[i+1] {
+		case '$':
+			b.WriteByte('$')
+			rest = rest[i+2:]
+		case '{':
+			end := strings.IndexByte(rest[i:], '}')
+			if end < 0 {
+				return "", errors.New("unclosed ${ in " + strconv.Quote(s))
+			}
+			name, def, hasDefault := strings.Cut(rest[i+2:i+end], ":-")
+			if !validName(name) {
+				return "", errors.New("invalid variable name " + strconv.Quote(name) + " in " + strconv.Quote(s))
+			}
+			value, ok := lookup(name)
+			if value == "" && hasDefault {
+				value = def
+			} else if !ok {
+				return "", errors.New("environment variable " + name + " is not set")
+			}
+			b.WriteString(value)
+			rest = rest[i+end+1:]
+		default:
+			b.WriteByte('$')
+			rest = rest[i+1:]
+		}
+	}
+}
+
+func validName(name string) bool {
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		return false
+	}
+	for _, r := range name {
+		if r != '_' && (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
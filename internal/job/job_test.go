@@ -0,0 +1,202 @@
+package job
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func lookup(vars map[string]string) LookupFunc {
+	return func(name string) (string, bool) {
+		v, ok := vars[name]
+		return v, ok
+	}
+}
+
+var env = lookup(map[string]string{"DATA": "/data", "EMPTY": ""}) //nolint:gochecknoglobals // test fixture
+
+const yamlJobs = `
+defaults:
+  compression: 1
+  delimiter: auto
+jobs:
+  - name: orders
+    input: ${DATA}/orders.csv
+    output: out/orders.parquet
+    flush: 50000
+    lazy-quotes: true
+    schema:
+      id: int
+      amount: decimal(9,2)
+    filter:
+      status: paid
+      amount: ">=100"
+      note: ""
+    partition-by: region
+  - name: export
+    command: csv
+    input: s3://bucket/${EMPTY:-x}.parquet
+    delimiter: ";"
+    header: ["Authorization: Bearer $$TOKEN", "X-A: 1"]
+    filter: ["day>=${DATA}", "day<2025"]
+`
+
+const tomlJobs = `
+[defaults]
+compression = 1
+delimiter = "auto"
+
+[[jobs]]
+name = "orders"
+input = "${DATA}/orders.csv"
+output = "out/orders.parquet"
+flush = 50000
+lazy-quotes = true
+schema = { id = "int", amount = "decimal(9,2)" }
+filter = { status = "paid", amount = ">=100", note = "" }
+partition-by = "region"
+
+[[jobs]]
+name = "export"
+command = "csv"
+input = "s3://bucket/${EMPTY:-x}.parquet"
+delimiter = ";"
+header = ["Authorization: Bearer $$TOKEN", "X-A: 1"]
+filter = ["day>=${DATA}", "day<2025"]
+`
+
+func TestParse(t *testing.T) {
+	want := []Job{
+		{
+			Name:   "orders",
+			Input:  "/data/orders.csv",
+			Output: "out/orders.parquet",
+			Options: map[string]any{
+				"compression":  "1",
+				"delimiter":    "auto",
+				"flush":        "50000",
+				"lazy-quotes":  "true",
+				"schema":       []string{"amount=decimal(9,2)", "id=int"},
+				"filter":       []string{"amount>=100", "note=", "status=paid"},
+				"partition-by": "region",
+			},
+		},
+		{
+			Name:    "export",
+			Command: "csv",
+			Input:   "s3://bucket/x.parquet",
+			Options: map[string]any{
+				"compression": "1",
+				"delimiter":   ";",
+				"header":      []string{"Authorization: Bearer $TOKEN", "X-A: 1"},
+				"filter":      []string{"day>=/data", "day<2025"},
+			},
+		},
+	}
+	for ext, data := range map[string]string{".yaml": yamlJobs, ".toml": tomlJobs} {
+		got, err := Parse([]byte(data), ext, env)
+		if err != nil {
+			t.Fatalf("Parse(%s) error = %v", ext, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Parse(%s) = %+v; want %+v", ext, got, want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name, ext, data, wantErr string
+	}{
+		{"format", ".json", `{}`, "must be .yaml"},
+		{"no jobs", ".yaml", "jobs: []", "no jobs"},
+		{"unknown yaml key", ".yaml", "job:\n  - name: a\n", "field job not found"},
+		{"unknown toml key", ".toml", "[[job]]\nname = \"a\"\n", "unknown key job"},
+		{"no name", ".yaml", "jobs:\n  - input: a.csv\n", "job 1: name is required"},
+		{"no input", ".yaml", "jobs:\n  - name: a\n", "job a: input is required"},
+		{"duplicate", ".yaml", "jobs:\n  - {name: a, input: a.csv}\n  - {name: a, input: b.csv}\n", "defined twice"},
+		{"unset variable", ".yaml", "jobs:\n  - {name: a, input: \"${NOPE}/a.csv\"}\n", "NOPE is not set"},
+		{"map option", ".yaml", "jobs:\n  - {name: a, input: a.csv, quote: {a: b}}\n", "quote can't be a map"},
+		{"filter of a list", ".yaml", "jobs:\n  - {name: a, input: a.csv, filter: {a: [b]}}\n", "filter.a has an unsupported value"},
+		{"list input", ".yaml", "jobs:\n  - {name: a, input: [a.csv]}\n", "input must be a string"},
+		{"bad yaml", ".yaml", "jobs: [", "error parse yaml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.data), tt.ext, env)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Parse() error = %v; want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.yml")
+	if err := os.WriteFile(path, []byte(yamlJobs), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	jobs, err := Load(path, env)
+	if err != nil || len(jobs) != 2 {
+		t.Fatalf("Load() = %v, %v", jobs, err)
+	}
+	if _, err = Load(filepath.Join(t.TempDir(), "none.yaml"), env); err == nil {
+		t.Error("Load() of a missing file: no error")
+	}
+}
+
+func TestSelect(t *testing.T) {
+	jobs := []Job{{Name: "a"}, {Name: "b"}, {Name: "c"}}
+	tests := []struct {
+		names   []string
+		want    string
+		wantErr bool
+	}{
+		{nil, "a b c", false},
+		{[]string{"c", "a"}, "a c", false},
+		{[]string{"b", "b"}, "b", false},
+		{[]string{"d"}, "", true},
+	}
+	for _, tt := range tests {
+		got, err := Select(jobs, tt.names)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("Select(%v) error = %v; wantErr %v", tt.names, err, tt.wantErr)
+		}
+		names := make([]string, 0, len(got))
+		for _, j := range got {
+			names = append(names, j.Name)
+		}
+		if !tt.wantErr && strings.Join(names, " ") != tt.want {
+			t.Errorf("Select(%v) = %v; want %s", tt.names, names, tt.want)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	vars := lookup(map[string]string{"A": "1", "B_2": "two", "EMPTY": ""})
+	tests := []struct {
+		in, want string
+		wantErr  bool
+	}{
+		{"plain", "plain", false},
+		{"${A}/${B_2}", "1/two", false},
+		{"${EMPTY:-x}${A:-y}", "x1", false},
+		{"${NOPE:-d/e}", "d/e", false},
+		{"${EMPTY}", "", false},
+		{"$$A $A $", "$A $A $", false},
+		{"${NOPE}", "", true},
+		{"${A", "", true},
+		{"${1A}", "", true},
+	}
+	for _, tt := range tests {
+		got, err := Expand(tt.in, vars)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("Expand(%q) error = %v; wantErr %v", tt.in, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("Expand(%q) = %q; want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	namespace       = "csv2parquet"
+	shutdownTimeout = 5 * time.Second
+)
+
+type contextKey struct{}
+
+// Metrics are the Prometheus collectors of a single command run.
+type Metrics struct {
+	registry        *prometheus.Registry
+	RowsRead        prometheus.Counter
+	RowsWritten     prometheus.Counter
+	RowsRejected    prometheus.Counter
+	BytesRead       prometheus.Counter
+	BatchesInFlight prometheus.Gauge
+	labels          prometheus.Labels
+	server          *http.Server
+	queue           atomic.Pointer[func() int]
+	queueOnce       sync.Once
+}
+
+func New(command string) *Metrics {
+	labels := prometheus.Labels{"command": command}
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		labels:   labels,
+		RowsRead: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Name: "rows_read_total", Help: "Rows read from the input.", ConstLabels: labels,
+		}),
+		RowsWritten: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Name: "rows_written_total", Help: "Rows written to the output.", ConstLabels: labels,
+		}),
+		RowsRejected: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Name: "rows_rejected_total", Help: "Input rows skipped as bad rows.", ConstLabels: labels,
+		}),
+		BytesRead: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Name: "bytes_read_total", Help: "Bytes read from the input.", ConstLabels: labels,
+		}),
+		BatchesInFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "batches_in_flight", Help: "Batches read but not written yet.", ConstLabels: labels,
+		}),
+	}
+	m.registry.MustRegister(
+		m.RowsRead,
+		m.RowsWritten,
+		m.RowsRejected,
+		m.BytesRead,
+		m.BatchesInFlight,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// WatchQueue exposes the current depth of a batch channel, e.g. the one of file.BatchProcessor.
+// A later call watches the queue of the next conversion instead.
+func (m *Metrics) WatchQueue(depth func() int) {
+	m.queue.Store(&depth)
+	m.queueOnce.Do(func() {
+		m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Name:        "batch_queue_depth",
+			Help:        "Batches waiting in the reader channel.",
+			ConstLabels: m.labels,
+		}, func() float64 {
+			return float64((*m.queue.Load())())
+		}))
+	})
+}
+
+// Handler serves /metrics and /debug/pprof.
+func (m *Metrics) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry}))
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
+
+// Serve exposes Handler on addr until Close is called.
+func (m *Metrics) Serve(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return errors.Wrap(err, "error listen metrics on "+addr)
+	}
+	m.server = &http.Server{Handler: m.Handler(), ReadHeaderTimeout: shutdownTimeout}
+	go func() {
+		_ = m.server.Serve(listener)
+	}()
+	return nil
+}
+
+func (m *Metrics) Close() error {
+	if m.server == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return m.server.Shutdown(ctx)
+}
+
+func NewContext(ctx context.Context, m *Metrics) context.Context {
+	return context.WithValue(ctx, contextKey{}, m)
+}
+
+// FromContext returns the metrics of the current run, unregistered ones are returned if there are none,
+// so callers never have to check for nil.
+func FromContext(ctx context.Context) *Metrics {
+	if ctx != nil {
+		if m, ok := ctx.Value(contextKey{}).(*Metrics); ok {
+			return m
+		}
+	}
+	return New("")
+}
@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	m := FromContext(NewContext(context.Background(), New("parquet")))
+	m.RowsRead.Add(3)
+	m.WatchQueue(func() int {
+		return 3
+	})
+	queue := make(chan int, 4)
+	queue <- 1
+	m.WatchQueue(func() int {
+		return len(queue)
+	})
+
+	srv := httptest.NewServer(m.Handler())
+	defer srv.Close()
+
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"rows", "/metrics", `csv2parquet_rows_read_total{command="parquet"} 3`},
+		{"queue depth", "/metrics", `csv2parquet_batch_queue_depth{command="parquet"} 1`},
+		{"gc stats", "/metrics", "go_gc_duration_seconds"},
+		{"pprof", "/debug/pprof/", "goroutine"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, srv.URL+tt.path, nil)
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("GET %s error: %v", tt.path, err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), tt.want) {
+				t.Errorf("GET %s = %d; expected body to contain %q", tt.path, resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
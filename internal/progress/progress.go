@@ -0,0 +1,183 @@
+package progress
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dbunt1tled/parquet2csv/internal/helper"
+)
+
+const (
+	TTYInterval = 200 * time.Millisecond
+	LogInterval = 10 * time.Second
+	barWidth    = 20
+)
+
+// Progress periodically reports conversion progress on a terminal line,
+// or as log lines when the output is not a terminal.
+// The fraction done is based on rows when the total number of rows is known (parquet footer),
+// otherwise on bytes read out of the input size.
+type Progress struct {
+	out       *os.File
+	logger    *slog.Logger
+	tty       bool
+	size      int64
+	totalRows int64
+	rows      atomic.Int64
+	bytes     atomic.Int64
+	baseRows  int64
+	baseBytes int64
+	start     time.Time
+	stop      chan struct{}
+	wg        sync.WaitGroup
+}
+
+func New(out *os.File, size, totalRows int64) *Progress {
+	return &Progress{
+		out:       out,
+		logger:    slog.New(slog.NewTextHandler(out, nil)),
+		tty:       IsTerminal(out),
+		size:      size,
+		totalRows: totalRows,
+		stop:      make(chan struct{}),
+	}
+}
+
+// IsTerminal reports whether f is a character device, i.e. an interactive terminal.
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// Start begins reporting, rows and bytes already processed (e.g. on resume) don't count towards the rates.
+func (p *Progress) Start(rows, bytes int64) *Progress {
+	p.baseRows, p.baseBytes = rows, bytes
+	p.rows.Store(rows)
+	p.bytes.Store(bytes)
+	p.start = time.Now()
+	interval := LogInterval
+	if p.tty {
+		interval = TTYInterval
+	}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				p.report()
+			}
+		}
+	}()
+	return p
+}
+
+// Set updates the number of processed rows and input bytes.
+func (p *Progress) Set(rows, bytes int64) {
+	p.rows.Store(rows)
+	p.bytes.Store(bytes)
+}
+
+// Stop prints the final state and stops reporting.
+func (p *Progress) Stop() {
+	close(p.stop)
+	p.wg.Wait()
+	p.report()
+	if p.tty {
+		_, _ = fmt.Fprintln(p.out)
+	}
+}
+
+func (p *Progress) report() {
+	s := p.snapshot(time.Now())
+	if p.tty {
+		_, _ = fmt.Fprintf(p.out, "\r\033[K%s", s.String())
+		return
+	}
+	p.logger.Info(
+		"progress",
+		"percent", fmt.Sprintf("%.1f", s.Fraction*100), //nolint:mnd // percent
+		"rows", s.Rows,
+		"bytes", s.Bytes,
+		"size", p.size,
+		"rows_per_sec", int64(s.RowsPerSec),
+		"mb_per_sec", fmt.Sprintf("%.2f", s.BytesPerSec/(1024*1024)), //nolint:mnd // MB
+		"mem_mb", s.Memory/(1024*1024), //nolint:mnd // MB
+		"eta", s.ETA.String(),
+	)
+}
+
+type Snapshot struct {
+	Rows        int64
+	Bytes       int64
+	Size        int64
+	Fraction    float64
+	RowsPerSec  float64
+	BytesPerSec float64
+	Memory      uint64
+	ETA         time.Duration
+}
+
+func (p *Progress) snapshot(now time.Time) Snapshot {
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	s := Snapshot{
+		Rows:   p.rows.Load(),
+		Bytes:  p.bytes.Load(),
+		Size:   p.size,
+		Memory: memStats.Sys,
+	}
+	switch {
+	case p.totalRows > 0:
+		s.Fraction = float64(s.Rows) / float64(p.totalRows)
+	case p.size > 0:
+		s.Fraction = float64(s.Bytes) / float64(p.size)
+	}
+	s.Fraction = min(s.Fraction, 1)
+	elapsed := now.Sub(p.start).Seconds()
+	if elapsed <= 0 {
+		return s
+	}
+	s.RowsPerSec = float64(s.Rows-p.baseRows) / elapsed
+	s.BytesPerSec = float64(s.Bytes-p.baseBytes) / elapsed
+	var base float64
+	if p.totalRows > 0 {
+		base = float64(p.baseRows) / float64(p.totalRows)
+	} else if p.size > 0 {
+		base = float64(p.baseBytes) / float64(p.size)
+	}
+	if s.Fraction > base {
+		s.ETA = time.Duration(elapsed * (1 - s.Fraction) / (s.Fraction - base) * float64(time.Second)).Round(time.Second)
+	}
+	return s
+}
+
+func (s Snapshot) String() string {
+	done := int(s.Fraction * barWidth)
+	return fmt.Sprintf(
+		"[%s%s] %5.1f%% | %d rows | %s / %s | %.0f rows/s | %.2f MB/s | mem %d MB | ETA %s",
+		strings.Repeat("#", done),
+		strings.Repeat(".", barWidth-done),
+		s.Fraction*100, //nolint:mnd // percent
+		s.Rows,
+		helper.GetFileSize(s.Bytes),
+		helper.GetFileSize(s.Size),
+		s.RowsPerSec,
+		s.BytesPerSec/(1024*1024), //nolint:mnd // MB
+		s.Memory/(1024*1024),      //nolint:mnd // MB
+		s.ETA,
+	)
+}
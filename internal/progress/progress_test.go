@@ -0,0 +1,45 @@
+package progress
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshot(t *testing.T) {
+	start := time.Now()
+	tests := []struct {
+		name         string
+		size, total  int64
+		base         [2]int64
+		rows, bytes  int64
+		wantFraction float64
+		wantETA      time.Duration
+	}{
+		{"by bytes", 1000, 0, [2]int64{0, 0}, 50, 250, 0.25, 30 * time.Second},
+		{"by rows", 1000, 200, [2]int64{0, 0}, 100, 10, 0.5, 10 * time.Second},
+		{"resumed", 1000, 0, [2]int64{0, 500}, 10, 750, 0.75, 10 * time.Second},
+		{"nothing yet", 1000, 0, [2]int64{0, 0}, 0, 0, 0, 0},
+		{"unknown size", 0, 0, [2]int64{0, 0}, 10, 10, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New(os.Stderr, tt.size, tt.total)
+			p.baseRows, p.baseBytes = tt.base[0], tt.base[1]
+			p.start = start
+			p.Set(tt.rows, tt.bytes)
+			s := p.snapshot(start.Add(10 * time.Second))
+			if s.Fraction != tt.wantFraction {
+				t.Errorf("Fraction = %v; want %v", s.Fraction, tt.wantFraction)
+			}
+			if s.ETA != tt.wantETA {
+				t.Errorf("ETA = %v; want %v", s.ETA, tt.wantETA)
+			}
+			if !strings.Contains(s.String(), "ETA") {
+				t.Errorf("String() = %q; expected to contain 'ETA'", s.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package report
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/pkg/errors"
+	parquetschema "github.com/xitongsys/parquet-go/schema"
+)
+
+type contextKey struct{}
+
+// Report is the machine-readable summary of a single command run.
+type Report struct {
+	Command          string    `json:"command"`
+	Version          string    `json:"version"`
+	Input            File      `json:"input"`
+	Output           File      `json:"output"`
+	Parts            []File    `json:"parts,omitempty"`
+	RowsRead         int64     `json:"rows_read"`
+	RowsWritten      int64     `json:"rows_written"`
+	RowsRejected     int64     `json:"rows_rejected"`
+	RowsFiltered     int64     `json:"rows_filtered"`
+	CompressionRatio float64   `json:"compression_ratio"`
+	Schema           []Column  `json:"schema"`
+	Timings          Timings   `json:"timings"`
+	PeakRSS          int64     `json:"peak_rss"`
+	StartedAt        time.Time `json:"started_at"`
+	FinishedAt       time.Time `json:"finished_at"`
+	Error            string    `json:"error,omitempty"`
+}
+
+type File struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+type Column struct {
+	Name          string `json:"name"`
+	Type          string `json:"type"`
+	ConvertedType string `json:"converted_type,omitempty"`
+}
+
+// Timings holds the time spent per phase, the total is wall time of the whole run.
+type Timings struct {
+	Read    Duration `json:"read"`
+	Convert Duration `json:"convert"`
+	Write   Duration `json:"write"`
+	Flush   Duration `json:"flush"`
+	Total   Duration `json:"total"`
+}
+
+// Duration is marshaled as seconds.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return sonic.ConfigStd.Marshal(time.Duration(d).Seconds())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s float64
+	if err := sonic.ConfigStd.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*d = Duration(s * float64(time.Second))
+	return nil
+}
+
+// Since adds the time passed since start to the phase.
+func (d *Duration) Since(start time.Time) {
+	*d += Duration(time.Since(start))
+}
+
+func New(command, version string) *Report {
+	return &Report{
+		Command:   command,
+		Version:   version,
+		StartedAt: time.Now(),
+	}
+}
+
+func NewContext(ctx context.Context, r *Report) context.Context {
+	return context.WithValue(ctx, contextKey{}, r)
+}
+
+// FromContext returns the report of the current run, a detached one is returned if there is none,
+// so callers never have to check for nil.
+func FromContext(ctx context.Context) *Report {
+	if ctx != nil {
+		if r, ok := ctx.Value(contextKey{}).(*Report); ok {
+			return r
+		}
+	}
+	return New("", "")
+}
+
+// Finish completes the report with file sizes, ratio, peak memory and the run error.
+func (r *Report) Finish(err error) {
+	r.FinishedAt = time.Now()
+	r.Timings.Total = Duration(r.FinishedAt.Sub(r.StartedAt))
+	r.PeakRSS = PeakRSS()
+	if err != nil {
+		r.Error = err.Error()
+	}
+	r.Input.Size = fileSize(r.Input.Path, r.Input.Size)
+	if len(r.Parts) > 0 {
+		r.Output.Size = 0
+		for i := range r.Parts {
+			r.Parts[i].Size = fileSize(r.Parts[i].Path, 0)
+			r.Output.Size += r.Parts[i].Size
+		}
+	} else {
+		r.Output.Size = fileSize(r.Output.Path, r.Output.Size)
+	}
+	r.CompressionRatio = ratio(r.Input, r.Output)
+}
+
+// ratio is the size of the plain text side divided by the size of the columnar side.
+func ratio(input, output File) float64 {
+	text, columnar := input.Size, output.Size
+	if filepath.Ext(input.Path) == ".parquet" {
+		text, columnar = columnar, text
+	}
+	if columnar == 0 {
+		return 0
+	}
+	return float64(text) / float64(columnar)
+}
+
+// fileSize is the size of a local file, or size as set by the command for objects in storage.
+func fileSize(path string, size int64) int64 {
+	if path == "" {
+		return 0
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return size
+	}
+	return info.Size()
+}
+
+// Write stores the report as indented JSON.
+func (r *Report) Write(path string) error {
+	data, err := sonic.ConfigStd.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "error marshal report")
+	}
+	return errors.Wrap(os.WriteFile(path, append(data, '\n'), 0o644), "error write report") //nolint:mnd,gosec // file perm
+}
+
+// Append adds the report as a single NDJSON line to the log file.
+func (r *Report) Append(path string) error {
+	data, err := sonic.ConfigStd.Marshal(r)
+	if err != nil {
+		return errors.Wrap(err, "error marshal report")
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644) //nolint:mnd,gosec // file perm
+	if err != nil {
+		return errors.Wrap(err, "error open report log")
+	}
+	if _, err = f.Write(append(data, '\n')); err != nil {
+		_ = f.Close()
+		return errors.Wrap(err, "error append report log")
+	}
+	return f.Close()
+}
+
+// ColumnsFromParquet describes the leaf columns of a parquet schema.
+func ColumnsFromParquet(sh *parquetschema.SchemaHandler) []Column {
+	var columns []Column
+	for i, el := range sh.SchemaElements {
+		if el.NumChildren != nil {
+			continue
+		}
+		column := Column{Name: sh.Infos[i].ExName, Type: el.GetType().String()}
+		if el.IsSetConvertedType() {
+			column.ConvertedType = el.GetConvertedType().String()
+		}
+		columns = append(columns, column)
+	}
+	return columns
+}
+
+// ColumnsFromStruct describes the schema of a parquet-go struct by its `parquet` field tags.
+func ColumnsFromStruct(v interface{}) []Column {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+	columns := make([]Column, 0, t.NumField())
+	for i := range t.NumField() {
+		tag, ok := t.Field(i).Tag.Lookup("parquet")
+		if !ok {
+			continue
+		}
+		column := Column{Name: t.Field(i).Name}
+		for _, kv := range strings.Split(tag, ",") {
+			key, value, _ := strings.Cut(strings.TrimSpace(kv), "=")
+			switch strings.ToLower(key) {
+			case "name":
+				column.Name = value
+			case "type":
+				column.Type = value
+			case "convertedtype":
+				column.ConvertedType = value
+			}
+		}
+		columns = append(columns, column)
+	}
+	return columns
+}
@@ -0,0 +1,104 @@
+package report
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bytedance/sonic"
+	"github.com/pkg/errors"
+)
+
+func TestColumnsFromStruct(t *testing.T) {
+	type row struct {
+		ID   string `parquet:"name=id, type=BYTE_ARRAY, convertedtype=UTF8"`
+		Age  int32  `parquet:"name=age, type=INT32"`
+		Skip string
+	}
+
+	tests := []struct {
+		name string
+		in   interface{}
+		want []Column
+	}{
+		{"struct", row{}, []Column{{"id", "BYTE_ARRAY", "UTF8"}, {"age", "INT32", ""}}},
+		{"pointer", &row{}, []Column{{"id", "BYTE_ARRAY", "UTF8"}, {"age", "INT32", ""}}},
+		{"not a struct", 1, nil},
+		{"nil", nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ColumnsFromStruct(tt.in)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ColumnsFromStruct() = %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRatio(t *testing.T) {
+	tests := []struct {
+		name          string
+		input, output File
+		want          float64
+	}{
+		{"csv to parquet", File{"a.csv", 400}, File{"a.parquet", 100}, 4},
+		{"parquet to csv", File{"a.parquet", 100}, File{"a.csv", 400}, 4},
+		{"empty output", File{"a.csv", 400}, File{"a.parquet", 0}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ratio(tt.input, tt.output); got != tt.want {
+				t.Errorf("ratio() = %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReportWriteAppend(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.csv")
+	if err := os.WriteFile(input, []byte("a\n1\n"), 0o600); err != nil {
+		t.Fatalf("Failed to create input: %v", err)
+	}
+
+	r := FromContext(NewContext(context.Background(), New("parquet", "v1.0.0")))
+	r.Input.Path = input
+	r.RowsRead, r.RowsWritten = 1, 1
+	r.Finish(errors.New("boom"))
+
+	path := filepath.Join(dir, "report.json")
+	if err := r.Write(path); err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read report: %v", err)
+	}
+	got := &Report{}
+	if err = sonic.ConfigStd.Unmarshal(data, got); err != nil {
+		t.Fatalf("Failed to parse report: %v", err)
+	}
+	if got.Command != "parquet" || got.Version != "v1.0.0" || got.Input.Size != 4 || got.Error != "boom" {
+		t.Errorf("report = %+v; want command, version, input size and error set", got)
+	}
+
+	log := filepath.Join(dir, "report.ndjson")
+	for range 2 {
+		if err = r.Append(log); err != nil {
+			t.Fatalf("Append() error: %v", err)
+		}
+	}
+	data, err = os.ReadFile(log)
+	if err != nil {
+		t.Fatalf("Failed to read report log: %v", err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 2 {
+		t.Errorf("report log has %d lines; want 2", lines)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build linux
+
+package report
+
+import "syscall"
+
+// PeakRSS returns the maximum resident set size of the process in bytes.
+func PeakRSS() int64 {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return 0
+	}
+	return usage.Maxrss * 1024 //nolint:mnd // Maxrss is in kilobytes on linux
+}
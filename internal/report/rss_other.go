@@ -0,0 +1,12 @@
+//go:build !linux && !darwin
+
+package report
+
+import "runtime"
+
+// PeakRSS has no rusage here, the memory obtained from the OS by the Go runtime is the closest estimate.
+func PeakRSS() int64 {
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	return int64(memStats.Sys) //nolint:gosec // fits in int64
+}
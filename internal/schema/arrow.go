@@ -0,0 +1,694 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"math/big"
+	"reflect"
+	"strconv"
+
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/apache/arrow/go/arrow/decimal128"
+	"github.com/apache/arrow/go/arrow/ipc"
+	"github.com/pkg/errors"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/types"
+)
+
+const (
+	decimal128Size  = 16
+	maxDecimal128   = 38
+	maxDecimalInt32 = 9
+	maxDecimalInt64 = 18
+	millisPerDay    = 24 * 60 * 60 * 1000
+	millisPerSecond = 1000
+)
+
+// ArrowSchemaKey is the parquet key-value metadata key arrow writers keep the original schema under.
+const ArrowSchemaKey = "ARROW:schema"
+
+// arrowEOS ends an arrow ipc stream.
+var arrowEOS = []byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0} //nolint:gochecknoglobals // ipc constant
+
+// ArrowSchema maps the parquet schema tree to an arrow schema.
+func (n *Node) ArrowSchema() (*arrow.Schema, error) {
+	fields := make([]arrow.Field, 0, len(n.Children))
+	for _, c := range n.Children {
+		f, err := c.arrowField()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, f)
+	}
+	return arrow.NewSchema(fields, nil), nil
+}
+
+// EncodeArrowSchema serializes an arrow schema the way arrow writers store it under ArrowSchemaKey.
+func EncodeArrowSchema(s *arrow.Schema) (string, error) {
+	var buf bytes.Buffer
+	w := ipc.NewWriter(&buf, ipc.WithSchema(s))
+	if err := w.Close(); err != nil {
+		return "", errors.Wrap(err, "error serialize arrow schema")
+	}
+	return base64.StdEncoding.EncodeToString(bytes.TrimSuffix(buf.Bytes(), arrowEOS)), nil
+}
+
+// DecodeArrowSchema reads an arrow schema stored under ArrowSchemaKey.
+func DecodeArrowSchema(value string) (*arrow.Schema, error) {
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errors.Wrap(err, "error decode arrow schema")
+	}
+	r, err := ipc.NewReader(bytes.NewReader(append(data, arrowEOS...)))
+	if err != nil {
+		return nil, errors.Wrap(err, "error read arrow schema")
+	}
+	defer r.Release()
+	return r.Schema(), nil
+}
+
+// RestoreArrowSchema brings back what parquet can't keep from the schema the file was written from:
+// timestamp time zones of top-level columns and schema and field metadata.
+func RestoreArrowSchema(s, stored *arrow.Schema) *arrow.Schema {
+	fields := s.Fields()
+	for i := range fields {
+		idx := stored.FieldIndices(fields[i].Name)
+		if len(idx) != 1 {
+			continue
+		}
+		orig := stored.Field(idx[0])
+		fields[i].Metadata = orig.Metadata
+		ts, ok := fields[i].Type.(*arrow.TimestampType)
+		origTS, origOk := orig.Type.(*arrow.TimestampType)
+		if ok && origOk && ts.Unit == origTS.Unit && (ts.TimeZone == "") == (origTS.TimeZone == "") {
+			fields[i].Type = origTS
+		}
+	}
+	md := stored.Metadata()
+	return arrow.NewSchema(fields, &md)
+}
+
+func (n *Node) arrowField() (arrow.Field, error) {
+	t, err := n.arrowType()
+	if err != nil {
+		return arrow.Field{}, errors.Wrap(err, "column "+n.Name)
+	}
+	return arrow.Field{Name: n.Name, Type: t, Nullable: n.isOptional()}, nil
+}
+
+func (n *Node) arrowType() (arrow.DataType, error) {
+	switch {
+	case n.isList():
+		elem := n.Children[0].Children[0]
+		t, err := elem.arrowType()
+		if err != nil {
+			return nil, err
+		}
+		// the arrow list and map builders always make nullable elements
+		return arrow.ListOf(t), nil
+	case n.isMap():
+		key, value := n.Children[0].Children[0], n.Children[0].Children[1]
+		kt, err := key.arrowType()
+		if err != nil {
+			return nil, err
+		}
+		vt, err := value.arrowType()
+		if err != nil {
+			return nil, err
+		}
+		return arrow.MapOf(kt, vt), nil
+	case n.isRepeated():
+		t, err := (&Node{Name: n.Name, Field: n.Field, Element: n.single(), Children: n.Children}).arrowType()
+		if err != nil {
+			return nil, err
+		}
+		return arrow.ListOf(t), nil
+	case len(n.Children) == 0:
+		return n.arrowLeaf()
+	default:
+		fields := make([]arrow.Field, 0, len(n.Children))
+		for _, c := range n.Children {
+			f, err := c.arrowField()
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, f)
+		}
+		return arrow.StructOf(fields...), nil
+	}
+}
+
+func (n *Node) arrowLeaf() (arrow.DataType, error) {
+	el := n.Element
+	ct := parquet.ConvertedType(-1)
+	if el.IsSetConvertedType() {
+		ct = el.GetConvertedType()
+	}
+	if ct == parquet.ConvertedType_DECIMAL {
+		if el.GetPrecision() > maxDecimal128 {
+			return nil, errors.New("decimal precision " + strconv.Itoa(int(el.GetPrecision())) + " doesn't fit decimal128")
+		}
+		return &arrow.Decimal128Type{Precision: el.GetPrecision(), Scale: el.GetScale()}, nil
+	}
+	if unit := n.timestampUnit(); unit != "" {
+		tz := "UTC"
+		if el.IsSetLogicalType() && el.GetLogicalType().IsSetTIMESTAMP() && !el.GetLogicalType().GetTIMESTAMP().IsAdjustedToUTC {
+			tz = ""
+		}
+		return &arrow.TimestampType{Unit: arrowUnit(unit), TimeZone: tz}, nil
+	}
+	switch el.GetType() {
+	case parquet.Type_BOOLEAN:
+		return arrow.FixedWidthTypes.Boolean, nil
+	case parquet.Type_INT32:
+		switch ct { //nolint:exhaustive // the rest are plain int32
+		case parquet.ConvertedType_INT_8:
+			return arrow.PrimitiveTypes.Int8, nil
+		case parquet.ConvertedType_INT_16:
+			return arrow.PrimitiveTypes.Int16, nil
+		case parquet.ConvertedType_UINT_8:
+			return arrow.PrimitiveTypes.Uint8, nil
+		case parquet.ConvertedType_UINT_16:
+			return arrow.PrimitiveTypes.Uint16, nil
+		case parquet.ConvertedType_UINT_32:
+			return arrow.PrimitiveTypes.Uint32, nil
+		case parquet.ConvertedType_DATE:
+			return arrow.FixedWidthTypes.Date32, nil
+		case parquet.ConvertedType_TIME_MILLIS:
+			return arrow.FixedWidthTypes.Time32ms, nil
+		}
+		return arrow.PrimitiveTypes.Int32, nil
+	case parquet.Type_INT64:
+		switch {
+		case ct == parquet.ConvertedType_UINT_64:
+			return arrow.PrimitiveTypes.Uint64, nil
+		case ct == parquet.ConvertedType_TIME_MICROS:
+			return arrow.FixedWidthTypes.Time64us, nil
+		case el.IsSetLogicalType() && el.GetLogicalType().IsSetTIME() && el.GetLogicalType().GetTIME().GetUnit().IsSetNANOS():
+			return arrow.FixedWidthTypes.Time64ns, nil
+		}
+		return arrow.PrimitiveTypes.Int64, nil
+	case parquet.Type_INT96:
+		return &arrow.TimestampType{Unit: arrow.Nanosecond, TimeZone: "UTC"}, nil
+	case parquet.Type_FLOAT:
+		return arrow.PrimitiveTypes.Float32, nil
+	case parquet.Type_DOUBLE:
+		return arrow.PrimitiveTypes.Float64, nil
+	case parquet.Type_BYTE_ARRAY:
+		if ct == parquet.ConvertedType_UTF8 || ct == parquet.ConvertedType_ENUM || ct == parquet.ConvertedType_JSON ||
+			(el.IsSetLogicalType() && el.GetLogicalType().IsSetSTRING()) {
+			return arrow.BinaryTypes.String, nil
+		}
+		return arrow.BinaryTypes.Binary, nil
+	case parquet.Type_FIXED_LEN_BYTE_ARRAY:
+		return &arrow.FixedSizeBinaryType{ByteWidth: int(el.GetTypeLength())}, nil
+	}
+	return nil, errors.New("unsupported parquet type " + el.GetType().String())
+}
+
+func arrowUnit(unit string) arrow.TimeUnit {
+	switch unit {
+	case "ms":
+		return arrow.Millisecond
+	case "us":
+		return arrow.Microsecond
+	default:
+		return arrow.Nanosecond
+	}
+}
+
+// AppendArrow appends a row decoded by the generic parquet-go reader to a record builder
+// made for the schema returned by ArrowSchema.
+func (n *Node) AppendArrow(b *array.RecordBuilder, row any) {
+	v := reflect.ValueOf(row)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	for i, c := range n.Children {
+		c.appendArrow(b.Field(i), v.FieldByName(c.Field))
+	}
+}
+
+func (n *Node) appendArrow(b array.Builder, v reflect.Value) {
+	if v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			b.AppendNull()
+			return
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		b.AppendNull()
+		return
+	}
+	switch {
+	case n.isList():
+		lb, _ := b.(*array.ListBuilder)
+		if v.IsNil() && n.isOptional() {
+			lb.AppendNull()
+			return
+		}
+		lb.Append(true)
+		elem := n.Children[0].Children[0]
+		for i := range v.Len() {
+			elem.appendArrow(lb.ValueBuilder(), v.Index(i))
+		}
+	case n.isMap():
+		mb, _ := b.(*array.MapBuilder)
+		if v.IsNil() && n.isOptional() {
+			mb.AppendNull()
+			return
+		}
+		mb.Append(true)
+		key, value := n.Children[0].Children[0], n.Children[0].Children[1]
+		iter := v.MapRange()
+		for iter.Next() {
+			key.appendArrow(mb.KeyBuilder(), iter.Key())
+			value.appendArrow(mb.ItemBuilder(), iter.Value())
+		}
+	case n.isRepeated() && v.Kind() == reflect.Slice:
+		lb, _ := b.(*array.ListBuilder)
+		lb.Append(true)
+		elem := &Node{Name: n.Name, Field: n.Field, Element: n.single(), Children: n.Children}
+		for i := range v.Len() {
+			elem.appendArrow(lb.ValueBuilder(), v.Index(i))
+		}
+	case len(n.Children) == 0:
+		n.appendArrowScalar(b, v)
+	default:
+		sb, _ := b.(*array.StructBuilder)
+		sb.Append(true)
+		for i, c := range n.Children {
+			c.appendArrow(sb.FieldBuilder(i), v.FieldByName(c.Field))
+		}
+	}
+}
+
+//nolint:gocyclo,cyclop // one case per arrow builder
+func (n *Node) appendArrowScalar(b array.Builder, v reflect.Value) {
+	switch bb := b.(type) {
+	case *array.BooleanBuilder:
+		bb.Append(v.Bool())
+	case *array.Int8Builder:
+		bb.Append(int8(v.Int()))
+	case *array.Int16Builder:
+		bb.Append(int16(v.Int()))
+	case *array.Int32Builder:
+		bb.Append(int32(v.Int()))
+	case *array.Int64Builder:
+		bb.Append(v.Int())
+	case *array.Uint8Builder:
+		bb.Append(uint8(v.Int()))
+	case *array.Uint16Builder:
+		bb.Append(uint16(v.Int()))
+	case *array.Uint32Builder:
+		bb.Append(uint32(v.Int()))
+	case *array.Uint64Builder:
+		bb.Append(uint64(v.Int()))
+	case *array.Float32Builder:
+		bb.Append(float32(v.Float()))
+	case *array.Float64Builder:
+		bb.Append(v.Float())
+	case *array.StringBuilder:
+		bb.Append(v.String())
+	case *array.BinaryBuilder:
+		bb.Append([]byte(v.String()))
+	case *array.FixedSizeBinaryBuilder:
+		bb.Append([]byte(v.String()))
+	case *array.Date32Builder:
+		bb.Append(arrow.Date32(v.Int()))
+	case *array.Time32Builder:
+		bb.Append(arrow.Time32(v.Int()))
+	case *array.Time64Builder:
+		bb.Append(arrow.Time64(v.Int()))
+	case *array.TimestampBuilder:
+		if v.Kind() == reflect.String {
+			bb.Append(arrow.Timestamp(types.INT96ToTime(v.String()).UnixNano()))
+			return
+		}
+		bb.Append(arrow.Timestamp(v.Int()))
+	case *array.Decimal128Builder:
+		if v.Kind() == reflect.String {
+			bb.Append(decimalFromBytes([]byte(v.String())))
+			return
+		}
+		bb.Append(decimal128.FromI64(v.Int()))
+	default:
+		b.AppendNull()
+	}
+}
+
+// decimalFromBytes reads a big-endian two's complement unscaled decimal.
+func decimalFromBytes(b []byte) decimal128.Num {
+	if len(b) > decimal128Size {
+		return decimal128.FromBigInt(new(big.Int).SetBytes(b))
+	}
+	buf := make([]byte, decimal128Size)
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		for i := range buf {
+			buf[i] = 0xff
+		}
+	}
+	copy(buf[decimal128Size-len(b):], b)
+	return decimal128.New(int64(binary.BigEndian.Uint64(buf[:8])), binary.BigEndian.Uint64(buf[8:])) //nolint:gosec // two's complement
+}
+
+func decimalToBytes(n decimal128.Num) string {
+	buf := make([]byte, decimal128Size)
+	binary.BigEndian.PutUint64(buf[:8], uint64(n.HighBits())) //nolint:gosec // two's complement
+	binary.BigEndian.PutUint64(buf[8:], n.LowBits())
+	return string(buf)
+}
+
+// ArrowRows converts arrow records into rows of a struct type tagged for the parquet-go writer.
+type ArrowRows struct {
+	Type    reflect.Type
+	columns []*arrowColumn
+}
+
+// arrowColumn knows the Go type and parquet tag of an arrow column and reads its values.
+type arrowColumn struct {
+	typ      reflect.Type
+	tag      []string
+	nullable bool
+	value    func(arr array.Interface, i int) reflect.Value
+}
+
+// NewArrowRows builds the parquet struct type of an arrow schema.
+// Timestamps keep their unit, decimals their precision and scale, lists, maps and structs their nesting.
+func NewArrowRows(s *arrow.Schema) (*ArrowRows, error) {
+	if len(s.Fields()) == 0 {
+		return nil, errors.New("arrow schema has no fields")
+	}
+	typ, columns, err := arrowStruct(s.Fields())
+	if err != nil {
+		return nil, err
+	}
+	return &ArrowRows{Type: typ, columns: columns}, nil
+}
+
+// New returns a pointer to an empty row, the parquet-go writer takes it as the schema.
+func (r *ArrowRows) New() any {
+	return reflect.New(r.Type).Interface()
+}
+
+// Row converts row i of rec.
+func (r *ArrowRows) Row(rec array.Record, i int) any {
+	row := reflect.New(r.Type)
+	for j, c := range r.columns {
+		row.Elem().Field(j).Set(c.get(rec.Column(j), i))
+	}
+	return row.Interface()
+}
+
+func (c *arrowColumn) goType() reflect.Type {
+	if c.nullable {
+		return reflect.PointerTo(c.typ)
+	}
+	return c.typ
+}
+
+func (c *arrowColumn) get(arr array.Interface, i int) reflect.Value {
+	if arr.IsNull(i) {
+		return reflect.Zero(c.goType())
+	}
+	v := c.value(arr, i)
+	if !c.nullable {
+		return v
+	}
+	p := reflect.New(c.typ)
+	p.Elem().Set(v)
+	return p
+}
+
+func arrowStruct(fields []arrow.Field) (reflect.Type, []*arrowColumn, error) {
+	columns := make([]*arrowColumn, 0, len(fields))
+	structFields := make([]reflect.StructField, 0, len(fields))
+	for i, f := range fields {
+		c, err := newArrowColumn(f.Type)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "column "+f.Name)
+		}
+		c.nullable = f.Nullable
+		tag := "name=" + tagName(f.Name)
+		for _, t := range c.tag {
+			tag += ", " + t
+		}
+		columns = append(columns, c)
+		structFields = append(structFields, reflect.StructField{
+			Name: "F" + strconv.Itoa(i),
+			Type: c.goType(),
+			Tag:  reflect.StructTag(`parquet:"` + tag + `"`),
+		})
+	}
+	return reflect.StructOf(structFields), columns, nil
+}
+
+//nolint:gocyclo,cyclop,funlen // one case per arrow type
+func newArrowColumn(dt arrow.DataType) (*arrowColumn, error) {
+	int32Of := func(get func(arr array.Interface, i int) int64) func(array.Interface, int) reflect.Value {
+		return func(arr array.Interface, i int) reflect.Value {
+			return reflect.ValueOf(int32(get(arr, i))) //nolint:gosec // arrow type width
+		}
+	}
+	int64Of := func(get func(arr array.Interface, i int) int64) func(array.Interface, int) reflect.Value {
+		return func(arr array.Interface, i int) reflect.Value {
+			return reflect.ValueOf(get(arr, i))
+		}
+	}
+	int32Type, int64Type := reflect.TypeFor[int32](), reflect.TypeFor[int64]()
+	stringType := reflect.TypeFor[string]()
+
+	switch t := dt.(type) {
+	case *arrow.BooleanType:
+		return &arrowColumn{typ: reflect.TypeFor[bool](), tag: []string{"type=BOOLEAN"}, value: func(arr array.Interface, i int) reflect.Value {
+			return reflect.ValueOf(arr.(*array.Boolean).Value(i))
+		}}, nil
+	case *arrow.Int8Type:
+		return &arrowColumn{typ: int32Type, tag: []string{"type=INT32", "convertedtype=INT_8"}, value: int32Of(func(arr array.Interface, i int) int64 {
+			return int64(arr.(*array.Int8).Value(i))
+		})}, nil
+	case *arrow.Int16Type:
+		return &arrowColumn{typ: int32Type, tag: []string{"type=INT32", "convertedtype=INT_16"}, value: int32Of(func(arr array.Interface, i int) int64 {
+			return int64(arr.(*array.Int16).Value(i))
+		})}, nil
+	case *arrow.Int32Type:
+		return &arrowColumn{typ: int32Type, tag: []string{"type=INT32"}, value: int32Of(func(arr array.Interface, i int) int64 {
+			return int64(arr.(*array.Int32).Value(i))
+		})}, nil
+	case *arrow.Int64Type:
+		return &arrowColumn{typ: int64Type, tag: []string{"type=INT64"}, value: int64Of(func(arr array.Interface, i int) int64 {
+			return arr.(*array.Int64).Value(i)
+		})}, nil
+	case *arrow.Uint8Type:
+		return &arrowColumn{typ: int32Type, tag: []string{"type=INT32", "convertedtype=UINT_8"}, value: int32Of(func(arr array.Interface, i int) int64 {
+			return int64(arr.(*array.Uint8).Value(i))
+		})}, nil
+	case *arrow.Uint16Type:
+		return &arrowColumn{typ: int32Type, tag: []string{"type=INT32", "convertedtype=UINT_16"}, value: int32Of(func(arr array.Interface, i int) int64 {
+			return int64(arr.(*array.Uint16).Value(i))
+		})}, nil
+	case *arrow.Uint32Type:
+		return &arrowColumn{typ: int32Type, tag: []string{"type=INT32", "convertedtype=UINT_32"}, value: int32Of(func(arr array.Interface, i int) int64 {
+			return int64(int32(arr.(*array.Uint32).Value(i))) //nolint:gosec // parquet stores uint32 in int32 bits
+		})}, nil
+	case *arrow.Uint64Type:
+		return &arrowColumn{typ: int64Type, tag: []string{"type=INT64", "convertedtype=UINT_64"}, value: int64Of(func(arr array.Interface, i int) int64 {
+			return int64(arr.(*array.Uint64).Value(i)) //nolint:gosec // parquet stores uint64 in int64 bits
+		})}, nil
+	case *arrow.Float16Type:
+		return &arrowColumn{typ: reflect.TypeFor[float32](), tag: []string{"type=FLOAT"}, value: func(arr array.Interface, i int) reflect.Value {
+			return reflect.ValueOf(arr.(*array.Float16).Value(i).Float32())
+		}}, nil
+	case *arrow.Float32Type:
+		return &arrowColumn{typ: reflect.TypeFor[float32](), tag: []string{"type=FLOAT"}, value: func(arr array.Interface, i int) reflect.Value {
+			return reflect.ValueOf(arr.(*array.Float32).Value(i))
+		}}, nil
+	case *arrow.Float64Type:
+		return &arrowColumn{typ: reflect.TypeFor[float64](), tag: []string{"type=DOUBLE"}, value: func(arr array.Interface, i int) reflect.Value {
+			return reflect.ValueOf(arr.(*array.Float64).Value(i))
+		}}, nil
+	case *arrow.StringType:
+		return &arrowColumn{typ: stringType, tag: []string{"type=BYTE_ARRAY", "convertedtype=UTF8"}, value: func(arr array.Interface, i int) reflect.Value {
+			return reflect.ValueOf(arr.(*array.String).Value(i))
+		}}, nil
+	case *arrow.BinaryType:
+		return &arrowColumn{typ: stringType, tag: []string{"type=BYTE_ARRAY"}, value: func(arr array.Interface, i int) reflect.Value {
+			return reflect.ValueOf(string(arr.(*array.Binary).Value(i)))
+		}}, nil
+	case *arrow.FixedSizeBinaryType:
+		return &arrowColumn{
+			typ: stringType,
+			tag: []string{"type=FIXED_LEN_BYTE_ARRAY", "length=" + strconv.Itoa(t.ByteWidth)},
+			value: func(arr array.Interface, i int) reflect.Value {
+				return reflect.ValueOf(string(arr.(*array.FixedSizeBinary).Value(i)))
+			},
+		}, nil
+	case *arrow.Date32Type:
+		return &arrowColumn{typ: int32Type, tag: []string{"type=INT32", "convertedtype=DATE"}, value: int32Of(func(arr array.Interface, i int) int64 {
+			return int64(arr.(*array.Date32).Value(i))
+		})}, nil
+	case *arrow.Date64Type:
+		return &arrowColumn{typ: int32Type, tag: []string{"type=INT32", "convertedtype=DATE"}, value: int32Of(func(arr array.Interface, i int) int64 {
+			return int64(arr.(*array.Date64).Value(i)) / millisPerDay
+		})}, nil
+	case *arrow.TimestampType:
+		return timestampColumn(t), nil
+	case *arrow.Time32Type:
+		scale := int64(1)
+		if t.Unit == arrow.Second {
+			scale = millisPerSecond
+		}
+		return &arrowColumn{typ: int32Type, tag: []string{"type=INT32", "convertedtype=TIME_MILLIS", "isadjustedtoutc=true"}, value: int32Of(func(arr array.Interface, i int) int64 {
+			return int64(arr.(*array.Time32).Value(i)) * scale
+		})}, nil
+	case *arrow.Time64Type:
+		tag := []string{"type=INT64", "convertedtype=TIME_MICROS", "isadjustedtoutc=true"}
+		if t.Unit == arrow.Nanosecond {
+			tag = []string{"type=INT64", "logicaltype=TIME", "logicaltype.isadjustedtoutc=true", "logicaltype.unit=NANOS"}
+		}
+		return &arrowColumn{typ: int64Type, tag: tag, value: int64Of(func(arr array.Interface, i int) int64 {
+			return int64(arr.(*array.Time64).Value(i))
+		})}, nil
+	case *arrow.Decimal128Type:
+		return decimalColumn(t), nil
+	case *arrow.StructType:
+		typ, columns, err := arrowStruct(t.Fields())
+		if err != nil {
+			return nil, err
+		}
+		return &arrowColumn{typ: typ, value: func(arr array.Interface, i int) reflect.Value {
+			s, _ := arr.(*array.Struct)
+			v := reflect.New(typ).Elem()
+			for j, c := range columns {
+				v.Field(j).Set(c.get(s.Field(j), i))
+			}
+			return v
+		}}, nil
+	case *arrow.MapType:
+		return mapColumn(t)
+	case *arrow.ListType:
+		return listColumn(t)
+	}
+	return nil, errors.New("unsupported arrow type " + dt.Name())
+}
+
+func timestampColumn(t *arrow.TimestampType) *arrowColumn {
+	adjusted := strconv.FormatBool(t.TimeZone != "")
+	scale := int64(1)
+	var tag []string
+	switch t.Unit {
+	case arrow.Second:
+		scale = millisPerSecond
+		tag = []string{"type=INT64", "convertedtype=TIMESTAMP_MILLIS", "isadjustedtoutc=" + adjusted}
+	case arrow.Millisecond:
+		tag = []string{"type=INT64", "convertedtype=TIMESTAMP_MILLIS", "isadjustedtoutc=" + adjusted}
+	case arrow.Microsecond:
+		tag = []string{"type=INT64", "convertedtype=TIMESTAMP_MICROS", "isadjustedtoutc=" + adjusted}
+	case arrow.Nanosecond:
+		tag = []string{"type=INT64", "logicaltype=TIMESTAMP", "logicaltype.isadjustedtoutc=" + adjusted, "logicaltype.unit=NANOS"}
+	}
+	return &arrowColumn{typ: reflect.TypeFor[int64](), tag: tag, value: func(arr array.Interface, i int) reflect.Value {
+		return reflect.ValueOf(int64(arr.(*array.Timestamp).Value(i)) * scale)
+	}}
+}
+
+func decimalColumn(t *arrow.Decimal128Type) *arrowColumn {
+	decimal := []string{"convertedtype=DECIMAL", "precision=" + strconv.Itoa(int(t.Precision)), "scale=" + strconv.Itoa(int(t.Scale))}
+	switch {
+	case t.Precision <= maxDecimalInt32:
+		return &arrowColumn{typ: reflect.TypeFor[int32](), tag: append([]string{"type=INT32"}, decimal...), value: func(arr array.Interface, i int) reflect.Value {
+			return reflect.ValueOf(int32(arr.(*array.Decimal128).Value(i).LowBits())) //nolint:gosec // fits the precision
+		}}
+	case t.Precision <= maxDecimalInt64:
+		return &arrowColumn{typ: reflect.TypeFor[int64](), tag: append([]string{"type=INT64"}, decimal...), value: func(arr array.Interface, i int) reflect.Value {
+			return reflect.ValueOf(int64(arr.(*array.Decimal128).Value(i).LowBits())) //nolint:gosec // fits the precision
+		}}
+	default:
+		tag := append([]string{"type=FIXED_LEN_BYTE_ARRAY", "length=" + strconv.Itoa(decimal128Size)}, decimal...)
+		return &arrowColumn{typ: reflect.TypeFor[string](), tag: tag, value: func(arr array.Interface, i int) reflect.Value {
+			return reflect.ValueOf(decimalToBytes(arr.(*array.Decimal128).Value(i)))
+		}}
+	}
+}
+
+// nestedTag prefixes the tag of a list element or map key/value, parquet-go drops logical types there.
+func nestedTag(prefix string, columnTag []string) ([]string, error) {
+	tag := make([]string, 0, len(columnTag))
+	for _, t := range columnTag {
+		if len(t) > len("logicaltype") && t[:len("logicaltype")] == "logicaltype" {
+			return nil, errors.New("nanosecond time values in lists and maps aren't supported by the parquet writer")
+		}
+		tag = append(tag, prefix+t)
+	}
+	return tag, nil
+}
+
+func listColumn(t *arrow.ListType) (*arrowColumn, error) {
+	elem, err := newArrowColumn(t.Elem())
+	if err != nil {
+		return nil, err
+	}
+	if elem.typ.Kind() == reflect.Slice || elem.typ.Kind() == reflect.Map {
+		return nil, errors.New("lists of lists or maps aren't supported by the parquet writer")
+	}
+	elem.nullable = t.ElemField().Nullable
+	tag, err := nestedTag("value", elem.tag)
+	if err != nil {
+		return nil, err
+	}
+	typ := reflect.SliceOf(elem.goType())
+	return &arrowColumn{typ: typ, tag: append([]string{"type=LIST"}, tag...), value: func(arr array.Interface, i int) reflect.Value {
+		l, _ := arr.(*array.List)
+		j := i + l.Data().Offset()
+		beg, end := int(l.Offsets()[j]), int(l.Offsets()[j+1])
+		v := reflect.MakeSlice(typ, 0, end-beg)
+		for k := beg; k < end; k++ {
+			v = reflect.Append(v, elem.get(l.ListValues(), k))
+		}
+		return v
+	}}, nil
+}
+
+func mapColumn(t *arrow.MapType) (*arrowColumn, error) {
+	key, err := newArrowColumn(t.KeyType())
+	if err != nil {
+		return nil, err
+	}
+	item, err := newArrowColumn(t.ItemType())
+	if err != nil {
+		return nil, err
+	}
+	if !key.typ.Comparable() || key.typ.Kind() == reflect.Struct {
+		return nil, errors.New("map keys must be primitive")
+	}
+	if item.typ.Kind() == reflect.Slice || item.typ.Kind() == reflect.Map {
+		return nil, errors.New("maps of lists or maps aren't supported by the parquet writer")
+	}
+	item.nullable = t.ItemField().Nullable
+	keyTag, err := nestedTag("key", key.tag)
+	if err != nil {
+		return nil, err
+	}
+	itemTag, err := nestedTag("value", item.tag)
+	if err != nil {
+		return nil, err
+	}
+	typ := reflect.MapOf(key.typ, item.goType())
+	tag := append(append([]string{"type=MAP"}, keyTag...), itemTag...)
+	return &arrowColumn{typ: typ, tag: tag, value: func(arr array.Interface, i int) reflect.Value {
+		m, _ := arr.(*array.Map)
+		j := i + m.Data().Offset()
+		beg, end := int(m.Offsets()[j]), int(m.Offsets()[j+1])
+		v := reflect.MakeMapWithSize(typ, end-beg)
+		for k := beg; k < end; k++ {
+			v.SetMapIndex(key.value(m.Keys(), k), item.get(m.Items(), k))
+		}
+		return v
+	}}, nil
+}
@@ -0,0 +1,207 @@
+package schema
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/apache/arrow/go/arrow/decimal128"
+	"github.com/apache/arrow/go/arrow/memory"
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+func testArrowRecord(t *testing.T) array.Record {
+	t.Helper()
+	point := arrow.StructOf(
+		arrow.Field{Name: "x", Type: arrow.PrimitiveTypes.Float64},
+		arrow.Field{Name: "label", Type: arrow.BinaryTypes.String, Nullable: true},
+	)
+	md := arrow.NewMetadata([]string{"origin"}, []string{"test"})
+	s := arrow.NewSchema([]arrow.Field{
+		{Name: "flag", Type: arrow.FixedWidthTypes.Boolean},
+		{Name: "i8", Type: arrow.PrimitiveTypes.Int8},
+		{Name: "i16", Type: arrow.PrimitiveTypes.Int16},
+		{Name: "i32", Type: arrow.PrimitiveTypes.Int32, Nullable: true},
+		{Name: "i64", Type: arrow.PrimitiveTypes.Int64},
+		{Name: "u8", Type: arrow.PrimitiveTypes.Uint8},
+		{Name: "u16", Type: arrow.PrimitiveTypes.Uint16},
+		{Name: "u32", Type: arrow.PrimitiveTypes.Uint32},
+		{Name: "u64", Type: arrow.PrimitiveTypes.Uint64},
+		{Name: "f32", Type: arrow.PrimitiveTypes.Float32},
+		{Name: "f64", Type: arrow.PrimitiveTypes.Float64, Nullable: true},
+		{Name: "name", Type: arrow.BinaryTypes.String, Nullable: true},
+		{Name: "raw", Type: arrow.BinaryTypes.Binary},
+		{Name: "fixed", Type: &arrow.FixedSizeBinaryType{ByteWidth: 2}},
+		{Name: "day", Type: arrow.FixedWidthTypes.Date32},
+		{Name: "at_ms", Type: &arrow.TimestampType{Unit: arrow.Millisecond, TimeZone: "Europe/Kyiv"}},
+		{Name: "at_us", Type: &arrow.TimestampType{Unit: arrow.Microsecond}},
+		{Name: "at_ns", Type: &arrow.TimestampType{Unit: arrow.Nanosecond, TimeZone: "UTC"}, Nullable: true},
+		{Name: "t_ms", Type: arrow.FixedWidthTypes.Time32ms},
+		{Name: "t_us", Type: arrow.FixedWidthTypes.Time64us},
+		{Name: "d9", Type: &arrow.Decimal128Type{Precision: 5, Scale: 2}},
+		{Name: "d18", Type: &arrow.Decimal128Type{Precision: 15, Scale: 3}},
+		{Name: "d38", Type: &arrow.Decimal128Type{Precision: 30, Scale: 4}, Nullable: true},
+		{Name: "nums", Type: arrow.ListOf(arrow.PrimitiveTypes.Int64), Nullable: true},
+		{Name: "points", Type: arrow.ListOf(point)},
+		{Name: "point", Type: point, Nullable: true},
+		{Name: "attrs", Type: arrow.MapOf(arrow.BinaryTypes.String, arrow.PrimitiveTypes.Int32)},
+	}, &md)
+
+	b := array.NewRecordBuilder(memory.NewGoAllocator(), s)
+	defer b.Release()
+	big, _ := decimal128.FromI64(-123456789).BigInt().SetString("-12345678901234567890123456", 10)
+	for row := range 2 {
+		full := row == 0
+		b.Field(0).(*array.BooleanBuilder).Append(full)
+		b.Field(1).(*array.Int8Builder).Append(-8)
+		b.Field(2).(*array.Int16Builder).Append(-16)
+		b.Field(4).(*array.Int64Builder).Append(-1 << 40)
+		b.Field(5).(*array.Uint8Builder).Append(250)
+		b.Field(6).(*array.Uint16Builder).Append(65000)
+		b.Field(7).(*array.Uint32Builder).Append(4000000000)
+		b.Field(8).(*array.Uint64Builder).Append(18000000000000000000)
+		b.Field(9).(*array.Float32Builder).Append(1.5)
+		b.Field(12).(*array.BinaryBuilder).Append([]byte{0, 1, 2})
+		b.Field(13).(*array.FixedSizeBinaryBuilder).Append([]byte{9, 8})
+		b.Field(14).(*array.Date32Builder).Append(19000)
+		b.Field(15).(*array.TimestampBuilder).Append(1700000000123)
+		b.Field(16).(*array.TimestampBuilder).Append(1700000000123456)
+		b.Field(18).(*array.Time32Builder).Append(3723000)
+		b.Field(19).(*array.Time64Builder).Append(3723000001)
+		b.Field(20).(*array.Decimal128Builder).Append(decimal128.FromI64(-12345))
+		b.Field(21).(*array.Decimal128Builder).Append(decimal128.FromI64(123456789012345))
+		points := b.Field(24).(*array.ListBuilder)
+		points.Append(true)
+		mb := b.Field(26).(*array.MapBuilder)
+		mb.Append(true)
+		if !full {
+			for _, i := range []int{3, 10, 11, 17, 22, 23, 25} {
+				b.Field(i).AppendNull()
+			}
+			continue
+		}
+		b.Field(3).(*array.Int32Builder).Append(32)
+		b.Field(10).(*array.Float64Builder).Append(2.25)
+		b.Field(11).(*array.StringBuilder).Append("ann")
+		b.Field(17).(*array.TimestampBuilder).Append(1700000000123456789)
+		b.Field(22).(*array.Decimal128Builder).Append(decimal128.FromBigInt(big))
+		nums := b.Field(23).(*array.ListBuilder)
+		nums.Append(true)
+		nums.ValueBuilder().(*array.Int64Builder).AppendValues([]int64{1, 0, 3}, []bool{true, false, true})
+		ps := points.ValueBuilder().(*array.StructBuilder)
+		ps.Append(true)
+		ps.FieldBuilder(0).(*array.Float64Builder).Append(1)
+		ps.FieldBuilder(1).(*array.StringBuilder).AppendNull()
+		p := b.Field(25).(*array.StructBuilder)
+		p.Append(true)
+		p.FieldBuilder(0).(*array.Float64Builder).Append(2)
+		p.FieldBuilder(1).(*array.StringBuilder).Append("b")
+		mb.KeyBuilder().(*array.StringBuilder).Append("k")
+		mb.ItemBuilder().(*array.Int32Builder).Append(7)
+	}
+	return b.NewRecord()
+}
+
+func TestArrowParquetRoundTrip(t *testing.T) {
+	rec := testArrowRecord(t)
+	defer rec.Release()
+
+	rows, err := NewArrowRows(rec.Schema())
+	if err != nil {
+		t.Fatalf("NewArrowRows() error: %v", err)
+	}
+	stored, err := EncodeArrowSchema(rec.Schema())
+	if err != nil {
+		t.Fatalf("EncodeArrowSchema() error: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "arrow.parquet")
+	fw, err := local.NewLocalFileWriter(path)
+	if err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	pw, err := writer.NewParquetWriter(fw, rows.New(), 1)
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+	pw.Footer.KeyValueMetadata = append(pw.Footer.KeyValueMetadata, &parquet.KeyValue{Key: ArrowSchemaKey, Value: &stored})
+	for i := range int(rec.NumRows()) {
+		if err = pw.Write(rows.Row(rec, i)); err != nil {
+			t.Fatalf("Write error: %v", err)
+		}
+	}
+	if err = pw.WriteStop(); err != nil {
+		t.Fatalf("WriteStop error: %v", err)
+	}
+	_ = fw.Close()
+
+	fr, err := local.NewLocalFileReader(path)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	pr, err := reader.NewParquetReader(fr, nil, 1)
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+	defer pr.ReadStop()
+	read, err := pr.ReadByNumber(int(rec.NumRows()))
+	if err != nil {
+		t.Fatalf("Read error: %v", err)
+	}
+
+	tree := NewTree(pr.SchemaHandler)
+	s, err := tree.ArrowSchema()
+	if err != nil {
+		t.Fatalf("ArrowSchema() error: %v", err)
+	}
+	var restored *arrow.Schema
+	for _, kv := range pr.Footer.GetKeyValueMetadata() {
+		if kv.GetKey() == ArrowSchemaKey {
+			if restored, err = DecodeArrowSchema(kv.GetValue()); err != nil {
+				t.Fatalf("DecodeArrowSchema() error: %v", err)
+			}
+		}
+	}
+	if restored == nil {
+		t.Fatal("ARROW:schema metadata not found")
+	}
+	s = RestoreArrowSchema(s, restored)
+	if !s.Equal(rec.Schema()) {
+		t.Fatalf("schema = %v\nwant %v", s, rec.Schema())
+	}
+
+	b := array.NewRecordBuilder(memory.NewGoAllocator(), s)
+	defer b.Release()
+	for _, row := range read {
+		tree.AppendArrow(b, row)
+	}
+	got := b.NewRecord()
+	defer got.Release()
+	for i := range int(rec.NumCols()) {
+		if !array.ArrayEqual(got.Column(i), rec.Column(i)) {
+			t.Errorf("column %s = %v; want %v", rec.ColumnName(i), got.Column(i), rec.Column(i))
+		}
+	}
+}
+
+func TestNewArrowRowsUnsupported(t *testing.T) {
+	tests := []struct {
+		name string
+		typ  arrow.DataType
+	}{
+		{"list of lists", arrow.ListOf(arrow.ListOf(arrow.PrimitiveTypes.Int32))},
+		{"nanoseconds in a list", arrow.ListOf(arrow.FixedWidthTypes.Timestamp_ns)},
+		{"null", arrow.Null},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewArrowRows(arrow.NewSchema([]arrow.Field{{Name: "c", Type: tt.typ}}, nil)); err == nil {
+				t.Errorf("NewArrowRows(%s) expected error", tt.typ)
+			}
+		})
+	}
+}
@@ -0,0 +1,868 @@
+package schema
+
+import (
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dbunt1tled/parquet2csv/internal/helper"
+	"github.com/linkedin/goavro/v2"
+	"github.com/pkg/errors"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/types"
+)
+
+// AvroSchemaKey is the parquet key-value metadata key parquet-avro keeps the avro schema under.
+const AvroSchemaKey = "parquet.avro.schema"
+
+const secondsPerDay = 24 * 60 * 60
+
+// avroType is a parsed avro schema. Named types are shared, a reference points to the definition.
+type avroType struct {
+	Type      string
+	Name      string
+	Logical   string
+	Precision int
+	Scale     int
+	Size      int
+	Fields    []avroField
+	Items     *avroType
+	Branches  []*avroType
+}
+
+type avroField struct {
+	Name string
+	Type *avroType
+}
+
+var avroNameRe = regexp.MustCompile(`[^A-Za-z0-9_]`) //nolint:gochecknoglobals // compiled once
+
+func isAvroPrimitive(name string) bool {
+	switch name {
+	case "null", "boolean", "int", "long", "float", "double", "bytes", "string":
+		return true
+	default:
+		return false
+	}
+}
+
+type avroParser struct {
+	named map[string]*avroType
+}
+
+func parseAvroSchema(schema string) (*avroType, error) {
+	var v any
+	if err := json.Unmarshal([]byte(schema), &v); err != nil {
+		return nil, errors.Wrap(err, "error parse avro schema")
+	}
+	p := &avroParser{named: make(map[string]*avroType)}
+	return p.parse(v, "")
+}
+
+//nolint:gocyclo,cyclop // one case per avro type
+func (p *avroParser) parse(v any, namespace string) (*avroType, error) {
+	switch s := v.(type) {
+	case string:
+		if isAvroPrimitive(s) {
+			return &avroType{Type: s}, nil
+		}
+		if t, ok := p.named[s]; ok {
+			return t, nil
+		}
+		if t, ok := p.named[namespace+"."+s]; ok && namespace != "" {
+			return t, nil
+		}
+		return nil, errors.New("unknown avro type " + s)
+	case []any:
+		t := &avroType{Type: "union"}
+		for _, b := range s {
+			branch, err := p.parse(b, namespace)
+			if err != nil {
+				return nil, err
+			}
+			t.Branches = append(t.Branches, branch)
+		}
+		return t, nil
+	case map[string]any:
+		typ, ok := s["type"].(string)
+		if !ok {
+			return p.parse(s["type"], namespace)
+		}
+		logical, _ := s["logicalType"].(string)
+		precision, _ := s["precision"].(float64)
+		scale, _ := s["scale"].(float64)
+		switch typ {
+		case "record", "error", "enum", "fixed":
+			t := &avroType{Type: typ, Name: avroFullName(s, namespace), Logical: logical, Precision: int(precision), Scale: int(scale)}
+			if typ == "error" {
+				t.Type = "record"
+			}
+			p.named[t.Name] = t
+			switch typ {
+			case "enum":
+			case "fixed":
+				size, _ := s["size"].(float64)
+				t.Size = int(size)
+			default:
+				ns := ""
+				if i := strings.LastIndex(t.Name, "."); i >= 0 {
+					ns = t.Name[:i]
+				}
+				fields, _ := s["fields"].([]any)
+				for _, f := range fields {
+					fm, _ := f.(map[string]any)
+					name, _ := fm["name"].(string)
+					ft, err := p.parse(fm["type"], ns)
+					if err != nil {
+						return nil, errors.Wrap(err, "field "+name)
+					}
+					t.Fields = append(t.Fields, avroField{Name: name, Type: ft})
+				}
+			}
+			return t, nil
+		case "array", "map":
+			key := "items"
+			if typ == "map" {
+				key = "values"
+			}
+			items, err := p.parse(s[key], namespace)
+			if err != nil {
+				return nil, err
+			}
+			return &avroType{Type: typ, Items: items}, nil
+		}
+		t, err := p.parse(typ, namespace)
+		if err != nil || !isAvroPrimitive(t.Type) {
+			return t, err
+		}
+		t.Logical, t.Precision, t.Scale = logical, int(precision), int(scale)
+		return t, nil
+	}
+	return nil, errors.New("invalid avro schema")
+}
+
+func avroFullName(s map[string]any, namespace string) string {
+	name, _ := s["name"].(string)
+	if strings.Contains(name, ".") {
+		return name
+	}
+	if ns, ok := s["namespace"].(string); ok {
+		namespace = ns
+	}
+	if namespace == "" {
+		return name
+	}
+	return namespace + "." + name
+}
+
+// unionName is the name goavro expects as the key of a union value of the type.
+func (t *avroType) unionName() string {
+	switch {
+	case t.Name != "":
+		return t.Name
+	case t.Type == "bytes" && t.Logical == "decimal":
+		return "bytes.decimal"
+	case t.Type == "int" && (t.Logical == "date" || t.Logical == "time-millis"),
+		t.Type == "long" && (t.Logical == "time-micros" || t.Logical == "timestamp-millis" || t.Logical == "timestamp-micros"):
+		return t.Type + "." + t.Logical
+	default:
+		return t.Type
+	}
+}
+
+// nullable returns the type of a union of null and one other type and whether null is a branch.
+func (t *avroType) nullable() (*avroType, bool, error) {
+	if t.Type != "union" {
+		return t, false, nil
+	}
+	var value *avroType
+	for _, b := range t.Branches {
+		if b.Type == "null" {
+			continue
+		}
+		if value != nil {
+			return nil, false, errors.New("only unions of null and one type are supported")
+		}
+		value = b
+	}
+	if value == nil {
+		return nil, false, errors.New("unions of only null aren't supported")
+	}
+	return value, len(t.Branches) > 1, nil
+}
+
+func (t *avroType) isDecimal() bool {
+	return t.Logical == "decimal" && (t.Type == "bytes" || t.Type == "fixed")
+}
+
+// AvroRows converts goavro native records into rows of a struct type tagged for the parquet-go writer.
+type AvroRows struct {
+	Type    reflect.Type
+	columns []*avroColumn
+	names   []string
+}
+
+// avroColumn knows the Go type and parquet tag of an avro field and converts its values.
+type avroColumn struct {
+	typ      reflect.Type
+	tag      []string
+	nullable bool
+	union    bool
+	value    func(v any) reflect.Value
+}
+
+// NewAvroRows builds the parquet struct type of an avro record schema.
+// Unions with null become optional columns, enums strings, arrays and maps LIST and MAP,
+// decimals, dates, times and timestamps the matching parquet types.
+func NewAvroRows(schema string) (*AvroRows, error) {
+	t, err := parseAvroSchema(schema)
+	if err != nil {
+		return nil, err
+	}
+	if t.Type != "record" || len(t.Fields) == 0 {
+		return nil, errors.New("avro schema must be a record with at least one field")
+	}
+	typ, columns, err := avroStruct(t, map[*avroType]bool{})
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(t.Fields))
+	for _, f := range t.Fields {
+		names = append(names, f.Name)
+	}
+	return &AvroRows{Type: typ, columns: columns, names: names}, nil
+}
+
+// New returns a pointer to an empty row, the parquet-go writer takes it as the schema.
+func (r *AvroRows) New() any {
+	return reflect.New(r.Type).Interface()
+}
+
+// Row converts a record decoded by goavro.
+func (r *AvroRows) Row(datum any) any {
+	row := reflect.New(r.Type)
+	record, _ := datum.(map[string]any)
+	for i, c := range r.columns {
+		row.Elem().Field(i).Set(c.get(record[r.names[i]]))
+	}
+	return row.Interface()
+}
+
+func (c *avroColumn) goType() reflect.Type {
+	if c.nullable {
+		return reflect.PointerTo(c.typ)
+	}
+	return c.typ
+}
+
+func (c *avroColumn) get(v any) reflect.Value {
+	if union, ok := v.(map[string]any); ok && c.union {
+		for _, value := range union {
+			v = value
+		}
+	}
+	if v == nil {
+		return reflect.Zero(c.goType())
+	}
+	value := c.value(v)
+	if !c.nullable {
+		return value
+	}
+	p := reflect.New(c.typ)
+	p.Elem().Set(value)
+	return p
+}
+
+func avroStruct(t *avroType, visiting map[*avroType]bool) (reflect.Type, []*avroColumn, error) {
+	if visiting[t] {
+		return nil, nil, errors.New("recursive record " + t.Name + " isn't supported")
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	columns := make([]*avroColumn, 0, len(t.Fields))
+	structFields := make([]reflect.StructField, 0, len(t.Fields))
+	for i, f := range t.Fields {
+		c, err := newAvroColumn(f.Type, visiting)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "field "+f.Name)
+		}
+		tag := "name=" + tagName(f.Name)
+		for _, t := range c.tag {
+			tag += ", " + t
+		}
+		columns = append(columns, c)
+		structFields = append(structFields, reflect.StructField{
+			Name: "F" + strconv.Itoa(i),
+			Type: c.goType(),
+			Tag:  reflect.StructTag(`parquet:"` + tag + `"`),
+		})
+	}
+	return reflect.StructOf(structFields), columns, nil
+}
+
+func newAvroColumn(t *avroType, visiting map[*avroType]bool) (*avroColumn, error) {
+	value, nullable, err := t.nullable()
+	if err != nil {
+		return nil, err
+	}
+	c, err := newAvroValueColumn(value, visiting)
+	if err != nil {
+		return nil, err
+	}
+	c.nullable, c.union = nullable, t.Type == "union"
+	return c, nil
+}
+
+//nolint:gocyclo,cyclop,funlen // one case per avro type
+func newAvroValueColumn(t *avroType, visiting map[*avroType]bool) (*avroColumn, error) {
+	int32Type, int64Type := reflect.TypeFor[int32](), reflect.TypeFor[int64]()
+	stringType := reflect.TypeFor[string]()
+	plain := func(v any) reflect.Value {
+		return reflect.ValueOf(v)
+	}
+	if t.isDecimal() {
+		decimal := []string{"convertedtype=DECIMAL", "precision=" + strconv.Itoa(t.Precision), "scale=" + strconv.Itoa(t.Scale)}
+		tag := append([]string{"type=BYTE_ARRAY"}, decimal...)
+		if t.Type == "fixed" {
+			tag = append([]string{"type=FIXED_LEN_BYTE_ARRAY", "length=" + strconv.Itoa(t.Size)}, decimal...)
+		}
+		return &avroColumn{typ: stringType, tag: tag, value: func(v any) reflect.Value {
+			return reflect.ValueOf(string(avroDecimalBytes(v, t.Scale, t.Size)))
+		}}, nil
+	}
+
+	switch t.Type {
+	case "boolean":
+		return &avroColumn{typ: reflect.TypeFor[bool](), tag: []string{"type=BOOLEAN"}, value: plain}, nil
+	case "int":
+		switch t.Logical {
+		case "date":
+			return &avroColumn{typ: int32Type, tag: []string{"type=INT32", "convertedtype=DATE"}, value: func(v any) reflect.Value {
+				if d, ok := v.(time.Time); ok {
+					days := d.Unix() / secondsPerDay
+					if d.Unix()%secondsPerDay < 0 {
+						days--
+					}
+					return reflect.ValueOf(int32(days)) //nolint:gosec // avro dates are int
+				}
+				return reflect.ValueOf(v)
+			}}, nil
+		case "time-millis":
+			return &avroColumn{typ: int32Type, tag: []string{"type=INT32", "convertedtype=TIME_MILLIS", "isadjustedtoutc=true"}, value: func(v any) reflect.Value {
+				if d, ok := v.(time.Duration); ok {
+					return reflect.ValueOf(int32(d.Milliseconds())) //nolint:gosec // avro time-millis is int
+				}
+				return reflect.ValueOf(v)
+			}}, nil
+		}
+		return &avroColumn{typ: int32Type, tag: []string{"type=INT32"}, value: plain}, nil
+	case "long":
+		var tag []string
+		switch t.Logical {
+		case "time-micros":
+			tag = []string{"type=INT64", "convertedtype=TIME_MICROS", "isadjustedtoutc=true"}
+		case "timestamp-millis", "local-timestamp-millis":
+			tag = []string{"type=INT64", "convertedtype=TIMESTAMP_MILLIS", "isadjustedtoutc=" + strconv.FormatBool(t.Logical == "timestamp-millis")}
+		case "timestamp-micros", "local-timestamp-micros":
+			tag = []string{"type=INT64", "convertedtype=TIMESTAMP_MICROS", "isadjustedtoutc=" + strconv.FormatBool(t.Logical == "timestamp-micros")}
+		default:
+			return &avroColumn{typ: int64Type, tag: []string{"type=INT64"}, value: plain}, nil
+		}
+		return &avroColumn{typ: int64Type, tag: tag, value: func(v any) reflect.Value {
+			switch value := v.(type) {
+			case time.Duration:
+				return reflect.ValueOf(value.Microseconds())
+			case time.Time:
+				if t.Logical == "timestamp-millis" {
+					return reflect.ValueOf(value.UnixMilli())
+				}
+				return reflect.ValueOf(value.UnixMicro())
+			}
+			return reflect.ValueOf(v)
+		}}, nil
+	case "float":
+		return &avroColumn{typ: reflect.TypeFor[float32](), tag: []string{"type=FLOAT"}, value: plain}, nil
+	case "double":
+		return &avroColumn{typ: reflect.TypeFor[float64](), tag: []string{"type=DOUBLE"}, value: plain}, nil
+	case "bytes":
+		return &avroColumn{typ: stringType, tag: []string{"type=BYTE_ARRAY"}, value: func(v any) reflect.Value {
+			b, _ := v.([]byte)
+			return reflect.ValueOf(string(b))
+		}}, nil
+	case "string":
+		return &avroColumn{typ: stringType, tag: []string{"type=BYTE_ARRAY", "convertedtype=UTF8"}, value: plain}, nil
+	case "enum":
+		// parquet-go can't write ENUM columns, the symbols come back from the stored avro schema
+		return &avroColumn{typ: stringType, tag: []string{"type=BYTE_ARRAY", "convertedtype=UTF8"}, value: plain}, nil
+	case "fixed":
+		return &avroColumn{typ: stringType, tag: []string{"type=FIXED_LEN_BYTE_ARRAY", "length=" + strconv.Itoa(t.Size)}, value: func(v any) reflect.Value {
+			b, _ := v.([]byte)
+			return reflect.ValueOf(string(b))
+		}}, nil
+	case "record":
+		typ, columns, err := avroStruct(t, visiting)
+		if err != nil {
+			return nil, err
+		}
+		return &avroColumn{typ: typ, value: func(v any) reflect.Value {
+			record, _ := v.(map[string]any)
+			row := reflect.New(typ).Elem()
+			for i, c := range columns {
+				row.Field(i).Set(c.get(record[t.Fields[i].Name]))
+			}
+			return row
+		}}, nil
+	case "array":
+		return avroListColumn(t, visiting)
+	case "map":
+		return avroMapColumn(t, visiting)
+	}
+	return nil, errors.New("unsupported avro type " + t.Type)
+}
+
+func avroListColumn(t *avroType, visiting map[*avroType]bool) (*avroColumn, error) {
+	elem, err := newAvroColumn(t.Items, visiting)
+	if err != nil {
+		return nil, err
+	}
+	if elem.typ.Kind() == reflect.Slice || elem.typ.Kind() == reflect.Map {
+		return nil, errors.New("arrays of arrays or maps aren't supported by the parquet writer")
+	}
+	tag, err := nestedTag("value", elem.tag)
+	if err != nil {
+		return nil, err
+	}
+	typ := reflect.SliceOf(elem.goType())
+	return &avroColumn{typ: typ, tag: append([]string{"type=LIST"}, tag...), value: func(v any) reflect.Value {
+		items, _ := v.([]any)
+		out := reflect.MakeSlice(typ, 0, len(items))
+		for _, item := range items {
+			out = reflect.Append(out, elem.get(item))
+		}
+		return out
+	}}, nil
+}
+
+func avroMapColumn(t *avroType, visiting map[*avroType]bool) (*avroColumn, error) {
+	item, err := newAvroColumn(t.Items, visiting)
+	if err != nil {
+		return nil, err
+	}
+	if item.typ.Kind() == reflect.Slice || item.typ.Kind() == reflect.Map {
+		return nil, errors.New("maps of arrays or maps aren't supported by the parquet writer")
+	}
+	itemTag, err := nestedTag("value", item.tag)
+	if err != nil {
+		return nil, err
+	}
+	typ := reflect.MapOf(reflect.TypeFor[string](), item.goType())
+	tag := append([]string{"type=MAP", "keytype=BYTE_ARRAY", "keyconvertedtype=UTF8"}, itemTag...)
+	return &avroColumn{typ: typ, tag: tag, value: func(v any) reflect.Value {
+		m, _ := v.(map[string]any)
+		out := reflect.MakeMapWithSize(typ, len(m))
+		for k, value := range m {
+			out.SetMapIndex(reflect.ValueOf(k), item.get(value))
+		}
+		return out
+	}}, nil
+}
+
+// avroDecimalBytes returns the big-endian two's complement unscaled value of a goavro decimal,
+// padded to size when it is set.
+func avroDecimalBytes(v any, scale, size int) []byte {
+	r, ok := v.(*big.Rat)
+	if !ok {
+		b, _ := v.([]byte)
+		return b
+	}
+	n := new(big.Int).Mul(r.Num(), new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)) //nolint:mnd // decimal
+	n.Quo(n, r.Denom())
+	length := n.BitLen()/8 + 1 //nolint:mnd // sign bit
+	if size > 0 {
+		length = size
+	}
+	if n.Sign() < 0 {
+		n.Add(n, new(big.Int).Lsh(big.NewInt(1), uint(length*8))) //nolint:gosec,mnd // two's complement
+	}
+	return n.FillBytes(make([]byte, length))
+}
+
+// AvroRecords converts rows decoded by the generic parquet-go reader to goavro native records.
+type AvroRecords struct {
+	Schema string
+	record func(v reflect.Value) any
+}
+
+// AvroRecords matches the schema tree with an avro schema, usually the one stored under AvroSchemaKey.
+// An empty schema is derived from the tree: optional columns become unions with null,
+// groups records, lists arrays and maps maps with string keys.
+func (n *Node) AvroRecords(schema string) (*AvroRecords, error) {
+	var (
+		t   *avroType
+		err error
+	)
+	if schema == "" {
+		if t, err = n.avroType(avroName(n.Name), map[string]bool{}); err != nil {
+			return nil, err
+		}
+		data, marshalErr := json.Marshal(t.schema(map[string]bool{}))
+		if marshalErr != nil {
+			return nil, errors.Wrap(marshalErr, "error marshal avro schema")
+		}
+		schema = string(data)
+	} else if t, err = parseAvroSchema(schema); err != nil {
+		return nil, err
+	}
+	if t.Type != "record" {
+		return nil, errors.New("avro schema must be a record")
+	}
+	record, err := n.avroConverter(t)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = goavro.NewCodec(schema); err != nil {
+		return nil, errors.Wrap(err, "invalid avro schema")
+	}
+	return &AvroRecords{Schema: schema, record: record}, nil
+}
+
+// Record converts a row decoded by the generic parquet-go reader.
+func (r *AvroRecords) Record(row any) any {
+	return r.record(reflect.ValueOf(row))
+}
+
+// avroName makes a parquet column name a valid avro name.
+func avroName(name string) string {
+	name = avroNameRe.ReplaceAllString(name, "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	return name
+}
+
+// avroType derives the avro type of a node, fullName names the records and fixed types it defines.
+//
+//nolint:gocyclo,cyclop // one case per parquet type
+func (n *Node) avroType(fullName string, names map[string]bool) (*avroType, error) {
+	var (
+		t   *avroType
+		err error
+	)
+	switch {
+	case n.isList():
+		var items *avroType
+		if items, err = n.Children[0].Children[0].avroType(fullName+".element", names); err == nil {
+			t = &avroType{Type: "array", Items: items}
+		}
+	case n.isMap():
+		var values *avroType
+		if values, err = n.Children[0].Children[1].avroType(fullName+".value", names); err == nil {
+			t = &avroType{Type: "map", Items: values}
+		}
+	case n.isRepeated():
+		var items *avroType
+		if items, err = (&Node{Name: n.Name, Field: n.Field, Element: n.single(), Children: n.Children}).avroType(fullName, names); err == nil {
+			return &avroType{Type: "array", Items: items}, nil
+		}
+	case len(n.Children) == 0:
+		t, err = n.avroLeaf(fullName)
+	default:
+		t = &avroType{Type: "record", Name: fullName}
+		seen := make(map[string]string, len(n.Children))
+		for _, c := range n.Children {
+			name := avroName(c.Name)
+			if other, ok := seen[name]; ok {
+				return nil, errors.New("columns " + other + " and " + c.Name + " map to the same avro field")
+			}
+			seen[name] = c.Name
+			ft, childErr := c.avroType(fullName+"."+name, names)
+			if childErr != nil {
+				return nil, errors.Wrap(childErr, "column "+c.Name)
+			}
+			t.Fields = append(t.Fields, avroField{Name: name, Type: ft})
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if t.Name != "" {
+		if names[t.Name] {
+			return nil, errors.New("avro name " + t.Name + " is defined twice")
+		}
+		names[t.Name] = true
+	}
+	if n.isOptional() {
+		return &avroType{Type: "union", Branches: []*avroType{{Type: "null"}, t}}, nil
+	}
+	return t, nil
+}
+
+//nolint:gocyclo,cyclop // one case per parquet type
+func (n *Node) avroLeaf(fullName string) (*avroType, error) {
+	el := n.Element
+	ct := parquet.ConvertedType(-1)
+	if el.IsSetConvertedType() {
+		ct = el.GetConvertedType()
+	}
+	if ct == parquet.ConvertedType_DECIMAL {
+		t := &avroType{Type: "bytes", Logical: "decimal", Precision: int(el.GetPrecision()), Scale: int(el.GetScale())}
+		if el.GetType() == parquet.Type_FIXED_LEN_BYTE_ARRAY {
+			t.Type, t.Name, t.Size = "fixed", fullName, int(el.GetTypeLength())
+		}
+		return t, nil
+	}
+	if unit := n.timestampUnit(); unit != "" {
+		local := el.IsSetLogicalType() && el.GetLogicalType().IsSetTIMESTAMP() && !el.GetLogicalType().GetTIMESTAMP().IsAdjustedToUTC
+		switch {
+		case unit == "ns":
+			// avro has no nanosecond timestamps before 1.12, the value stays the raw count
+			return &avroType{Type: "long"}, nil
+		case local:
+			return &avroType{Type: "long", Logical: "local-timestamp-" + map[string]string{"ms": "millis", "us": "micros"}[unit]}, nil
+		default:
+			return &avroType{Type: "long", Logical: "timestamp-" + map[string]string{"ms": "millis", "us": "micros"}[unit]}, nil
+		}
+	}
+	switch el.GetType() {
+	case parquet.Type_BOOLEAN:
+		return &avroType{Type: "boolean"}, nil
+	case parquet.Type_INT32:
+		switch ct { //nolint:exhaustive // the rest are plain int
+		case parquet.ConvertedType_UINT_32:
+			return &avroType{Type: "long"}, nil
+		case parquet.ConvertedType_DATE:
+			return &avroType{Type: "int", Logical: "date"}, nil
+		case parquet.ConvertedType_TIME_MILLIS:
+			return &avroType{Type: "int", Logical: "time-millis"}, nil
+		}
+		return &avroType{Type: "int"}, nil
+	case parquet.Type_INT64:
+		if ct == parquet.ConvertedType_TIME_MICROS {
+			return &avroType{Type: "long", Logical: "time-micros"}, nil
+		}
+		return &avroType{Type: "long"}, nil
+	case parquet.Type_INT96:
+		return &avroType{Type: "long", Logical: "timestamp-micros"}, nil
+	case parquet.Type_FLOAT:
+		return &avroType{Type: "float"}, nil
+	case parquet.Type_DOUBLE:
+		return &avroType{Type: "double"}, nil
+	case parquet.Type_BYTE_ARRAY:
+		if ct == parquet.ConvertedType_UTF8 || ct == parquet.ConvertedType_ENUM || ct == parquet.ConvertedType_JSON ||
+			(el.IsSetLogicalType() && el.GetLogicalType().IsSetSTRING()) {
+			return &avroType{Type: "string"}, nil
+		}
+		return &avroType{Type: "bytes"}, nil
+	case parquet.Type_FIXED_LEN_BYTE_ARRAY:
+		return &avroType{Type: "fixed", Name: fullName, Size: int(el.GetTypeLength())}, nil
+	}
+	return nil, errors.New("unsupported parquet type " + el.GetType().String())
+}
+
+// schema returns the json form of the type, named types are written in full once.
+func (t *avroType) schema(defined map[string]bool) any {
+	if t.Name != "" && defined[t.Name] {
+		return t.Name
+	}
+	if t.Name != "" {
+		defined[t.Name] = true
+	}
+	s := map[string]any{"type": t.Type}
+	switch t.Type {
+	case "union":
+		branches := make([]any, 0, len(t.Branches))
+		for _, b := range t.Branches {
+			branches = append(branches, b.schema(defined))
+		}
+		return branches
+	case "record":
+		fields := make([]any, 0, len(t.Fields))
+		for _, f := range t.Fields {
+			field := map[string]any{"name": f.Name, "type": f.Type.schema(defined)}
+			if f.Type.Type == "union" {
+				field["default"] = nil
+			}
+			fields = append(fields, field)
+		}
+		s["name"], s["fields"] = t.Name, fields
+	case "array":
+		s["items"] = t.Items.schema(defined)
+	case "map":
+		s["values"] = t.Items.schema(defined)
+	case "fixed":
+		s["name"], s["size"] = t.Name, t.Size
+	default:
+		if t.Logical == "" {
+			return t.Type
+		}
+	}
+	if t.Logical != "" {
+		s["logicalType"] = t.Logical
+	}
+	if t.Logical == "decimal" {
+		s["precision"], s["scale"] = t.Precision, t.Scale
+	}
+	return s
+}
+
+// avroConverter returns the function converting values of the node to goavro native values of t.
+func (n *Node) avroConverter(t *avroType) (func(v reflect.Value) any, error) {
+	value, nullable, err := t.nullable()
+	if err != nil {
+		return nil, err
+	}
+	union := t.Type == "union"
+	if n.isOptional() && !nullable {
+		return nil, errors.New("column " + n.Name + " is optional, the avro type isn't a union with null")
+	}
+	conv, err := n.avroValueConverter(value)
+	if err != nil {
+		return nil, err
+	}
+	name := value.unionName()
+	return func(v reflect.Value) any {
+		if v.Kind() == reflect.Interface {
+			v = v.Elem()
+		}
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return nil
+			}
+			v = v.Elem()
+		}
+		if !v.IsValid() {
+			return nil
+		}
+		if (v.Kind() == reflect.Slice || v.Kind() == reflect.Map) && v.IsNil() && n.isOptional() {
+			return nil
+		}
+		if union {
+			return goavro.Union(name, conv(v))
+		}
+		return conv(v)
+	}, nil
+}
+
+//nolint:gocyclo,cyclop,funlen // one case per avro type
+func (n *Node) avroValueConverter(t *avroType) (func(v reflect.Value) any, error) {
+	mismatch := errors.New("column " + n.Name + " doesn't match avro type " + t.Type)
+	switch {
+	case t.Type == "array" && n.isList(), t.Type == "array" && n.isRepeated():
+		elem := n.Children[0].Children[0]
+		if !n.isList() {
+			elem = &Node{Name: n.Name, Field: n.Field, Element: n.single(), Children: n.Children}
+		}
+		conv, err := elem.avroConverter(t.Items)
+		if err != nil {
+			return nil, err
+		}
+		return func(v reflect.Value) any {
+			out := make([]any, v.Len())
+			for i := range v.Len() {
+				out[i] = conv(v.Index(i))
+			}
+			return out
+		}, nil
+	case t.Type == "map" && n.isMap():
+		key, value := n.Children[0].Children[0], n.Children[0].Children[1]
+		conv, err := value.avroConverter(t.Items)
+		if err != nil {
+			return nil, err
+		}
+		return func(v reflect.Value) any {
+			out := make(map[string]any, v.Len())
+			iter := v.MapRange()
+			for iter.Next() {
+				out[helper.AnyToString(key.Value(iter.Key()))] = conv(iter.Value())
+			}
+			return out
+		}, nil
+	case t.Type == "record" && len(n.Children) > 0 && !n.isList() && !n.isMap():
+		if len(t.Fields) != len(n.Children) {
+			return nil, errors.New("group " + n.Name + " and avro record " + t.Name + " have different fields")
+		}
+		convs := make([]func(reflect.Value) any, len(t.Fields))
+		for i, f := range t.Fields {
+			c := n.Children[i]
+			if f.Name != c.Name && avroName(c.Name) != f.Name {
+				return nil, errors.New("column " + c.Name + " doesn't match avro field " + f.Name)
+			}
+			conv, err := c.avroConverter(f.Type)
+			if err != nil {
+				return nil, err
+			}
+			convs[i] = conv
+		}
+		return func(v reflect.Value) any {
+			out := make(map[string]any, len(convs))
+			for i, conv := range convs {
+				out[t.Fields[i].Name] = conv(v.FieldByName(n.Children[i].Field))
+			}
+			return out
+		}, nil
+	case len(n.Children) > 0 || n.isRepeated():
+		return nil, mismatch
+	}
+
+	pt := n.Element.GetType()
+	switch {
+	case t.isDecimal():
+		if !n.Element.IsSetConvertedType() || n.Element.GetConvertedType() != parquet.ConvertedType_DECIMAL {
+			return nil, mismatch
+		}
+		scale := int(n.Element.GetScale())
+		return func(v reflect.Value) any {
+			return parquetDecimal(v, scale)
+		}, nil
+	case t.Type == "boolean" && pt == parquet.Type_BOOLEAN:
+		return func(v reflect.Value) any {
+			return v.Bool()
+		}, nil
+	case t.Type == "int" && pt == parquet.Type_INT32:
+		return func(v reflect.Value) any {
+			return int32(v.Int()) //nolint:gosec // int32 column
+		}, nil
+	case t.Type == "long" && pt == parquet.Type_INT32:
+		unsigned := n.Element.IsSetConvertedType() && n.Element.GetConvertedType() == parquet.ConvertedType_UINT_32
+		return func(v reflect.Value) any {
+			if unsigned {
+				return int64(uint32(v.Int())) //nolint:gosec // parquet stores uint32 in int32 bits
+			}
+			return v.Int()
+		}, nil
+	case t.Type == "long" && pt == parquet.Type_INT64:
+		return func(v reflect.Value) any {
+			return v.Int()
+		}, nil
+	case t.Type == "long" && pt == parquet.Type_INT96:
+		return func(v reflect.Value) any {
+			ts := types.INT96ToTime(v.String())
+			if t.Logical == "timestamp-millis" {
+				return ts.UnixMilli()
+			}
+			return ts.UnixMicro()
+		}, nil
+	case t.Type == "float" && pt == parquet.Type_FLOAT, t.Type == "double" && pt == parquet.Type_DOUBLE:
+		return func(v reflect.Value) any {
+			return v.Interface()
+		}, nil
+	case t.Type == "double" && pt == parquet.Type_FLOAT:
+		return func(v reflect.Value) any {
+			return v.Float()
+		}, nil
+	case (t.Type == "string" || t.Type == "enum") && pt == parquet.Type_BYTE_ARRAY:
+		return func(v reflect.Value) any {
+			return v.String()
+		}, nil
+	case t.Type == "bytes" && pt == parquet.Type_BYTE_ARRAY, t.Type == "fixed" && pt == parquet.Type_FIXED_LEN_BYTE_ARRAY:
+		return func(v reflect.Value) any {
+			return []byte(v.String())
+		}, nil
+	}
+	return nil, mismatch
+}
@@ -0,0 +1,269 @@
+package schema
+
+import (
+	"encoding/json"
+	"math/big"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+const testAvroSchema = `{
+	"type": "record", "name": "Event", "namespace": "test",
+	"fields": [
+		{"name": "flag", "type": "boolean"},
+		{"name": "i", "type": "int"},
+		{"name": "l", "type": "long"},
+		{"name": "f", "type": "float"},
+		{"name": "d", "type": "double"},
+		{"name": "s", "type": "string"},
+		{"name": "raw", "type": "bytes"},
+		{"name": "id", "type": {"type": "fixed", "name": "Id", "size": 3}},
+		{"name": "color", "type": {"type": "enum", "name": "Color", "symbols": ["RED", "GREEN"]}},
+		{"name": "day", "type": {"type": "int", "logicalType": "date"}},
+		{"name": "t_ms", "type": {"type": "int", "logicalType": "time-millis"}},
+		{"name": "t_us", "type": {"type": "long", "logicalType": "time-micros"}},
+		{"name": "ts_ms", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+		{"name": "ts_us", "type": {"type": "long", "logicalType": "timestamp-micros"}},
+		{"name": "local", "type": {"type": "long", "logicalType": "local-timestamp-micros"}},
+		{"name": "price", "type": {"type": "bytes", "logicalType": "decimal", "precision": 9, "scale": 2}},
+		{"name": "big", "type": {"type": "fixed", "name": "Big", "size": 16, "logicalType": "decimal", "precision": 30, "scale": 4}},
+		{"name": "note", "type": ["null", "string"], "default": null},
+		{"name": "amount", "type": ["null", {"type": "bytes", "logicalType": "decimal", "precision": 5, "scale": 1}]},
+		{"name": "tags", "type": {"type": "array", "items": "string"}},
+		{"name": "scores", "type": {"type": "map", "values": ["null", "double"]}},
+		{"name": "point", "type": ["null", {"type": "record", "name": "Point", "fields": [
+			{"name": "x", "type": "double"},
+			{"name": "label", "type": ["null", "string"]}
+		]}]},
+		{"name": "points", "type": {"type": "array", "items": "Point"}}
+	]
+}`
+
+func testAvroRecords(t *testing.T, codec *goavro.Codec) [][]byte {
+	t.Helper()
+	records := []map[string]any{
+		{
+			"flag": true, "i": int32(-7), "l": int64(-1 << 40), "f": float32(1.5), "d": 2.25,
+			"s": "ann", "raw": []byte{0, 1, 2}, "id": []byte{9, 8, 7}, "color": "GREEN",
+			"day": time.Date(2022, 1, 2, 0, 0, 0, 0, time.UTC), "t_ms": 3723 * time.Millisecond,
+			"t_us": 3723000001 * time.Microsecond, "ts_ms": time.UnixMilli(1700000000123).UTC(),
+			"ts_us": time.UnixMicro(-1700000000123456).UTC(), "local": int64(1700000000123456),
+			"price": big.NewRat(-12345, 100), "big": new(big.Rat).SetFrac(bigInt(t, "-12345678901234567890123456"), big.NewInt(10000)),
+			"note": goavro.Union("string", "hi"), "amount": goavro.Union("bytes.decimal", big.NewRat(15, 10)),
+			"tags": []any{"a", "b"}, "scores": map[string]any{"k": goavro.Union("double", 1.0), "n": nil},
+			"point":  goavro.Union("test.Point", map[string]any{"x": 1.0, "label": goavro.Union("string", "p")}),
+			"points": []any{map[string]any{"x": 2.0, "label": nil}},
+		},
+		{
+			"flag": false, "i": int32(0), "l": int64(0), "f": float32(0), "d": 0.0,
+			"s": "", "raw": []byte{}, "id": []byte{0, 0, 0}, "color": "RED",
+			"day": time.Date(1969, 12, 31, 0, 0, 0, 0, time.UTC), "t_ms": time.Duration(0),
+			"t_us": time.Duration(0), "ts_ms": time.UnixMilli(0).UTC(),
+			"ts_us": time.UnixMicro(0).UTC(), "local": int64(0),
+			"price": big.NewRat(0, 1), "big": big.NewRat(1, 10000),
+			"note": nil, "amount": nil, "tags": []any{}, "scores": map[string]any{}, "point": nil, "points": []any{},
+		},
+	}
+	out := make([][]byte, 0, len(records))
+	for _, r := range records {
+		data, err := codec.BinaryFromNative(nil, r)
+		if err != nil {
+			t.Fatalf("BinaryFromNative() error: %v", err)
+		}
+		out = append(out, data)
+	}
+	return out
+}
+
+func bigInt(t *testing.T, s string) *big.Int {
+	t.Helper()
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		t.Fatalf("invalid number %s", s)
+	}
+	return n
+}
+
+func writeAvroParquet(t *testing.T, schema string, records [][]byte, codec *goavro.Codec) *reader.ParquetReader {
+	t.Helper()
+	rows, err := NewAvroRows(schema)
+	if err != nil {
+		t.Fatalf("NewAvroRows() error: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "avro.parquet")
+	fw, err := local.NewLocalFileWriter(path)
+	if err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	pw, err := writer.NewParquetWriter(fw, rows.New(), 1)
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+	pw.Footer.KeyValueMetadata = append(pw.Footer.KeyValueMetadata, &parquet.KeyValue{Key: AvroSchemaKey, Value: &schema})
+	for _, data := range records {
+		datum, _, decodeErr := codec.NativeFromBinary(data)
+		if decodeErr != nil {
+			t.Fatalf("NativeFromBinary() error: %v", decodeErr)
+		}
+		if err = pw.Write(rows.Row(datum)); err != nil {
+			t.Fatalf("Write error: %v", err)
+		}
+	}
+	if err = pw.WriteStop(); err != nil {
+		t.Fatalf("WriteStop error: %v", err)
+	}
+	_ = fw.Close()
+
+	fr, err := local.NewLocalFileReader(path)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	pr, err := reader.NewParquetReader(fr, nil, 1)
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+	return pr
+}
+
+func TestAvroParquetRoundTrip(t *testing.T) {
+	codec, err := goavro.NewCodec(testAvroSchema)
+	if err != nil {
+		t.Fatalf("NewCodec() error: %v", err)
+	}
+	records := testAvroRecords(t, codec)
+	pr := writeAvroParquet(t, testAvroSchema, records, codec)
+	defer pr.ReadStop()
+	read, err := pr.ReadByNumber(len(records))
+	if err != nil {
+		t.Fatalf("Read error: %v", err)
+	}
+
+	var stored string
+	for _, kv := range pr.Footer.GetKeyValueMetadata() {
+		if kv.GetKey() == AvroSchemaKey {
+			stored = kv.GetValue()
+		}
+	}
+	conv, err := NewTree(pr.SchemaHandler).AvroRecords(stored)
+	if err != nil {
+		t.Fatalf("AvroRecords() error: %v", err)
+	}
+	for i, row := range read {
+		// map keys are encoded in random order, compare the decoded values
+		data, err := codec.BinaryFromNative(nil, conv.Record(row))
+		if err != nil {
+			t.Fatalf("row %d: BinaryFromNative() error: %v", i, err)
+		}
+		got, _, _ := codec.NativeFromBinary(data)
+		want, _, _ := codec.NativeFromBinary(records[i])
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("row %d = %v; want %v", i, got, want)
+		}
+	}
+}
+
+func TestAvroRecordsDerivedSchema(t *testing.T) {
+	type point struct {
+		X float64 `parquet:"name=x, type=DOUBLE"`
+	}
+	type row struct {
+		ID     int64            `parquet:"name=id, type=INT64"`
+		Name   *string          `parquet:"name=first name, type=BYTE_ARRAY, convertedtype=UTF8"`
+		Price  int32            `parquet:"name=price, type=INT32, convertedtype=DECIMAL, precision=5, scale=2"`
+		At     int64            `parquet:"name=at, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+		Tags   []string         `parquet:"name=tags, type=LIST, valuetype=BYTE_ARRAY, valueconvertedtype=UTF8"`
+		Counts map[string]int32 `parquet:"name=counts, type=MAP, keytype=BYTE_ARRAY, keyconvertedtype=UTF8, valuetype=INT32"`
+		Point  *point           `parquet:"name=point"`
+	}
+	name := "ann"
+	path := filepath.Join(t.TempDir(), "derived.parquet")
+	fw, err := local.NewLocalFileWriter(path)
+	if err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	pw, err := writer.NewParquetWriter(fw, new(row), 1)
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+	rows := []row{
+		{ID: 1, Name: &name, Price: -1250, At: 1700000000123, Tags: []string{"a"}, Counts: map[string]int32{"k": 2}, Point: &point{X: 1.5}},
+		{ID: 2},
+	}
+	for _, r := range rows {
+		if err = pw.Write(r); err != nil {
+			t.Fatalf("Write error: %v", err)
+		}
+	}
+	if err = pw.WriteStop(); err != nil {
+		t.Fatalf("WriteStop error: %v", err)
+	}
+	_ = fw.Close()
+
+	fr, err := local.NewLocalFileReader(path)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	pr, err := reader.NewParquetReader(fr, nil, 1)
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+	defer pr.ReadStop()
+	read, err := pr.ReadByNumber(len(rows))
+	if err != nil {
+		t.Fatalf("Read error: %v", err)
+	}
+
+	conv, err := NewTree(pr.SchemaHandler).AvroRecords("")
+	if err != nil {
+		t.Fatalf("AvroRecords() error: %v", err)
+	}
+	codec, err := goavro.NewCodec(conv.Schema)
+	if err != nil {
+		t.Fatalf("NewCodec(%s) error: %v", conv.Schema, err)
+	}
+	want := []string{
+		`{"id":1,"first_name":{"string":"ann"},"price":"\u00fb\u001e","at":1700000000123,"tags":["a"],"counts":{"k":2},"point":{"parquet_go_root.point":{"x":1.5}}}`,
+		`{"id":2,"first_name":null,"price":"\u0000","at":0,"tags":[],"counts":{},"point":null}`,
+	}
+	for i, r := range read {
+		data, err := codec.TextualFromNative(nil, conv.Record(r))
+		if err != nil {
+			t.Fatalf("row %d: TextualFromNative() error: %v", i, err)
+		}
+		var got, exp any
+		_ = json.Unmarshal(data, &got)
+		_ = json.Unmarshal([]byte(want[i]), &exp)
+		if !reflect.DeepEqual(got, exp) {
+			t.Errorf("row %d = %s; want %s", i, data, want[i])
+		}
+	}
+}
+
+func TestNewAvroRowsUnsupported(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+	}{
+		{"not a record", `"string"`},
+		{"union of two types", `{"type":"record","name":"R","fields":[{"name":"c","type":["int","string"]}]}`},
+		{"recursive record", `{"type":"record","name":"R","fields":[{"name":"next","type":["null","R"]}]}`},
+		{"array of arrays", `{"type":"record","name":"R","fields":[{"name":"c","type":{"type":"array","items":{"type":"array","items":"int"}}}]}`},
+		{"unknown type", `{"type":"record","name":"R","fields":[{"name":"c","type":"Missing"}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewAvroRows(tt.schema); err == nil {
+				t.Errorf("NewAvroRows(%s) expected error", tt.schema)
+			}
+		})
+	}
+}
@@ -1,7 +1,10 @@
 package schema
 
 import (
+	"strconv"
 	"sync"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/bytedance/sonic"
 	"github.com/iancoleman/strcase"
@@ -51,12 +54,35 @@ func ProcessDefault(header []string) (interface{}, Processor) {
 
 func MakeDefaultSchema(header []string) interface{} {
 	sc := dynamicstruct.NewStruct()
+	used := make(map[string]bool, len(header))
 	for i := range header {
+		name := fieldName(header[i], i)
+		// F1 of "имя" and a column named F1, or user_name and userName, must not share a field
+		for n := 2; used[name]; n++ {
+			name = fieldName(header[i], i) + "_" + strconv.Itoa(n)
+		}
+		used[name] = true
 		sc.AddField(
-			strcase.ToCamel(header[i]),
+			name,
 			"",
 			`json:"`+header[i]+`" parquet:"name=`+header[i]+`, type=BYTE_ARRAY, convertedtype=UTF8"`,
 		)
 	}
 	return sc.Build().New()
 }
+
+// fieldName is the struct field of a header name, headers like "имя" that don't make
+// an exported go identifier get a positional name.
+func fieldName(header string, i int) string {
+	name := strcase.ToCamel(header)
+	first, _ := utf8.DecodeRuneInString(name)
+	if !unicode.IsUpper(first) {
+		return "F" + strconv.Itoa(i)
+	}
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			return "F" + strconv.Itoa(i)
+		}
+	}
+	return name
+}
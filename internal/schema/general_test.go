@@ -0,0 +1,29 @@
+package schema
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMakeDefaultSchema(t *testing.T) {
+	header := []string{"имя", "F0", "user_name", "userName", "F0_2"}
+	sc := MakeDefaultSchema(header)
+	typ := reflect.TypeOf(sc).Elem()
+	if typ.NumField() != len(header) {
+		t.Fatalf("fields = %d; want %d", typ.NumField(), len(header))
+	}
+	want := []string{"F0", "F0_2", "UserName", "UserName_2", "F02"}
+	for i, name := range want {
+		if got := typ.Field(i).Name; got != name {
+			t.Errorf("field %d = %s; want %s", i, got, name)
+		}
+	}
+
+	_, process := ProcessDefault(header)
+	v := reflect.ValueOf(process([]string{"a", "b", "c", "d", "e"}, sc, header, nil)).Elem()
+	for i, s := range []string{"a", "b", "c", "d", "e"} {
+		if got := v.Field(i).String(); got != s {
+			t.Errorf("field %d = %q; want %q", i, got, s)
+		}
+	}
+}
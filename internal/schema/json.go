@@ -0,0 +1,383 @@
+package schema
+
+import (
+	"encoding/json"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bytedance/sonic"
+	"github.com/pkg/errors"
+	"github.com/xitongsys/parquet-go/common"
+)
+
+type JSONKind int
+
+const (
+	JSONNull JSONKind = iota
+	JSONBool
+	JSONInt
+	JSONFloat
+	JSONString
+	JSONObject
+	JSONArray
+)
+
+// JSONType is the type inferred for a json value across all records.
+// Types widen when records disagree: int and float give float, objects or arrays mixed
+// with anything else and scalars of different kinds give string.
+type JSONType struct {
+	Kind     JSONKind
+	Optional bool
+	Fields   []*JSONField
+	Elem     *JSONType
+	seen     int
+	index    map[string]int
+}
+
+type JSONField struct {
+	Name  string
+	Type  *JSONType
+	count int
+}
+
+func NewJSONType() *JSONType {
+	return &JSONType{}
+}
+
+// Merge widens the type with a value decoded by encoding/json with UseNumber.
+func (t *JSONType) Merge(v any) {
+	kind := jsonKind(v)
+	if kind == JSONNull {
+		t.Optional = true
+		return
+	}
+	t.widen(kind)
+	switch t.Kind { //nolint:exhaustive // only containers keep a structure
+	case JSONObject:
+		t.seen++
+		if t.index == nil {
+			t.index = make(map[string]int)
+		}
+		obj, _ := v.(map[string]any)
+		for _, name := range sortedKeys(obj) {
+			i, ok := t.index[name]
+			if !ok {
+				i = len(t.Fields)
+				t.index[name] = i
+				t.Fields = append(t.Fields, &JSONField{Name: name, Type: NewJSONType()})
+			}
+			if obj[name] == nil {
+				t.Fields[i].Type.Optional = true
+				continue
+			}
+			t.Fields[i].count++
+			t.Fields[i].Type.Merge(obj[name])
+		}
+	case JSONArray:
+		if t.Elem == nil {
+			t.Elem = NewJSONType()
+		}
+		arr, _ := v.([]any)
+		for _, e := range arr {
+			t.Elem.Merge(e)
+		}
+	}
+}
+
+func (t *JSONType) widen(kind JSONKind) {
+	switch {
+	case t.Kind == JSONNull || t.Kind == kind:
+		t.Kind = kind
+	case t.Kind == JSONInt && kind == JSONFloat, t.Kind == JSONFloat && kind == JSONInt:
+		t.Kind = JSONFloat
+	default:
+		t.Kind = JSONString
+		t.Fields, t.Elem, t.index = nil, nil, nil
+	}
+}
+
+func jsonKind(v any) JSONKind {
+	switch value := v.(type) {
+	case nil:
+		return JSONNull
+	case bool:
+		return JSONBool
+	case json.Number:
+		if _, err := value.Int64(); err == nil {
+			return JSONInt
+		}
+		return JSONFloat
+	case float64:
+		return JSONFloat
+	case string:
+		return JSONString
+	case map[string]any:
+		return JSONObject
+	case []any:
+		return JSONArray
+	default:
+		return JSONString
+	}
+}
+
+// ParquetSchema returns the parquet-go json schema of a record type.
+func (t *JSONType) ParquetSchema() (string, error) {
+	if t.Kind != JSONObject || len(t.Fields) == 0 {
+		return "", errors.New("json records must be objects with at least one field")
+	}
+	root := &parquetschemaItem{Tag: "name=parquet_go_root, repetitiontype=REQUIRED"}
+	fields, err := t.parquetFields()
+	if err != nil {
+		return "", err
+	}
+	root.Fields = fields
+	data, err := sonic.ConfigStd.Marshal(root)
+	if err != nil {
+		return "", errors.Wrap(err, "error marshal parquet schema")
+	}
+	return string(data), nil
+}
+
+type parquetschemaItem struct {
+	Tag    string               `json:"Tag"`
+	Fields []*parquetschemaItem `json:"Fields,omitempty"`
+}
+
+func (t *JSONType) parquetFields() ([]*parquetschemaItem, error) {
+	names := make(map[string]string, len(t.Fields))
+	items := make([]*parquetschemaItem, 0, len(t.Fields))
+	for _, f := range t.Fields {
+		inName := common.StringToVariableName(f.Name)
+		if other, ok := names[inName]; ok {
+			return nil, errors.New("json fields " + other + " and " + f.Name + " map to the same parquet column")
+		}
+		names[inName] = f.Name
+		item, err := f.Type.parquetItem(f.Name, f.Type.Optional || f.count < t.seen)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (t *JSONType) parquetItem(name string, optional bool) (*parquetschemaItem, error) {
+	tag := "name=" + tagName(name)
+	repetition := ", repetitiontype=REQUIRED"
+	if optional {
+		repetition = ", repetitiontype=OPTIONAL"
+	}
+	switch t.Kind {
+	case JSONBool:
+		return &parquetschemaItem{Tag: tag + ", type=BOOLEAN" + repetition}, nil
+	case JSONInt:
+		return &parquetschemaItem{Tag: tag + ", type=INT64" + repetition}, nil
+	case JSONFloat:
+		return &parquetschemaItem{Tag: tag + ", type=DOUBLE" + repetition}, nil
+	case JSONObject:
+		if len(t.Fields) == 0 {
+			break
+		}
+		fields, err := t.parquetFields()
+		if err != nil {
+			return nil, err
+		}
+		return &parquetschemaItem{Tag: tag + repetition, Fields: fields}, nil
+	case JSONArray:
+		elem := t.Elem
+		if elem == nil {
+			elem = NewJSONType()
+		}
+		// null elements are dropped on write, parquet-go can't store them in a list
+		item, err := elem.parquetItem("element", elem.Kind == JSONNull)
+		if err != nil {
+			return nil, err
+		}
+		return &parquetschemaItem{Tag: tag + ", type=LIST" + repetition, Fields: []*parquetschemaItem{item}}, nil
+	case JSONNull, JSONString:
+	}
+	if t.Kind == JSONNull {
+		repetition = ", repetitiontype=OPTIONAL"
+	}
+	return &parquetschemaItem{Tag: tag + ", type=BYTE_ARRAY, convertedtype=UTF8" + repetition}, nil
+}
+
+// tagName keeps a json key from breaking the parquet-go tag syntax.
+func tagName(name string) string {
+	return strings.NewReplacer(",", "_", "=", "_").Replace(name)
+}
+
+// Coerce converts a decoded json value to match the inferred type,
+// values of widened string columns become their json text. A value that doesn't fit the type,
+// a missing required field or a key that isn't in the type is an error naming the field,
+// keys that are null or {} only are dropped.
+func (t *JSONType) Coerce(v any) (any, error) {
+	return t.coerce("", v)
+}
+
+func (t *JSONType) coerce(path string, v any) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	kind := jsonKind(v)
+	switch t.Kind {
+	case JSONObject:
+		if len(t.Fields) == 0 {
+			break
+		}
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil, mismatch(path, t.Kind, kind)
+		}
+		if path != "" {
+			path += "."
+		}
+		return t.coerceObject(path, obj)
+	case JSONArray:
+		arr, ok := v.([]any)
+		if !ok {
+			return nil, mismatch(path, t.Kind, kind)
+		}
+		elem := t.Elem
+		if elem == nil {
+			elem = NewJSONType()
+		}
+		out := make([]any, 0, len(arr))
+		for i, e := range arr {
+			if e == nil {
+				continue
+			}
+			value, err := elem.coerce(path+"["+strconv.Itoa(i)+"]", e)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, value)
+		}
+		return out, nil
+	case JSONBool:
+		if kind != JSONBool {
+			return nil, mismatch(path, t.Kind, kind)
+		}
+		return v, nil
+	case JSONInt:
+		if kind != JSONInt {
+			return nil, mismatch(path, t.Kind, kind)
+		}
+		return v, nil
+	case JSONFloat:
+		if kind != JSONInt && kind != JSONFloat {
+			return nil, mismatch(path, t.Kind, kind)
+		}
+		return v, nil
+	case JSONNull, JSONString:
+	}
+	switch value := v.(type) {
+	case string:
+		return value, nil
+	case json.Number:
+		return value.String(), nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	}
+	data, err := sonic.ConfigStd.MarshalToString(v)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshal "+path)
+	}
+	return data, nil
+}
+
+func (t *JSONType) coerceObject(path string, obj map[string]any) (any, error) {
+	out := make(map[string]any, len(obj))
+	for _, f := range t.Fields {
+		value := obj[f.Name]
+		if value == nil {
+			if !f.Type.Optional && f.count == t.seen {
+				return nil, errors.New("field " + path + f.Name + " is missing or null")
+			}
+			continue
+		}
+		coerced, err := f.Type.coerce(path+f.Name, value)
+		if err != nil {
+			return nil, err
+		}
+		out[tagName(f.Name)] = coerced
+	}
+	for _, name := range sortedKeys(obj) {
+		if _, ok := t.index[name]; ok {
+			continue
+		}
+		if nested, ok := obj[name].(map[string]any); obj[name] == nil || ok && len(nested) == 0 {
+			continue
+		}
+		return nil, errors.New("field " + path + name + " is not in the schema")
+	}
+	return out, nil
+}
+
+func mismatch(path string, want, got JSONKind) error {
+	return errors.New("field " + path + " is " + got.String() + ", not " + want.String())
+}
+
+func (k JSONKind) String() string {
+	switch k {
+	case JSONNull:
+		return "null"
+	case JSONBool:
+		return "a boolean"
+	case JSONInt:
+		return "an integer"
+	case JSONFloat:
+		return "a number"
+	case JSONString:
+		return "a string"
+	case JSONObject:
+		return "an object"
+	default:
+		return "an array"
+	}
+}
+
+// DropNullParents removes the top-level fields that are only null or {} while other fields are
+// named after them, the parents of nested objects Flatten turned into "parent.child" keys.
+func (t *JSONType) DropNullParents() {
+	fields := t.Fields[:0]
+	for _, f := range t.Fields {
+		empty := f.Type.Kind == JSONNull || f.Type.Kind == JSONObject && len(f.Type.Fields) == 0
+		if !empty || !slices.ContainsFunc(t.Fields, func(other *JSONField) bool {
+			return strings.HasPrefix(other.Name, f.Name+".")
+		}) {
+			fields = append(fields, f)
+		}
+	}
+	t.Fields = fields
+	for name := range t.index {
+		delete(t.index, name)
+	}
+	for i, f := range t.Fields {
+		t.index[f.Name] = i
+	}
+}
+
+// Flatten collapses nested objects into top-level keys joined by dots, e.g. {"a":{"b":1}} to {"a.b":1}.
+func Flatten(record map[string]any) map[string]any {
+	out := make(map[string]any, len(record))
+	flatten("", record, out)
+	return out
+}
+
+func flatten(prefix string, obj map[string]any, out map[string]any) {
+	for _, k := range sortedKeys(obj) {
+		v := obj[k]
+		if nested, ok := v.(map[string]any); ok && len(nested) > 0 {
+			flatten(prefix+k+".", nested, out)
+			continue
+		}
+		out[prefix+k] = v
+	}
+}
+
+func sortedKeys(obj map[string]any) []string {
+	return slices.Sorted(maps.Keys(obj))
+}
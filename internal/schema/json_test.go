@@ -0,0 +1,195 @@
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bytedance/sonic"
+)
+
+func decodeJSON(t *testing.T, s string) map[string]any {
+	t.Helper()
+	var v map[string]any
+	d := json.NewDecoder(strings.NewReader(s))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		t.Fatalf("Failed to decode %s: %v", s, err)
+	}
+	return v
+}
+
+func TestJSONTypeParquetSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		records []string
+		want    []string
+		wantErr bool
+	}{
+		{
+			"scalars",
+			[]string{`{"b":true,"i":1,"f":1.5,"s":"x"}`},
+			[]string{
+				"name=b, type=BOOLEAN, repetitiontype=REQUIRED",
+				"name=f, type=DOUBLE, repetitiontype=REQUIRED",
+				"name=i, type=INT64, repetitiontype=REQUIRED",
+				"name=s, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=REQUIRED",
+			},
+			false,
+		},
+		{
+			"widening and optional",
+			[]string{`{"n":1,"m":1,"o":{"a":1},"x":1}`, `{"n":2.5,"m":"x","o":"text"}`, `{"n":null,"m":true,"o":"t","x":2}`},
+			[]string{
+				"name=m, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=REQUIRED",
+				"name=n, type=DOUBLE, repetitiontype=OPTIONAL",
+				"name=o, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=REQUIRED",
+				"name=x, type=INT64, repetitiontype=OPTIONAL",
+			},
+			false,
+		},
+		{
+			"nested",
+			[]string{`{"u":{"a":1},"l":[1,2],"e":[],"z":null}`, `{"u":{"a":2,"b":"x"},"l":[]}`},
+			[]string{
+				"name=e, type=LIST, repetitiontype=OPTIONAL",
+				"name=l, type=LIST, repetitiontype=REQUIRED",
+				"name=u, repetitiontype=REQUIRED",
+				"name=z, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL",
+			},
+			false,
+		},
+		{"not objects", nil, nil, true},
+		{"name clash", []string{`{"a":1,"A":2}`}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			typ := NewJSONType()
+			for _, r := range tt.records {
+				typ.Merge(decodeJSON(t, r))
+			}
+			got, err := typ.ParquetSchema()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParquetSchema() error = %v; wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			root := &parquetschemaItem{}
+			if err = sonic.ConfigStd.UnmarshalFromString(got, root); err != nil {
+				t.Fatalf("Failed to parse schema: %v", err)
+			}
+			tags := make([]string, 0, len(root.Fields))
+			for _, f := range root.Fields {
+				tags = append(tags, f.Tag)
+			}
+			if !reflect.DeepEqual(tags, tt.want) {
+				t.Errorf("ParquetSchema() fields = %v; want %v", tags, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONTypeCoerce(t *testing.T) {
+	typ := NewJSONType()
+	typ.Merge(decodeJSON(t, `{"s":1,"o":{"a":1},"l":[1,null,2],"e":{},"n":1,"f":1,"b":true,"z":null}`))
+	typ.Merge(decodeJSON(t, `{"s":"x","o":{"a":2},"n":2,"f":2.5,"b":false}`))
+
+	tests := []struct {
+		name    string
+		record  string
+		want    string
+		wantErr string
+	}{
+		{
+			"fits",
+			`{"s":5,"o":{"a":3},"l":[3,null],"e":{},"n":3,"f":4,"b":true,"z":[1]}`,
+			`{"b":true,"e":"{}","f":4,"l":[3],"n":3,"o":{"a":3},"s":"5","z":"[1]"}`,
+			"",
+		},
+		{"null and {} keys outside the schema", `{"s":"x","o":{"a":1},"n":1,"f":1,"b":true,"u":null,"v":{}}`, `{"b":true,"f":1,"n":1,"o":{"a":1},"s":"x"}`, ""},
+		{"float in an integer field", `{"s":"x","o":{"a":1},"n":1.5,"f":1,"b":true}`, "", "field n is a number, not an integer"},
+		{"string in a nested integer field", `{"s":"x","o":{"a":"1"},"n":1,"f":1,"b":true}`, "", "field o.a is a string, not an integer"},
+		{"scalar in a list field", `{"s":"x","o":{"a":1},"l":1,"n":1,"f":1,"b":true}`, "", "field l is an integer, not an array"},
+		{"string in a list element", `{"s":"x","o":{"a":1},"l":[1,"2"],"n":1,"f":1,"b":true}`, "", "field l[1] is a string, not an integer"},
+		{"missing required field", `{"s":"x","o":{"a":1},"f":1,"b":true}`, "", "field n is missing or null"},
+		{"key outside the schema", `{"s":"x","o":{"a":1,"c":1},"n":1,"f":1,"b":true}`, "", "field o.c is not in the schema"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := typ.Coerce(decodeJSON(t, tt.record))
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Coerce() error = %v; want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Coerce() error = %v", err)
+			}
+			got, err := sonic.ConfigStd.MarshalToString(value)
+			if err != nil {
+				t.Fatalf("Marshal error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Coerce() = %s; want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONTypeCoerceAfterInferRows(t *testing.T) {
+	records := []string{
+		`{"id":1,"tags":["a"]}`,
+		`{"id":2,"tags":[]}`,
+		`{"id":3,"tags":["b"]}`,
+		`{"id":"4","tags":["c"]}`,
+		`{"id":5,"tags":"d"}`,
+		`{"id":6,"tags":["e"],"extra":true}`,
+		`{"tags":["f"]}`,
+	}
+	// the schema of the first two records like --infer-rows 2
+	typ := NewJSONType()
+	for _, r := range records[:2] {
+		typ.Merge(decodeJSON(t, r))
+	}
+
+	var rejected []int
+	for i, r := range records {
+		if _, err := typ.Coerce(decodeJSON(t, r)); err != nil {
+			rejected = append(rejected, i+1)
+		}
+	}
+	if want := []int{4, 5, 6, 7}; !reflect.DeepEqual(rejected, want) {
+		t.Errorf("Coerce() rejected records %v; want %v", rejected, want)
+	}
+}
+
+func TestJSONTypeDropNullParents(t *testing.T) {
+	typ := NewJSONType()
+	typ.Merge(Flatten(decodeJSON(t, `{"a":{"b":1},"c":null}`)))
+	typ.Merge(Flatten(decodeJSON(t, `{"a":null,"c":null}`)))
+	typ.DropNullParents()
+
+	names := make([]string, 0, len(typ.Fields))
+	for _, f := range typ.Fields {
+		names = append(names, f.Name)
+	}
+	if want := []string{"a.b", "c"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("DropNullParents() fields = %v; want %v", names, want)
+	}
+	if _, err := typ.Coerce(Flatten(decodeJSON(t, `{"a":null,"c":null}`))); err != nil {
+		t.Errorf("Coerce() error = %v", err)
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	got := Flatten(decodeJSON(t, `{"a":{"b":{"c":1},"d":[{"e":1}]},"f":{},"g":2}`))
+	want := map[string]any{"a.b.c": json.Number("1"), "a.d": []any{map[string]any{"e": json.Number("1")}}, "f": map[string]any{}, "g": json.Number("2")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Flatten() = %v; want %v", got, want)
+	}
+}
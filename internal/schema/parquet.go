@@ -0,0 +1,302 @@
+package schema
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"reflect"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/dbunt1tled/parquet2csv/internal/helper"
+	"github.com/xitongsys/parquet-go/parquet"
+	parquetschema "github.com/xitongsys/parquet-go/schema"
+	"github.com/xitongsys/parquet-go/types"
+)
+
+const dateLayout = "2006-01-02"
+
+// Node is an element of a parquet schema tree.
+// Name is the column name as stored in the file, Field is the name of the Go struct field
+// the generic parquet-go reader decodes it into.
+type Node struct {
+	Name     string
+	Field    string
+	Element  *parquet.SchemaElement
+	Children []*Node
+}
+
+// Field is a single key of an Object.
+type Field struct {
+	Name  string
+	Value any
+}
+
+// Object keeps the fields of a parquet group in schema order.
+type Object []Field
+
+func (o Object) MarshalJSON() ([]byte, error) {
+	buf := make([]byte, 0, 64) //nolint:mnd // initial capacity
+	buf = append(buf, '{')
+	for i, f := range o {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		key, err := sonic.ConfigStd.Marshal(f.Name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := sonic.ConfigStd.Marshal(f.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, key...)
+		buf = append(buf, ':')
+		buf = append(buf, value...)
+	}
+	return append(buf, '}'), nil
+}
+
+// NewTree builds the schema tree of a parquet file.
+func NewTree(sh *parquetschema.SchemaHandler) *Node {
+	pos := 0
+	var build func() *Node
+	build = func() *Node {
+		i := pos
+		pos++
+		n := &Node{
+			Name:    sh.Infos[i].ExName,
+			Field:   sh.Infos[i].InName,
+			Element: sh.SchemaElements[i],
+		}
+		for range sh.SchemaElements[i].GetNumChildren() {
+			n.Children = append(n.Children, build())
+		}
+		return n
+	}
+	return build()
+}
+
+// Leaves returns the primitive columns in schema order.
+func (n *Node) Leaves() []*Node {
+	if len(n.Children) == 0 {
+		return []*Node{n}
+	}
+	var leaves []*Node
+	for _, c := range n.Children {
+		leaves = append(leaves, c.Leaves()...)
+	}
+	return leaves
+}
+
+// Value converts a row decoded by the generic parquet-go reader into plain values:
+// groups become Object, lists []any, maps map[string]any, missing values nil,
+// and logical types (decimal, date, timestamp) are resolved.
+func (n *Node) Value(v reflect.Value) any {
+	return n.value(v, false)
+}
+
+// Native is Value with Go values instead of json ready ones: groups become map[string]any,
+// dates and timestamps time.Time in UTC. Decimals stay json.Number to keep their digits.
+func (n *Node) Native(v reflect.Value) any {
+	return n.value(v, true)
+}
+
+func (n *Node) value(v reflect.Value, native bool) any {
+	if v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return nil
+	}
+	switch {
+	case n.isList():
+		if v.IsNil() && n.isOptional() {
+			return nil
+		}
+		return n.slice(v, n.Children[0].Children[0], native)
+	case n.isMap():
+		key, value := n.Children[0].Children[0], n.Children[0].Children[1]
+		out := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			out[helper.AnyToString(key.Value(iter.Key()))] = value.value(iter.Value(), native)
+		}
+		return out
+	case n.isRepeated() && v.Kind() == reflect.Slice:
+		return n.slice(v, &Node{Name: n.Name, Field: n.Field, Element: n.single(), Children: n.Children}, native)
+	case len(n.Children) == 0:
+		return n.scalar(v.Interface(), native)
+	case native:
+		out := make(map[string]any, len(n.Children))
+		for _, c := range n.Children {
+			out[c.Name] = c.value(v.FieldByName(c.Field), native)
+		}
+		return out
+	default:
+		obj := make(Object, 0, len(n.Children))
+		for _, c := range n.Children {
+			obj = append(obj, Field{Name: c.Name, Value: c.Value(v.FieldByName(c.Field))})
+		}
+		return obj
+	}
+}
+
+func (n *Node) slice(v reflect.Value, elem *Node, native bool) []any {
+	out := make([]any, v.Len())
+	for i := range v.Len() {
+		out[i] = elem.value(v.Index(i), native)
+	}
+	return out
+}
+
+func (n *Node) isOptional() bool {
+	return n.Element.IsSetRepetitionType() && n.Element.GetRepetitionType() == parquet.FieldRepetitionType_OPTIONAL
+}
+
+func (n *Node) isRepeated() bool {
+	return n.Element.IsSetRepetitionType() && n.Element.GetRepetitionType() == parquet.FieldRepetitionType_REPEATED
+}
+
+// single is the element of a repeated node without the repetition.
+func (n *Node) single() *parquet.SchemaElement {
+	el := *n.Element
+	rt := parquet.FieldRepetitionType_REQUIRED
+	el.RepetitionType = &rt
+	return &el
+}
+
+func (n *Node) isList() bool {
+	return n.Element.IsSetConvertedType() && n.Element.GetConvertedType() == parquet.ConvertedType_LIST &&
+		len(n.Children) == 1 && n.Children[0].Field == "List" &&
+		len(n.Children[0].Children) == 1 && n.Children[0].Children[0].Field == "Element"
+}
+
+func (n *Node) isMap() bool {
+	return n.Element.IsSetConvertedType() && n.Element.GetConvertedType() == parquet.ConvertedType_MAP &&
+		len(n.Children) == 1 && n.Children[0].Field == "Key_value" &&
+		len(n.Children[0].Children) == 2 && n.Children[0].Children[0].Field == "Key" &&
+		n.Children[0].Children[1].Field == "Value"
+}
+
+func (n *Node) scalar(value any, native bool) any {
+	el := n.Element
+	switch v := value.(type) {
+	case float32:
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return nil
+		}
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil
+		}
+	case int32:
+		switch {
+		case el.IsSetConvertedType() && el.GetConvertedType() == parquet.ConvertedType_DECIMAL:
+			return n.decimal(v)
+		case el.IsSetConvertedType() && el.GetConvertedType() == parquet.ConvertedType_DATE:
+			t := time.Unix(int64(v)*int64(24*time.Hour/time.Second), 0).UTC()
+			if native {
+				return t
+			}
+			return t.Format(dateLayout)
+		}
+	case int64:
+		switch {
+		case el.IsSetConvertedType() && el.GetConvertedType() == parquet.ConvertedType_DECIMAL:
+			return n.decimal(v)
+		case n.timestampUnit() != "" && native:
+			return n.timestamp(v)
+		case n.timestampUnit() != "":
+			return n.timestamp(v).Format(time.RFC3339Nano)
+		}
+	case string:
+		switch {
+		case el.GetType() == parquet.Type_INT96 && native:
+			return types.INT96ToTime(v).UTC()
+		case el.GetType() == parquet.Type_INT96:
+			return types.INT96ToTime(v).Format(time.RFC3339Nano)
+		case el.IsSetConvertedType() && el.GetConvertedType() == parquet.ConvertedType_DECIMAL:
+			return n.decimal(v)
+		}
+	}
+	return value
+}
+
+// Text formats a value returned by Value or Native as a text field: groups, lists and maps as json,
+// times as RFC 3339, missing values empty.
+func Text(v any) string {
+	switch value := v.(type) {
+	case json.Number:
+		return value.String()
+	case time.Time:
+		return value.Format(time.RFC3339Nano)
+	case Object, []any, map[string]any:
+		data, _ := sonic.ConfigStd.MarshalToString(value)
+		return data
+	default:
+		return helper.AnyToString(value)
+	}
+}
+
+// decimal formats an unscaled decimal value with all digits of its scale.
+func (n *Node) decimal(v any) json.Number {
+	scale := int(n.Element.GetScale())
+	return json.Number(parquetDecimal(reflect.ValueOf(v), scale).FloatString(scale))
+}
+
+// parquetDecimal reads an unscaled parquet decimal stored as an integer or big-endian two's complement bytes.
+func parquetDecimal(v reflect.Value, scale int) *big.Rat {
+	var n *big.Int
+	if v.Kind() == reflect.String {
+		b := []byte(v.String())
+		n = new(big.Int).SetBytes(b)
+		if len(b) > 0 && b[0]&0x80 != 0 {
+			n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(len(b)*8))) //nolint:gosec,mnd // two's complement
+		}
+	} else {
+		n = big.NewInt(v.Int())
+	}
+	return new(big.Rat).SetFrac(n, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)) //nolint:mnd // decimal
+}
+
+func (n *Node) timestampUnit() string {
+	el := n.Element
+	if el.IsSetLogicalType() && el.GetLogicalType().IsSetTIMESTAMP() {
+		unit := el.GetLogicalType().GetTIMESTAMP().GetUnit()
+		switch {
+		case unit.IsSetMILLIS():
+			return "ms"
+		case unit.IsSetMICROS():
+			return "us"
+		case unit.IsSetNANOS():
+			return "ns"
+		}
+	}
+	if el.IsSetConvertedType() {
+		switch el.GetConvertedType() { //nolint:exhaustive // only timestamps
+		case parquet.ConvertedType_TIMESTAMP_MILLIS:
+			return "ms"
+		case parquet.ConvertedType_TIMESTAMP_MICROS:
+			return "us"
+		}
+	}
+	return ""
+}
+
+func (n *Node) timestamp(v int64) time.Time {
+	switch n.timestampUnit() {
+	case "ms":
+		return time.UnixMilli(v).UTC()
+	case "us":
+		return time.UnixMicro(v).UTC()
+	default:
+		return time.Unix(0, v).UTC()
+	}
+}
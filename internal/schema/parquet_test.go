@@ -0,0 +1,159 @@
+package schema
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+type testAddress struct {
+	City string `parquet:"name=city, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+type testRow struct {
+	ID      int64            `parquet:"name=id, type=INT64"`
+	Name    *string          `parquet:"name=userName, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+	Score   float64          `parquet:"name=score, type=DOUBLE"`
+	Active  bool             `parquet:"name=active, type=BOOLEAN"`
+	Price   int64            `parquet:"name=price, type=INT64, convertedtype=DECIMAL, scale=2, precision=10"`
+	Born    int32            `parquet:"name=born, type=INT32, convertedtype=DATE"`
+	Seen    int64            `parquet:"name=seen, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	Tags    []string         `parquet:"name=tags, type=LIST, valuetype=BYTE_ARRAY, valueconvertedtype=UTF8"`
+	Attrs   map[string]int32 `parquet:"name=attrs, type=MAP, keytype=BYTE_ARRAY, keyconvertedtype=UTF8, valuetype=INT32"`
+	Address *testAddress     `parquet:"name=address, repetitiontype=OPTIONAL"`
+	Nums    []int32          `parquet:"name=nums, type=INT32, repetitiontype=REPEATED"`
+}
+
+// readTestRows writes two testRow rows to parquet and reads them back with the generic reader.
+func readTestRows(t *testing.T) (*reader.ParquetReader, []interface{}) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "nested.parquet")
+	fw, err := local.NewLocalFileWriter(path)
+	if err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	pw, err := writer.NewParquetWriter(fw, new(testRow), 1)
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+	name := "ann"
+	rows := []testRow{
+		{
+			ID: 1, Name: &name, Score: 1.5, Active: true, Price: 12345, Born: 19000, Seen: 1700000000123,
+			Tags: []string{"a", "b"}, Attrs: map[string]int32{"k": 7}, Address: &testAddress{City: "Kyiv"},
+			Nums: []int32{1, 2},
+		},
+		{ID: 2},
+	}
+	for _, row := range rows {
+		if err = pw.Write(row); err != nil {
+			t.Fatalf("Write error: %v", err)
+		}
+	}
+	if err = pw.WriteStop(); err != nil {
+		t.Fatalf("WriteStop error: %v", err)
+	}
+	_ = fw.Close()
+
+	fr, err := local.NewLocalFileReader(path)
+	if err != nil {
+		t.Fatalf("Failed to open file: %v", err)
+	}
+	pr, err := reader.NewParquetReader(fr, nil, 1)
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+	t.Cleanup(pr.ReadStop)
+	read, err := pr.ReadByNumber(len(rows))
+	if err != nil {
+		t.Fatalf("Read error: %v", err)
+	}
+	return pr, read
+}
+
+func TestNodeValue(t *testing.T) {
+	pr, read := readTestRows(t)
+	tree := NewTree(pr.SchemaHandler)
+	tests := []struct {
+		name string
+		row  interface{}
+		want string
+	}{
+		{
+			"full row",
+			read[0],
+			`{"id":1,"userName":"ann","score":1.5,"active":true,"price":123.45,"born":"2022-01-08",` +
+				`"seen":"2023-11-14T22:13:20.123Z","tags":["a","b"],"attrs":{"k":7},"address":{"city":"Kyiv"},"nums":[1,2]}`,
+		},
+		{
+			"empty row",
+			read[1],
+			`{"id":2,"userName":null,"score":0,"active":false,"price":0.00,"born":"1970-01-01",` +
+				`"seen":"1970-01-01T00:00:00Z","tags":[],"attrs":{},"address":null,"nums":[]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sonic.ConfigStd.MarshalToString(tree.Value(reflect.ValueOf(tt.row)))
+			if err != nil {
+				t.Fatalf("Marshal error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Value() = %s; want %s", got, tt.want)
+			}
+		})
+	}
+
+	leaves := tree.Leaves()
+	if len(leaves) != 12 || leaves[1].Name != "userName" {
+		t.Errorf("Leaves() = %d columns, second %q; want 12 and userName", len(leaves), leaves[1].Name)
+	}
+}
+
+func TestNodeNative(t *testing.T) {
+	pr, read := readTestRows(t)
+	got, ok := NewTree(pr.SchemaHandler).Native(reflect.ValueOf(read[0])).(map[string]any)
+	if !ok {
+		t.Fatalf("Native() = %T; want map[string]any", got)
+	}
+	want := map[string]any{
+		"born":    time.Date(2022, 1, 8, 0, 0, 0, 0, time.UTC),
+		"seen":    time.UnixMilli(1700000000123).UTC(),
+		"price":   json.Number("123.45"),
+		"address": map[string]any{"city": "Kyiv"},
+		"tags":    []any{"a", "b"},
+	}
+	for name, value := range want {
+		if !reflect.DeepEqual(got[name], value) {
+			t.Errorf("Native()[%q] = %#v; want %#v", name, got[name], value)
+		}
+	}
+}
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		value any
+		want  string
+	}{
+		{nil, ""},
+		{"a,b", "a,b"},
+		{int32(7), "7"},
+		{json.Number("1.50"), "1.50"},
+		{time.Date(2024, 1, 2, 3, 4, 5, 600, time.UTC), "2024-01-02T03:04:05.0000006Z"},
+		{Object{{Name: "b", Value: 1}, {Name: "a", Value: nil}}, `{"b":1,"a":null}`},
+		{[]any{"x", 1}, `["x",1]`},
+	}
+	for _, tt := range tests {
+		if got := Text(tt.value); got != tt.want {
+			t.Errorf("Text(%#v) = %q; want %q", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/types"
+)
+
+// SQLKind is the dialect independent type of a sql column.
+type SQLKind int
+
+const (
+	SQLBoolean SQLKind = iota
+	SQLSmallInt
+	SQLInt
+	SQLBigInt
+	SQLUnsignedBigInt
+	SQLReal
+	SQLDouble
+	SQLDecimal
+	SQLDate
+	SQLTime
+	SQLTimestamp
+	SQLTimestampTZ
+	SQLText
+	SQLJSON
+	SQLBinary
+)
+
+const (
+	sqlTimeLayout      = "15:04:05.999999"
+	sqlTimestampLayout = "2006-01-02 15:04:05.999999"
+)
+
+// SQLColumn is a top-level parquet column as a sql table column, groups, lists and maps are JSON.
+type SQLColumn struct {
+	Name      string
+	Kind      SQLKind
+	Precision int
+	Scale     int
+	Nullable  bool
+}
+
+// SQLColumns maps the top-level columns of the schema tree to sql columns.
+func (n *Node) SQLColumns() []SQLColumn {
+	columns := make([]SQLColumn, 0, len(n.Children))
+	for _, c := range n.Children {
+		col := SQLColumn{Name: c.Name, Kind: c.sqlKind(), Nullable: c.isOptional()}
+		if col.Kind == SQLDecimal {
+			col.Precision, col.Scale = int(c.Element.GetPrecision()), int(c.Element.GetScale())
+		}
+		columns = append(columns, col)
+	}
+	return columns
+}
+
+//nolint:gocyclo,cyclop // one case per parquet type
+func (n *Node) sqlKind() SQLKind {
+	if len(n.Children) > 0 || n.isRepeated() {
+		return SQLJSON
+	}
+	el := n.Element
+	ct := parquet.ConvertedType(-1)
+	if el.IsSetConvertedType() {
+		ct = el.GetConvertedType()
+	}
+	if ct == parquet.ConvertedType_DECIMAL {
+		return SQLDecimal
+	}
+	if unit := n.timestampUnit(); unit != "" {
+		// the legacy converted types are always UTC adjusted
+		if ct != parquet.ConvertedType_TIMESTAMP_MILLIS && ct != parquet.ConvertedType_TIMESTAMP_MICROS &&
+			el.IsSetLogicalType() && el.GetLogicalType().IsSetTIMESTAMP() && !el.GetLogicalType().GetTIMESTAMP().IsAdjustedToUTC {
+			return SQLTimestamp
+		}
+		return SQLTimestampTZ
+	}
+	switch el.GetType() {
+	case parquet.Type_BOOLEAN:
+		return SQLBoolean
+	case parquet.Type_INT32:
+		switch ct { //nolint:exhaustive // the rest are plain int
+		case parquet.ConvertedType_INT_8, parquet.ConvertedType_INT_16, parquet.ConvertedType_UINT_8:
+			return SQLSmallInt
+		case parquet.ConvertedType_UINT_16:
+			return SQLInt
+		case parquet.ConvertedType_UINT_32:
+			return SQLBigInt
+		case parquet.ConvertedType_DATE:
+			return SQLDate
+		case parquet.ConvertedType_TIME_MILLIS:
+			return SQLTime
+		}
+		return SQLInt
+	case parquet.Type_INT64:
+		switch {
+		case ct == parquet.ConvertedType_UINT_64:
+			return SQLUnsignedBigInt
+		case ct == parquet.ConvertedType_TIME_MICROS, el.IsSetLogicalType() && el.GetLogicalType().IsSetTIME():
+			return SQLTime
+		}
+		return SQLBigInt
+	case parquet.Type_INT96:
+		return SQLTimestampTZ
+	case parquet.Type_FLOAT:
+		return SQLReal
+	case parquet.Type_DOUBLE:
+		return SQLDouble
+	case parquet.Type_BYTE_ARRAY:
+		switch {
+		case ct == parquet.ConvertedType_JSON:
+			return SQLJSON
+		case ct == parquet.ConvertedType_UTF8, ct == parquet.ConvertedType_ENUM, el.IsSetLogicalType() && el.GetLogicalType().IsSetSTRING():
+			return SQLText
+		}
+		return SQLBinary
+	case parquet.Type_FIXED_LEN_BYTE_ARRAY:
+		return SQLBinary
+	}
+	return SQLText
+}
+
+// SQLRow converts a row decoded by the generic parquet-go reader into values of the columns
+// returned by SQLColumns: nil, bool, json.Number for numbers, []byte for binary and string for the rest.
+// Timestamps are written in UTC without an offset.
+func (n *Node) SQLRow(row any, columns []SQLColumn) []any {
+	v := reflect.ValueOf(row)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	out := make([]any, len(n.Children))
+	for i, c := range n.Children {
+		out[i] = c.sqlValue(v.FieldByName(c.Field), columns[i].Kind)
+	}
+	return out
+}
+
+//nolint:gocyclo,cyclop // one case per sql kind
+func (n *Node) sqlValue(v reflect.Value, kind SQLKind) any {
+	if len(n.Children) > 0 || n.isRepeated() {
+		value := n.Value(v)
+		if value == nil {
+			return nil
+		}
+		data, _ := sonic.ConfigStd.MarshalToString(value)
+		return data
+	}
+	if v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return nil
+	}
+	switch kind {
+	case SQLBoolean:
+		return v.Bool()
+	case SQLUnsignedBigInt:
+		return json.Number(strconv.FormatUint(uint64(v.Int()), 10)) //nolint:gosec // parquet stores uint64 in int64 bits
+	case SQLBigInt:
+		if n.Element.GetType() == parquet.Type_INT32 {
+			return json.Number(strconv.FormatUint(uint64(uint32(v.Int())), 10)) //nolint:gosec // parquet stores uint32 in int32 bits
+		}
+		return json.Number(strconv.FormatInt(v.Int(), 10))
+	case SQLSmallInt, SQLInt:
+		if n.Element.IsSetConvertedType() && n.Element.GetConvertedType() == parquet.ConvertedType_UINT_16 {
+			return json.Number(strconv.FormatInt(int64(uint16(v.Int())), 10)) //nolint:gosec // parquet stores uint16 in int32 bits
+		}
+		return json.Number(strconv.FormatInt(v.Int(), 10))
+	case SQLReal, SQLDouble, SQLDecimal:
+		switch value := n.scalar(v.Interface(), false).(type) {
+		case nil:
+			return nil
+		case json.Number:
+			return value
+		case float32:
+			return json.Number(strconv.FormatFloat(float64(value), 'g', -1, 32))
+		case float64:
+			return json.Number(strconv.FormatFloat(value, 'g', -1, 64))
+		}
+	case SQLDate:
+		return n.scalar(v.Interface(), false)
+	case SQLTime:
+		d := time.Duration(v.Int()) * time.Millisecond
+		switch {
+		case n.Element.GetType() == parquet.Type_INT64 && n.Element.IsSetLogicalType() && n.Element.GetLogicalType().GetTIME().GetUnit().IsSetNANOS():
+			d = time.Duration(v.Int())
+		case n.Element.GetType() == parquet.Type_INT64:
+			d = time.Duration(v.Int()) * time.Microsecond
+		}
+		return time.Time{}.Add(d).Format(sqlTimeLayout)
+	case SQLTimestamp, SQLTimestampTZ:
+		if n.Element.GetType() == parquet.Type_INT96 {
+			return types.INT96ToTime(v.String()).Format(sqlTimestampLayout)
+		}
+		return n.timestamp(v.Int()).Format(sqlTimestampLayout)
+	case SQLBinary:
+		return []byte(v.String())
+	case SQLText, SQLJSON:
+	}
+	return v.String()
+}
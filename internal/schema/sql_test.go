@@ -0,0 +1,61 @@
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestNodeSQLRow(t *testing.T) {
+	pr, read := readTestRows(t)
+	tree := NewTree(pr.SchemaHandler)
+	columns := tree.SQLColumns()
+
+	wantColumns := []SQLColumn{
+		{Name: "id", Kind: SQLBigInt},
+		{Name: "userName", Kind: SQLText, Nullable: true},
+		{Name: "score", Kind: SQLDouble},
+		{Name: "active", Kind: SQLBoolean},
+		{Name: "price", Kind: SQLDecimal, Precision: 10, Scale: 2},
+		{Name: "born", Kind: SQLDate},
+		{Name: "seen", Kind: SQLTimestampTZ},
+		{Name: "tags", Kind: SQLJSON},
+		{Name: "attrs", Kind: SQLJSON},
+		{Name: "address", Kind: SQLJSON, Nullable: true},
+		{Name: "nums", Kind: SQLJSON},
+	}
+	if !reflect.DeepEqual(columns, wantColumns) {
+		t.Fatalf("SQLColumns() = %+v; want %+v", columns, wantColumns)
+	}
+
+	tests := []struct {
+		name string
+		row  interface{}
+		want []any
+	}{
+		{
+			"full row",
+			read[0],
+			[]any{
+				json.Number("1"), "ann", json.Number("1.5"), true, json.Number("123.45"), "2022-01-08",
+				"2023-11-14 22:13:20.123", `["a","b"]`, `{"k":7}`, `{"city":"Kyiv"}`, "[1,2]",
+			},
+		},
+		{
+			"empty row",
+			read[1],
+			[]any{
+				json.Number("2"), nil, json.Number("0"), false, json.Number("0.00"), "1970-01-01",
+				"1970-01-01 00:00:00", "[]", "{}", nil, "[]",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tree.SQLRow(tt.row, columns); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SQLRow() = %#v; want %#v", got, tt.want)
+			}
+		})
+	}
+}
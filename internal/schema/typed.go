@@ -0,0 +1,180 @@
+package schema
+
+import (
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	dynamicstruct "github.com/ompluscator/dynamic-struct"
+	"github.com/pkg/errors"
+)
+
+// maxInt64Decimal is the largest decimal precision stored in an INT64.
+const maxInt64Decimal = 18
+
+var decimalType = regexp.MustCompile(`^decimal\((\d+),\s*(\d+)\)$`) //nolint:gochecknoglobals // compiled once
+
+// TextType is the type of a text column, e.g. one of a fixed-width layout:
+// string, int, double, boolean, date or decimal(p,s).
+type TextType struct {
+	Name      string
+	Precision int
+	Scale     int
+}
+
+func ParseTextType(s string) (TextType, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "", "string":
+		return TextType{Name: "string"}, nil
+	case "int", "double", "boolean", "date":
+		return TextType{Name: s}, nil
+	}
+	m := decimalType.FindStringSubmatch(s)
+	if m == nil {
+		return TextType{}, errors.New("unknown type " + s + ", use string, int, double, boolean, date or decimal(p,s)")
+	}
+	p, _ := strconv.Atoi(m[1])
+	sc, _ := strconv.Atoi(m[2])
+	if p < 1 || p > maxInt64Decimal || sc > p {
+		return TextType{}, errors.New("decimal precision must be 1-18 and not less than scale: " + s)
+	}
+	return TextType{Name: "decimal", Precision: p, Scale: sc}, nil
+}
+
+// Parse converts a trimmed text value. Empty values of all but string columns are null.
+// Dates are YYYYMMDD or YYYY-MM-DD, booleans also Y and N.
+//
+//nolint:gocyclo,cyclop // one case per type
+func (t TextType) Parse(s string) (any, error) {
+	if t.Name == "string" {
+		return s, nil
+	}
+	if s == "" {
+		return nil, nil //nolint:nilnil // null value
+	}
+	switch t.Name {
+	case "int":
+		v, err := strconv.ParseInt(s, 10, 64)
+		return v, errors.Wrap(err, "invalid int "+s)
+	case "double":
+		v, err := strconv.ParseFloat(s, 64)
+		return v, errors.Wrap(err, "invalid double "+s)
+	case "boolean":
+		switch strings.ToUpper(s) {
+		case "Y", "YES":
+			return true, nil
+		case "N", "NO":
+			return false, nil
+		}
+		v, err := strconv.ParseBool(s)
+		return v, errors.Wrap(err, "invalid boolean "+s)
+	case "date":
+		layout := "20060102"
+		if strings.Contains(s, "-") {
+			layout = time.DateOnly
+		}
+		d, err := time.Parse(layout, s)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid date "+s)
+		}
+		return int32(d.Unix() / secondsPerDay), nil //nolint:gosec // days since epoch fit int32
+	case "decimal":
+		return t.parseDecimal(s)
+	}
+	return nil, errors.New("unknown type " + t.Name)
+}
+
+// parseDecimal returns the value as an integer scaled by 10^Scale.
+func (t TextType) parseDecimal(s string) (any, error) {
+	invalid := errors.New("invalid decimal(" + strconv.Itoa(t.Precision) + "," + strconv.Itoa(t.Scale) + ") " + s)
+	digits, negative := strings.CutPrefix(s, "-")
+	if !negative {
+		digits = strings.TrimPrefix(digits, "+")
+	}
+	whole, frac, _ := strings.Cut(digits, ".")
+	if len(frac) > t.Scale || whole == "" && frac == "" {
+		return nil, invalid
+	}
+	digits = strings.TrimLeft(whole+frac+strings.Repeat("0", t.Scale-len(frac)), "0")
+	if len(digits) > t.Precision {
+		return nil, invalid
+	}
+	if digits == "" {
+		return int64(0), nil
+	}
+	v, err := strconv.ParseUint(digits, 10, 64)
+	if err != nil || v > math.MaxInt64 {
+		return nil, invalid
+	}
+	if negative {
+		return -int64(v), nil
+	}
+	return int64(v), nil
+}
+
+func (t TextType) goType() any {
+	switch t.Name {
+	case "int", "decimal":
+		return new(int64)
+	case "double":
+		return new(float64)
+	case "boolean":
+		return new(bool)
+	case "date":
+		return new(int32)
+	}
+	return ""
+}
+
+func (t TextType) tag(name string) string {
+	switch t.Name {
+	case "int":
+		return "name=" + name + ", type=INT64, repetitiontype=OPTIONAL"
+	case "double":
+		return "name=" + name + ", type=DOUBLE, repetitiontype=OPTIONAL"
+	case "boolean":
+		return "name=" + name + ", type=BOOLEAN, repetitiontype=OPTIONAL"
+	case "date":
+		return "name=" + name + ", type=INT32, convertedtype=DATE, repetitiontype=OPTIONAL"
+	case "decimal":
+		return "name=" + name + ", type=INT64, convertedtype=DECIMAL, precision=" + strconv.Itoa(t.Precision) +
+			", scale=" + strconv.Itoa(t.Scale) + ", repetitiontype=OPTIONAL"
+	}
+	return "name=" + name + ", type=BYTE_ARRAY, convertedtype=UTF8"
+}
+
+// ProcessTyped is ProcessDefault with typed columns, string columns are required and the rest optional.
+// Records must hold values accepted by TextType.Parse.
+func ProcessTyped(header []string, types []TextType) (interface{}, Processor) {
+	sc := dynamicstruct.NewStruct()
+	for i := range header {
+		// field names don't come from the header, it may hold names that aren't go identifiers
+		sc.AddField("F"+strconv.Itoa(i), types[i].goType(), `parquet:"`+types[i].tag(header[i])+`"`)
+	}
+	return sc.Build().New(), func(record []string, sc interface{}, _ []string, _ *sync.Pool) interface{} {
+		v := reflect.ValueOf(sc).Elem()
+		for i, s := range record {
+			value, err := types[i].Parse(s)
+			if err != nil {
+				panic(err)
+			}
+			field := v.Field(i)
+			switch {
+			case value == nil:
+				field.SetZero()
+			case field.Kind() == reflect.Ptr:
+				p := reflect.New(field.Type().Elem())
+				p.Elem().Set(reflect.ValueOf(value))
+				field.Set(p)
+			default:
+				field.Set(reflect.ValueOf(value))
+			}
+		}
+		return sc
+	}
+}
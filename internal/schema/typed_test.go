@@ -0,0 +1,57 @@
+package schema
+
+import (
+	"testing"
+)
+
+func TestTextTypeParse(t *testing.T) {
+	tests := []struct {
+		typ     string
+		value   string
+		want    any
+		wantErr bool
+	}{
+		{"string", "", "", false},
+		{"", " a ", " a ", false},
+		{"int", "-0042", int64(-42), false},
+		{"int", "", nil, false},
+		{"int", "4x", nil, true},
+		{"double", "1.5e3", 1500.0, false},
+		{"boolean", "y", true, false},
+		{"boolean", "0", false, false},
+		{"boolean", "maybe", nil, true},
+		{"date", "20240115", int32(19737), false},
+		{"date", "1969-12-31", int32(-1), false},
+		{"date", "20241315", nil, true},
+		{"decimal(5,2)", "012.5", int64(1250), false},
+		{"decimal(5,2)", "-0.01", int64(-1), false},
+		{"decimal(5,2)", "+7", int64(700), false},
+		{"decimal(5,2)", "1234.00", nil, true},
+		{"decimal(5,2)", "1.234", nil, true},
+		{"decimal(5,2)", ".", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.typ+" "+tt.value, func(t *testing.T) {
+			typ, err := ParseTextType(tt.typ)
+			if err != nil {
+				t.Fatalf("ParseTextType(%q) error: %v", tt.typ, err)
+			}
+			got, err := typ.Parse(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse(%q) error = %v; wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("Parse(%q) = %#v; want %#v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTextTypeInvalid(t *testing.T) {
+	for _, s := range []string{"money", "decimal(19,2)", "decimal(2,3)", "decimal(0,0)"} {
+		if _, err := ParseTextType(s); err == nil {
+			t.Errorf("ParseTextType(%q) expected error", s)
+		}
+	}
+}
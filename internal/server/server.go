@@ -0,0 +1,226 @@
+// Package server serves csv ⇄ parquet conversions over HTTP.
+package server
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/dbunt1tled/parquet2csv/internal/file"
+	"github.com/dbunt1tled/parquet2csv/internal/metrics"
+	"github.com/dbunt1tled/parquet2csv/pkg/convert"
+	"github.com/pkg/errors"
+)
+
+const (
+	// MaxBody is the default limit of a request body.
+	MaxBody = 1 << 30
+	// ShutdownTimeout is the default wait for running conversions on shutdown.
+	ShutdownTimeout = 30 * time.Second
+
+	readHeaderTimeout = 10 * time.Second
+)
+
+// OptionsFunc turns the query of a request converting to the format "parquet" or "csv" into
+// the conversion options, it may peek at the start of the body, e.g. to sniff the csv dialect.
+// Its errors are bad requests, or 413 for a body over the limit. Rows a BadRow hook skips are counted
+// in the X-Rows-Rejected trailer.
+type OptionsFunc func(to string, query url.Values, body *bufio.Reader) (convert.Options, error)
+
+// Config configures a Server.
+type Config struct {
+	// MaxBody is the request body limit in bytes, MaxBody when zero.
+	MaxBody int64
+	// Concurrency is the number of conversions run at once, more get 503 Service Unavailable.
+	Concurrency int
+	// Options reads the conversion options of a request.
+	Options OptionsFunc
+	// Metrics count the rows of all conversions, optional.
+	Metrics *metrics.Metrics
+	// Log gets a line per conversion, optional.
+	Log io.Writer
+	// TempDir holds the parquet bodies while they are converted, os.TempDir when empty.
+	TempDir string
+}
+
+// Server converts the bodies of POST /convert?to=parquet and POST /convert?to=csv requests
+// and streams the result back. GET /healthz answers ok.
+type Server struct {
+	cfg   Config
+	slots chan struct{}
+}
+
+func New(cfg Config) *Server {
+	if cfg.MaxBody <= 0 {
+		cfg.MaxBody = MaxBody
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 1
+	}
+	if cfg.Metrics == nil {
+		cfg.Metrics = metrics.New("")
+	}
+	if cfg.Log == nil {
+		cfg.Log = io.Discard
+	}
+	return &Server{cfg: cfg, slots: make(chan struct{}, cfg.Concurrency)}
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /convert", s.convert)
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, "ok\n")
+	})
+	return mux
+}
+
+// Serve serves Handler on listener until ctx is done, then waits up to shutdownTimeout
+// for the running conversions to finish.
+func (s *Server) Serve(ctx context.Context, listener net.Listener, shutdownTimeout time.Duration) error {
+	srv := &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: readHeaderTimeout,
+		BaseContext: func(net.Listener) context.Context {
+			return context.WithoutCancel(ctx)
+		},
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.Serve(listener)
+	}()
+	select {
+	case err := <-done:
+		return errors.Wrap(err, "error serve")
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		_ = srv.Close()
+		return errors.Wrap(err, "error shutdown")
+	}
+	return nil
+}
+
+func (s *Server) convert(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	query := r.URL.Query()
+	to := query.Get("to")
+	query.Del("to")
+
+	var (
+		fn          func(context.Context, io.Reader, io.Writer, convert.Options) (convert.Stats, error)
+		contentType string
+	)
+	switch to {
+	case "parquet":
+		fn, contentType = convert.CSVToParquet, "application/vnd.apache.parquet"
+	case "csv":
+		fn, contentType = convert.ParquetToCSV, "text/csv"
+	default:
+		http.Error(w, "to must be parquet or csv", http.StatusBadRequest)
+		return
+	}
+	select {
+	case s.slots <- struct{}{}:
+		defer func() {
+			<-s.slots
+		}()
+	default:
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "too many conversions running", http.StatusServiceUnavailable)
+		return
+	}
+	if r.ContentLength > s.cfg.MaxBody {
+		http.Error(w, "request body over "+strconv.FormatInt(s.cfg.MaxBody, 10)+" bytes", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	body := bufio.NewReaderSize(http.MaxBytesReader(w, r.Body, s.cfg.MaxBody), file.SniffSize+1)
+	opts, err := s.cfg.Options(to, query, body)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err, http.StatusBadRequest))
+		return
+	}
+	mtr := s.cfg.Metrics
+	var last convert.Stats
+	opts.Hooks.BatchWritten = func(stats convert.Stats) {
+		mtr.RowsRead.Add(float64(stats.RowsRead - last.RowsRead))
+		mtr.RowsWritten.Add(float64(stats.RowsWritten - last.RowsWritten))
+		last = stats
+	}
+	if skip := opts.Hooks.BadRow; skip != nil {
+		opts.Hooks.BadRow = func(bad convert.BadRow) error {
+			mtr.RowsRejected.Inc()
+			return skip(bad)
+		}
+	}
+
+	var in io.Reader = body
+	if to == "csv" {
+		// parquet is read from its footer, the body goes to disk rather than memory
+		f, err := os.CreateTemp(s.cfg.TempDir, "convert-*.parquet")
+		if err != nil {
+			http.Error(w, "error create temp file", http.StatusInternalServerError)
+			return
+		}
+		defer func() {
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+		}()
+		if _, err = io.Copy(f, body); err != nil {
+			http.Error(w, err.Error(), errorStatus(err, http.StatusBadRequest))
+			return
+		}
+		in = f
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Trailer", "X-Rows-Read, X-Rows-Written, X-Rows-Rejected")
+	out := &responseWriter{w: w}
+	stats, err := fn(r.Context(), in, out, opts)
+	if err != nil {
+		fmt.Fprintf(s.cfg.Log, "%s %s: %v\n", r.Method, r.URL, err)
+		if out.n > 0 {
+			// the status is sent, a broken response tells the client the output is incomplete
+			panic(http.ErrAbortHandler)
+		}
+		w.Header().Del("Trailer")
+		http.Error(w, err.Error(), errorStatus(err, http.StatusUnprocessableEntity))
+		return
+	}
+	w.Header().Set("X-Rows-Read", strconv.FormatInt(stats.RowsRead, 10))
+	w.Header().Set("X-Rows-Written", strconv.FormatInt(stats.RowsWritten, 10))
+	w.Header().Set("X-Rows-Rejected", strconv.FormatInt(stats.RowsRejected, 10))
+	fmt.Fprintf(s.cfg.Log, "%s %s: %d rows, %d bytes in %s\n",
+		r.Method, r.URL, stats.RowsWritten, out.n, time.Since(start).Round(time.Millisecond))
+}
+
+// errorStatus is 413 for a body over the limit, otherwise status.
+func errorStatus(err error, status int) int {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return status
+}
+
+// responseWriter counts what is written to the response.
+type responseWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (rw *responseWriter) Write(p []byte) (int, error) {
+	n, err := rw.w.Write(p)
+	rw.n += int64(n)
+	return n, err
+}
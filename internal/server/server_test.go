@@ -0,0 +1,202 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dbunt1tled/parquet2csv/pkg/convert"
+	"github.com/pkg/errors"
+)
+
+// testOptions takes the delimiter and bad-rows options and peeks at the body like the csv sniffing does,
+// bad-rows types the id column too.
+func testOptions(_ string, query url.Values, body *bufio.Reader) (convert.Options, error) {
+	var opts convert.Options
+	for name := range query {
+		if name != "delimiter" && name != "bad-rows" {
+			return opts, errors.New("unknown option " + name)
+		}
+	}
+	if query.Has("bad-rows") {
+		opts.Schema = []convert.Column{{Name: "id", Type: "int"}}
+		opts.Hooks.BadRow = func(convert.BadRow) error {
+			return nil
+		}
+	}
+	if _, err := body.Peek(1); err != nil && !errors.Is(err, io.EOF) {
+		return opts, err
+	}
+	opts.Delimiter = query.Get("delimiter")
+	return opts, nil
+}
+
+func newTestServer(t *testing.T, cfg Config) *httptest.Server {
+	t.Helper()
+	cfg.Options = testOptions
+	srv := httptest.NewServer(New(cfg).Handler())
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func post(t *testing.T, url string, body io.Reader) (*http.Response, []byte) {
+	t.Helper()
+	resp, err := http.Post(url, "application/octet-stream", body) //nolint:noctx // test request
+	if err != nil {
+		t.Fatalf("POST %s error = %v", url, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("POST %s read error = %v", url, err)
+	}
+	return resp, data
+}
+
+func TestConvert(t *testing.T) {
+	dir := t.TempDir()
+	srv := newTestServer(t, Config{Concurrency: 2, TempDir: dir})
+	in := "id;name\n1;a\n2;b\n"
+
+	resp, pq := post(t, srv.URL+"/convert?to=parquet&delimiter=%3B", strings.NewReader(in))
+	if resp.StatusCode != http.StatusOK || !bytes.HasPrefix(pq, []byte("PAR1")) {
+		t.Fatalf("to=parquet = %d %q", resp.StatusCode, pq)
+	}
+	if resp.Header.Get("Content-Type") != "application/vnd.apache.parquet" || resp.Trailer.Get("X-Rows-Written") != "2" {
+		t.Errorf("to=parquet headers = %v, trailers = %v", resp.Header, resp.Trailer)
+	}
+
+	resp, out := post(t, srv.URL+"/convert?to=csv&delimiter=%7C", bytes.NewReader(pq))
+	if resp.StatusCode != http.StatusOK || string(out) != "id|name\n1|a\n2|b\n" {
+		t.Errorf("to=csv = %d %q", resp.StatusCode, out)
+	}
+	if resp.Trailer.Get("X-Rows-Read") != "2" {
+		t.Errorf("to=csv trailers = %v", resp.Trailer)
+	}
+
+	resp, pq = post(t, srv.URL+"/convert?to=parquet&bad-rows", strings.NewReader("id,name\n1,a\nx,b\n"))
+	if resp.StatusCode != http.StatusOK || !bytes.HasPrefix(pq, []byte("PAR1")) {
+		t.Fatalf("to=parquet&bad-rows = %d %q", resp.StatusCode, pq)
+	}
+	if resp.Trailer.Get("X-Rows-Written") != "1" || resp.Trailer.Get("X-Rows-Rejected") != "1" {
+		t.Errorf("to=parquet&bad-rows trailers = %v", resp.Trailer)
+	}
+	if spooled, _ := os.ReadDir(dir); len(spooled) != 0 {
+		t.Errorf("temp files left = %v", spooled)
+	}
+}
+
+func TestConvertErrors(t *testing.T) {
+	srv := newTestServer(t, Config{MaxBody: 1024})
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{name: "method", method: http.MethodGet, path: "/convert?to=csv", status: http.StatusMethodNotAllowed},
+		{name: "format", method: http.MethodPost, path: "/convert?to=json", status: http.StatusBadRequest},
+		{name: "option", method: http.MethodPost, path: "/convert?to=csv&flatten=1", status: http.StatusBadRequest},
+		{name: "not parquet", method: http.MethodPost, path: "/convert?to=csv", body: "id\n1\n", status: http.StatusUnprocessableEntity},
+		{name: "bad row", method: http.MethodPost, path: "/convert?to=parquet", body: "a,b\n1,2,3\n", status: http.StatusUnprocessableEntity},
+		{name: "too large", method: http.MethodPost, path: "/convert?to=parquet", body: strings.Repeat("a\n", 1024), status: http.StatusRequestEntityTooLarge},
+		{name: "too large parquet", method: http.MethodPost, path: "/convert?to=csv", body: strings.Repeat("PAR1", 512), status: http.StatusRequestEntityTooLarge},
+		{name: "health", method: http.MethodGet, path: "/healthz", status: http.StatusOK},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequestWithContext(context.Background(), tt.method, srv.URL+tt.path, strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s: error = %v", tt.name, err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: status = %d; want %d", tt.name, resp.StatusCode, tt.status)
+		}
+	}
+
+	// without a content length the limit stops the conversion as it reads
+	resp, _ := post(t, srv.URL+"/convert?to=parquet", io.MultiReader(strings.NewReader(strings.Repeat("a\n", 1024))))
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("chunked body over the limit status = %d; want 413", resp.StatusCode)
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	srv := newTestServer(t, Config{Concurrency: 1})
+	pr, pw := io.Pipe()
+	done := make(chan int)
+	go func() {
+		resp, _ := post(t, srv.URL+"/convert?to=parquet", pr)
+		done <- resp.StatusCode
+	}()
+	_, _ = pw.Write([]byte("id\n"))
+
+	// the first conversion waits for the rest of its body
+	var status int
+	for range 50 {
+		resp, _ := post(t, srv.URL+"/convert?to=parquet", strings.NewReader("id\n1\n"))
+		if status = resp.StatusCode; status == http.StatusServiceUnavailable {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if status != http.StatusServiceUnavailable {
+		t.Errorf("second conversion status = %d; want 503", status)
+	}
+	_, _ = pw.Write([]byte("1\n"))
+	_ = pw.Close()
+	if status = <-done; status != http.StatusOK {
+		t.Errorf("first conversion status = %d; want 200", status)
+	}
+}
+
+func TestServeShutdown(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error)
+	go func() {
+		served <- New(Config{Options: testOptions}).Serve(ctx, listener, time.Second)
+	}()
+
+	url := "http://" + listener.Addr().String() + "/convert?to=parquet"
+	pr, pw := io.Pipe()
+	done := make(chan int)
+	go func() {
+		resp, _ := post(t, url, pr)
+		done <- resp.StatusCode
+	}()
+	_, _ = pw.Write([]byte("id\n"))
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	// the running conversion finishes, new connections are refused
+	time.Sleep(50 * time.Millisecond)
+	_, _ = pw.Write([]byte("1\n"))
+	_ = pw.Close()
+	if status := <-done; status != http.StatusOK {
+		t.Errorf("conversion during shutdown status = %d; want 200", status)
+	}
+	if err = <-served; err != nil {
+		t.Errorf("Serve() error = %v", err)
+	}
+	if _, err = http.Get(url); err == nil { //nolint:noctx // test request
+		t.Error("request after shutdown succeeded")
+	}
+}
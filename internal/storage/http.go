@@ -0,0 +1,264 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/xitongsys/parquet-go/source"
+)
+
+const (
+	// HTTPTimeout is the default wait for the response to a request.
+	HTTPTimeout = 30 * time.Second
+	// HTTPRetries is the default number of retries of a failed request.
+	HTTPRetries = 3
+
+	retryDelay    = 200 * time.Millisecond
+	maxRetryDelay = 5 * time.Second
+)
+
+// IsHTTP tells if path is an http:// or https:// url.
+func IsHTTP(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// HTTPOptions are the request options of OpenHTTP.
+type HTTPOptions struct {
+	// Header is sent with every request, e.g. Authorization.
+	Header http.Header
+	// Timeout limits the wait for the response headers of every request, 0 waits forever.
+	Timeout time.Duration
+	// Retries of requests that fail to connect or get a 429 or 5xx response, a dropped
+	// connection resumes from where it stopped.
+	Retries int
+}
+
+// ParseHeaders reads "Name: value" headers.
+func ParseHeaders(headers []string) (http.Header, error) {
+	h := make(http.Header, len(headers))
+	for _, header := range headers {
+		name, value, ok := strings.Cut(header, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, errors.Errorf("invalid header %q, expected \"Name: value\"", header)
+		}
+		h.Add(name, strings.TrimSpace(value))
+	}
+	return h, nil
+}
+
+// StatusError is a response to a request with an unexpected status.
+type StatusError struct {
+	URL  string
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return "error get " + e.URL + ": " + strconv.Itoa(e.Code) + " " + http.StatusText(e.Code)
+}
+
+// HTTPFile reads a url from the current offset: sequential reads stream one response,
+// a Seek starts a range request on the next Read, so parquet only fetches the footer
+// and the column chunks it needs. It is a read-only source.ParquetFile.
+type HTTPFile struct {
+	ctx    context.Context
+	client *http.Client
+	url    string
+	opts   HTTPOptions
+	// size is -1 while unknown, ranges tells if the server answers range requests
+	size   int64
+	ranges bool
+	offset int64
+	body   io.ReadCloser
+}
+
+// OpenHTTP starts reading url and returns its size, 0 if the server doesn't tell it.
+func OpenHTTP(ctx context.Context, url string, opts HTTPOptions) (*HTTPFile, int64, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone() //nolint:forcetypeassert // the default transport
+	transport.ResponseHeaderTimeout = opts.Timeout
+	if opts.Timeout > 0 {
+		transport.DialContext = (&net.Dialer{Timeout: opts.Timeout}).DialContext
+		transport.TLSHandshakeTimeout = opts.Timeout
+	}
+	f := &HTTPFile{ctx: ctx, client: &http.Client{Transport: transport}, url: url, opts: opts, size: -1}
+	if err := f.open(); err != nil {
+		return nil, 0, err
+	}
+	return f, max(f.size, 0), nil
+}
+
+func (f *HTTPFile) Read(p []byte) (int, error) {
+	if f.size >= 0 && f.offset >= f.size {
+		return 0, io.EOF
+	}
+	for attempt := 0; ; attempt++ {
+		if f.body == nil {
+			if err := f.open(); err != nil {
+				return 0, err
+			}
+		}
+		n, err := f.body.Read(p)
+		f.offset += int64(n)
+		if err == nil {
+			return n, nil
+		}
+		f.closeBody()
+		if errors.Is(err, io.EOF) && (f.size < 0 || f.offset >= f.size) {
+			return n, io.EOF
+		}
+		// the response ended early, the next read resumes at the offset
+		if n > 0 {
+			return n, nil
+		}
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		if attempt >= f.opts.Retries || !f.ranges || f.ctx.Err() != nil {
+			return 0, errors.Wrap(err, "error read "+f.url)
+		}
+		if err = f.wait(attempt); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// Seek sets the offset of the next Read, SeekEnd needs a server that tells the size.
+func (f *HTTPFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		if f.size < 0 {
+			return 0, errors.New("error seek " + f.url + ": size unknown")
+		}
+		offset += f.size
+	default:
+		return 0, errors.New("error seek " + f.url + ": invalid whence")
+	}
+	if offset < 0 || f.size >= 0 && offset > f.size {
+		return 0, errors.New("error seek " + f.url + ": invalid offset " + strconv.FormatInt(offset, 10))
+	}
+	if offset != f.offset {
+		f.closeBody()
+		f.offset = offset
+	}
+	return offset, nil
+}
+
+// Open returns another reader of the same url for concurrent reads.
+func (f *HTTPFile) Open(string) (source.ParquetFile, error) {
+	return &HTTPFile{ctx: f.ctx, client: f.client, url: f.url, opts: f.opts, size: f.size, ranges: f.ranges}, nil
+}
+
+func (f *HTTPFile) Close() error {
+	f.closeBody()
+	return nil
+}
+
+func (f *HTTPFile) Write([]byte) (int, error) {
+	return 0, errors.New("error write " + f.url + ": read only")
+}
+
+func (f *HTTPFile) Create(string) (source.ParquetFile, error) {
+	return nil, errors.New("error create " + f.url + ": read only")
+}
+
+// open starts the response to read from the offset on.
+func (f *HTTPFile) open() error {
+	resp, err := f.get()
+	if err != nil {
+		return err
+	}
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		f.ranges = true
+		if _, total, ok := strings.Cut(resp.Header.Get("Content-Range"), "/"); ok {
+			if size, err := strconv.ParseInt(total, 10, 64); err == nil {
+				f.size = size
+			}
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		// an empty file has no first byte
+		_ = resp.Body.Close()
+		if f.offset > 0 {
+			return &StatusError{URL: f.url, Code: resp.StatusCode}
+		}
+		f.size = 0
+		f.body = http.NoBody
+		return nil
+	default:
+		if f.offset > 0 {
+			_ = resp.Body.Close()
+			return errors.New("error get " + f.url + ": the server doesn't support range requests")
+		}
+		f.size = resp.ContentLength
+	}
+	f.body = resp.Body
+	return nil
+}
+
+// get requests the url from the offset on, retrying failed requests.
+func (f *HTTPFile) get() (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := f.request()
+		if err == nil {
+			return resp, nil
+		}
+		var status *StatusError
+		retry := !errors.As(err, &status) || status.Code == http.StatusTooManyRequests ||
+			status.Code >= http.StatusInternalServerError
+		if !retry || attempt >= f.opts.Retries || f.ctx.Err() != nil {
+			return nil, err
+		}
+		if err = f.wait(attempt); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (f *HTTPFile) request() (*http.Response, error) {
+	req, err := http.NewRequestWithContext(f.ctx, http.MethodGet, f.url, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "error request "+f.url)
+	}
+	for name, values := range f.opts.Header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Range", "bytes="+strconv.FormatInt(f.offset, 10)+"-")
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "error get "+f.url)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusPartialContent, http.StatusRequestedRangeNotSatisfiable:
+		return resp, nil
+	}
+	_ = resp.Body.Close()
+	return nil, &StatusError{URL: f.url, Code: resp.StatusCode}
+}
+
+// wait backs off before the next attempt.
+func (f *HTTPFile) wait(attempt int) error {
+	t := time.NewTimer(min(retryDelay<<attempt, maxRetryDelay))
+	defer t.Stop()
+	select {
+	case <-f.ctx.Done():
+		return errors.Wrap(f.ctx.Err(), "error get "+f.url)
+	case <-t.C:
+		return nil
+	}
+}
+
+func (f *HTTPFile) closeBody() {
+	if f.body != nil {
+		_ = f.body.Close()
+		f.body = nil
+	}
+}
@@ -0,0 +1,195 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dbunt1tled/parquet2csv/pkg/convert"
+)
+
+// fakeHTTP serves files with range requests, failing the first requests with fail
+// and cutting the first response short with truncate.
+type fakeHTTP struct {
+	mu       sync.Mutex
+	files    map[string][]byte
+	ranges   []string
+	headers  []string
+	requests int
+	fail     []int
+	truncate bool
+	noRanges bool
+}
+
+func newFakeHTTP(t *testing.T) (*fakeHTTP, string) {
+	t.Helper()
+	f := &fakeHTTP{files: map[string][]byte{}}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, srv.URL
+}
+
+func (f *fakeHTTP) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.requests++
+	f.ranges = append(f.ranges, r.Header.Get("Range"))
+	f.headers = append(f.headers, r.Header.Get("Authorization"))
+	data, ok := f.files[r.URL.Path]
+	var code int
+	if len(f.fail) > 0 {
+		code, f.fail = f.fail[0], f.fail[1:]
+	}
+	truncate := f.truncate
+	f.truncate = false
+	f.mu.Unlock()
+
+	switch {
+	case code != 0:
+		w.WriteHeader(code)
+	case !ok:
+		http.NotFound(w, r)
+	case truncate:
+		w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes 0-%d/%d", len(data)-1, len(data)))
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = w.Write(data[:len(data)/2])
+	case f.noRanges:
+		w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+		_, _ = w.Write(data)
+	default:
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	}
+}
+
+func TestParseHeaders(t *testing.T) {
+	tests := []struct {
+		headers []string
+		want    http.Header
+		wantErr bool
+	}{
+		{headers: nil, want: http.Header{}},
+		{
+			headers: []string{"Authorization: Bearer a:b", "x-trace:1", "X-Trace: 2"},
+			want:    http.Header{"Authorization": {"Bearer a:b"}, "X-Trace": {"1", "2"}},
+		},
+		{headers: []string{"Authorization"}, wantErr: true},
+		{headers: []string{": value"}, wantErr: true},
+		{headers: []string{"Bad Name: value"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseHeaders(tt.headers)
+		if (err != nil) != tt.wantErr || !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseHeaders(%q) = %v, %v; want %v, error %t", tt.headers, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestHTTPRoundTrip(t *testing.T) {
+	fake, url := newFakeHTTP(t)
+	ctx := context.Background()
+	opts := HTTPOptions{Header: http.Header{"Authorization": {"Bearer token"}}, Timeout: time.Second}
+
+	var in strings.Builder
+	in.WriteString("id,name\n")
+	for i := range 50000 {
+		fmt.Fprintf(&in, "%d,name %d\n", i, i)
+	}
+	fake.files["/in.csv"] = []byte(in.String())
+
+	r, size, err := OpenHTTP(ctx, url+"/in.csv", opts)
+	if err != nil {
+		t.Fatalf("OpenHTTP() error = %v", err)
+	}
+	if size != int64(in.Len()) {
+		t.Errorf("OpenHTTP() size = %d; want %d", size, in.Len())
+	}
+	var pq bytes.Buffer
+	if _, err = convert.CSVToParquet(ctx, r, &pq, convert.Options{}); err != nil {
+		t.Fatalf("CSVToParquet() error = %v", err)
+	}
+	if fake.requests != 1 {
+		t.Errorf("csv requests = %d; want it streamed in 1", fake.requests)
+	}
+	if fake.headers[0] != "Bearer token" {
+		t.Errorf("Authorization header = %q", fake.headers[0])
+	}
+
+	fake.files["/out.parquet"] = pq.Bytes()
+	fake.ranges = nil
+	r, _, err = OpenHTTP(ctx, url+"/out.parquet", opts)
+	if err != nil {
+		t.Fatalf("OpenHTTP() error = %v", err)
+	}
+	var out bytes.Buffer
+	if _, err = convert.ParquetToCSV(ctx, r, &out, convert.Options{}); err != nil {
+		t.Fatalf("ParquetToCSV() error = %v", err)
+	}
+	if out.String() != in.String() {
+		t.Errorf("round trip differs, %d bytes; want %d", out.Len(), in.Len())
+	}
+	if len(fake.ranges) < 3 || fake.ranges[1] == "bytes=0-" {
+		t.Errorf("parquet reads = %v; want range requests", fake.ranges)
+	}
+}
+
+func TestHTTPErrors(t *testing.T) {
+	fake, url := newFakeHTTP(t)
+	ctx := context.Background()
+	data := []byte(strings.Repeat("0123456789", 100000))
+	fake.files["/data.csv"] = data
+
+	read := func(opts HTTPOptions) ([]byte, error) {
+		r, _, err := OpenHTTP(ctx, url+"/data.csv", opts)
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(r)
+	}
+
+	fake.requests = 0
+	if _, _, err := OpenHTTP(ctx, url+"/missing.csv", HTTPOptions{Retries: 2}); err == nil ||
+		!strings.Contains(err.Error(), "404") || fake.requests != 1 {
+		t.Errorf("OpenHTTP() of a missing url error = %v after %d requests; want a 404 without retries", err, fake.requests)
+	}
+
+	fake.fail = []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}
+	if got, err := read(HTTPOptions{Retries: 2}); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("read after 2 failures = %d bytes, %v; want it retried", len(got), err)
+	}
+	fake.fail = []int{http.StatusServiceUnavailable}
+	if _, err := read(HTTPOptions{}); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("read without retries error = %v; want 503", err)
+	}
+
+	fake.truncate = true
+	fake.ranges = nil
+	if got, err := read(HTTPOptions{Retries: 1}); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("read of a dropped response = %d bytes, %v; want it resumed", len(got), err)
+	}
+	if len(fake.ranges) != 2 || fake.ranges[1] != fmt.Sprintf("bytes=%d-", len(data)/2) {
+		t.Errorf("ranges = %v; want a resume from the middle", fake.ranges)
+	}
+
+	fake.noRanges = true
+	if got, err := read(HTTPOptions{}); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("read without range support = %d bytes, %v", len(got), err)
+	}
+	r, _, err := OpenHTTP(ctx, url+"/data.csv", HTTPOptions{})
+	if err != nil {
+		t.Fatalf("OpenHTTP() error = %v", err)
+	}
+	if _, err = r.Seek(-8, io.SeekEnd); err != nil {
+		t.Fatalf("Seek() error = %v", err)
+	}
+	if _, err = r.Read(make([]byte, 8)); err == nil || !strings.Contains(err.Error(), "range requests") {
+		t.Errorf("Read() after Seek without range support error = %v", err)
+	}
+}
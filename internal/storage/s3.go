@@ -0,0 +1,112 @@
+// Package storage opens inputs and creates outputs on S3 compatible object storage and reads http(s) urls.
+package storage
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/pkg/errors"
+	"github.com/xitongsys/parquet-go-source/s3v2"
+	"github.com/xitongsys/parquet-go/source"
+)
+
+const s3Scheme = "s3://"
+
+// IsS3 tells if path is an s3://bucket/key url.
+func IsS3(path string) bool {
+	return strings.HasPrefix(path, s3Scheme)
+}
+
+// ParseS3 splits an s3://bucket/key url.
+func ParseS3(url string) (string, string, error) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(url, s3Scheme), "/")
+	if !IsS3(url) || bucket == "" || key == "" || strings.HasSuffix(key, "/") {
+		return "", "", errors.New("invalid s3 url " + url + ", expected s3://bucket/key")
+	}
+	return bucket, key, nil
+}
+
+// NewS3Client is a client configured like the aws cli: credentials from AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN or AWS_PROFILE, the region from AWS_REGION.
+// AWS_ENDPOINT_URL_S3 or AWS_ENDPOINT_URL point it at MinIO or another S3 compatible store,
+// which is then addressed path-style and without the optional integrity checksums.
+func NewS3Client(ctx context.Context) (*s3.Client, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "error load aws config")
+	}
+	return s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if o.BaseEndpoint != nil {
+			o.UsePathStyle = true
+			o.RequestChecksumCalculation = aws.RequestChecksumCalculationWhenRequired
+			o.ResponseChecksumValidation = aws.ResponseChecksumValidationWhenRequired
+		}
+	}), nil
+}
+
+// OpenS3 opens an object for reading and returns its size. Reads are ranged GETs from the
+// current offset, so parquet only fetches the footer and the column chunks it needs.
+func OpenS3(ctx context.Context, client s3v2.S3API, url string) (source.ParquetFile, int64, error) {
+	bucket, key, err := ParseS3(url)
+	if err != nil {
+		return nil, 0, err
+	}
+	head, err := client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "error open "+url)
+	}
+	f, err := s3v2.NewS3FileReaderWithClient(ctx, client, bucket, key)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "error open "+url)
+	}
+	return f, aws.ToInt64(head.ContentLength), nil
+}
+
+// S3Writer uploads what is written to an object, in parts once it outgrows one.
+// Close completes the upload, Abort drops it.
+type S3Writer struct {
+	f      source.ParquetFile
+	cancel context.CancelFunc
+	once   sync.Once
+	err    error
+}
+
+// CreateS3 starts an upload to url.
+func CreateS3(ctx context.Context, client s3v2.S3API, url string) (*S3Writer, error) {
+	bucket, key, err := ParseS3(url)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	f, err := s3v2.NewS3FileWriterWithClient(ctx, client, bucket, key, nil)
+	if err != nil {
+		cancel()
+		return nil, errors.Wrap(err, "error create "+url)
+	}
+	return &S3Writer{f: f, cancel: cancel}, nil
+}
+
+func (w *S3Writer) Write(p []byte) (int, error) {
+	return w.f.Write(p)
+}
+
+// Close waits for the upload to complete.
+func (w *S3Writer) Close() error {
+	w.once.Do(func() {
+		w.err = w.f.Close()
+		w.cancel()
+	})
+	return w.err
+}
+
+// Abort stops the upload, the object is left as it was. It does nothing after Close.
+func (w *S3Writer) Abort() {
+	w.once.Do(func() {
+		w.cancel()
+		w.err = w.f.Close()
+	})
+}
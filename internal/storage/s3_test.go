@@ -0,0 +1,227 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dbunt1tled/parquet2csv/pkg/convert"
+)
+
+// fakeS3 is an in-memory S3 server with path-style addressing: objects, ranged GETs and multipart uploads.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	uploads map[string]map[int][]byte
+	ranges  []string
+	parts   int
+}
+
+func newFakeS3(t *testing.T) *fakeS3 {
+	t.Helper()
+	f := &fakeS3{objects: map[string][]byte{}, uploads: map[string]map[int][]byte{}}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	t.Setenv("AWS_ENDPOINT_URL", srv.URL)
+	t.Setenv("AWS_ENDPOINT_URL_S3", "")
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+	t.Setenv("AWS_CONFIG_FILE", "/nonexistent")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", "/nonexistent")
+	return f
+}
+
+// ServeHTTP writes the response outside of the lock, the client reads objects lazily.
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	rec := httptest.NewRecorder()
+	f.mu.Lock()
+	f.handle(rec, r, body)
+	f.mu.Unlock()
+	for k, v := range rec.Header() {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(rec.Code)
+	_, _ = w.Write(rec.Body.Bytes())
+}
+
+func (f *fakeS3) handle(w http.ResponseWriter, r *http.Request, body []byte) {
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	query := r.URL.Query()
+
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		id := strconv.Itoa(len(f.uploads) + 1)
+		f.uploads[id] = map[int][]byte{}
+		bucket, object, _ := strings.Cut(key, "/")
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><Bucket>%s</Bucket><Key>%s</Key><UploadId>%s</UploadId>"+
+			"</InitiateMultipartUploadResult>", bucket, object, id)
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		n, _ := strconv.Atoi(query.Get("partNumber"))
+		f.uploads[query.Get("uploadId")][n] = body
+		f.parts++
+		w.Header().Set("ETag", strconv.Quote(strconv.Itoa(n)))
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		parts := f.uploads[query.Get("uploadId")]
+		numbers := make([]int, 0, len(parts))
+		for n := range parts {
+			numbers = append(numbers, n)
+		}
+		sort.Ints(numbers)
+		var data []byte
+		for _, n := range numbers {
+			data = append(data, parts[n]...)
+		}
+		f.objects[key] = data
+		fmt.Fprintf(w, "<CompleteMultipartUploadResult><Key>%s</Key></CompleteMultipartUploadResult>", key)
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		delete(f.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut:
+		f.objects[key] = body
+	case r.Method == http.MethodHead || r.Method == http.MethodGet:
+		data, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			if r.Method == http.MethodGet {
+				fmt.Fprint(w, "<Error><Code>NoSuchKey</Code></Error>")
+			}
+			return
+		}
+		status := http.StatusOK
+		if rng := r.Header.Get("Range"); rng != "" {
+			f.ranges = append(f.ranges, rng)
+			var start, end int
+			if _, err := fmt.Sscanf(rng, "bytes=%d-%d", &start, &end); err != nil || start >= len(data) {
+				w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+				return
+			}
+			end = min(end, len(data)-1)
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)))
+			data = data[start : end+1]
+			status = http.StatusPartialContent
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.WriteHeader(status)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(data)
+		}
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestParseS3(t *testing.T) {
+	tests := []struct {
+		url, bucket, key string
+		wantErr          bool
+	}{
+		{url: "s3://data/in/a.csv", bucket: "data", key: "in/a.csv"},
+		{url: "s3://data/a.parquet", bucket: "data", key: "a.parquet"},
+		{url: "s3://data", wantErr: true},
+		{url: "s3://data/dir/", wantErr: true},
+		{url: "s3:///a.csv", wantErr: true},
+		{url: "data/a.csv", wantErr: true},
+	}
+	for _, tt := range tests {
+		bucket, key, err := ParseS3(tt.url)
+		if (err != nil) != tt.wantErr || bucket != tt.bucket || key != tt.key {
+			t.Errorf("ParseS3(%q) = %q, %q, %v; want %q, %q, error %t", tt.url, bucket, key, err, tt.bucket, tt.key, tt.wantErr)
+		}
+	}
+}
+
+func TestS3RoundTrip(t *testing.T) {
+	fake := newFakeS3(t)
+	ctx := context.Background()
+	client, err := NewS3Client(ctx)
+	if err != nil {
+		t.Fatalf("NewS3Client() error = %v", err)
+	}
+
+	// more than the 5 MB of one upload part
+	var in strings.Builder
+	in.WriteString("id,name\n")
+	for i := range 250000 {
+		fmt.Fprintf(&in, "%d,name %d with some padding\n", i, i)
+	}
+	fake.objects["data/in.csv"] = []byte(in.String())
+
+	r, size, err := OpenS3(ctx, client, "s3://data/in.csv")
+	if err != nil {
+		t.Fatalf("OpenS3() error = %v", err)
+	}
+	if size != int64(in.Len()) {
+		t.Errorf("OpenS3() size = %d; want %d", size, in.Len())
+	}
+	w, err := CreateS3(ctx, client, "s3://data/out.parquet")
+	if err != nil {
+		t.Fatalf("CreateS3() error = %v", err)
+	}
+	if _, err = convert.CSVToParquet(ctx, r, w, convert.Options{}); err != nil {
+		t.Fatalf("CSVToParquet() error = %v", err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if fake.parts < 2 {
+		t.Errorf("upload parts = %d; want a multipart upload", fake.parts)
+	}
+
+	fake.ranges = nil
+	r, _, err = OpenS3(ctx, client, "s3://data/out.parquet")
+	if err != nil {
+		t.Fatalf("OpenS3() error = %v", err)
+	}
+	w, err = CreateS3(ctx, client, "s3://data/out.csv")
+	if err != nil {
+		t.Fatalf("CreateS3() error = %v", err)
+	}
+	if _, err = convert.ParquetToCSV(ctx, r, w, convert.Options{}); err != nil {
+		t.Fatalf("ParquetToCSV() error = %v", err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if !bytes.Equal(fake.objects["data/out.csv"], []byte(in.String())) {
+		t.Errorf("round trip differs, %d bytes; want %d", len(fake.objects["data/out.csv"]), in.Len())
+	}
+	if len(fake.ranges) < 2 || fake.ranges[0] == "bytes=0-" {
+		t.Errorf("parquet reads = %v; want ranged GETs", fake.ranges)
+	}
+}
+
+func TestS3Errors(t *testing.T) {
+	fake := newFakeS3(t)
+	ctx := context.Background()
+	client, err := NewS3Client(ctx)
+	if err != nil {
+		t.Fatalf("NewS3Client() error = %v", err)
+	}
+	if _, _, err = OpenS3(ctx, client, "s3://data/missing.csv"); err == nil {
+		t.Error("OpenS3() of a missing object error = nil")
+	}
+
+	w, err := CreateS3(ctx, client, "s3://data/aborted.csv")
+	if err != nil {
+		t.Fatalf("CreateS3() error = %v", err)
+	}
+	if _, err = w.Write(bytes.Repeat([]byte("x"), 6<<20)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	w.Abort()
+	_ = w.Close()
+	if _, ok := fake.objects["data/aborted.csv"]; ok {
+		t.Error("aborted upload created the object")
+	}
+}
@@ -0,0 +1,395 @@
+// Package validate checks csv rows against a schema of column types and constraints.
+//
+//	columns:
+//	  - name: id
+//	    type: int
+//	    required: true
+//	    unique: true
+//	  - name: status
+//	    values: [new, paid, shipped]
+//	  - name: amount
+//	    type: decimal(9,2)
+//	    min: 0
+//	  - name: code
+//	    pattern: '^[A-Z]{3}$'
+package validate
+
+import (
+	"bytes"
+	"fmt"
+	"hash/maphash"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/BurntSushi/toml"
+	"github.com/dbunt1tled/parquet2csv/internal/schema"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Rules of a Violation.
+const (
+	RuleHeader    = "header"
+	RuleFields    = "fields"
+	RuleType      = "type"
+	RuleRequired  = "required"
+	RuleUnique    = "unique"
+	RuleValues    = "values"
+	RuleMin       = "min"
+	RuleMax       = "max"
+	RulePattern   = "pattern"
+	RuleMinLength = "min_length"
+	RuleMaxLength = "max_length"
+)
+
+// MaxViolations is the default number of violations kept per column and rule.
+const MaxViolations = 100
+
+// Column is the type and constraints of a csv column. The constraints but Required apply to
+// non-empty values, an empty value is null.
+type Column struct {
+	Name string `yaml:"name" toml:"name"`
+	// Type is string, int, double, boolean, date or decimal(p,s), string when empty.
+	Type     string `yaml:"type"     toml:"type"`
+	Required bool   `yaml:"required" toml:"required"`
+	Unique   bool   `yaml:"unique"   toml:"unique"`
+	// Values are the allowed values.
+	Values []string `yaml:"values" toml:"values"`
+	// Min and Max bound int, double, decimal and date values, given in the column type.
+	Min any `yaml:"min" toml:"min"`
+	Max any `yaml:"max" toml:"max"`
+	// Pattern is a regular expression values must match, anchor it with ^ and $ to match whole values.
+	Pattern string `yaml:"pattern" toml:"pattern"`
+	// MinLength and MaxLength bound the length of values in characters.
+	MinLength *int `yaml:"min_length" toml:"min_length"`
+	MaxLength *int `yaml:"max_length" toml:"max_length"`
+}
+
+// Schema is the columns a csv file must have.
+type Schema struct {
+	Columns []Column `yaml:"columns" toml:"columns"`
+	// Strict makes header columns that aren't in the schema violations.
+	Strict bool `yaml:"strict" toml:"strict"`
+}
+
+// Load reads a schema file, yaml for .yaml, .yml and .json, toml for .toml.
+func Load(path string) (*Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "error read schema file")
+	}
+	var s Schema
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".json":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(&s)
+	case ".toml":
+		var md toml.MetaData
+		if md, err = toml.Decode(string(data), &s); err == nil {
+			if undecoded := md.Undecoded(); len(undecoded) > 0 {
+				err = errors.New("unknown key " + undecoded[0].String())
+			}
+		}
+	default:
+		err = errors.New("schema file must be .yaml, .yml, .json or .toml")
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "schema file "+path)
+	}
+	return &s, nil
+}
+
+// Violation is a value, row or header breaking a rule.
+type Violation struct {
+	Line int
+	// Column is empty for a row with another number of fields than the header.
+	Column  string
+	Rule    string
+	Value   string
+	Message string
+}
+
+func (v Violation) String() string {
+	if v.Column == "" {
+		return fmt.Sprintf("line %d: %s: %s", v.Line, v.Rule, v.Message)
+	}
+	return fmt.Sprintf("line %d, column %s: %s: %s", v.Line, v.Column, v.Rule, v.Message)
+}
+
+// RuleCount is the number of violations of a rule by a column.
+type RuleCount struct {
+	Column string
+	Rule   string
+	Count  int
+}
+
+// Result is the outcome of a validation.
+type Result struct {
+	Rows int
+	// Violations are the ones kept, in input order.
+	Violations []Violation
+	// Counts are all violations by column and rule, in the order first seen.
+	Counts []RuleCount
+}
+
+// Total is the number of violations.
+func (r Result) Total() int {
+	total := 0
+	for _, c := range r.Counts {
+		total += c.Count
+	}
+	return total
+}
+
+type column struct {
+	Column
+	index    int
+	typ      schema.TextType
+	min, max any
+	// minText and maxText are the bounds as given, for messages
+	minText, maxText string
+	values           map[string]bool
+	pattern          *regexp.Regexp
+	// seen keeps the line of each unique value by a 64-bit hash, not the value, to scale to huge files
+	seen map[uint64]int
+}
+
+// Validator checks the header and rows of a csv file, rows are checked as they are read
+// so only the hashes of unique columns are kept.
+type Validator struct {
+	columns []*column
+	strict  bool
+	header  int
+	limit   int
+	seed    maphash.Seed
+	rows    int
+	kept    map[RuleCount]int
+	result  Result
+}
+
+// New compiles the schema. At most limit violations are kept per column and rule, all without a limit.
+func New(s *Schema, limit int) (*Validator, error) {
+	if len(s.Columns) == 0 {
+		return nil, errors.New("schema has no columns")
+	}
+	v := &Validator{strict: s.Strict, limit: limit, seed: maphash.MakeSeed(), kept: map[RuleCount]int{}}
+	names := make(map[string]bool, len(s.Columns))
+	for _, c := range s.Columns {
+		if c.Name == "" {
+			return nil, errors.New("schema column without name")
+		}
+		if names[c.Name] {
+			return nil, errors.New("schema column " + c.Name + " is defined twice")
+		}
+		names[c.Name] = true
+		col, err := compile(c)
+		if err != nil {
+			return nil, errors.Wrap(err, "schema column "+c.Name)
+		}
+		v.columns = append(v.columns, col)
+	}
+	return v, nil
+}
+
+func compile(c Column) (*column, error) {
+	col := &column{Column: c, index: -1}
+	var err error
+	if col.typ, err = schema.ParseTextType(c.Type); err != nil {
+		return nil, err
+	}
+	for _, bound := range []struct {
+		value any
+		to    *any
+		text  *string
+	}{{c.Min, &col.min, &col.minText}, {c.Max, &col.max, &col.maxText}} {
+		if bound.value == nil {
+			continue
+		}
+		if col.typ.Name == "string" || col.typ.Name == "boolean" {
+			return nil, errors.New("min and max need an int, double, decimal or date type")
+		}
+		*bound.text = boundText(bound.value)
+		if *bound.to, err = col.typ.Parse(*bound.text); err != nil {
+			return nil, errors.Wrap(err, "bound")
+		}
+	}
+	if col.min != nil && col.max != nil && compare(col.min, col.max) > 0 {
+		return nil, errors.New("min is greater than max")
+	}
+	if len(c.Values) > 0 {
+		col.values = make(map[string]bool, len(c.Values))
+		for _, value := range c.Values {
+			col.values[value] = true
+		}
+	}
+	if c.Pattern != "" {
+		if col.pattern, err = regexp.Compile(c.Pattern); err != nil {
+			return nil, errors.Wrap(err, "pattern")
+		}
+	}
+	if (c.MinLength != nil && *c.MinLength < 0) || (c.MaxLength != nil && *c.MaxLength < 0) {
+		return nil, errors.New("min_length and max_length can't be negative")
+	}
+	if c.Unique {
+		col.seen = map[uint64]int{}
+	}
+	return col, nil
+}
+
+// Header maps the schema columns to the header read at line, columns missing from it are violations.
+func (v *Validator) Header(line int, header []string) {
+	v.header = len(header)
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		if _, ok := index[name]; !ok {
+			index[name] = i
+		}
+	}
+	known := make(map[string]bool, len(v.columns))
+	for _, c := range v.columns {
+		known[c.Name] = true
+		if i, ok := index[c.Name]; ok {
+			c.index = i
+		} else {
+			v.add(Violation{Line: line, Column: c.Name, Rule: RuleHeader, Message: "column is missing"})
+		}
+	}
+	if v.strict {
+		for _, name := range header {
+			if !known[name] {
+				v.add(Violation{Line: line, Column: name, Rule: RuleHeader, Message: "column is not in the schema"})
+			}
+		}
+	}
+}
+
+// Row checks the record read at line.
+func (v *Validator) Row(line int, record []string) {
+	v.rows++
+	if len(record) != v.header {
+		v.add(Violation{
+			Line: line, Rule: RuleFields,
+			Message: fmt.Sprintf("%d fields, the header has %d", len(record), v.header),
+		})
+	}
+	for _, c := range v.columns {
+		if c.index < 0 {
+			continue
+		}
+		value := ""
+		if c.index < len(record) {
+			value = record[c.index]
+		}
+		v.check(line, c, value)
+	}
+}
+
+func (v *Validator) check(line int, c *column, value string) {
+	fail := func(rule, message string) {
+		v.add(Violation{Line: line, Column: c.Name, Rule: rule, Value: value, Message: message})
+	}
+	if value == "" {
+		if c.Required {
+			fail(RuleRequired, "value is empty")
+		}
+		return
+	}
+	parsed, err := c.typ.Parse(value)
+	if err != nil {
+		fail(RuleType, err.Error())
+	} else {
+		if c.min != nil && compare(parsed, c.min) < 0 {
+			fail(RuleMin, value+" is less than "+c.minText)
+		}
+		if c.max != nil && compare(parsed, c.max) > 0 {
+			fail(RuleMax, value+" is greater than "+c.maxText)
+		}
+	}
+	if c.values != nil && !c.values[value] {
+		fail(RuleValues, strconv.Quote(value)+" is not one of "+strings.Join(c.Values, ", "))
+	}
+	if c.pattern != nil && !c.pattern.MatchString(value) {
+		fail(RulePattern, strconv.Quote(value)+" doesn't match "+c.Pattern)
+	}
+	if c.MinLength != nil || c.MaxLength != nil {
+		n := utf8.RuneCountInString(value)
+		if c.MinLength != nil && n < *c.MinLength {
+			fail(RuleMinLength, fmt.Sprintf("%d characters, less than %d", n, *c.MinLength))
+		}
+		if c.MaxLength != nil && n > *c.MaxLength {
+			fail(RuleMaxLength, fmt.Sprintf("%d characters, more than %d", n, *c.MaxLength))
+		}
+	}
+	if c.seen != nil {
+		h := maphash.String(v.seed, value)
+		if first, ok := c.seen[h]; ok {
+			fail(RuleUnique, fmt.Sprintf("%s is already at line %d", strconv.Quote(value), first))
+		} else {
+			c.seen[h] = line
+		}
+	}
+}
+
+// boundText is a min or max of a schema file as text for schema.TextType.Parse.
+func boundText(value any) string {
+	switch v := value.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return v.Format(time.DateOnly)
+	}
+	return fmt.Sprint(value)
+}
+
+func (v *Validator) add(violation Violation) {
+	key := RuleCount{Column: violation.Column, Rule: violation.Rule}
+	n, ok := v.kept[key]
+	if !ok {
+		v.result.Counts = append(v.result.Counts, key)
+	}
+	v.kept[key] = n + 1
+	if v.limit <= 0 || n < v.limit {
+		v.result.Violations = append(v.result.Violations, violation)
+	}
+}
+
+// Result is the outcome of the rows checked so far.
+func (v *Validator) Result() Result {
+	r := v.result
+	r.Rows = v.rows
+	r.Counts = make([]RuleCount, len(v.result.Counts))
+	for i, key := range v.result.Counts {
+		key.Count = v.kept[key]
+		r.Counts[i] = key
+	}
+	return r
+}
+
+// compare orders two values parsed by the same schema.TextType.
+func compare(a, b any) int {
+	switch x := a.(type) {
+	case int64:
+		return cmpOrdered(x, b.(int64))
+	case int32:
+		return cmpOrdered(x, b.(int32))
+	case float64:
+		return cmpOrdered(x, b.(float64))
+	}
+	return 0
+}
+
+func cmpOrdered[T int64 | int32 | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
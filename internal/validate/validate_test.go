@@ -0,0 +1,175 @@
+package validate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func intp(n int) *int {
+	return &n
+}
+
+func run(t *testing.T, s *Schema, limit int, rows ...string) Result {
+	t.Helper()
+	v, err := New(s, limit)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	for i, row := range rows {
+		if i == 0 {
+			v.Header(1, strings.Split(row, ","))
+			continue
+		}
+		v.Row(i+1, strings.Split(row, ","))
+	}
+	return v.Result()
+}
+
+func TestRules(t *testing.T) {
+	s := &Schema{Columns: []Column{
+		{Name: "id", Type: "int", Required: true, Unique: true, Min: 1},
+		{Name: "status", Values: []string{"new", "paid"}},
+		{Name: "amount", Type: "decimal(9,2)", Min: 0, Max: 1000.5},
+		{Name: "code", Pattern: `^[A-Z]{3}$`, MinLength: intp(3), MaxLength: intp(3)},
+		{Name: "day", Type: "date", Min: "2024-01-01"},
+	}}
+	tests := []struct {
+		row  string
+		want []string
+	}{
+		{"1,new,10.50,ABC,20240101", nil},
+		{"2,paid,,ABC,", nil},
+		{",new,1,ABC,20240101", []string{"id required"}},
+		{"x,new,1,ABC,20240101", []string{"id type"}},
+		{"0,new,1,ABC,20240101", []string{"id min"}},
+		{"3,old,1,ABC,20240101", []string{"status values"}},
+		{"4,new,-1,ABC,20240101", []string{"amount min"}},
+		{"5,new,1000.51,ABC,20240101", []string{"amount max"}},
+		{"6,new,1.234,ABC,20240101", []string{"amount type"}},
+		{"7,new,1,AB,20240101", []string{"code pattern", "code min_length"}},
+		{"8,new,1,ABCD,20240101", []string{"code pattern", "code max_length"}},
+		{"9,new,1,ABC,2023-12-31", []string{"day min"}},
+		{"1,new,1,ABC,20240101", []string{"id unique"}},
+		{"10,new,1,ABC", []string{" fields"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.row, func(t *testing.T) {
+			rows := []string{"id,status,amount,code,day", tt.row}
+			if strings.HasPrefix(tt.row, "1,new,1,") {
+				// a duplicate of the id of the first row
+				rows = []string{"id,status,amount,code,day", "1,new,10.50,ABC,20240101", tt.row}
+			}
+			got := []string{}
+			for _, v := range run(t, s, 0, rows...).Violations {
+				got = append(got, v.Column+" "+v.Rule)
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("violations = %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeader(t *testing.T) {
+	s := &Schema{Columns: []Column{{Name: "id", Required: true}, {Name: "name"}}, Strict: true}
+	r := run(t, s, 0, "id,extra", "1,x", ",y")
+	var got []string
+	for _, v := range r.Violations {
+		got = append(got, v.String())
+	}
+	want := []string{
+		"line 1, column name: header: column is missing",
+		"line 1, column extra: header: column is not in the schema",
+		"line 3, column id: required: value is empty",
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("violations = %q; want %q", got, want)
+	}
+	if r.Rows != 2 || r.Total() != 3 {
+		t.Errorf("rows %d, total %d; want 2, 3", r.Rows, r.Total())
+	}
+}
+
+func TestLimit(t *testing.T) {
+	s := &Schema{Columns: []Column{{Name: "n", Type: "int"}, {Name: "m", Required: true}}}
+	rows := []string{"n,m"}
+	for range 5 {
+		rows = append(rows, "x,")
+	}
+	r := run(t, s, 2, rows...)
+	if len(r.Violations) != 4 || r.Total() != 10 {
+		t.Fatalf("kept %d of %d violations; want 4 of 10", len(r.Violations), r.Total())
+	}
+	if r.Violations[0].Line != 2 || r.Violations[3].Line != 3 {
+		t.Errorf("violations = %v; want the first ones in input order", r.Violations)
+	}
+	want := []RuleCount{{"n", RuleType, 5}, {"m", RuleRequired, 5}}
+	for i, c := range r.Counts {
+		if c != want[i] {
+			t.Errorf("counts = %v; want %v", r.Counts, want)
+		}
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	tests := map[string]*Schema{
+		"no columns":       {},
+		"no name":          {Columns: []Column{{Type: "int"}}},
+		"duplicate":        {Columns: []Column{{Name: "a"}, {Name: "a"}}},
+		"type":             {Columns: []Column{{Name: "a", Type: "float"}}},
+		"string bound":     {Columns: []Column{{Name: "a", Min: 1}}},
+		"bad bound":        {Columns: []Column{{Name: "a", Type: "int", Max: "x"}}},
+		"min over max":     {Columns: []Column{{Name: "a", Type: "double", Min: 2, Max: 1.5}}},
+		"pattern":          {Columns: []Column{{Name: "a", Pattern: "("}}},
+		"negative lengths": {Columns: []Column{{Name: "a", MaxLength: intp(-1)}}},
+	}
+	for name, s := range tests {
+		if _, err := New(s, 0); err == nil {
+			t.Errorf("New(%s) = nil error", name)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	files := map[string]string{
+		"schema.yaml": "strict: true\ncolumns:\n  - {name: id, type: int, unique: true, min: 1}\n" +
+			"  - {name: day, type: date, max: 2030-01-01}\n  - {name: code, max_length: 3, values: [A, B]}\n",
+		"schema.toml": "strict = true\n[[columns]]\nname = \"id\"\ntype = \"int\"\nunique = true\nmin = 1\n" +
+			"[[columns]]\nname = \"day\"\ntype = \"date\"\nmax = 2030-01-01\n" +
+			"[[columns]]\nname = \"code\"\nmax_length = 3\nvalues = [\"A\", \"B\"]\n",
+		"schema.json": `{"strict": true, "columns": [{"name": "id", "type": "int", "unique": true, "min": 1},` +
+			`{"name": "day", "type": "date", "max": "2030-01-01"}, {"name": "code", "max_length": 3, "values": ["A", "B"]}]}`,
+	}
+	dir := t.TempDir()
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		s, err := Load(path)
+		if err != nil {
+			t.Fatalf("Load(%s) error = %v", name, err)
+		}
+		r := run(t, s, 0, "id,day,code", "0,2030-01-02,ABCD", "0,20300101,A")
+		if got := r.Total(); got != 6 || !s.Strict || len(s.Columns) != 3 {
+			t.Errorf("Load(%s) = %+v, %d violations; want 6", name, s, got)
+		}
+	}
+
+	bad := map[string]string{
+		"unknown.yaml": "columns: []\nrules: []\n",
+		"unknown.toml": "rules = 1\n",
+		"schema.txt":   "",
+	}
+	for name, data := range bad {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(path); err == nil {
+			t.Errorf("Load(%s) = nil error", name)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package verify
+
+import (
+	"encoding/binary"
+	"math/bits"
+
+	"github.com/golang/snappy"
+	"github.com/pkg/errors"
+	"github.com/xitongsys/parquet-go/compress"
+	"github.com/xitongsys/parquet-go/parquet"
+)
+
+// maxDeltaBlock bounds the block size of delta encoded values, writers use 128.
+const maxDeltaBlock = 1 << 16
+
+// column is what checkPage needs to know of the column of a page.
+type column struct {
+	codec      parquet.CompressionCodec
+	typ        parquet.Type
+	typeLength int
+	maxRL      int32
+	maxDL      int32
+	// uncompressed is the total uncompressed size of the chunk, a page can't be bigger
+	uncompressed int64
+}
+
+// checkPage walks the levels and values of a page without decoding them. The decoders of parquet-go
+// trust the lengths and counts they read, corrupt ones would make them allocate beyond any limit.
+func checkPage(header *parquet.PageHeader, data []byte, c column, values int64) error {
+	if int64(header.GetUncompressedPageSize()) > c.uncompressed || header.GetUncompressedPageSize() < 0 {
+		return errors.Errorf("uncompressed size %d, the column chunk has %d", header.GetUncompressedPageSize(), c.uncompressed)
+	}
+	switch header.GetType() {
+	case parquet.PageType_DICTIONARY_PAGE:
+		body, err := uncompress(data, c.codec, header.GetUncompressedPageSize())
+		if err != nil {
+			return err
+		}
+		n := header.GetDictionaryPageHeader().GetNumValues()
+		if n < 0 {
+			return errors.Errorf("%d dictionary values", n)
+		}
+		return checkPlain(body, c, int64(n))
+	case parquet.PageType_DATA_PAGE:
+		h := header.GetDataPageHeader()
+		if h == nil || int64(h.GetNumValues()) > values || h.GetNumValues() < 0 {
+			return errors.Errorf("%d values, %d are left in the column chunk", h.GetNumValues(), values)
+		}
+		body, err := uncompress(data, c.codec, header.GetUncompressedPageSize())
+		if err != nil {
+			return err
+		}
+		n := int64(h.GetNumValues())
+		for _, maxLevel := range []int32{c.maxRL, c.maxDL} {
+			if maxLevel == 0 {
+				continue
+			}
+			if len(body) < 4 { //nolint:mnd // length of levels
+				return errors.New("levels are missing")
+			}
+			size := int64(binary.LittleEndian.Uint32(body))
+			if size > int64(len(body)-4) {
+				return errors.Errorf("levels of %d bytes, the page has %d", size, len(body)-4)
+			}
+			if err = checkHybrid(body[4:4+size], bits.Len32(uint32(maxLevel)), n, true); err != nil {
+				return errors.Wrap(err, "levels")
+			}
+			body = body[4+size:]
+		}
+		return checkValues(body, h.GetEncoding(), c, n)
+	case parquet.PageType_DATA_PAGE_V2:
+		h := header.GetDataPageHeaderV2()
+		if h == nil || int64(h.GetNumValues()) > values || h.GetNumValues() < 0 {
+			return errors.Errorf("%d values, %d are left in the column chunk", h.GetNumValues(), values)
+		}
+		n := int64(h.GetNumValues())
+		rl, dl := int(h.GetRepetitionLevelsByteLength()), int(h.GetDefinitionLevelsByteLength())
+		if rl < 0 || dl < 0 || rl+dl > len(data) {
+			return errors.Errorf("levels of %d bytes, the page has %d", rl+dl, len(data))
+		}
+		for i, levels := range [][]byte{data[:rl], data[rl : rl+dl]} {
+			maxLevel := []int32{c.maxRL, c.maxDL}[i]
+			if maxLevel == 0 {
+				continue
+			}
+			if err := checkHybrid(levels, bits.Len32(uint32(maxLevel)), n, true); err != nil {
+				return errors.Wrap(err, "levels")
+			}
+		}
+		body := data[rl+dl:]
+		if len(body) > 0 {
+			var err error
+			if body, err = uncompress(body, c.codec, header.GetUncompressedPageSize()); err != nil {
+				return err
+			}
+		}
+		return checkValues(body, h.GetEncoding(), c, n)
+	}
+	return nil
+}
+
+func uncompress(data []byte, codec parquet.CompressionCodec, size int32) ([]byte, error) {
+	if codec == parquet.CompressionCodec_SNAPPY {
+		n, err := snappy.DecodedLen(data)
+		if err != nil {
+			return nil, errors.Wrap(err, "doesn't uncompress")
+		}
+		if n > int(size) {
+			return nil, errors.Errorf("uncompresses to %d bytes, the header says %d", n, size)
+		}
+	}
+	body, err := compress.Uncompress(data, codec)
+	if err != nil {
+		return nil, errors.Wrap(err, "doesn't uncompress")
+	}
+	return body, nil
+}
+
+// checkValues walks the values of a data page, at most n with nulls.
+func checkValues(data []byte, encoding parquet.Encoding, c column, n int64) error {
+	switch encoding {
+	case parquet.Encoding_PLAIN:
+		return checkPlain(data, c, -n)
+	case parquet.Encoding_PLAIN_DICTIONARY, parquet.Encoding_RLE_DICTIONARY:
+		if len(data) == 0 {
+			return nil
+		}
+		if data[0] > 32 { //nolint:mnd // dictionary indexes are int32
+			return errors.Errorf("dictionary index width %d", data[0])
+		}
+		return errors.Wrap(checkHybrid(data[1:], int(data[0]), n, false), "dictionary indexes")
+	case parquet.Encoding_RLE:
+		if len(data) < 4 { //nolint:mnd // length of values
+			return nil
+		}
+		size := int64(binary.LittleEndian.Uint32(data))
+		if size > int64(len(data)-4) {
+			return errors.Errorf("values of %d bytes, the page has %d", size, len(data)-4)
+		}
+		return checkHybrid(data[4:4+size], 1, n, true)
+	case parquet.Encoding_DELTA_BINARY_PACKED, parquet.Encoding_DELTA_LENGTH_BYTE_ARRAY,
+		parquet.Encoding_DELTA_BYTE_ARRAY:
+		return checkDelta(data, n)
+	}
+	return nil
+}
+
+// checkPlain walks plain values, n of them or at most -n of them when negative.
+func checkPlain(data []byte, c column, n int64) error {
+	exact := n >= 0
+	if !exact {
+		n = -n
+	}
+	var size int64
+	switch c.typ {
+	case parquet.Type_BYTE_ARRAY:
+		pos := 0
+		for i := int64(0); i < n && (exact || pos < len(data)); i++ {
+			if len(data)-pos < 4 { //nolint:mnd // length of a byte array
+				return errors.Errorf("value %d of %d is missing", i+1, n)
+			}
+			length := int64(binary.LittleEndian.Uint32(data[pos:]))
+			if length > int64(len(data)-pos-4) {
+				return errors.Errorf("value %d of %d bytes, %d are left in the page", i+1, length, len(data)-pos-4)
+			}
+			pos += 4 + int(length)
+		}
+		return nil
+	case parquet.Type_BOOLEAN:
+		size = (n + 7) / 8 //nolint:mnd // bits
+	case parquet.Type_INT32, parquet.Type_FLOAT:
+		size = 4 * n
+	case parquet.Type_INT64, parquet.Type_DOUBLE:
+		size = 8 * n
+	case parquet.Type_INT96:
+		size = 12 * n
+	case parquet.Type_FIXED_LEN_BYTE_ARRAY:
+		if c.typeLength <= 0 {
+			return errors.Errorf("fixed length %d", c.typeLength)
+		}
+		size = int64(c.typeLength) * n
+	}
+	if exact && size > int64(len(data)) {
+		return errors.Errorf("%d values need %d bytes, the page has %d", n, size, len(data))
+	}
+	return nil
+}
+
+// checkHybrid walks rle and bit-packed runs of width bits, n values in all or at most n.
+func checkHybrid(data []byte, width int, n int64, exact bool) error {
+	var count int64
+	pos := 0
+	for pos < len(data) {
+		header, read := binary.Uvarint(data[pos:])
+		if read <= 0 {
+			return errors.New("run header doesn't decode")
+		}
+		pos += read
+		left := max(n-count, 0)
+		if header&1 == 0 {
+			run := header >> 1
+			if run > uint64(left) {
+				return errors.Errorf("run of %d values, %d are left in the page", run, left)
+			}
+			count += int64(run)
+			pos += (width + 7) / 8 //nolint:mnd // bits
+		} else {
+			groups := header >> 1
+			if groups > uint64(left+7)/8 { //nolint:mnd // values of a group
+				return errors.Errorf("%d bit-packed groups, %d values are left in the page", groups, left)
+			}
+			count += int64(groups) * 8 //nolint:mnd // values of a group
+			pos += int(groups) * width
+		}
+		if pos > len(data) {
+			return errors.New("run goes past the end of the page")
+		}
+	}
+	if exact && count < n {
+		return errors.Errorf("%d values, the page has %d", count, n)
+	}
+	return nil
+}
+
+// checkDelta checks the header of delta encoded values: block size, miniblocks and value count.
+func checkDelta(data []byte, n int64) error {
+	var header [3]uint64
+	pos := 0
+	for i := range header {
+		value, read := binary.Uvarint(data[pos:])
+		if read <= 0 {
+			return errors.New("delta header doesn't decode")
+		}
+		header[i] = value
+		pos += read
+	}
+	block, miniblocks, count := header[0], header[1], header[2]
+	if block == 0 || block > maxDeltaBlock || miniblocks == 0 || block%miniblocks != 0 || (block/miniblocks)%8 != 0 {
+		return errors.Errorf("delta block of %d values in %d miniblocks", block, miniblocks)
+	}
+	if count > uint64(n) {
+		return errors.Errorf("%d delta values, the page has %d", count, n)
+	}
+	return nil
+}
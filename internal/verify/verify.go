@@ -0,0 +1,465 @@
+// Package verify checks the integrity of a parquet file: magic bytes, footer, every page of
+// every column chunk, row counts and column statistics.
+package verify
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/pkg/errors"
+	"github.com/xitongsys/parquet-go/common"
+	"github.com/xitongsys/parquet-go/encoding"
+	"github.com/xitongsys/parquet-go/layout"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/schema"
+)
+
+const (
+	magic = "PAR1"
+	// tailSize is the footer length and the magic bytes ending the file.
+	tailSize = 8
+)
+
+// Problem is a corruption found in the file, a row group or a column chunk.
+type Problem struct {
+	// RowGroup is -1 for the file.
+	RowGroup int
+	// Column is the dotted path of the column chunk, empty for the file or a whole row group.
+	Column  string
+	Message string
+}
+
+func (p Problem) String() string {
+	switch {
+	case p.RowGroup < 0:
+		return "file: " + p.Message
+	case p.Column == "":
+		return fmt.Sprintf("row group %d: %s", p.RowGroup, p.Message)
+	}
+	return fmt.Sprintf("row group %d, column %s: %s", p.RowGroup, p.Column, p.Message)
+}
+
+// Result is the outcome of a verification.
+type Result struct {
+	RowGroups int
+	Columns   int
+	Rows      int64
+	Pages     int
+	Problems  []Problem
+}
+
+// OK tells if no corruption was found.
+func (r Result) OK() bool {
+	return len(r.Problems) == 0
+}
+
+type verifier struct {
+	r      io.ReaderAt
+	size   int64
+	footer *parquet.FileMetaData
+	schema *schema.SchemaHandler
+	// dataEnd is where the footer starts, column chunks end before it
+	dataEnd int64
+	// parquetGo tells if the file is written by parquet-go, which leaves the first value
+	// of each data page out of the null count
+	parquetGo bool
+	result    Result
+}
+
+// File verifies the parquet file read by r of the given size. A corrupt file is reported
+// by the problems of the result, the error is about reading r.
+func File(r io.ReaderAt, size int64) (Result, error) {
+	v := &verifier{r: r, size: size}
+	ok, err := v.readFooter()
+	if err != nil || !ok {
+		return v.result, err
+	}
+	v.parquetGo = strings.HasPrefix(v.footer.GetCreatedBy(), "parquet-go ")
+	v.result.RowGroups = len(v.footer.RowGroups)
+	v.result.Columns = len(v.schema.ValueColumns)
+
+	var rows int64
+	for i, rg := range v.footer.RowGroups {
+		if err = v.rowGroup(i, rg); err != nil {
+			return v.result, err
+		}
+		rows += rg.GetNumRows()
+	}
+	v.result.Rows = rows
+	if rows != v.footer.GetNumRows() {
+		v.fail(-1, "", "row groups have %d rows, the footer says %d", rows, v.footer.GetNumRows())
+	}
+	return v.result, nil
+}
+
+func (v *verifier) fail(rowGroup int, column, format string, args ...any) {
+	v.result.Problems = append(v.result.Problems, Problem{
+		RowGroup: rowGroup,
+		Column:   column,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// readFooter checks the magic bytes and decodes the footer, false when the file can't be read further.
+func (v *verifier) readFooter() (bool, error) {
+	if v.size < int64(len(magic)+tailSize) {
+		v.fail(-1, "", "%d bytes, too short for a parquet file", v.size)
+		return false, nil
+	}
+	head := make([]byte, len(magic))
+	if _, err := v.r.ReadAt(head, 0); err != nil {
+		return false, errors.Wrap(err, "error read header")
+	}
+	tail := make([]byte, tailSize)
+	if _, err := v.r.ReadAt(tail, v.size-tailSize); err != nil {
+		return false, errors.Wrap(err, "error read footer length")
+	}
+	if string(head) != magic {
+		v.fail(-1, "", "starts with %q instead of %q", head, magic)
+	}
+	if string(tail[4:]) != magic {
+		v.fail(-1, "", "ends with %q instead of %q, the file is truncated or not parquet", tail[4:], magic)
+		return false, nil
+	}
+	length := int64(binary.LittleEndian.Uint32(tail))
+	v.dataEnd = v.size - tailSize - length
+	if v.dataEnd < int64(len(magic)) {
+		v.fail(-1, "", "footer of %d bytes doesn't fit in the file of %d", length, v.size)
+		return false, nil
+	}
+	data := make([]byte, length)
+	if _, err := v.r.ReadAt(data, v.dataEnd); err != nil {
+		return false, errors.Wrap(err, "error read footer")
+	}
+	v.footer = parquet.NewFileMetaData()
+	buf := &thrift.TMemoryBuffer{Buffer: bytes.NewBuffer(data)}
+	if err := v.footer.Read(context.Background(), thrift.NewTCompactProtocolConf(buf, nil)); err != nil {
+		v.fail(-1, "", "footer doesn't decode: %v", err)
+		return false, nil
+	}
+	if err := catch(func() {
+		v.schema = schema.NewSchemaHandlerFromSchemaList(v.footer.GetSchema())
+	}); err != nil || len(v.footer.GetSchema()) == 0 {
+		v.fail(-1, "", "footer schema is invalid")
+		return false, nil
+	}
+	return true, nil
+}
+
+func (v *verifier) rowGroup(index int, rg *parquet.RowGroup) error {
+	if len(rg.GetColumns()) != len(v.schema.ValueColumns) {
+		v.fail(index, "", "%d column chunks, the schema has %d columns", len(rg.GetColumns()), len(v.schema.ValueColumns))
+	}
+	for _, chunk := range rg.GetColumns() {
+		md := chunk.GetMetaData()
+		if md == nil {
+			v.fail(index, "", "column chunk without metadata")
+			continue
+		}
+		name := strings.Join(md.GetPathInSchema(), ".")
+		if chunk.IsSetFilePath() {
+			v.fail(index, name, "column chunk is in another file, %s", chunk.GetFilePath())
+			continue
+		}
+		c, err := v.newChunk(md)
+		if err != nil {
+			v.fail(index, name, "%v", err)
+			continue
+		}
+		rows, err := c.read()
+		v.result.Pages += c.pages
+		var re readError
+		if errors.As(err, &re) {
+			return re.error
+		}
+		if err != nil {
+			v.fail(index, name, "%v", err)
+			continue
+		}
+		if rows != rg.GetNumRows() {
+			v.fail(index, name, "%d rows, the row group has %d", rows, rg.GetNumRows())
+		}
+		for _, problem := range c.statistics() {
+			v.fail(index, name, "%s", problem)
+		}
+	}
+	return nil
+}
+
+// readError is an io error of the reader as opposed to a corrupt chunk.
+type readError struct {
+	error
+}
+
+// chunk is a column chunk with the values and nulls of its pages counted.
+type chunk struct {
+	v   *verifier
+	md  *parquet.ColumnMetaData
+	col column
+	// funcs orders the values, nil when the statistics of the type can't be checked
+	funcs     common.FuncTable
+	min, max  any
+	nulls     int64
+	pages     int
+	dataPages int
+}
+
+func (v *verifier) newChunk(md *parquet.ColumnMetaData) (*chunk, error) {
+	exPath := append([]string{v.schema.GetRootExName()}, md.GetPathInSchema()...)
+	inPath, ok := v.schema.ExPathToInPath[common.PathToStr(exPath)]
+	if !ok {
+		return nil, errors.New("column is not in the schema")
+	}
+	// layout.ReadPage finds the column by the path of the schema handler
+	inMD := *md
+	inMD.PathInSchema = common.StrToPath(inPath)[1:]
+	element := v.schema.SchemaElements[v.schema.MapIndex[inPath]]
+	if element.GetType() != md.GetType() {
+		return nil, errors.Errorf("type %s, the schema has %s", md.GetType(), element.GetType())
+	}
+	c := &chunk{v: v, md: &inMD, col: column{
+		codec:        md.GetCodec(),
+		typ:          md.GetType(),
+		typeLength:   int(element.GetTypeLength()),
+		uncompressed: md.GetTotalUncompressedSize(),
+	}}
+	path := common.StrToPath(inPath)
+	var err error
+	if c.col.maxRL, err = v.schema.MaxRepetitionLevel(path); err != nil {
+		return nil, errors.Wrap(err, "schema")
+	}
+	if c.col.maxDL, err = v.schema.MaxDefinitionLevel(path); err != nil {
+		return nil, errors.Wrap(err, "schema")
+	}
+	if md.GetType() != parquet.Type_INT96 && element.GetConvertedType() != parquet.ConvertedType_INTERVAL {
+		_ = catch(func() {
+			c.funcs = common.FindFuncTable(element.Type, element.ConvertedType, element.LogicalType)
+		})
+	}
+	return c, nil
+}
+
+// read decodes every page of the chunk and returns its row count.
+func (c *chunk) read() (rows int64, err error) {
+	start := c.md.GetDataPageOffset()
+	if c.md.IsSetDictionaryPageOffset() && c.md.GetDictionaryPageOffset() > 0 && c.md.GetDictionaryPageOffset() < start {
+		start = c.md.GetDictionaryPageOffset()
+	}
+	end := start + c.md.GetTotalCompressedSize()
+	if start < int64(len(magic)) || c.md.GetTotalCompressedSize() < 0 || end > c.v.dataEnd {
+		return 0, errors.Errorf("bytes %d to %d are outside the data of the file, it ends at %d", start, end, c.v.dataEnd)
+	}
+	buf := make([]byte, end-start)
+	if _, err = c.v.r.ReadAt(buf, start); err != nil {
+		return 0, readError{errors.Wrap(err, "error read column chunk")}
+	}
+
+	var (
+		values int64
+		dict   *layout.Page
+		pos    int
+	)
+	defer func() {
+		// the decoders of parquet-go panic on some corrupt input
+		if p := recover(); p != nil {
+			err = errors.Errorf("page %d doesn't decode: %v", c.pages, p)
+		}
+	}()
+	for values < c.md.GetNumValues() {
+		if pos >= len(buf) {
+			return rows, errors.Errorf("%d values, the metadata says %d", values, c.md.GetNumValues())
+		}
+		mem := &thrift.TMemoryBuffer{Buffer: bytes.NewBuffer(buf[pos:])}
+		header := parquet.NewPageHeader()
+		if err = header.Read(context.Background(), thrift.NewTCompactProtocolConf(mem, nil)); err != nil {
+			return rows, errors.Errorf("page %d header doesn't decode: %v", c.pages, err)
+		}
+		headerSize := len(buf) - pos - mem.Len()
+		size := int(header.GetCompressedPageSize())
+		if size < 0 || pos+headerSize+size > len(buf) {
+			return rows, errors.Errorf("page %d of %d bytes goes past the end of the column chunk", c.pages, size)
+		}
+		page := buf[pos : pos+headerSize+size]
+		pos += len(page)
+		if header.IsSetCrc() && crc32.ChecksumIEEE(page[headerSize:]) != uint32(header.GetCrc()) {
+			return rows, errors.Errorf("page %d crc mismatch", c.pages)
+		}
+		if header.GetType() == parquet.PageType_INDEX_PAGE {
+			c.pages++
+			continue
+		}
+		if err = checkPage(header, page[headerSize:], c.col, c.md.GetNumValues()-values); err != nil {
+			return rows, errors.Errorf("page %d: %v", c.pages, err)
+		}
+
+		reader := thrift.NewTBufferedTransport(thrift.NewStreamTransportR(bytes.NewReader(page)), len(page))
+		decoded, n, pageRows, readErr := layout.ReadPage(reader, c.v.schema, c.md)
+		if readErr != nil {
+			return rows, errors.Errorf("page %d doesn't decode: %v", c.pages, readErr)
+		}
+		if header.GetType() == parquet.PageType_DICTIONARY_PAGE {
+			dict = decoded
+			c.pages++
+			continue
+		}
+		if encoding := dataEncoding(header); encoding == parquet.Encoding_RLE_DICTIONARY ||
+			encoding == parquet.Encoding_PLAIN_DICTIONARY {
+			if dict == nil {
+				return rows, errors.Errorf("page %d is dictionary encoded without a dictionary page", c.pages)
+			}
+			decoded.Decode(dict)
+		}
+		c.count(decoded.DataTable)
+		values += n
+		rows += pageRows
+		c.pages++
+	}
+	if values != c.md.GetNumValues() {
+		return rows, errors.Errorf("%d values, the metadata says %d", values, c.md.GetNumValues())
+	}
+	return rows, nil
+}
+
+func dataEncoding(header *parquet.PageHeader) parquet.Encoding {
+	if header.GetType() == parquet.PageType_DATA_PAGE_V2 {
+		return header.GetDataPageHeaderV2().GetEncoding()
+	}
+	return header.GetDataPageHeader().GetEncoding()
+}
+
+// count keeps the nulls, min and max of the values of a data page.
+func (c *chunk) count(table *layout.Table) {
+	c.dataPages++
+	for i, value := range table.Values {
+		if table.DefinitionLevels[i] != table.MaxDefinitionLevel {
+			c.nulls++
+			continue
+		}
+		if c.funcs == nil || isNaN(value) {
+			continue
+		}
+		if c.min == nil || c.funcs.LessThan(value, c.min) {
+			c.min = value
+		}
+		if c.max == nil || c.funcs.LessThan(c.max, value) {
+			c.max = value
+		}
+	}
+}
+
+// statistics compares the statistics of the chunk metadata with the values read. Byte array
+// bounds may be truncated by writers, so they only have to hold, the others must be exact.
+func (c *chunk) statistics() []string {
+	stats := c.md.GetStatistics()
+	if stats == nil {
+		return nil
+	}
+	var problems []string
+	minNulls := c.nulls
+	if c.v.parquetGo {
+		minNulls -= int64(c.dataPages)
+	}
+	if n := stats.GetNullCount(); stats.IsSetNullCount() && (n > c.nulls || n < minNulls) {
+		problems = append(problems, fmt.Sprintf("statistics null count is %d, the data has %d", stats.GetNullCount(), c.nulls))
+	}
+	if c.funcs == nil {
+		return problems
+	}
+	byteArray := c.md.GetType() == parquet.Type_BYTE_ARRAY || c.md.GetType() == parquet.Type_FIXED_LEN_BYTE_ARRAY
+	minBytes, maxBytes := stats.GetMinValue(), stats.GetMaxValue()
+	if !stats.IsSetMinValue() && !stats.IsSetMaxValue() && !byteArray {
+		// the deprecated fields have a signed order, only trusted for other types
+		minBytes, maxBytes = stats.GetMin(), stats.GetMax()
+	}
+	for _, bound := range []struct {
+		name  string
+		data  []byte
+		value any
+		// beyond tells if the stored bound excludes the value found
+		beyond func(stored, found any) bool
+	}{
+		{"min", minBytes, c.min, func(stored, found any) bool { return c.funcs.LessThan(found, stored) }},
+		{"max", maxBytes, c.max, func(stored, found any) bool { return c.funcs.LessThan(stored, found) }},
+	} {
+		if bound.data == nil {
+			continue
+		}
+		if bound.value == nil {
+			problems = append(problems, fmt.Sprintf("statistics have a %s, the data has no values", bound.name))
+			continue
+		}
+		stored, err := c.statValue(bound.data)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("statistics %s doesn't decode: %v", bound.name, err))
+			continue
+		}
+		var mismatch bool
+		if err = catch(func() {
+			if byteArray {
+				mismatch = bound.beyond(stored, bound.value)
+			} else {
+				mismatch = !isNaN(stored) && (c.funcs.LessThan(stored, bound.value) || c.funcs.LessThan(bound.value, stored))
+			}
+		}); err != nil {
+			problems = append(problems, fmt.Sprintf("statistics %s %s doesn't compare: %v", bound.name, show(stored), err))
+			continue
+		}
+		if mismatch {
+			problems = append(problems, fmt.Sprintf("statistics %s is %s, the data has %s",
+				bound.name, show(stored), show(bound.value)))
+		}
+	}
+	return problems
+}
+
+// statValue decodes a plain encoded statistics bound, byte arrays are stored without their length.
+func (c *chunk) statValue(data []byte) (any, error) {
+	switch c.md.GetType() {
+	case parquet.Type_BYTE_ARRAY, parquet.Type_FIXED_LEN_BYTE_ARRAY:
+		return string(data), nil
+	}
+	values, err := encoding.ReadPlain(bytes.NewReader(data), c.md.GetType(), 1, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != 1 {
+		return nil, errors.New("no value")
+	}
+	return values[0], nil
+}
+
+func isNaN(value any) bool {
+	switch f := value.(type) {
+	case float32:
+		return math.IsNaN(float64(f))
+	case float64:
+		return math.IsNaN(f)
+	}
+	return false
+}
+
+func show(value any) string {
+	if s, ok := value.(string); ok {
+		return strconv.Quote(s)
+	}
+	return fmt.Sprint(value)
+}
+
+// catch turns a panic of fn into an error.
+func catch(fn func()) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = errors.Errorf("%v", p)
+		}
+	}()
+	fn()
+	return nil
+}
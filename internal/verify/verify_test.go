@@ -0,0 +1,168 @@
+package verify
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+type testRow struct {
+	ID   int64   `parquet:"name=id, type=INT64"`
+	Name *string `parquet:"name=name, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+	Tags []int32 `parquet:"name=tags, type=INT32, repetitiontype=REPEATED"`
+}
+
+// writeTestFile writes 300 rows in row groups of about 100 rows, the ids 1 to 300 plain encoded.
+func writeTestFile(t *testing.T) []byte {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.parquet")
+	fw, err := local.NewLocalFileWriter(path)
+	if err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	pw, err := writer.NewParquetWriter(fw, new(testRow), 1)
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+	pw.CompressionType = parquet.CompressionCodec_UNCOMPRESSED
+	for i := 1; i <= 300; i++ {
+		row := testRow{ID: int64(i), Tags: []int32{int32(i), -int32(i)}}
+		if i%3 != 0 {
+			name := "name" + strings.Repeat("x", i%7)
+			row.Name = &name
+		}
+		if err = pw.Write(row); err != nil {
+			t.Fatalf("Write error: %v", err)
+		}
+		if i%100 == 0 {
+			if err = pw.Flush(true); err != nil {
+				t.Fatalf("Flush error: %v", err)
+			}
+		}
+	}
+	if err = pw.WriteStop(); err != nil {
+		t.Fatalf("WriteStop error: %v", err)
+	}
+	_ = fw.Close()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func verifyBytes(t *testing.T, data []byte) Result {
+	t.Helper()
+	r, err := File(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("File() error = %v", err)
+	}
+	return r
+}
+
+func TestValid(t *testing.T) {
+	r := verifyBytes(t, writeTestFile(t))
+	if !r.OK() {
+		t.Fatalf("problems = %v", r.Problems)
+	}
+	if r.RowGroups != 3 || r.Columns != 3 || r.Rows != 300 || r.Pages < 9 {
+		t.Errorf("result = %+v; want 3 row groups, 3 columns, 300 rows, 9 pages or more", r)
+	}
+}
+
+func TestCorrupt(t *testing.T) {
+	valid := writeTestFile(t)
+	footerStart := len(valid) - 8 - int(binary.LittleEndian.Uint32(valid[len(valid)-8:]))
+	tests := []struct {
+		name    string
+		corrupt func(data []byte) []byte
+		want    string
+	}{
+		{
+			name: "truncated",
+			corrupt: func(data []byte) []byte {
+				return data[:len(data)/2]
+			},
+			want: "file: ends with",
+		},
+		{
+			name: "too short",
+			corrupt: func(data []byte) []byte {
+				return data[:10]
+			},
+			want: "file: 10 bytes",
+		},
+		{
+			name: "header magic",
+			corrupt: func(data []byte) []byte {
+				copy(data, "PAR0")
+				return data
+			},
+			want: "file: starts with",
+		},
+		{
+			name: "footer length",
+			corrupt: func(data []byte) []byte {
+				binary.LittleEndian.PutUint32(data[len(data)-8:], uint32(len(data)))
+				return data
+			},
+			want: "file: footer of",
+		},
+		{
+			name: "footer",
+			corrupt: func(data []byte) []byte {
+				for i := footerStart; i < len(data)-8; i++ {
+					data[i] = 0xff
+				}
+				return data
+			},
+			want: "file: footer doesn't decode",
+		},
+		{
+			name: "value",
+			corrupt: func(data []byte) []byte {
+				// the id 250 of the third row group becomes 1000, beyond the statistics max
+				i := bytes.Index(data, binary.LittleEndian.AppendUint64(nil, 250))
+				binary.LittleEndian.PutUint64(data[i:], 1000)
+				return data
+			},
+			want: "row group 2, column id: statistics max is 300, the data has 1000",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.corrupt(bytes.Clone(valid))
+			r := verifyBytes(t, data)
+			if r.OK() {
+				t.Fatal("no problems")
+			}
+			if got := r.Problems[0].String(); !strings.HasPrefix(got, tt.want) {
+				t.Errorf("problems = %v; want %q first", r.Problems, tt.want)
+			}
+		})
+	}
+}
+
+func TestCorruptPages(t *testing.T) {
+	valid := writeTestFile(t)
+	// garbage in every byte of the chunk data of the first row group, one at a time, must be
+	// found or harmless, never a panic
+	end := bytes.Index(valid, binary.LittleEndian.AppendUint64(nil, 100)) + 8
+	for i := 4; i < end; i++ {
+		data := bytes.Clone(valid)
+		data[i] ^= 0xa5
+		r := verifyBytes(t, data)
+		for _, p := range r.Problems {
+			if p.RowGroup != 0 {
+				t.Fatalf("byte %d: problem %v outside the first row group", i, p)
+			}
+		}
+	}
+}
@@ -0,0 +1,331 @@
+// Package watch converts the csv and parquet files dropped into an inbox directory.
+package watch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Settle is the default time a file must stay unchanged before it is picked up.
+	Settle = 5 * time.Second
+	// Interval is the default time between scans of the inbox.
+	Interval = time.Second
+
+	// MarkerExt marks a file as complete: data.csv is picked up at once when data.csv.done exists.
+	MarkerExt = ".done"
+	tmpPrefix = "."
+	tmpExt    = ".tmp"
+)
+
+// Config configures a Watcher.
+type Config struct {
+	// In is the inbox, Out gets the converted files, Archive the originals converted and
+	// Failed the originals that failed to convert.
+	In, Out, Archive, Failed string
+	// Settle is how long a file without a marker must keep its size and modification time.
+	Settle time.Duration
+	// Interval is the time between scans, the only trigger of them with Poll.
+	Interval time.Duration
+	// Poll scans on Interval only, for file systems without change notifications like NFS.
+	Poll bool
+	// Convert converts input, a .csv file to parquet or a .parquet file to csv, into output.
+	Convert func(ctx context.Context, input, output string) error
+	// Done is called once a file is processed, optional.
+	Done func(Result)
+	// Log gets a line per file and about the watching, optional.
+	Log io.Writer
+}
+
+// Result is a processed file.
+type Result struct {
+	// Name is the file name in the inbox.
+	Name string
+	// Output is the converted file, empty on failure.
+	Output string
+	// Original is where the input was moved to, in Archive or Failed.
+	Original string
+	Err      error
+	Start    time.Time
+}
+
+// Watcher picks up files from the inbox once they are stable.
+type Watcher struct {
+	cfg  Config
+	seen map[string]*state
+	// skip are files that failed and couldn't be moved out of the inbox
+	skip map[string]bool
+}
+
+// state is the last observation of a file.
+type state struct {
+	size  int64
+	mod   time.Time
+	since time.Time
+}
+
+func New(cfg Config) *Watcher {
+	if cfg.Settle < 0 {
+		cfg.Settle = 0
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = Interval
+	}
+	if cfg.Log == nil {
+		cfg.Log = io.Discard
+	}
+	if cfg.Done == nil {
+		cfg.Done = func(Result) {}
+	}
+	return &Watcher{cfg: cfg, seen: map[string]*state{}, skip: map[string]bool{}}
+}
+
+// Run processes the inbox until ctx is done. A file being converted then is left in the inbox.
+func (w *Watcher) Run(ctx context.Context) error {
+	if err := w.mkdirs(); err != nil {
+		return err
+	}
+	events := w.notify(ctx)
+	ticker := time.NewTicker(w.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := w.Scan(ctx); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		case <-events:
+		}
+	}
+}
+
+// Once processes the files in the inbox as they become stable and returns when none is left.
+func (w *Watcher) Once(ctx context.Context) error {
+	if err := w.mkdirs(); err != nil {
+		return err
+	}
+	for {
+		pending, err := w.Scan(ctx)
+		if err != nil || pending == 0 {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(w.cfg.Interval):
+		}
+	}
+}
+
+// Scan processes the stable files of the inbox and returns the number of files still settling.
+func (w *Watcher) Scan(ctx context.Context) (int, error) {
+	entries, err := os.ReadDir(w.cfg.In)
+	if err != nil {
+		return 0, errors.Wrap(err, "error read inbox "+w.cfg.In)
+	}
+	names := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		names[e.Name()] = true
+	}
+	now := time.Now()
+	pending := 0
+	for _, e := range entries {
+		name := e.Name()
+		if !e.Type().IsRegular() || !isInput(name) || w.skip[name] {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			// removed since the listing
+			continue
+		}
+		s, ok := w.seen[name]
+		if !ok || s.size != info.Size() || !s.mod.Equal(info.ModTime()) {
+			s = &state{size: info.Size(), mod: info.ModTime(), since: now}
+			w.seen[name] = s
+		}
+		if !names[name+MarkerExt] && now.Sub(s.since) < w.cfg.Settle {
+			pending++
+			continue
+		}
+		if ctx.Err() != nil {
+			return pending, nil
+		}
+		w.process(ctx, name)
+	}
+	for name := range w.seen {
+		if !names[name] {
+			delete(w.seen, name)
+		}
+	}
+	return pending, nil
+}
+
+// process converts a file and moves it out of the inbox.
+func (w *Watcher) process(ctx context.Context, name string) {
+	res := Result{Name: name, Start: time.Now()}
+	input := filepath.Join(w.cfg.In, name)
+	output := filepath.Join(w.cfg.Out, OutputName(name))
+	tmp := filepath.Join(w.cfg.Out, tmpPrefix+OutputName(name)+tmpExt)
+
+	res.Err = w.cfg.Convert(ctx, input, tmp)
+	if res.Err == nil {
+		output = uniquePath(output)
+		res.Err = errors.Wrap(os.Rename(tmp, output), "error move output")
+	}
+	if res.Err != nil {
+		_ = os.Remove(tmp)
+		if ctx.Err() != nil {
+			// stopped, the file is picked up again on the next run
+			return
+		}
+	} else {
+		res.Output = output
+	}
+
+	dir := w.cfg.Archive
+	if res.Err != nil {
+		dir = w.cfg.Failed
+	}
+	original, err := moveFile(input, uniquePath(filepath.Join(dir, name)))
+	if err != nil {
+		fmt.Fprintf(w.cfg.Log, "%s: %v, skipping it until restart\n", name, err)
+		w.skip[name] = true
+	}
+	res.Original = original
+	_ = os.Remove(input + MarkerExt)
+	delete(w.seen, name)
+
+	if res.Err != nil {
+		fmt.Fprintf(w.cfg.Log, "%s: failed: %v\n", name, res.Err)
+	} else {
+		fmt.Fprintf(w.cfg.Log, "%s: converted to %s in %s\n", name, output, time.Since(res.Start).Round(time.Millisecond))
+	}
+	w.cfg.Done(res)
+}
+
+func (w *Watcher) mkdirs() error {
+	if _, err := os.Stat(w.cfg.In); err != nil {
+		return errors.Wrap(err, "error open inbox")
+	}
+	for _, dir := range []string{w.cfg.Out, w.cfg.Archive, w.cfg.Failed} {
+		if err := os.MkdirAll(dir, 0o755); err != nil { //nolint:mnd // directory permissions
+			return errors.Wrap(err, "error create directory "+dir)
+		}
+	}
+	return nil
+}
+
+// notify sends on the channel when the inbox changes, it never sends with Poll or when
+// the file system has no change notifications.
+func (w *Watcher) notify(ctx context.Context) <-chan struct{} {
+	events := make(chan struct{}, 1)
+	if w.cfg.Poll {
+		return events
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err == nil {
+		if err = watcher.Add(w.cfg.In); err != nil {
+			_ = watcher.Close()
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(w.cfg.Log, "no change notifications (%v), polling every %s\n", err, w.cfg.Interval)
+		return events
+	}
+	go func() {
+		defer watcher.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+			case _, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				select {
+				case events <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return events
+}
+
+// isInput tells if name is a csv or parquet file to pick up, not a hidden or temporary one.
+func isInput(name string) bool {
+	if strings.HasPrefix(name, ".") {
+		return false
+	}
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == ".csv" || ext == ".parquet"
+}
+
+// OutputName is the name of the converted file: data.csv becomes data.parquet and data.parquet data.csv.
+func OutputName(name string) string {
+	ext := filepath.Ext(name)
+	out := ".parquet"
+	if strings.EqualFold(ext, ".parquet") {
+		out = ".csv"
+	}
+	return strings.TrimSuffix(name, ext) + out
+}
+
+// uniquePath is path, or path with a timestamp before the extension if it exists.
+func uniquePath(path string) string {
+	if _, err := os.Stat(path); err != nil {
+		return path
+	}
+	ext := filepath.Ext(path)
+	stem := strings.TrimSuffix(path, ext) + "." + time.Now().Format("20060102T150405")
+	unique := stem + ext
+	for i := 1; ; i++ {
+		if _, err := os.Stat(unique); err != nil {
+			return unique
+		}
+		unique = fmt.Sprintf("%s-%d%s", stem, i, ext)
+	}
+}
+
+// moveFile renames src to dst, copying it across file systems.
+func moveFile(src, dst string) (string, error) {
+	if err := os.Rename(src, dst); err == nil {
+		return dst, nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return "", errors.Wrap(err, "error move "+src)
+	}
+	defer func(in *os.File) {
+		_ = in.Close()
+	}(in)
+	out, err := os.Create(dst)
+	if err != nil {
+		return "", errors.Wrap(err, "error move "+src)
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		_ = out.Close()
+		_ = os.Remove(dst)
+		return "", errors.Wrap(err, "error move "+src)
+	}
+	if err = out.Close(); err != nil {
+		_ = os.Remove(dst)
+		return "", errors.Wrap(err, "error move "+src)
+	}
+	return dst, errors.Wrap(os.Remove(src), "error move "+src)
+}
@@ -0,0 +1,249 @@
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type dirs struct {
+	in, out, archive, failed string
+}
+
+func newDirs(t *testing.T) dirs {
+	t.Helper()
+	root := t.TempDir()
+	d := dirs{
+		in:      filepath.Join(root, "inbox"),
+		out:     filepath.Join(root, "processed"),
+		archive: filepath.Join(root, "done"),
+		failed:  filepath.Join(root, "failed"),
+	}
+	if err := os.Mkdir(d.in, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func (d dirs) config(results *[]Result) Config {
+	var mu sync.Mutex
+	return Config{
+		In: d.in, Out: d.out, Archive: d.archive, Failed: d.failed,
+		Settle:   20 * time.Millisecond,
+		Interval: 5 * time.Millisecond,
+		// the conversion is a copy, failing for files named bad
+		Convert: func(_ context.Context, input, output string) error {
+			if strings.HasPrefix(filepath.Base(input), "bad") {
+				if err := os.WriteFile(output, []byte("partial"), 0o600); err != nil {
+					return err
+				}
+				return errors.New("bad input")
+			}
+			data, err := os.ReadFile(input)
+			if err != nil {
+				return err
+			}
+			return os.WriteFile(output, data, 0o600)
+		},
+		Done: func(r Result) {
+			mu.Lock()
+			defer mu.Unlock()
+			*results = append(*results, r)
+		},
+	}
+}
+
+func write(t *testing.T, dir, name, data string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func list(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func equal(a, b []string) bool {
+	return strings.Join(a, "|") == strings.Join(b, "|")
+}
+
+func TestOnce(t *testing.T) {
+	d := newDirs(t)
+	write(t, d.in, "a.csv", "id\n1\n")
+	write(t, d.in, "b.parquet", "PAR1")
+	write(t, d.in, "b.parquet.done", "")
+	write(t, d.in, "bad.csv", "x")
+	write(t, d.in, "notes.txt", "x")
+	write(t, d.in, ".upload.csv", "x")
+	var results []Result
+	if err := New(d.config(&results)).Once(context.Background()); err != nil {
+		t.Fatalf("Once() error = %v", err)
+	}
+
+	if got, want := list(t, d.in), []string{".upload.csv", "notes.txt"}; !equal(got, want) {
+		t.Errorf("inbox = %v; want %v", got, want)
+	}
+	if got, want := list(t, d.out), []string{"a.parquet", "b.csv"}; !equal(got, want) {
+		t.Errorf("out = %v; want %v", got, want)
+	}
+	if got, want := list(t, d.archive), []string{"a.csv", "b.parquet"}; !equal(got, want) {
+		t.Errorf("archive = %v; want %v", got, want)
+	}
+	if got, want := list(t, d.failed), []string{"bad.csv"}; !equal(got, want) {
+		t.Errorf("failed = %v; want %v", got, want)
+	}
+	if data, _ := os.ReadFile(filepath.Join(d.out, "a.parquet")); string(data) != "id\n1\n" {
+		t.Errorf("a.parquet = %q", data)
+	}
+	if len(results) != 3 {
+		t.Fatalf("results = %+v; want 3", results)
+	}
+	for _, r := range results {
+		switch {
+		case r.Name == "bad.csv" && (r.Err == nil || r.Output != "" || r.Original != filepath.Join(d.failed, "bad.csv")):
+			t.Errorf("failed result = %+v", r)
+		case r.Name != "bad.csv" && (r.Err != nil || r.Output == "" || filepath.Dir(r.Original) != d.archive):
+			t.Errorf("result = %+v", r)
+		}
+	}
+
+	// a second file of the same name keeps the first one in the archive
+	write(t, d.in, "a.csv", "id\n2\n")
+	if err := New(d.config(&results)).Once(context.Background()); err != nil {
+		t.Fatalf("Once() error = %v", err)
+	}
+	if got := list(t, d.archive); len(got) != 3 || !strings.HasPrefix(got[0], "a.20") || got[1] != "a.csv" {
+		t.Errorf("archive after a second a.csv = %v", got)
+	}
+	if got := list(t, d.out); len(got) != 3 {
+		t.Errorf("out after a second a.csv = %v", got)
+	}
+}
+
+func TestScanSettle(t *testing.T) {
+	d := newDirs(t)
+	var results []Result
+	cfg := d.config(&results)
+	cfg.Settle = time.Hour
+	w := New(cfg)
+	if err := w.mkdirs(); err != nil {
+		t.Fatal(err)
+	}
+
+	write(t, d.in, "a.csv", "id\n")
+	for range 2 {
+		if pending, err := w.Scan(context.Background()); err != nil || pending != 1 || len(results) != 0 {
+			t.Fatalf("Scan() of a settling file = %d, %v, results %v", pending, err, results)
+		}
+	}
+	write(t, d.in, "a.csv.done", "")
+	if pending, err := w.Scan(context.Background()); err != nil || pending != 0 || len(results) != 1 {
+		t.Errorf("Scan() with a marker = %d, %v, results %v", pending, err, results)
+	}
+	if got := list(t, d.in); len(got) != 0 {
+		t.Errorf("inbox = %v; want the file and its marker moved", got)
+	}
+}
+
+func TestRun(t *testing.T) {
+	for _, poll := range []bool{false, true} {
+		d := newDirs(t)
+		var (
+			mu      sync.Mutex
+			results []Result
+		)
+		cfg := d.config(&results)
+		cfg.Settle = 0
+		cfg.Poll = poll
+		if !poll {
+			// only a change notification can trigger the scan in time
+			cfg.Interval = time.Hour
+		}
+		done := cfg.Done
+		cfg.Done = func(r Result) {
+			mu.Lock()
+			defer mu.Unlock()
+			done(r)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		stopped := make(chan error)
+		go func() {
+			stopped <- New(cfg).Run(ctx)
+		}()
+
+		time.Sleep(50 * time.Millisecond)
+		write(t, d.in, "a.csv", "id\n")
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			mu.Lock()
+			n := len(results)
+			mu.Unlock()
+			if n > 0 {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		cancel()
+		if err := <-stopped; err != nil {
+			t.Errorf("Run(poll %t) error = %v", poll, err)
+		}
+		if got := list(t, d.out); !equal(got, []string{"a.parquet"}) {
+			t.Errorf("Run(poll %t) out = %v", poll, got)
+		}
+	}
+}
+
+func TestStop(t *testing.T) {
+	d := newDirs(t)
+	var results []Result
+	cfg := d.config(&results)
+	ctx, cancel := context.WithCancel(context.Background())
+	cfg.Convert = func(ctx context.Context, _, output string) error {
+		cancel()
+		if err := os.WriteFile(output, []byte("partial"), 0o600); err != nil {
+			return err
+		}
+		return ctx.Err()
+	}
+	write(t, d.in, "a.csv", "id\n")
+	if err := New(cfg).Once(ctx); err != nil {
+		t.Fatalf("Once() error = %v", err)
+	}
+	if len(results) != 0 || !equal(list(t, d.in), []string{"a.csv"}) || len(list(t, d.out)) != 0 {
+		t.Errorf("stopped conversion: results %v, inbox %v, out %v; want the input left alone",
+			results, list(t, d.in), list(t, d.out))
+	}
+}
+
+func TestOutputName(t *testing.T) {
+	tests := map[string]string{
+		"a.csv":          "a.parquet",
+		"a.CSV":          "a.parquet",
+		"a.b.parquet":    "a.b.csv",
+		"data.PARQUET":   "data.csv",
+		"2024-01-01.csv": "2024-01-01.parquet",
+	}
+	for name, want := range tests {
+		if got := OutputName(name); got != want {
+			t.Errorf("OutputName(%q) = %q; want %q", name, got, want)
+		}
+	}
+}
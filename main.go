@@ -2,6 +2,12 @@ package main
 
 import "github.com/dbunt1tled/parquet2csv/cmd"
 
+var (
+	version = "dev"
+	commit  = "none"
+	date    = "unknown"
+)
+
 func main() {
-	cmd.Execute()
-}
\ No newline at end of file
+	cmd.Execute(version, commit, date)
+}
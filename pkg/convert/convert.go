@@ -0,0 +1,284 @@
+// Package convert converts between csv and parquet and from parquet to json, sql, avro and arrow
+// on plain readers and writers, it is the engine behind the csv2parquet conversion commands.
+//
+//	f, _ := os.Open("data.csv")
+//	var out bytes.Buffer
+//	stats, err := convert.CSVToParquet(ctx, f, &out, convert.Options{
+//		Delimiter:   ";",
+//		Schema:      []convert.Column{{Name: "amount", Type: "decimal(9,2)"}},
+//		Compression: convert.Zstd,
+//	})
+package convert
+
+import (
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/dbunt1tled/parquet2csv/internal/file"
+	"github.com/pkg/errors"
+)
+
+// Compression is the parquet compression codec.
+type Compression int32
+
+const (
+	Uncompressed Compression = iota
+	Snappy
+	Gzip
+	LZO
+	Brotli
+	LZ4
+	Zstd
+)
+
+// Dialect is the csv flavour besides the delimiter, the zero value is RFC 4180 in utf-8 with a header.
+// Quote is '"' when zero, Escape zero means a quote inside a quoted field is doubled.
+type Dialect struct {
+	Quote  rune
+	Escape rune
+	// Encoding is a WHATWG character set like windows-1251, utf-8 when empty,
+	// "auto" detects utf-16 and utf-8 by the byte order mark.
+	Encoding string
+	// input, FieldsPerRecord -1 allows rows of any length
+	Comment          rune
+	LazyQuotes       bool
+	TrimLeadingSpace bool
+	FieldsPerRecord  int
+	// NoHeader means the first row is data, the columns are named column_1, column_2 and so on.
+	NoHeader bool
+	// output
+	QuoteMode string
+	CRLF      bool
+	BOM       bool
+}
+
+func (d Dialect) csv() file.CSVDialect {
+	return file.CSVDialect{
+		Quote:            d.Quote,
+		Escape:           d.Escape,
+		Encoding:         d.Encoding,
+		Comment:          d.Comment,
+		LazyQuotes:       d.LazyQuotes,
+		TrimLeadingSpace: d.TrimLeadingSpace,
+		FieldsPerRecord:  d.FieldsPerRecord,
+		NoHeader:         d.NoHeader,
+		QuoteMode:        d.QuoteMode,
+		CRLF:             d.CRLF,
+		BOM:              d.BOM,
+	}
+}
+
+// Quote styles of Dialect.QuoteMode for csv output.
+const (
+	QuoteMinimal = "minimal"
+	QuoteAll     = "all"
+	QuoteNever   = "never"
+)
+
+// BadRow is an input row that doesn't fit the header or the schema, Raw is its text.
+type BadRow struct {
+	Line int
+	Raw  string
+	Err  error
+}
+
+func (r BadRow) Error() string {
+	return "line " + strconv.Itoa(r.Line) + ": " + r.Err.Error()
+}
+
+// Column gives a csv column a parquet type: string, int, double, boolean, date (YYYYMMDD or
+// YYYY-MM-DD) or decimal(p,s) with p up to 18. Typed columns are optional, empty values are null.
+type Column struct {
+	Name string
+	Type string
+}
+
+// FixedWidthColumn is a column of fixed-width input, Start is its 1-based character position
+// and Type a Column type. Trim is right (the default for strings), left, both or none,
+// values of other types are always trimmed.
+type FixedWidthColumn struct {
+	Name   string
+	Start  int
+	Length int
+	Type   string
+	Trim   string
+}
+
+// LoadLayout reads the columns of a fixed-width layout json file, an array of
+// {"name": "amount", "start": 16, "length": 9, "type": "decimal(9,2)", "trim": "both"}.
+func LoadLayout(path string) ([]FixedWidthColumn, error) {
+	specs, err := file.ReadFixedWidthSpecs(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = file.NewFixedWidthLayout(specs); err != nil {
+		return nil, errors.Wrap(err, "layout "+path)
+	}
+	columns := make([]FixedWidthColumn, len(specs))
+	for i, s := range specs {
+		columns[i] = FixedWidthColumn{Name: s.Name, Start: s.Start, Length: s.Length, Type: s.Type, Trim: s.Trim}
+	}
+	return columns, nil
+}
+
+// Position is a point of the input right after a batch of rows: the byte offset and line the batch
+// ends at, the number of records read up to there, a csv header included, and the batch number.
+type Position struct {
+	Offset int64
+	Line   int
+	Row    int
+	Batch  int
+}
+
+// Part is an output part of a conversion with Options.PartRows, written in full.
+type Part struct {
+	// Position is right after the last row of the part.
+	Position Position
+	Rows     int64
+	// Checksum is that of the rows of the part, with Options.Verify only.
+	Checksum string
+}
+
+// Partition is the output of the rows of a value of Options.PartitionBy, written in full.
+type Partition struct {
+	Value string
+	Rows  int64
+	// Checksum is that of the rows of the partition, with Options.Verify only.
+	Checksum string
+}
+
+// Resume is where a conversion written in parts goes on.
+type Resume struct {
+	// Position is that of the last part written.
+	Position
+	// Header are the columns of csv input, the header row is before Position.
+	Header []string
+}
+
+// Field is a leaf column of the parquet side of a conversion.
+type Field struct {
+	Name          string
+	Type          string
+	ConvertedType string
+}
+
+// Timings is the time spent per phase of a conversion.
+type Timings struct {
+	Read    time.Duration
+	Convert time.Duration
+	Write   time.Duration
+	Flush   time.Duration
+}
+
+// Stats are the running totals of a conversion.
+type Stats struct {
+	RowsRead     int64
+	RowsWritten  int64
+	RowsRejected int64
+	// RowsFiltered are the rows read that Options.Filter dropped
+	RowsFiltered int64
+	// RowsTotal is the row count of a parquet input, 0 for csv
+	RowsTotal int64
+	BytesRead int64
+	// Queued is the number of batches read ahead of the one being converted
+	Queued  int
+	Header  []string
+	Schema  []Field
+	Timings Timings
+	// Checksum is the hex sha-256 of the rows written in order, with Options.Verify and without
+	// Options.PartRows and PartitionBy only, parts and partitions have their own.
+	// VerifyParquet and VerifyCSV check an output against it.
+	Checksum string
+	// Partitions are the outputs of Options.PartitionBy in the order of their first rows.
+	Partitions []Partition
+}
+
+// Hooks are called during a conversion, an error returned by one stops it.
+type Hooks struct {
+	// Start is called once the columns are known, before any row is written.
+	Start func(Stats) error
+	// BatchRead is called when a batch of rows has been read.
+	BatchRead func(Stats)
+	// BatchWritten is called when a batch of rows has been written.
+	BatchWritten func(Stats)
+	// BadRow is called with rows that don't fit the header or the schema, they are skipped
+	// unless it returns an error. Without it the first bad row fails the conversion.
+	BadRow func(BadRow) error
+	// Part returns the writer of the next output part with Options.PartRows.
+	Part func() (io.Writer, error)
+	// PartDone is called when a part is written in full, before the next one is started.
+	PartDone func(Part) error
+	// Partition returns the writer of the rows of a value of Options.PartitionBy, once per value.
+	Partition func(value string) (io.Writer, error)
+}
+
+// Options configure a conversion, the zero value converts comma separated utf-8 csv
+// with a header to uncompressed parquet with string columns.
+type Options struct {
+	// Delimiter is one or more characters, "," when empty. ParseDelimiter reads specs like tab or \t.
+	Delimiter string
+	Dialect   Dialect
+	// Schema types csv columns by header name, the other columns stay strings.
+	Schema      []Column
+	Compression Compression
+	// BatchSize is the number of rows read, converted and reported at a time.
+	BatchSize int
+	// FlushRows is the number of rows between parquet page flushes, or csv writer flushes.
+	FlushRows int
+	// RowGroupSize is the parquet row group size in bytes, 128 MB when zero.
+	RowGroupSize int64
+	// Verify sums the rows given to the writer into Stats.Checksum, or into Part.Checksum of every
+	// part with PartRows and Partition.Checksum of every partition with PartitionBy.
+	Verify bool
+	// Layout reads the input as fixed-width text of these columns instead of csv, lines that don't
+	// fit it are bad rows. Of the dialect only the encoding applies, Schema must be empty.
+	Layout []FixedWidthColumn
+	// PartRows splits the parquet output into parts of about PartRows rows: a part ends with the
+	// batch its PartRows-th row is in, since a conversion resumes after whole batches, so it holds
+	// up to PartRows+BatchSize-1 rows. The parts are written to the writers of Hooks.Part instead
+	// of w, a conversion that isn't resumed writes one at least.
+	PartRows int
+	// Resume goes on with a conversion after Resume.Position, r is read from its offset on.
+	Resume *Resume
+	// Filter keeps the rows all of these filters keep, by the text of their fields.
+	Filter []Filter
+	// PartitionBy writes the parquet rows of every value of this column to its own writer of
+	// Hooks.Partition instead of w, the column stays in the rows. All of them are open until the
+	// conversion ends, it suits columns of a few values. PartRows must be 0.
+	PartitionBy string
+	Hooks       Hooks
+}
+
+// ParseDelimiter turns a delimiter spec into the delimiter: a name like tab or pipe,
+// an escape like \t, \x1f or \u00a6, or the characters themselves such as ¦ or ~|~.
+func ParseDelimiter(spec string) (string, error) {
+	return file.ParseDelimiter(spec)
+}
+
+const defaultRowGroupSize = 128 * 1024 * 1024
+
+// layout is the fixed-width layout of Layout.
+func (o Options) layout() (*file.FixedWidthLayout, error) {
+	specs := make([]file.FixedWidthSpec, len(o.Layout))
+	for i, c := range o.Layout {
+		specs[i] = file.FixedWidthSpec{Name: c.Name, Start: c.Start, Length: c.Length, Type: c.Type, Trim: c.Trim}
+	}
+	return file.NewFixedWidthLayout(specs)
+}
+
+func (o Options) withDefaults() Options {
+	if o.Delimiter == "" {
+		o.Delimiter = ","
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = file.FlushCount
+	}
+	if o.FlushRows <= 0 {
+		o.FlushRows = file.FlushCount
+	}
+	if o.RowGroupSize <= 0 {
+		o.RowGroupSize = defaultRowGroupSize
+	}
+	return o
+}
@@ -0,0 +1,403 @@
+package convert
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		opts Options
+		want string
+	}{
+		{
+			name: "default",
+			in:   "id,name\n1,a\n2,\"b,c\"\n",
+			want: "id,name\n1,a\n2,\"b,c\"\n",
+		},
+		{
+			name: "multi-character delimiter",
+			in:   "id~|~name\n1~|~a|b\n",
+			opts: Options{Delimiter: "~|~"},
+			want: "id~|~name\n1~|~a|b\n",
+		},
+		{
+			name: "no header",
+			in:   "1,a\n2,b\n",
+			opts: Options{Dialect: Dialect{NoHeader: true}},
+			want: "column_1,column_2\n1,a\n2,b\n",
+		},
+		{
+			name: "typed schema",
+			in:   "id,amount,day\n1,10.50,2024-01-02\n2,,20240103\n",
+			opts: Options{Schema: []Column{{Name: "amount", Type: "decimal(9,2)"}, {Name: "day", Type: "date"}}},
+			want: "id,amount,day\n1,10.50,2024-01-02\n2,,2024-01-03\n",
+		},
+		{
+			name: "fixed width",
+			in:   "  1a      10.50\n 22bc     -1.00\n",
+			opts: Options{Layout: []FixedWidthColumn{
+				{Name: "id", Start: 1, Length: 3, Type: "int"},
+				{Name: "name", Start: 4, Length: 6},
+				{Name: "amount", Start: 10, Length: 6, Type: "decimal(5,2)"},
+			}},
+			want: "id,name,amount\n1,a,10.50\n22,bc,-1.00\n",
+		},
+		{
+			name: "small batches",
+			in:   "id\n1\n2\n3\n4\n5\n",
+			opts: Options{BatchSize: 2, FlushRows: 3},
+			want: "id\n1\n2\n3\n4\n5\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pq, out bytes.Buffer
+			stats, err := CSVToParquet(context.Background(), strings.NewReader(tt.in), &pq, tt.opts)
+			if err != nil {
+				t.Fatalf("CSVToParquet() error = %v", err)
+			}
+			if stats.RowsRead != stats.RowsWritten || stats.RowsRejected != 0 {
+				t.Errorf("CSVToParquet() stats = %+v", stats)
+			}
+			opts := Options{Delimiter: tt.opts.Delimiter, BatchSize: tt.opts.BatchSize}
+			back, err := ParquetToCSV(context.Background(), bytes.NewReader(pq.Bytes()), &out, opts)
+			if err != nil {
+				t.Fatalf("ParquetToCSV() error = %v", err)
+			}
+			if back.RowsTotal != stats.RowsWritten || back.RowsWritten != stats.RowsWritten {
+				t.Errorf("ParquetToCSV() stats = %+v, want %d rows", back, stats.RowsWritten)
+			}
+			if out.String() != tt.want {
+				t.Errorf("round trip = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestCSVToParquetParts(t *testing.T) {
+	in := "id\n1\n2\n3\n4\n5\n"
+	convertParts := func(r *strings.Reader, resume *Resume) ([]Part, []string) {
+		t.Helper()
+		var (
+			parts []Part
+			out   []*bytes.Buffer
+		)
+		opts := Options{BatchSize: 2, PartRows: 2, Resume: resume}
+		opts.Hooks.Part = func() (io.Writer, error) {
+			out = append(out, &bytes.Buffer{})
+			return out[len(out)-1], nil
+		}
+		opts.Hooks.PartDone = func(p Part) error {
+			parts = append(parts, p)
+			return nil
+		}
+		if _, err := CSVToParquet(context.Background(), r, nil, opts); err != nil {
+			t.Fatalf("CSVToParquet() error = %v", err)
+		}
+		csv := make([]string, len(out))
+		for i, pq := range out {
+			var b bytes.Buffer
+			if _, err := ParquetToCSV(context.Background(), bytes.NewReader(pq.Bytes()), &b, Options{}); err != nil {
+				t.Fatalf("ParquetToCSV() of part %d error = %v", i, err)
+			}
+			csv[i] = b.String()
+		}
+		return parts, csv
+	}
+
+	// a part ends with the batch its PartRows-th row is in
+	parts, csv := convertParts(strings.NewReader(in), nil)
+	want := []Part{{Position: Position{Offset: 9, Line: 4, Row: 4, Batch: 1}, Rows: 3}, {Position: Position{Offset: 13, Line: 6, Row: 6, Batch: 2}, Rows: 2}}
+	if !reflect.DeepEqual(parts, want) || strings.Join(csv, "|") != "id\n1\n2\n3\n|id\n4\n5\n" {
+		t.Fatalf("parts = %+v %q; want %+v", parts, csv, want)
+	}
+
+	r := strings.NewReader(in)
+	if _, err := r.Seek(parts[0].Position.Offset, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	resumed, csv := convertParts(r, &Resume{Position: parts[0].Position, Header: []string{"id"}})
+	if !reflect.DeepEqual(resumed, want[1:]) || strings.Join(csv, "|") != "id\n4\n5\n" {
+		t.Errorf("resumed parts = %+v %q; want %+v", resumed, csv, want[1:])
+	}
+
+	// nothing left after the last part, no part is added
+	if _, err := r.Seek(0, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	if resumed, _ = convertParts(r, &Resume{Position: parts[1].Position, Header: []string{"id"}}); len(resumed) != 0 {
+		t.Errorf("resumed at the end parts = %+v; want none", resumed)
+	}
+}
+
+func TestCSVToParquetPartsVerify(t *testing.T) {
+	var (
+		parts []Part
+		out   []*bytes.Buffer
+	)
+	opts := Options{BatchSize: 2, PartRows: 2, Verify: true}
+	opts.Hooks.Part = func() (io.Writer, error) {
+		out = append(out, &bytes.Buffer{})
+		return out[len(out)-1], nil
+	}
+	opts.Hooks.PartDone = func(p Part) error {
+		parts = append(parts, p)
+		return nil
+	}
+	ctx := context.Background()
+	if _, err := CSVToParquet(ctx, strings.NewReader("id\n1\n2\n3\n4\n5\n"), nil, opts); err != nil {
+		t.Fatalf("CSVToParquet() error = %v", err)
+	}
+	if len(parts) != 2 || parts[0].Checksum == parts[1].Checksum {
+		t.Fatalf("parts = %+v; want 2 with checksums of their own", parts)
+	}
+	// every part holds the rows of its own checksum
+	for i, p := range parts {
+		stats := Stats{RowsWritten: p.Rows, Checksum: p.Checksum}
+		if err := VerifyParquet(ctx, bytes.NewReader(out[i].Bytes()), Options{}, stats); err != nil {
+			t.Errorf("VerifyParquet() of part %d error = %v", i, err)
+		}
+	}
+}
+
+func TestCSVToParquetBadRows(t *testing.T) {
+	in := "id,amount\n1,1.5\n2,abc\n3,2,x\n4,3\n"
+	opts := Options{
+		Dialect: Dialect{FieldsPerRecord: -1},
+		Schema:  []Column{{Name: "amount", Type: "double"}},
+	}
+
+	var bad []BadRow
+	opts.Hooks.BadRow = func(row BadRow) error {
+		bad = append(bad, row)
+		return nil
+	}
+	stats, err := CSVToParquet(context.Background(), strings.NewReader(in), &bytes.Buffer{}, opts)
+	if err != nil {
+		t.Fatalf("CSVToParquet() error = %v", err)
+	}
+	if stats.RowsRead != 4 || stats.RowsWritten != 2 || stats.RowsRejected != 2 {
+		t.Errorf("CSVToParquet() stats = %+v", stats)
+	}
+	if len(bad) != 2 || bad[0].Line != 3 || bad[0].Raw != "2,abc" || bad[1].Line != 4 || bad[1].Raw != "3,2,x" {
+		t.Errorf("bad rows = %+v", bad)
+	}
+
+	opts.Hooks.BadRow = nil
+	_, err = CSVToParquet(context.Background(), strings.NewReader(in), &bytes.Buffer{}, opts)
+	var row BadRow
+	if !errors.As(err, &row) || row.Line != 3 {
+		t.Errorf("CSVToParquet() without hook error = %v, want the bad row of line 3", err)
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Filter
+		err  string
+	}{
+		{in: "region=EU", want: Filter{Column: "region", Op: "=", Value: "EU"}},
+		{in: "amount >= 100", want: Filter{Column: "amount", Op: ">=", Value: " 100"}},
+		{in: "note!=", want: Filter{Column: "note", Op: "!=", Value: ""}},
+		{in: "a<b=c", want: Filter{Column: "a", Op: "<", Value: "b=c"}},
+		{in: "=EU", err: "has no column"},
+		{in: "region", err: "has no operator"},
+	}
+	for _, tt := range tests {
+		got, err := ParseFilter(tt.in)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("ParseFilter(%q) error = %v, want %q", tt.in, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseFilter(%q) = %+v, %v; want %+v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	in := "id,region,amount\n1,EU,9.5\n2,US,100\n3,EU,20\n4,,abc\n"
+	tests := []struct {
+		name    string
+		filters []string
+		want    string
+	}{
+		{name: "equal", filters: []string{"region=EU"}, want: "id,region,amount\n1,EU,9.5\n3,EU,20\n"},
+		{name: "numbers", filters: []string{"amount>=20"}, want: "id,region,amount\n2,US,100\n3,EU,20\n"},
+		{name: "all of them", filters: []string{"region!=US", "amount<10"}, want: "id,region,amount\n1,EU,9.5\n"},
+		{name: "empty field", filters: []string{"region="}, want: "id,region,amount\n4,,abc\n"},
+		{name: "text", filters: []string{"amount>a"}, want: "id,region,amount\n4,,abc\n"},
+	}
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts Options
+			for _, s := range tt.filters {
+				f, err := ParseFilter(s)
+				if err != nil {
+					t.Fatal(err)
+				}
+				opts.Filter = append(opts.Filter, f)
+			}
+			var pq, out bytes.Buffer
+			stats, err := CSVToParquet(ctx, strings.NewReader(in), &pq, opts)
+			if err != nil {
+				t.Fatalf("CSVToParquet() error = %v", err)
+			}
+			if stats.RowsRead != 4 || stats.RowsFiltered != 4-stats.RowsWritten {
+				t.Errorf("CSVToParquet() stats = %+v", stats)
+			}
+			if _, err = ParquetToCSV(ctx, bytes.NewReader(pq.Bytes()), &out, Options{}); err != nil {
+				t.Fatalf("ParquetToCSV() error = %v", err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("filtered csv = %q; want %q", out.String(), tt.want)
+			}
+
+			// the same filters on the way back
+			pq.Reset()
+			out.Reset()
+			if _, err = CSVToParquet(ctx, strings.NewReader(in), &pq, Options{}); err != nil {
+				t.Fatalf("CSVToParquet() error = %v", err)
+			}
+			stats, err = ParquetToCSV(ctx, bytes.NewReader(pq.Bytes()), &out, opts)
+			if err != nil {
+				t.Fatalf("ParquetToCSV() error = %v", err)
+			}
+			if out.String() != tt.want || stats.RowsFiltered != 4-stats.RowsWritten {
+				t.Errorf("ParquetToCSV() = %q, %+v; want %q", out.String(), stats, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSVToParquetPartitions(t *testing.T) {
+	in := "id,region\n1,EU\n2,US\n3,EU\n4,\n5,US\n"
+	out := map[string]*bytes.Buffer{}
+	opts := Options{PartitionBy: "region", Verify: true, Filter: []Filter{{Column: "id", Op: "!=", Value: "5"}}}
+	opts.Hooks.Partition = func(value string) (io.Writer, error) {
+		if out[value] != nil {
+			t.Errorf("Partition(%q) called twice", value)
+		}
+		out[value] = &bytes.Buffer{}
+		return out[value], nil
+	}
+	ctx := context.Background()
+	stats, err := CSVToParquet(ctx, strings.NewReader(in), nil, opts)
+	if err != nil {
+		t.Fatalf("CSVToParquet() error = %v", err)
+	}
+	var values []string
+	for _, p := range stats.Partitions {
+		values = append(values, p.Value)
+		if err = VerifyParquet(ctx, bytes.NewReader(out[p.Value].Bytes()), Options{}, Stats{RowsWritten: p.Rows, Checksum: p.Checksum}); err != nil {
+			t.Errorf("VerifyParquet() of partition %q error = %v", p.Value, err)
+		}
+	}
+	if !reflect.DeepEqual(values, []string{"EU", "US", ""}) || stats.RowsWritten != 4 {
+		t.Fatalf("partitions = %+v, %d rows; want EU, US and empty of 4 rows", stats.Partitions, stats.RowsWritten)
+	}
+	want := map[string]string{"EU": "id,region\n1,EU\n3,EU\n", "US": "id,region\n2,US\n", "": "id,region\n4,\n"}
+	for value, csv := range want {
+		var b bytes.Buffer
+		if _, err = ParquetToCSV(ctx, bytes.NewReader(out[value].Bytes()), &b, Options{}); err != nil {
+			t.Fatalf("ParquetToCSV() of partition %q error = %v", value, err)
+		}
+		if b.String() != csv {
+			t.Errorf("partition %q = %q; want %q", value, b.String(), csv)
+		}
+	}
+}
+
+func TestCSVToParquetErrors(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name string
+		ctx  context.Context //nolint:containedctx // test input
+		in   string
+		opts Options
+		want string
+	}{
+		{
+			name: "empty",
+			ctx:  context.Background(),
+			want: "csv file is empty",
+		},
+		{
+			name: "unknown schema column",
+			ctx:  context.Background(),
+			in:   "id\n1\n",
+			opts: Options{Schema: []Column{{Name: "price", Type: "int"}}},
+			want: "schema column price is not in the header",
+		},
+		{
+			name: "bad schema type",
+			ctx:  context.Background(),
+			in:   "id\n1\n",
+			opts: Options{Schema: []Column{{Name: "id", Type: "uuid"}}},
+			want: "schema column id",
+		},
+		{
+			name: "bad dialect",
+			ctx:  context.Background(),
+			in:   "id\n1\n",
+			opts: Options{Dialect: Dialect{Quote: ','}},
+			want: "quote",
+		},
+		{
+			name: "unknown filter column",
+			ctx:  context.Background(),
+			in:   "id\n1\n",
+			opts: Options{Filter: []Filter{{Column: "price", Op: "=", Value: "1"}}},
+			want: "filter column price is not in the header",
+		},
+		{
+			name: "unknown partition column",
+			ctx:  context.Background(),
+			in:   "id\n1\n",
+			opts: Options{PartitionBy: "region"},
+			want: "partition column region is not in the header",
+		},
+		{
+			name: "partitions in parts",
+			ctx:  context.Background(),
+			in:   "id\n1\n",
+			opts: Options{PartitionBy: "id", PartRows: 1},
+			want: "partitions can't be written in parts",
+		},
+		{
+			name: "canceled",
+			ctx:  canceled,
+			in:   "id\n1\n",
+			want: context.Canceled.Error(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := CSVToParquet(tt.ctx, strings.NewReader(tt.in), &bytes.Buffer{}, tt.opts)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("CSVToParquet() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParquetToCSVNotParquet(t *testing.T) {
+	_, err := ParquetToCSV(context.Background(), strings.NewReader("id\n1\n"), &bytes.Buffer{}, Options{})
+	if err == nil {
+		t.Error("ParquetToCSV() error = nil, want an error for csv input")
+	}
+}
@@ -0,0 +1,470 @@
+package convert
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dbunt1tled/parquet2csv/internal/file"
+	"github.com/dbunt1tled/parquet2csv/internal/report"
+	"github.com/dbunt1tled/parquet2csv/internal/schema"
+	"github.com/pkg/errors"
+	"github.com/xitongsys/parquet-go-source/writerfile"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// CSVToParquet converts csv, or fixed-width text with Options.Layout, read from r to parquet written
+// to w, w is not closed. The stats so far are returned with an error too.
+func CSVToParquet(ctx context.Context, r io.Reader, w io.Writer, opts Options) (Stats, error) {
+	opts = opts.withDefaults()
+	c := &csvToParquet{opts: opts, w: w, delimiter: opts.Delimiter, output: &output{}}
+	if opts.Verify {
+		c.sum = newChecksum()
+	}
+	if opts.PartitionBy != "" && opts.PartRows > 0 {
+		return c.stats, errors.New("partitions can't be written in parts")
+	}
+
+	// the reader stops when the conversion returns early
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		bCh chan file.Batch
+		eCh chan error
+		err error
+	)
+	if len(opts.Layout) > 0 {
+		bCh, eCh, err = c.readFixedWidth(ctx, r)
+	} else {
+		bCh, eCh, c.dialect, err = readCSV(ctx, r, opts)
+	}
+	if err != nil {
+		return c.stats, err
+	}
+	switch {
+	case c.layout != nil:
+		err = c.start(c.layout.Names())
+	case opts.Resume != nil && len(opts.Resume.Header) == 0:
+		err = errors.New("resume needs the header of the input")
+	case opts.Resume != nil:
+		err = c.start(opts.Resume.Header)
+	}
+	if err != nil {
+		return c.stats, err
+	}
+
+	var pos Position
+	if opts.Resume != nil {
+		pos = opts.Resume.Position
+	}
+	readStart := time.Now()
+	for batch := range bCh {
+		c.stats.Timings.Read += time.Since(readStart)
+		if err = ctx.Err(); err != nil {
+			return c.stats, err
+		}
+		c.stats.BytesRead = batch.Offset
+		c.stats.Queued = len(bCh)
+		if opts.Hooks.BatchRead != nil {
+			opts.Hooks.BatchRead(c.stats)
+		}
+		for _, bad := range batch.Rejected {
+			c.stats.RowsRead++
+			if err = c.reject(BadRow{Line: bad.Line, Raw: bad.Raw, Err: bad.Err}); err != nil {
+				return c.stats, err
+			}
+		}
+		for n, record := range batch.Rows {
+			if err = c.row(record, batch.Lines[n]); err != nil {
+				return c.stats, err
+			}
+		}
+		pos = Position{Offset: batch.Offset, Line: batch.Line, Row: batch.Start + len(batch.Rows) - 1, Batch: batch.Id}
+		if opts.PartRows > 0 && c.pw != nil && c.partRows >= int64(opts.PartRows) {
+			if err = c.endPart(pos); err != nil {
+				return c.stats, err
+			}
+		}
+		if opts.Hooks.BatchWritten != nil {
+			opts.Hooks.BatchWritten(c.stats)
+		}
+		readStart = time.Now()
+	}
+	if err = <-eCh; err != nil {
+		return c.stats, errors.Wrap(err, "read error")
+	}
+	if c.header == nil {
+		return c.stats, errors.New("csv file is empty")
+	}
+	if opts.PartitionBy != "" {
+		return c.stats, c.endPartitions()
+	}
+	if opts.PartRows > 0 {
+		// a new conversion of no rows has an empty part, a resumed one has its parts
+		if c.pw == nil && c.parts == 0 && opts.Resume == nil {
+			if err = c.open(); err != nil {
+				return c.stats, err
+			}
+		}
+		if c.pw != nil {
+			err = c.endPart(pos)
+		}
+		return c.stats, err
+	}
+	if c.sum != nil {
+		c.stats.Checksum = c.sum.String()
+	}
+	flushStart := time.Now()
+	err = c.pw.WriteStop()
+	c.stats.Timings.Flush += time.Since(flushStart)
+	return c.stats, errors.Wrap(err, "write stop error")
+}
+
+// readCSV starts reading batches of csv records from r, an auto encoding is told by the byte order mark.
+func readCSV(ctx context.Context, r io.Reader, opts Options) (chan file.Batch, chan error, file.CSVDialect, error) {
+	dialect := opts.Dialect.csv()
+	r, dialect.Encoding = inputEncoding(r, dialect.Encoding)
+	if err := file.CheckEncoding(dialect.Encoding); err != nil {
+		return nil, nil, dialect, err
+	}
+	if err := dialect.Validate(opts.Delimiter); err != nil {
+		return nil, nil, dialect, err
+	}
+	bp := file.NewBatchProcessor("", opts.BatchSize, opts.Delimiter, false).
+		Dialect(dialect).Source(r).Context(ctx)
+	if p := opts.Resume; p != nil {
+		bp.Resume(p.Offset, p.Line, p.Row, p.Batch+1)
+	}
+	bCh, eCh := bp.Reader()
+	return bCh, eCh, dialect, nil
+}
+
+// readFixedWidth starts reading batches of fixed-width records from r, lines that don't fit
+// the layout are rejected.
+func (c *csvToParquet) readFixedWidth(ctx context.Context, r io.Reader) (chan file.Batch, chan error, error) {
+	if len(c.opts.Schema) > 0 {
+		return nil, nil, errors.New("the layout columns have their types, a schema is for csv input")
+	}
+	layout, err := c.opts.layout()
+	if err != nil {
+		return nil, nil, err
+	}
+	c.layout = layout
+	var encoding string
+	r, encoding = inputEncoding(r, c.opts.Dialect.Encoding)
+	if err = file.CheckEncoding(encoding); err != nil {
+		return nil, nil, err
+	}
+	fp := file.NewFixedWidthProcessor("", layout, c.opts.BatchSize).Encoding(encoding).Source(r).Context(ctx)
+	if p := c.opts.Resume; p != nil {
+		fp.Resume(p.Offset, p.Line, p.Row, p.Batch+1)
+	}
+	bCh, eCh := fp.Reader()
+	return bCh, eCh, nil
+}
+
+// inputEncoding tells an auto encoding by the byte order mark at the start of r.
+func inputEncoding(r io.Reader, encoding string) (io.Reader, string) {
+	if !strings.EqualFold(encoding, file.EncodingAuto) {
+		return r, encoding
+	}
+	br := bufio.NewReader(r)
+	bom, _ := br.Peek(2) //nolint:mnd // utf-16 bom length
+	return br, file.BOMEncoding(bom)
+}
+
+type csvToParquet struct {
+	opts       Options
+	w          io.Writer
+	delimiter  string
+	dialect    file.CSVDialect
+	layout     *file.FixedWidthLayout
+	header     []string
+	types      []schema.TextType
+	structType interface{}
+	processor  schema.Processor
+	pool       *sync.Pool
+	filters    []rowFilter
+	partition  int
+	partitions map[string]*output
+	parts      int
+	stats      Stats
+	// output is the one the rows go to, that of the partition of the row with PartitionBy
+	*output
+}
+
+// output is a parquet writer and the rows given to it.
+type output struct {
+	pw        *writer.ParquetWriter
+	sum       *checksum
+	unflushed int
+	partRows  int64
+	value     string
+}
+
+// row writes a csv record, the first one is the header unless the dialect has none.
+func (c *csvToParquet) row(record []string, line int) error {
+	if c.header == nil {
+		header := record
+		if c.dialect.NoHeader {
+			header = file.ColumnNames(len(record))
+		}
+		if err := c.start(header); err != nil {
+			return err
+		}
+		if !c.dialect.NoHeader {
+			return nil
+		}
+	}
+	c.stats.RowsRead++
+	fitted, err := file.FitRecord(record, len(c.header))
+	for i := 0; err == nil && i < len(c.types); i++ {
+		if _, err = c.types[i].Parse(fitted[i]); err != nil {
+			err = errors.Wrap(err, "column "+c.header[i])
+		}
+	}
+	if err != nil {
+		return c.reject(BadRow{Line: line, Raw: file.FormatRecord(record, c.delimiter, c.dialect), Err: err})
+	}
+	if !keep(c.filters, fitted) {
+		c.stats.RowsFiltered++
+		return nil
+	}
+	if c.partitions != nil {
+		if err = c.toPartition(fitted[c.partition]); err != nil {
+			return err
+		}
+	}
+
+	if c.pw == nil {
+		if err = c.open(); err != nil {
+			return err
+		}
+	}
+	if c.sum != nil {
+		c.checksum(fitted)
+	}
+	phaseStart := time.Now()
+	data := c.processor(fitted, c.structType, c.header, c.pool)
+	c.stats.Timings.Convert += time.Since(phaseStart)
+	phaseStart = time.Now()
+	err = c.pw.Write(data)
+	c.stats.Timings.Write += time.Since(phaseStart)
+	if err != nil {
+		return errors.Wrap(err, "write error")
+	}
+	c.stats.RowsWritten++
+	c.partRows++
+	if c.unflushed++; c.unflushed == c.opts.FlushRows {
+		phaseStart = time.Now()
+		err = c.pw.Flush(true)
+		c.stats.Timings.Flush += time.Since(phaseStart)
+		if err != nil {
+			return errors.Wrap(err, "write flush error")
+		}
+		c.unflushed = 0
+	}
+	return nil
+}
+
+// checksum sums a record given to the writer by the values the parquet columns get.
+func (c *csvToParquet) checksum(record []string) {
+	if c.types == nil {
+		c.sum.record(record)
+		return
+	}
+	c.sum.start(len(record))
+	for i, s := range record {
+		v, _ := c.types[i].Parse(s)
+		c.sum.value(reflect.ValueOf(v))
+	}
+}
+
+// start builds the parquet schema of the header and, unless the output is written in parts or
+// partitions, opens the parquet writer.
+func (c *csvToParquet) start(header []string) error {
+	c.header = header
+	switch {
+	case c.layout != nil:
+		c.types = c.layout.Types()
+		c.structType, c.processor = schema.ProcessTyped(header, c.types)
+	case len(c.opts.Schema) > 0:
+		types, err := textTypes(header, c.opts.Schema)
+		if err != nil {
+			return err
+		}
+		c.types = types
+		c.structType, c.processor = schema.ProcessTyped(header, types)
+	default:
+		c.structType, c.processor = schema.ProcessDefault(header)
+		c.pool = &sync.Pool{
+			New: func() interface{} {
+				data := make(map[string]interface{})
+				return &data
+			},
+		}
+	}
+	c.stats.Header = header
+	c.stats.Schema = fields(report.ColumnsFromStruct(c.structType))
+	var err error
+	if c.filters, err = rowFilters(c.opts.Filter, header); err != nil {
+		return err
+	}
+	if c.opts.PartitionBy != "" {
+		if c.partition = slices.Index(header, c.opts.PartitionBy); c.partition < 0 {
+			return errors.New("partition column " + c.opts.PartitionBy + " is not in the header")
+		}
+		c.partitions = map[string]*output{}
+	}
+
+	if c.opts.PartRows == 0 && c.partitions == nil {
+		if err = c.open(); err != nil {
+			return err
+		}
+	}
+	if c.opts.Hooks.Start != nil {
+		return c.opts.Hooks.Start(c.stats)
+	}
+	return nil
+}
+
+// open starts writing parquet to w or, with PartRows, to the next part.
+func (c *csvToParquet) open() error {
+	w := c.w
+	if c.opts.PartRows > 0 {
+		if c.opts.Hooks.Part == nil {
+			return errors.New("the output parts need Hooks.Part")
+		}
+		var err error
+		if w, err = c.opts.Hooks.Part(); err != nil {
+			return err
+		}
+		if c.sum != nil {
+			c.sum = newChecksum()
+		}
+	}
+	c.partRows, c.unflushed = 0, 0
+	return c.writer(w)
+}
+
+func (c *csvToParquet) writer(w io.Writer) error {
+	var err error
+	c.pw, err = writer.NewParquetWriter(writerfile.NewWriterFile(w), c.structType, 2) //nolint:mnd // maybe the number of threads
+	if err != nil {
+		return errors.Wrap(err, "can't create parquet writer")
+	}
+	c.pw.RowGroupSize = c.opts.RowGroupSize
+	c.pw.CompressionType = parquet.CompressionCodec(c.opts.Compression)
+	return nil
+}
+
+// toPartition makes the output of a PartitionBy value the one the rows go to, opening it for a new value.
+func (c *csvToParquet) toPartition(value string) error {
+	if out, ok := c.partitions[value]; ok {
+		c.output = out
+		return nil
+	}
+	if c.opts.Hooks.Partition == nil {
+		return errors.New("the partitions need Hooks.Partition")
+	}
+	w, err := c.opts.Hooks.Partition(value)
+	if err != nil {
+		return err
+	}
+	c.output = &output{value: value}
+	if c.opts.Verify {
+		c.sum = newChecksum()
+	}
+	c.partitions[value] = c.output
+	c.stats.Partitions = append(c.stats.Partitions, Partition{Value: value})
+	return c.writer(w)
+}
+
+// endPartitions completes the output of every PartitionBy value.
+func (c *csvToParquet) endPartitions() error {
+	for i := range c.stats.Partitions {
+		p := &c.stats.Partitions[i]
+		out := c.partitions[p.Value]
+		p.Rows = out.partRows
+		if out.sum != nil {
+			p.Checksum = out.sum.String()
+		}
+		flushStart := time.Now()
+		err := out.pw.WriteStop()
+		c.stats.Timings.Flush += time.Since(flushStart)
+		if err != nil {
+			return errors.Wrap(err, "write stop error")
+		}
+	}
+	return nil
+}
+
+// endPart completes the part being written, pos is right after its last row.
+func (c *csvToParquet) endPart(pos Position) error {
+	flushStart := time.Now()
+	err := c.pw.WriteStop()
+	c.stats.Timings.Flush += time.Since(flushStart)
+	c.pw = nil
+	if err != nil {
+		return errors.Wrap(err, "write stop error")
+	}
+	c.parts++
+	if c.opts.Hooks.PartDone == nil {
+		return nil
+	}
+	part := Part{Position: pos, Rows: c.partRows}
+	if c.sum != nil {
+		part.Checksum = c.sum.String()
+	}
+	return c.opts.Hooks.PartDone(part)
+}
+
+func (c *csvToParquet) reject(bad BadRow) error {
+	if c.opts.Hooks.BadRow == nil {
+		return bad
+	}
+	if err := c.opts.Hooks.BadRow(bad); err != nil {
+		return err
+	}
+	c.stats.RowsRejected++
+	return nil
+}
+
+// textTypes are the types of the header columns, string unless the schema names them.
+func textTypes(header []string, columns []Column) ([]schema.TextType, error) {
+	byName := make(map[string]schema.TextType, len(columns))
+	for _, column := range columns {
+		t, err := schema.ParseTextType(column.Type)
+		if err != nil {
+			return nil, errors.Wrap(err, "schema column "+column.Name)
+		}
+		byName[column.Name] = t
+	}
+	types := make([]schema.TextType, len(header))
+	for i, name := range header {
+		t, ok := byName[name]
+		if !ok {
+			t = schema.TextType{Name: "string"}
+		}
+		types[i] = t
+		delete(byName, name)
+	}
+	for name := range byName {
+		return nil, errors.New("schema column " + name + " is not in the header")
+	}
+	return types, nil
+}
+
+func fields(columns []report.Column) []Field {
+	f := make([]Field, 0, len(columns))
+	for _, c := range columns {
+		f = append(f, Field{Name: c.Name, Type: c.Type, ConvertedType: c.ConvertedType})
+	}
+	return f
+}
@@ -0,0 +1,276 @@
+package convert
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dbunt1tled/parquet2csv/internal/schema"
+	"github.com/pkg/errors"
+)
+
+//nolint:gochecknoglobals // reflect types
+var (
+	timeType  = reflect.TypeFor[time.Time]()
+	bytesType = reflect.TypeFor[[]byte]()
+	textType  = reflect.TypeFor[[]string]()
+	mapType   = reflect.TypeFor[map[string]any]()
+)
+
+// decoder sets the columns of a row on a T: a struct, a pointer to one, map[string]any or []string.
+type decoder[T any] struct {
+	columns []string
+	// fields is the struct field index of every column, nil for columns without a field
+	fields [][]int
+	isMap  bool
+	isPtr  bool
+	// isText rows are the text of the fields, like csv output has them
+	isText bool
+}
+
+// newDecoder maps columns to the fields of T by the tag key, the other tag key, then the field name,
+// names are matched exactly first and case-insensitively after.
+func newDecoder[T any](columns []string, tag string) (*decoder[T], error) {
+	d := &decoder[T]{columns: columns}
+	t := reflect.TypeFor[T]()
+	switch t {
+	case mapType:
+		d.isMap = true
+		return d, nil
+	case textType:
+		d.isText = true
+		return d, nil
+	}
+	if t.Kind() == reflect.Ptr {
+		d.isPtr = true
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, errors.Errorf("unsupported row type %s, expected a struct, map[string]any or []string", reflect.TypeFor[T]())
+	}
+
+	names := make(map[string][]int, t.NumField())
+	folded := make(map[string][]int, t.NumField())
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+		name := fieldTag(f, tag)
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		names[name] = f.Index
+		if _, ok := folded[strings.ToLower(name)]; !ok {
+			folded[strings.ToLower(name)] = f.Index
+		}
+	}
+	d.fields = make([][]int, len(columns))
+	for i, column := range columns {
+		index, ok := names[column]
+		if !ok {
+			index = folded[strings.ToLower(column)]
+		}
+		d.fields[i] = index
+	}
+	return d, nil
+}
+
+// fieldTag is the column name of a field from its tag, parquet-go tags like `parquet:"name=id, type=INT64"` too.
+func fieldTag(f reflect.StructField, tag string) string {
+	other := "csv"
+	if tag == "csv" {
+		other = "parquet"
+	}
+	for _, key := range []string{tag, other} {
+		value, ok := f.Tag.Lookup(key)
+		if !ok {
+			continue
+		}
+		for _, part := range strings.Split(value, ",") {
+			part = strings.TrimSpace(part)
+			if name, ok := strings.CutPrefix(part, "name="); ok {
+				return name
+			}
+		}
+		if name, _, _ := strings.Cut(value, ","); !strings.Contains(name, "=") {
+			return strings.TrimSpace(name)
+		}
+	}
+	return ""
+}
+
+// decode builds a T of the values of a row, in column order.
+func (d *decoder[T]) decode(values []any) (T, error) {
+	var row T
+	if d.isText {
+		record := make([]string, len(values))
+		for i, v := range values {
+			record[i] = schema.Text(v)
+		}
+		reflect.ValueOf(&row).Elem().Set(reflect.ValueOf(record))
+		return row, nil
+	}
+	if d.isMap {
+		m := make(map[string]any, len(values))
+		for i, v := range values {
+			m[d.columns[i]] = v
+		}
+		reflect.ValueOf(&row).Elem().Set(reflect.ValueOf(m))
+		return row, nil
+	}
+	rv := reflect.ValueOf(&row).Elem()
+	if d.isPtr {
+		rv.Set(reflect.New(rv.Type().Elem()))
+		rv = rv.Elem()
+	}
+	for i, v := range values {
+		if d.fields[i] == nil {
+			continue
+		}
+		if err := setValue(rv.FieldByIndex(d.fields[i]), v); err != nil {
+			return row, errors.Wrap(err, "column "+d.columns[i])
+		}
+	}
+	return row, nil
+}
+
+// setValue sets dst to v converted to its type, nil is the zero value and so is an empty
+// string for types other than strings.
+//
+//nolint:cyclop,gocyclo // one case per kind
+func setValue(dst reflect.Value, v any) error {
+	if s, ok := v.(string); v == nil || ok && s == "" && !isText(dst.Type()) {
+		dst.SetZero()
+		return nil
+	}
+	if dst.Kind() == reflect.Ptr {
+		p := reflect.New(dst.Type().Elem())
+		if err := setValue(p.Elem(), v); err != nil {
+			return err
+		}
+		dst.Set(p)
+		return nil
+	}
+	src := reflect.ValueOf(v)
+	if src.Type().AssignableTo(dst.Type()) {
+		dst.Set(src)
+		return nil
+	}
+	s, isString := v.(string)
+	if n, ok := v.(json.Number); ok {
+		s, isString = n.String(), true
+	}
+
+	switch {
+	case dst.Type() == timeType:
+		if !isString {
+			break
+		}
+		t, err := parseTime(s)
+		if err != nil {
+			return err
+		}
+		dst.Set(reflect.ValueOf(t))
+		return nil
+	case dst.Type() == bytesType:
+		dst.SetBytes([]byte(schema.Text(v)))
+		return nil
+	}
+	switch dst.Kind() { //nolint:exhaustive // the other kinds only take assignable values
+	case reflect.String:
+		dst.SetString(schema.Text(v))
+		return nil
+	case reflect.Bool:
+		if !isString {
+			break
+		}
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return errors.Wrap(err, "invalid boolean")
+		}
+		dst.SetBool(b)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		switch {
+		case src.CanInt():
+			i = src.Int()
+		case isString:
+			var err error
+			if i, err = strconv.ParseInt(strings.TrimSpace(s), 10, 64); err != nil {
+				return errors.Wrap(err, "invalid integer")
+			}
+		default:
+			return errors.Errorf("can't set %T to %s", v, dst.Type())
+		}
+		if dst.OverflowInt(i) {
+			return errors.Errorf("%d overflows %s", i, dst.Type())
+		}
+		dst.SetInt(i)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var u uint64
+		switch {
+		case src.CanUint():
+			u = src.Uint()
+		case src.CanInt() && src.Int() >= 0:
+			u = uint64(src.Int())
+		case isString:
+			var err error
+			if u, err = strconv.ParseUint(strings.TrimSpace(s), 10, 64); err != nil {
+				return errors.Wrap(err, "invalid unsigned integer")
+			}
+		default:
+			return errors.Errorf("can't set %T to %s", v, dst.Type())
+		}
+		if dst.OverflowUint(u) {
+			return errors.Errorf("%d overflows %s", u, dst.Type())
+		}
+		dst.SetUint(u)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		switch {
+		case src.CanFloat():
+			dst.SetFloat(src.Float())
+		case src.CanInt():
+			dst.SetFloat(float64(src.Int()))
+		case isString:
+			f, err := strconv.ParseFloat(strings.TrimSpace(s), dst.Type().Bits())
+			if err != nil {
+				return errors.Wrap(err, "invalid number")
+			}
+			dst.SetFloat(f)
+		default:
+			return errors.Errorf("can't set %T to %s", v, dst.Type())
+		}
+		return nil
+	}
+	if src.Type().ConvertibleTo(dst.Type()) && src.Kind() == dst.Kind() {
+		dst.Set(src.Convert(dst.Type()))
+		return nil
+	}
+	return errors.Errorf("can't set %T to %s", v, dst.Type())
+}
+
+func isText(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.String || t.Kind() == reflect.Interface || t == bytesType
+}
+
+// parseTime reads RFC 3339 timestamps and YYYY-MM-DD or YYYYMMDD dates.
+func parseTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02", "20060102", "2006-01-02 15:04:05"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.Errorf("invalid time %q", s)
+}
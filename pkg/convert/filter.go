@@ -0,0 +1,119 @@
+package convert
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// filterOps are the operators of a Filter, the two character ones first.
+var filterOps = []string{"!=", "<=", ">=", "=", "<", ">"} //nolint:gochecknoglobals // operator table
+
+// Filter keeps the rows whose Column compares to Value by Op: =, !=, <, <=, > or >=.
+// A Value that parses as a number compares to fields as numbers, fields that aren't numbers are
+// only != to it. Other values compare as text.
+type Filter struct {
+	Column string
+	Op     string
+	Value  string
+}
+
+// ParseFilter reads a filter like region=EU or amount>=100, the value is the rest after the operator.
+func ParseFilter(s string) (Filter, error) {
+	for i := range len(s) {
+		for _, op := range filterOps {
+			if !strings.HasPrefix(s[i:], op) {
+				continue
+			}
+			f := Filter{Column: strings.TrimSpace(s[:i]), Op: op, Value: s[i+len(op):]}
+			if f.Column == "" {
+				return f, errors.New("filter " + strconv.Quote(s) + " has no column")
+			}
+			return f, nil
+		}
+	}
+	return Filter{}, errors.New("filter " + strconv.Quote(s) + " has no operator, use = != < <= > or >=")
+}
+
+func (f Filter) String() string {
+	return f.Column + f.Op + f.Value
+}
+
+// rowFilter is a Filter on the field of index i of a record.
+type rowFilter struct {
+	Filter
+	i     int
+	num   float64
+	isNum bool
+}
+
+// rowFilters resolves filters by the header, a column that isn't in it is an error.
+func rowFilters(filters []Filter, header []string) ([]rowFilter, error) {
+	out := make([]rowFilter, 0, len(filters))
+	for _, f := range filters {
+		if !slices.Contains(filterOps, f.Op) {
+			return nil, errors.New("filter " + strconv.Quote(f.String()) + " has an unknown operator")
+		}
+		i := slices.Index(header, f.Column)
+		if i < 0 {
+			return nil, errors.New("filter column " + f.Column + " is not in the header")
+		}
+		rf := rowFilter{Filter: f, i: i}
+		rf.num, rf.isNum = number(f.Value)
+		out = append(out, rf)
+	}
+	return out, nil
+}
+
+// keep tells whether a record passes all filters.
+func keep(filters []rowFilter, record []string) bool {
+	for _, f := range filters {
+		var field string
+		if f.i < len(record) {
+			field = record[f.i]
+		}
+		if !f.match(field) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f rowFilter) match(field string) bool {
+	cmp := strings.Compare(field, f.Value)
+	if f.isNum {
+		n, ok := number(field)
+		if !ok {
+			return f.Op == "!="
+		}
+		switch {
+		case n < f.num:
+			cmp = -1
+		case n > f.num:
+			cmp = 1
+		default:
+			cmp = 0
+		}
+	}
+	switch f.Op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+func number(s string) (float64, bool) {
+	n, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return n, err == nil
+}
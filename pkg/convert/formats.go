@@ -0,0 +1,195 @@
+package convert
+
+import (
+	"context"
+	"io"
+	"reflect"
+
+	"github.com/dbunt1tled/parquet2csv/internal/file"
+	"github.com/dbunt1tled/parquet2csv/internal/schema"
+	"github.com/pkg/errors"
+)
+
+// The outputs of parquet input besides csv read it like ParquetToCSV: in place when r allows it,
+// in batches of opts.BatchSize rows and keeping the rows of opts.Filter. Of the other options only
+// FlushRows and Hooks apply, Stats.Checksum stays empty. w is not closed.
+
+// JSONOptions shape the json of ParquetToJSON, an array of objects when zero.
+type JSONOptions struct {
+	// NDJSON writes an object per line instead of an array.
+	NDJSON bool
+	// Pretty indents the array, it can't be set with NDJSON.
+	Pretty bool
+}
+
+// ParquetToJSON converts parquet read from r to json written to w, an object per row with the columns
+// in schema order. Groups are objects and lists arrays.
+func ParquetToJSON(ctx context.Context, r io.Reader, w io.Writer, opts Options, jo JSONOptions) (Stats, error) {
+	if jo.NDJSON && jo.Pretty {
+		return Stats{}, errors.New("pretty json can't be newline-delimited")
+	}
+	return convertParquet(ctx, r, opts.withDefaults(), func(p *parquetInput, _ *Stats) (parquetOutput, error) {
+		return &jsonOutput{p: p, fw: file.NewJSONWriter(w, jo.NDJSON, jo.Pretty)}, nil
+	})
+}
+
+type jsonOutput struct {
+	p  *parquetInput
+	fw *file.JSONWriter
+}
+
+func (o *jsonOutput) convert(_ int64, row any, _ []string) any {
+	return o.p.tree.Value(reflect.ValueOf(row))
+}
+
+func (o *jsonOutput) write(v any) error {
+	return errors.Wrap(o.fw.Write(v), "error write row")
+}
+
+func (o *jsonOutput) close() error {
+	return o.fw.Close()
+}
+
+// SQL dialects of SQLOptions.
+const (
+	SQLPostgres = file.SQLPostgres
+	SQLMySQL    = file.SQLMySQL
+	SQLSQLite   = file.SQLSQLite
+)
+
+// SQLOptions shape the dump of ParquetToSQL.
+type SQLOptions struct {
+	// Dialect is postgres, mysql or sqlite.
+	Dialect string
+	Table   string
+	// Copy writes the rows as a postgres COPY ... FROM stdin block instead of INSERTs.
+	Copy bool
+}
+
+// ParquetToSQL converts parquet read from r to a sql dump written to w: CREATE TABLE with the columns
+// typed for the dialect, then INSERT statements of opts.FlushRows rows or a COPY block.
+func ParquetToSQL(ctx context.Context, r io.Reader, w io.Writer, opts Options, so SQLOptions) (Stats, error) {
+	opts = opts.withDefaults()
+	return convertParquet(ctx, r, opts, func(p *parquetInput, _ *Stats) (parquetOutput, error) {
+		columns := p.tree.SQLColumns()
+		fw, err := file.NewSQLWriter(w, so.Dialect, so.Table, columns, opts.FlushRows, so.Copy)
+		if err != nil {
+			return nil, err
+		}
+		return &sqlOutput{p: p, columns: columns, fw: fw}, nil
+	})
+}
+
+type sqlOutput struct {
+	p       *parquetInput
+	columns []schema.SQLColumn
+	fw      *file.SQLWriter
+}
+
+func (o *sqlOutput) convert(_ int64, row any, _ []string) any {
+	return o.p.tree.SQLRow(row, o.columns)
+}
+
+func (o *sqlOutput) write(v any) error {
+	values, _ := v.([]any)
+	return errors.Wrap(o.fw.Write(values), "error write row")
+}
+
+func (o *sqlOutput) close() error {
+	return errors.Wrap(o.fw.Close(), "error write sql")
+}
+
+// AvroOptions shape the avro of ParquetToAvro.
+type AvroOptions struct {
+	// Codec compresses the blocks: null, deflate or snappy.
+	Codec string
+}
+
+// ParquetToAvro converts parquet read from r to an avro object container file written to w in blocks
+// of opts.FlushRows records. The avro schema stored in the footer by the avro to parquet conversion is used while it
+// still fits the columns, otherwise one is derived from them.
+func ParquetToAvro(ctx context.Context, r io.Reader, w io.Writer, opts Options, ao AvroOptions) (Stats, error) {
+	switch ao.Codec {
+	case "null", "deflate", "snappy":
+	default:
+		return Stats{}, errors.New("unknown avro codec " + ao.Codec + ", use null, deflate or snappy")
+	}
+	opts = opts.withDefaults()
+	return convertParquet(ctx, r, opts, func(p *parquetInput, _ *Stats) (parquetOutput, error) {
+		// a stored schema that no longer matches the columns is ignored
+		records, err := p.tree.AvroRecords(p.metadata(schema.AvroSchemaKey))
+		if err != nil {
+			if records, err = p.tree.AvroRecords(""); err != nil {
+				return nil, err
+			}
+		}
+		fw, err := file.NewAvroWriter(w, records.Schema, ao.Codec, opts.FlushRows)
+		if err != nil {
+			return nil, err
+		}
+		return &avroOutput{records: records, fw: fw}, nil
+	})
+}
+
+type avroOutput struct {
+	records *schema.AvroRecords
+	fw      *file.AvroWriter
+}
+
+func (o *avroOutput) convert(_ int64, row any, _ []string) any {
+	return o.records.Record(row)
+}
+
+func (o *avroOutput) write(v any) error {
+	return errors.Wrap(o.fw.Write(v), "error write row")
+}
+
+func (o *avroOutput) close() error {
+	return errors.Wrap(o.fw.Close(), "error write avro block")
+}
+
+// ArrowOptions shape the arrow of ParquetToArrow.
+type ArrowOptions struct {
+	// Stream writes the arrow ipc stream format instead of the file format (feather v2).
+	Stream bool
+}
+
+// ParquetToArrow converts parquet read from r to arrow ipc written to w in record batches of
+// opts.FlushRows rows. The arrow types stored in the footer by
+// the arrow to parquet conversion are restored.
+func ParquetToArrow(ctx context.Context, r io.Reader, w io.Writer, opts Options, ao ArrowOptions) (Stats, error) {
+	opts = opts.withDefaults()
+	return convertParquet(ctx, r, opts, func(p *parquetInput, _ *Stats) (parquetOutput, error) {
+		s, err := p.tree.ArrowSchema()
+		if err != nil {
+			return nil, err
+		}
+		if stored, decodeErr := schema.DecodeArrowSchema(p.metadata(schema.ArrowSchemaKey)); decodeErr == nil {
+			s = schema.RestoreArrowSchema(s, stored)
+		}
+		fw, err := file.NewArrowWriter(w, s, ao.Stream, opts.FlushRows)
+		if err != nil {
+			return nil, err
+		}
+		return &arrowOutput{p: p, fw: fw}, nil
+	})
+}
+
+type arrowOutput struct {
+	p  *parquetInput
+	fw *file.ArrowWriter
+}
+
+// convert appends the row to the record batch being built.
+func (o *arrowOutput) convert(_ int64, row any, _ []string) any {
+	o.p.tree.AppendArrow(o.fw.Builder, row)
+	return nil
+}
+
+func (o *arrowOutput) write(any) error {
+	return o.fw.Added()
+}
+
+func (o *arrowOutput) close() error {
+	return o.fw.Close()
+}
@@ -0,0 +1,88 @@
+package convert
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestParquetToFormats(t *testing.T) {
+	pq := testParquet(t, "id,name,amount\n1,Ann,10.50\n2,Bob,\n3,Zoë,-0.01\n", Options{
+		Schema: []Column{{Name: "id", Type: "int"}, {Name: "amount", Type: "decimal(9,2)"}},
+	})
+	opts := Options{BatchSize: 2, FlushRows: 1, Filter: []Filter{{Column: "id", Op: "!=", Value: "2"}}}
+
+	tests := []struct {
+		name    string
+		convert func(ctx context.Context, r io.Reader, w io.Writer, opts Options) (Stats, error)
+		want    []string
+	}{
+		{
+			"json",
+			func(ctx context.Context, r io.Reader, w io.Writer, opts Options) (Stats, error) {
+				return ParquetToJSON(ctx, r, w, opts, JSONOptions{NDJSON: true})
+			},
+			[]string{`{"id":1,"name":"Ann","amount":10.50}` + "\n" + `{"id":3,"name":"Zoë","amount":-0.01}` + "\n"},
+		},
+		{
+			"sql",
+			func(ctx context.Context, r io.Reader, w io.Writer, opts Options) (Stats, error) {
+				return ParquetToSQL(ctx, r, w, opts, SQLOptions{Dialect: SQLPostgres, Table: "t"})
+			},
+			[]string{`CREATE TABLE "t"`, "(1, 'Ann', 10.50);", "(3, 'Zoë', -0.01);"},
+		},
+		{
+			"avro",
+			func(ctx context.Context, r io.Reader, w io.Writer, opts Options) (Stats, error) {
+				return ParquetToAvro(ctx, r, w, opts, AvroOptions{Codec: "null"})
+			},
+			[]string{"Obj\x01", "Ann", "Zoë"},
+		},
+		{
+			"arrow",
+			func(ctx context.Context, r io.Reader, w io.Writer, opts Options) (Stats, error) {
+				return ParquetToArrow(ctx, r, w, opts, ArrowOptions{})
+			},
+			[]string{"ARROW1", "Ann", "Zoë"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			stats, err := tt.convert(context.Background(), bytes.NewReader(pq), &out, opts)
+			if err != nil {
+				t.Fatalf("%s error = %v", tt.name, err)
+			}
+			if stats.RowsRead != 3 || stats.RowsWritten != 2 || stats.RowsFiltered != 1 {
+				t.Errorf("%s stats = %+v", tt.name, stats)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("%s output = %q; want it to contain %q", tt.name, out.String(), want)
+				}
+			}
+			if strings.Contains(out.String(), "Bob") {
+				t.Errorf("%s output = %q; want the filtered row left out", tt.name, out.String())
+			}
+		})
+	}
+}
+
+func TestParquetToFormatsErrors(t *testing.T) {
+	pq := testParquet(t, "id\n1\n", Options{})
+	ctx := context.Background()
+
+	if _, err := ParquetToJSON(ctx, bytes.NewReader(pq), io.Discard, Options{}, JSONOptions{NDJSON: true, Pretty: true}); err == nil {
+		t.Error("ParquetToJSON() pretty ndjson error = nil")
+	}
+	if _, err := ParquetToSQL(ctx, bytes.NewReader(pq), io.Discard, Options{}, SQLOptions{Dialect: "oracle"}); err == nil {
+		t.Error("ParquetToSQL() unknown dialect error = nil")
+	}
+	_, err := ParquetToAvro(ctx, bytes.NewReader(pq), io.Discard, Options{}, AvroOptions{Codec: "zstd"})
+	if err == nil || !strings.Contains(err.Error(), "unknown avro codec zstd") {
+		t.Errorf("ParquetToAvro() unknown codec error = %v", err)
+	}
+}
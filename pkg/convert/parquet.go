@@ -0,0 +1,366 @@
+package convert
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/dbunt1tled/parquet2csv/internal/file"
+	"github.com/dbunt1tled/parquet2csv/internal/report"
+	"github.com/dbunt1tled/parquet2csv/internal/schema"
+	"github.com/pkg/errors"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/source"
+)
+
+// ParquetToCSV converts parquet read from r to csv written to w, w is not closed. Parquet needs
+// random access: r is read in place when it is a source.ParquetFile of parquet-go-source or
+// an io.ReaderAt and io.Seeker like *os.File, otherwise it is read into memory first. An input without rows gives an empty output.
+// The stats so far are returned with an error too.
+func ParquetToCSV(ctx context.Context, r io.Reader, w io.Writer, opts Options) (Stats, error) {
+	opts = opts.withDefaults()
+	if err := file.CheckEncoding(opts.Dialect.Encoding); err != nil {
+		return Stats{}, err
+	}
+	if err := opts.Dialect.csv().Validate(opts.Delimiter); err != nil {
+		return Stats{}, err
+	}
+	return convertParquet(ctx, r, opts, func(p *parquetInput, stats *Stats) (parquetOutput, error) {
+		return newCSVOutput(w, p, opts, stats)
+	})
+}
+
+// parquetOutput converts the rows of a parquet input to an output format and writes them.
+type parquetOutput interface {
+	// convert converts the nth row of the input read by parquetInput.read, record is its text
+	// when the filters needed it and nil otherwise.
+	convert(n int64, row any, record []string) any
+	write(v any) error
+	// close writes what is left, it is called after an error too.
+	close() error
+}
+
+// convertParquet reads parquet from r in batches of opts.BatchSize rows and writes the rows opts.Filter
+// keeps to the output of open, keeping the stats and calling the hooks. It is the read loop of every
+// conversion of parquet input.
+func convertParquet(
+	ctx context.Context, r io.Reader, opts Options, open func(p *parquetInput, stats *Stats) (parquetOutput, error),
+) (Stats, error) {
+	var stats Stats
+	p, err := openParquet(r)
+	if err != nil {
+		return stats, err
+	}
+	defer p.pr.ReadStop()
+
+	for _, name := range p.names {
+		// csv headers are lower case like the names parquet-go gives the columns
+		stats.Header = append(stats.Header, strings.ToLower(name))
+	}
+	stats.Schema = fields(report.ColumnsFromParquet(p.pr.SchemaHandler))
+	stats.RowsTotal = p.pr.GetNumRows()
+	filters, err := rowFilters(opts.Filter, stats.Header)
+	if err != nil {
+		return stats, err
+	}
+	if opts.Hooks.Start != nil {
+		if err = opts.Hooks.Start(stats); err != nil {
+			return stats, err
+		}
+	}
+
+	out, err := open(p, &stats)
+	if err != nil {
+		return stats, err
+	}
+	if err = writeParquetRows(ctx, p, out, filters, opts, &stats); err != nil {
+		_ = out.close()
+		return stats, err
+	}
+	flushStart := time.Now()
+	err = out.close()
+	stats.Timings.Flush += time.Since(flushStart)
+	return stats, err
+}
+
+func writeParquetRows(
+	ctx context.Context, p *parquetInput, out parquetOutput, filters []rowFilter, opts Options, stats *Stats,
+) error {
+	var record []string
+	if len(filters) > 0 {
+		record = make([]string, len(p.columns))
+	}
+	for stats.RowsRead < stats.RowsTotal {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		phaseStart := time.Now()
+		rows, err := p.read(int(min(int64(opts.BatchSize), stats.RowsTotal-stats.RowsRead)))
+		stats.Timings.Read += time.Since(phaseStart)
+		if err != nil {
+			return err
+		}
+		first := stats.RowsRead + 1
+		stats.RowsRead += int64(len(rows))
+		stats.BytesRead = p.bytes.Load()
+		if opts.Hooks.BatchRead != nil {
+			opts.Hooks.BatchRead(*stats)
+		}
+		for i, row := range rows {
+			phaseStart = time.Now()
+			if record != nil {
+				p.text(row, record)
+				if !keep(filters, record) {
+					stats.Timings.Convert += time.Since(phaseStart)
+					stats.RowsFiltered++
+					continue
+				}
+			}
+			v := out.convert(first+int64(i), row, record)
+			stats.Timings.Convert += time.Since(phaseStart)
+			phaseStart = time.Now()
+			err = out.write(v)
+			stats.Timings.Write += time.Since(phaseStart)
+			if err != nil {
+				return err
+			}
+			stats.RowsWritten++
+		}
+		if opts.Hooks.BatchWritten != nil {
+			opts.Hooks.BatchWritten(*stats)
+		}
+	}
+	return nil
+}
+
+// csvOutput writes parquet rows as csv, nothing for an input without rows.
+type csvOutput struct {
+	fw     *file.CSVWriter
+	p      *parquetInput
+	record []string
+	sum    *checksum
+	stats  *Stats
+}
+
+func newCSVOutput(w io.Writer, p *parquetInput, opts Options, stats *Stats) (*csvOutput, error) {
+	out := &csvOutput{p: p, record: make([]string, len(p.columns)), stats: stats}
+	if opts.Verify {
+		out.sum = newChecksum()
+	}
+	if stats.RowsTotal == 0 {
+		return out, nil
+	}
+	fw, err := file.NewCSVStreamWriter(w, opts.Delimiter, opts.Dialect.csv(), opts.FlushRows)
+	if err != nil {
+		return nil, errors.Wrap(err, "error open csv writer")
+	}
+	out.fw = fw
+	if !opts.Dialect.NoHeader {
+		if err = fw.WriteS(stats.Header); err != nil {
+			_ = fw.Close()
+			return nil, errors.Wrap(err, "error write header")
+		}
+	}
+	return out, nil
+}
+
+func (o *csvOutput) convert(_ int64, row any, record []string) any {
+	if record == nil {
+		o.p.text(row, o.record)
+		record = o.record
+	}
+	return record
+}
+
+func (o *csvOutput) write(v any) error {
+	record, _ := v.([]string)
+	if o.sum != nil {
+		o.sum.record(record)
+	}
+	return errors.Wrap(o.fw.WriteS(record), "error write row")
+}
+
+func (o *csvOutput) close() error {
+	if o.sum != nil {
+		o.stats.Checksum = o.sum.String()
+	}
+	if o.fw == nil {
+		return nil
+	}
+	return errors.Wrap(o.fw.Close(), "error close csv writer")
+}
+
+// parquetInput reads the rows of a parquet input, its top-level columns are the row values.
+type parquetInput struct {
+	bytes   *atomic.Int64
+	pr      *reader.ParquetReader
+	tree    *schema.Node
+	columns []*schema.Node
+	names   []string
+}
+
+func openParquet(r io.Reader) (*parquetInput, error) {
+	read := &atomic.Int64{}
+	pf, err := parquetSource(r, read)
+	if err != nil {
+		return nil, err
+	}
+	pr, err := reader.NewParquetReader(pf, nil, 2) //nolint:mnd // nil = generic interface, 2 = goroutines
+	if err != nil {
+		return nil, errors.Wrap(err, "error open parquet reader")
+	}
+	tree := schema.NewTree(pr.SchemaHandler)
+	p := &parquetInput{bytes: read, pr: pr, tree: tree, columns: tree.Children}
+	for _, c := range p.columns {
+		p.names = append(p.names, c.Name)
+	}
+	return p, nil
+}
+
+// metadata is the value of a key of the footer key-value metadata, "" when it is missing.
+func (p *parquetInput) metadata(key string) string {
+	for _, kv := range p.pr.Footer.GetKeyValueMetadata() {
+		if kv.GetKey() == key {
+			return kv.GetValue()
+		}
+	}
+	return ""
+}
+
+// read reads the next n rows, an input with less rows left is an error.
+func (p *parquetInput) read(n int) ([]interface{}, error) {
+	rows, err := p.pr.ReadByNumber(n)
+	if err != nil {
+		return nil, errors.Wrap(err, "error read rows")
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("error read rows: parquet file ended early")
+	}
+	return rows, nil
+}
+
+// native sets the Go values of the columns of a row read by read.
+func (p *parquetInput) native(row interface{}, values []any) {
+	rv := reflect.Indirect(reflect.ValueOf(row))
+	for i, c := range p.columns {
+		values[i] = c.Native(rv.FieldByName(c.Field))
+	}
+}
+
+// text sets the csv fields of the columns of a row read by read, nested values are json.
+func (p *parquetInput) text(row interface{}, record []string) {
+	rv := reflect.Indirect(reflect.ValueOf(row))
+	for i, c := range p.columns {
+		record[i] = schema.Text(c.Value(rv.FieldByName(c.Field)))
+	}
+}
+
+// readerAtSeeker is what parquet needs of an input to read it in place.
+type readerAtSeeker interface {
+	io.ReaderAt
+	io.Seeker
+}
+
+// minParquetSize is the leading and trailing magic with the footer length between.
+const minParquetSize = 12
+
+// parquetSource wraps r as a read only parquet file counting the bytes read in read.
+func parquetSource(r io.Reader, read *atomic.Int64) (source.ParquetFile, error) {
+	if pf, ok := r.(source.ParquetFile); ok {
+		return &countingFile{ParquetFile: pf, read: read}, nil
+	}
+	ra, ok := r.(readerAtSeeker)
+	if !ok {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, errors.Wrap(err, "error read parquet input")
+		}
+		ra = bytes.NewReader(data)
+	}
+	size, err := ra.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, errors.Wrap(err, "error seek parquet input")
+	}
+	if size < minParquetSize {
+		return nil, errors.New("parquet input is too short")
+	}
+	return &parquetFile{r: ra, size: size, read: read}, nil
+}
+
+// countingFile counts the bytes read from a source.ParquetFile and the files it opens.
+type countingFile struct {
+	source.ParquetFile
+	read *atomic.Int64
+}
+
+func (f *countingFile) Read(b []byte) (int, error) {
+	n, err := f.ParquetFile.Read(b)
+	f.read.Add(int64(n))
+	return n, err
+}
+
+func (f *countingFile) Open(name string) (source.ParquetFile, error) {
+	pf, err := f.ParquetFile.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &countingFile{ParquetFile: pf, read: f.read}, nil
+}
+
+// parquetFile is a source.ParquetFile over an io.ReaderAt, every Open has its own position.
+type parquetFile struct {
+	r      io.ReaderAt
+	size   int64
+	offset int64
+	read   *atomic.Int64
+}
+
+func (f *parquetFile) Read(b []byte) (int, error) {
+	if f.offset >= f.size {
+		return 0, io.EOF
+	}
+	n, err := f.r.ReadAt(b[:min(int64(len(b)), f.size-f.offset)], f.offset)
+	f.offset += int64(n)
+	f.read.Add(int64(n))
+	if errors.Is(err, io.EOF) && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (f *parquetFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *parquetFile) Open(string) (source.ParquetFile, error) {
+	return &parquetFile{r: f.r, size: f.size, read: f.read}, nil
+}
+
+func (f *parquetFile) Create(string) (source.ParquetFile, error) {
+	return nil, errors.New("parquet input is read only")
+}
+
+func (f *parquetFile) Write([]byte) (int, error) {
+	return 0, errors.New("parquet input is read only")
+}
+
+func (f *parquetFile) Close() error {
+	return nil
+}
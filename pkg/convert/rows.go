@@ -0,0 +1,166 @@
+package convert
+
+import (
+	"context"
+	"io"
+	"iter"
+	"strconv"
+
+	"github.com/dbunt1tled/parquet2csv/internal/file"
+	"github.com/pkg/errors"
+)
+
+// ParquetRows iterates the rows of parquet read from r decoded into T, a struct, a pointer to one,
+// map[string]any or []string. Struct fields take the column of their parquet tag (`parquet:"name"` or a
+// parquet-go tag with name=), their csv tag or their name. Values keep their logical types:
+// dates and timestamps are time.Time, decimals json.Number, groups map[string]any and lists []any,
+// null is the zero value. []string rows have the fields ParquetToCSV writes. Of opts only BatchSize,
+// Filter and Hooks are used.
+//
+// A row that doesn't fit T is yielded as an error and the iteration goes on,
+// any other error is the last value yielded.
+func ParquetRows[T any](ctx context.Context, r io.Reader, opts Options) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		_, err := convertParquet(ctx, r, opts.withDefaults(), func(p *parquetInput, _ *Stats) (parquetOutput, error) {
+			d, err := newDecoder[T](p.names, "parquet")
+			if err != nil {
+				return nil, err
+			}
+			return &rowsOutput[T]{p: p, d: d, yield: yield, values: make([]any, len(p.columns))}, nil
+		})
+		if err != nil && !errors.Is(err, errStopRows) {
+			yield(zero, err)
+		}
+	}
+}
+
+// errStopRows ends the read of ParquetRows when the consumer breaks out of the loop.
+var errStopRows = errors.New("rows iteration stopped") //nolint:gochecknoglobals // sentinel error
+
+// rowsOutput yields the rows of ParquetRows.
+type rowsOutput[T any] struct {
+	p      *parquetInput
+	d      *decoder[T]
+	yield  func(T, error) bool
+	values []any
+	record []string
+}
+
+// decoded is a row of rowsOutput, err is why it doesn't fit T.
+type decoded[T any] struct {
+	v   T
+	err error
+}
+
+func (o *rowsOutput[T]) convert(n int64, row any, record []string) any {
+	if o.d.isText {
+		if record == nil {
+			if o.record == nil {
+				o.record = make([]string, len(o.p.columns))
+			}
+			o.p.text(row, o.record)
+			record = o.record
+		}
+		for i, field := range record {
+			o.values[i] = field
+		}
+	} else {
+		o.p.native(row, o.values)
+	}
+	v, err := o.d.decode(o.values)
+	if err != nil {
+		err = errors.Wrap(err, "row "+strconv.FormatInt(n, 10))
+	}
+	return decoded[T]{v: v, err: err}
+}
+
+func (o *rowsOutput[T]) write(v any) error {
+	row, _ := v.(decoded[T])
+	if !o.yield(row.v, row.err) {
+		return errStopRows
+	}
+	return nil
+}
+
+func (o *rowsOutput[T]) close() error {
+	return nil
+}
+
+// CSVRows iterates the rows of csv read from r decoded into T like ParquetRows, columns are named
+// by the header or column_1, column_2, ... with Dialect.NoHeader. Values are strings, struct fields
+// of other types parse them and take the zero value for empty ones.
+//
+// Rows that don't fit the header or T go to Hooks.BadRow, without it they are yielded as a BadRow
+// error and the iteration goes on. Any other error is the last value yielded.
+func CSVRows[T any](ctx context.Context, r io.Reader, opts Options) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var (
+			zero   T
+			header []string
+			d      *decoder[T]
+			values []any
+		)
+		opts = opts.withDefaults()
+		// the reader stops when the consumer breaks out of the loop
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		bCh, eCh, dialect, err := readCSV(ctx, r, opts)
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+
+		for batch := range bCh {
+			if err = ctx.Err(); err != nil {
+				yield(zero, err)
+				return
+			}
+			for n, record := range batch.Rows {
+				if header == nil {
+					header = record
+					if dialect.NoHeader {
+						header = file.ColumnNames(len(record))
+					}
+					if d, err = newDecoder[T](header, "csv"); err != nil {
+						yield(zero, err)
+						return
+					}
+					values = make([]any, len(header))
+					if !dialect.NoHeader {
+						continue
+					}
+				}
+
+				fitted, err := file.FitRecord(record, len(header))
+				var v T
+				if err == nil {
+					for i, field := range fitted {
+						values[i] = field
+					}
+					v, err = d.decode(values)
+				}
+				if err == nil {
+					if !yield(v, nil) {
+						return
+					}
+					continue
+				}
+				bad := BadRow{Line: batch.Lines[n], Raw: file.FormatRecord(record, opts.Delimiter, dialect), Err: err}
+				if opts.Hooks.BadRow == nil {
+					if !yield(zero, bad) {
+						return
+					}
+					continue
+				}
+				if err = opts.Hooks.BadRow(bad); err != nil {
+					yield(zero, err)
+					return
+				}
+			}
+		}
+		if err = <-eCh; err != nil {
+			yield(zero, errors.Wrap(err, "read error"))
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package convert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type order struct {
+	ID      int64      `parquet:"name=id, type=INT64"`
+	Amount  string     `csv:"amount"`
+	Day     time.Time  `parquet:"day"`
+	Note    *string    `csv:"note,omitempty"`
+	Missing int        `csv:"-"`
+	Paid    *time.Time `csv:"paid"`
+}
+
+// testParquet converts csv to parquet in memory.
+func testParquet(t *testing.T, in string, opts Options) []byte {
+	t.Helper()
+	var pq bytes.Buffer
+	if _, err := CSVToParquet(context.Background(), strings.NewReader(in), &pq, opts); err != nil {
+		t.Fatalf("CSVToParquet() error = %v", err)
+	}
+	return pq.Bytes()
+}
+
+func collect[T any](t *testing.T, rows func(func(T, error) bool)) ([]T, []error) {
+	t.Helper()
+	var (
+		got  []T
+		errs []error
+	)
+	for row, err := range rows {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		got = append(got, row)
+	}
+	return got, errs
+}
+
+func TestParquetRows(t *testing.T) {
+	pq := testParquet(t, "id,amount,day,note,paid\n1,10.50,2024-01-02,a,\n2,,20240103,,\n", Options{
+		Schema: []Column{{Name: "id", Type: "int"}, {Name: "amount", Type: "decimal(9,2)"}, {Name: "day", Type: "date"}},
+	})
+	day := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	orders, errs := collect(t, ParquetRows[order](context.Background(), bytes.NewReader(pq), Options{BatchSize: 1}))
+	note := "a"
+	empty := ""
+	want := []order{
+		{ID: 1, Amount: "10.50", Day: day, Note: &note},
+		{ID: 2, Day: day.AddDate(0, 0, 1), Note: &empty},
+	}
+	if len(errs) != 0 || !reflect.DeepEqual(orders, want) {
+		t.Errorf("ParquetRows[order]() = %+v, %v; want %+v", orders, errs, want)
+	}
+
+	maps, errs := collect(t, ParquetRows[map[string]any](context.Background(), bytes.NewReader(pq), Options{}))
+	if len(errs) != 0 || len(maps) != 2 {
+		t.Fatalf("ParquetRows[map]() = %v, %v", maps, errs)
+	}
+	wantMap := map[string]any{"id": int64(1), "amount": json.Number("10.50"), "day": day, "note": "a", "paid": ""}
+	if !reflect.DeepEqual(maps[0], wantMap) {
+		t.Errorf("ParquetRows[map]() first row = %#v; want %#v", maps[0], wantMap)
+	}
+	if maps[1]["amount"] != nil {
+		t.Errorf("ParquetRows[map]() null decimal = %#v; want nil", maps[1]["amount"])
+	}
+
+	filter := Options{Filter: []Filter{{Column: "id", Op: ">", Value: "1"}}}
+	records, errs := collect(t, ParquetRows[[]string](context.Background(), bytes.NewReader(pq), filter))
+	wantRecords := [][]string{{"2", "", "2024-01-03", "", ""}}
+	if len(errs) != 0 || !reflect.DeepEqual(records, wantRecords) {
+		t.Errorf("ParquetRows[[]string]() = %q, %v; want %q", records, errs, wantRecords)
+	}
+
+	type bad struct {
+		Day int `parquet:"day"`
+	}
+	_, errs = collect(t, ParquetRows[bad](context.Background(), bytes.NewReader(pq), Options{}))
+	if len(errs) != 2 || !strings.Contains(errs[0].Error(), "row 1: column day") {
+		t.Errorf("ParquetRows[bad]() errors = %v; want one per row", errs)
+	}
+	_, errs = collect(t, ParquetRows[int](context.Background(), bytes.NewReader(pq), Options{}))
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "unsupported row type") {
+		t.Errorf("ParquetRows[int]() errors = %v", errs)
+	}
+}
+
+func TestCSVRows(t *testing.T) {
+	in := "ID;Amount;day;note;paid\n1;10.50;2024-01-02;a;2024-01-02T10:00:00Z\nx;1;;;\n3;2\n"
+	opts := Options{Delimiter: ";", Dialect: Dialect{FieldsPerRecord: -1}}
+
+	orders, errs := collect(t, CSVRows[*order](context.Background(), strings.NewReader(in), opts))
+	if len(orders) != 2 || len(errs) != 1 {
+		t.Fatalf("CSVRows() = %d rows, errors %v; want 2 rows and 1 error", len(orders), errs)
+	}
+	var row BadRow
+	if !errors.As(errs[0], &row) || row.Line != 3 || row.Raw != "x;1;;;" {
+		t.Errorf("CSVRows() error = %#v; want the bad row of line 3", errs[0])
+	}
+	paid := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	if o := orders[0]; o.ID != 1 || o.Amount != "10.50" || !o.Day.Equal(paid.Truncate(24*time.Hour)) ||
+		*o.Note != "a" || !o.Paid.Equal(paid) {
+		t.Errorf("CSVRows() first row = %+v", o)
+	}
+	if o := orders[1]; o.ID != 3 || o.Amount != "2" || !o.Day.IsZero() || *o.Note != "" || o.Paid != nil {
+		t.Errorf("CSVRows() short row = %+v", o)
+	}
+
+	var rejected []BadRow
+	opts.Hooks.BadRow = func(bad BadRow) error {
+		rejected = append(rejected, bad)
+		return nil
+	}
+	maps, errs := collect(t, CSVRows[map[string]any](context.Background(), strings.NewReader(in), opts))
+	if len(maps) != 3 || len(errs) != 0 || len(rejected) != 0 || maps[1]["ID"] != "x" || maps[2]["paid"] != "" {
+		t.Errorf("CSVRows[map]() = %v, %v, rejected %v", maps, errs, rejected)
+	}
+	_, errs = collect(t, CSVRows[order](context.Background(), strings.NewReader(in), opts))
+	if len(errs) != 0 || len(rejected) != 1 {
+		t.Errorf("CSVRows() with hook errors = %v, rejected %v", errs, rejected)
+	}
+}
+
+func TestCSVRowsBreak(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("n\n")
+	for range 50000 {
+		b.WriteString("1\n")
+	}
+	n := 0
+	for _, err := range CSVRows[map[string]any](context.Background(), strings.NewReader(b.String()), Options{BatchSize: 10}) {
+		if err != nil {
+			t.Fatalf("CSVRows() error = %v", err)
+		}
+		if n++; n == 25 {
+			break
+		}
+	}
+	if n != 25 {
+		t.Errorf("CSVRows() stopped after %d rows; want 25", n)
+	}
+}
@@ -0,0 +1,262 @@
+package convert
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/dbunt1tled/parquet2csv/internal/schema"
+	"github.com/pkg/errors"
+	"github.com/xitongsys/parquet-go/marshal"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// VerifyParquet re-reads parquet written by CSVToParquet with Options.Verify, or summed by a Checksum,
+// and checks it holds the rows of stats: their count and Checksum. Only opts.BatchSize is used.
+func VerifyParquet(ctx context.Context, r io.Reader, opts Options, stats Stats) error {
+	opts = opts.withDefaults()
+	p, err := openParquet(r)
+	if err != nil {
+		return errors.Wrap(err, "verify")
+	}
+	defer p.pr.ReadStop()
+
+	sum := newChecksum()
+	var read int64
+	total := p.pr.GetNumRows()
+	for read < total {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		rows, err := p.read(int(min(int64(opts.BatchSize), total-read)))
+		if err != nil {
+			return errors.Wrap(err, "verify")
+		}
+		for _, row := range rows {
+			rv := reflect.Indirect(reflect.ValueOf(row))
+			sum.start(len(p.columns))
+			for _, c := range p.columns {
+				sum.value(rv.FieldByName(c.Field))
+			}
+		}
+		read += int64(len(rows))
+	}
+	return checkVerified(stats, read, sum.String())
+}
+
+// VerifyCSV re-reads csv written by ParquetToCSV with Options.Verify, or summed by a Checksum, with opts
+// and checks it holds the header and rows of stats: their count and Checksum.
+func VerifyCSV(ctx context.Context, r io.Reader, opts Options, stats Stats) error {
+	opts = opts.withDefaults()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	bCh, eCh, dialect, err := readCSV(ctx, r, opts)
+	if err != nil {
+		return errors.Wrap(err, "verify")
+	}
+	sum := newChecksum()
+	var read int64
+	header := dialect.NoHeader
+	for batch := range bCh {
+		for _, record := range batch.Rows {
+			if !header {
+				header = true
+				if !slices.Equal(record, stats.Header) {
+					return errors.Errorf("verify: header %q read back, %q written", record, stats.Header)
+				}
+				continue
+			}
+			read++
+			sum.record(record)
+		}
+	}
+	if err = <-eCh; err != nil {
+		return errors.Wrap(err, "verify: read error")
+	}
+	if !header && stats.RowsWritten > 0 {
+		return errors.New("verify: the header is missing")
+	}
+	return checkVerified(stats, read, sum.String())
+}
+
+func checkVerified(stats Stats, read int64, sum string) error {
+	if read != stats.RowsWritten {
+		return errors.Errorf("verify: %d rows read back, %d written", read, stats.RowsWritten)
+	}
+	if sum != stats.Checksum {
+		return errors.Errorf("verify: checksum %s of the rows read back, %s written", sum, stats.Checksum)
+	}
+	return nil
+}
+
+// checksumRows is the number of rows a Checksum decodes at once.
+const checksumRows = 1024
+
+// Checksum sums rows written by a writer of its own the way Options.Verify does, VerifyParquet
+// or VerifyCSV check the output against Stats{RowsWritten: n, Checksum: sum} of its n rows.
+type Checksum struct {
+	sum     *checksum
+	pw      *writer.ParquetWriter
+	columns []*schema.Node
+	rows    []interface{}
+}
+
+// NewChecksum sums the csv records given to Record.
+func NewChecksum() *Checksum {
+	return &Checksum{sum: newChecksum()}
+}
+
+// NewParquetChecksum sums the rows given to pw by Row. They are decoded back the way the parquet
+// reader decodes them, so rows of any type pw takes, json included, sum as they read back.
+func NewParquetChecksum(pw *writer.ParquetWriter) *Checksum {
+	return &Checksum{sum: newChecksum(), pw: pw, columns: schema.NewTree(pw.SchemaHandler).Children}
+}
+
+// Record sums a csv record.
+func (c *Checksum) Record(record []string) {
+	c.sum.record(record)
+}
+
+// Row sums a row given to the parquet writer.
+func (c *Checksum) Row(row interface{}) error {
+	if v := reflect.ValueOf(row); v.Kind() == reflect.Ptr {
+		row = v.Elem().Interface()
+	}
+	c.rows = append(c.rows, row)
+	if len(c.rows) < checksumRows {
+		return nil
+	}
+	return c.flush()
+}
+
+// Sum returns the checksum of the rows so far.
+func (c *Checksum) Sum() (string, error) {
+	if err := c.flush(); err != nil {
+		return "", err
+	}
+	return c.sum.String(), nil
+}
+
+func (c *Checksum) flush() error {
+	if len(c.rows) == 0 {
+		return nil
+	}
+	sh := c.pw.SchemaHandler
+	tables, err := c.pw.MarshalFunc(c.rows, sh)
+	if err != nil {
+		return errors.Wrap(err, "checksum")
+	}
+	rowType, err := sh.GetType(sh.GetRootInName())
+	if err != nil {
+		return errors.Wrap(err, "checksum")
+	}
+	rows := reflect.New(reflect.SliceOf(rowType))
+	if err = marshal.Unmarshal(tables, 0, len(c.rows), rows.Interface(), sh, ""); err != nil {
+		return errors.Wrap(err, "checksum")
+	}
+	for i := range rows.Elem().Len() {
+		rv := reflect.Indirect(rows.Elem().Index(i))
+		c.sum.start(len(c.columns))
+		for _, col := range c.columns {
+			c.sum.value(rv.FieldByName(col.Field))
+		}
+	}
+	c.rows = c.rows[:0]
+	return nil
+}
+
+// checksum is an order sensitive sha-256 of rows, every value is tagged with its kind and length
+// so that csv fields and the typed parquet values they become hash the same.
+type checksum struct {
+	h   hash.Hash
+	buf []byte
+}
+
+func newChecksum() *checksum {
+	return &checksum{h: sha256.New()}
+}
+
+// start begins a row of n values.
+func (c *checksum) start(n int) {
+	c.write('r', uint64(n)) //nolint:gosec // value count
+}
+
+// record hashes a row of text fields.
+func (c *checksum) record(record []string) {
+	c.start(len(record))
+	for _, s := range record {
+		c.text(s)
+	}
+}
+
+func (c *checksum) text(s string) {
+	c.write('s', uint64(len(s)))
+	c.h.Write([]byte(s))
+}
+
+// value hashes a value of a row: nil and nil pointers are null, numbers are hashed by kind
+// whatever their size, groups, lists and maps by their values.
+func (c *checksum) value(v reflect.Value) {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		c.write('n', 0)
+		return
+	}
+	switch v.Kind() { //nolint:exhaustive // other kinds are hashed as text
+	case reflect.String:
+		c.text(v.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		c.write('i', uint64(v.Int())) //nolint:gosec // bits of the value
+	case reflect.Float32, reflect.Float64:
+		c.write('f', math.Float64bits(v.Float()))
+	case reflect.Bool:
+		var b uint64
+		if v.Bool() {
+			b = 1
+		}
+		c.write('b', b)
+	case reflect.Struct:
+		c.write('o', uint64(v.NumField())) //nolint:gosec // field count
+		for i := range v.NumField() {
+			c.value(v.Field(i))
+		}
+	case reflect.Slice, reflect.Array:
+		c.write('l', uint64(v.Len())) //nolint:gosec // element count
+		for i := range v.Len() {
+			c.value(v.Index(i))
+		}
+	case reflect.Map:
+		// map order is random, the entries are hashed by their keys
+		keys := v.MapKeys()
+		slices.SortFunc(keys, func(a, b reflect.Value) int {
+			return strings.Compare(fmt.Sprint(a.Interface()), fmt.Sprint(b.Interface()))
+		})
+		c.write('m', uint64(len(keys)))
+		for _, k := range keys {
+			c.value(k)
+			c.value(v.MapIndex(k))
+		}
+	default:
+		c.text(fmt.Sprint(v.Interface()))
+	}
+}
+
+func (c *checksum) write(tag byte, n uint64) {
+	c.buf = binary.LittleEndian.AppendUint64(append(c.buf[:0], tag), n)
+	c.h.Write(c.buf)
+}
+
+// String is the hex checksum of the rows so far.
+func (c *checksum) String() string {
+	return hex.EncodeToString(c.h.Sum(nil))
+}
@@ -0,0 +1,189 @@
+package convert
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/xitongsys/parquet-go-source/writerfile"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		opts Options
+	}{
+		{
+			name: "default",
+			in:   "id,name\n1,a\n2,\"b,c\"\n3,\n",
+		},
+		{
+			name: "typed schema",
+			in:   "id,amount,day,paid,rate\n1,10.5,2024-01-02,Y,0.25\n2,,20240103,false,\n-3,-0.01,,1,1e3\n",
+			opts: Options{Schema: []Column{
+				{Name: "id", Type: "int"}, {Name: "amount", Type: "decimal(9,2)"}, {Name: "day", Type: "date"},
+				{Name: "paid", Type: "boolean"}, {Name: "rate", Type: "double"},
+			}},
+		},
+		{
+			name: "no header, small batches",
+			in:   "1\n2\n3\n4\n5\n",
+			opts: Options{Dialect: Dialect{NoHeader: true}, BatchSize: 2},
+		},
+		{
+			name: "empty",
+			in:   "id\n",
+		},
+	}
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pq, out bytes.Buffer
+			tt.opts.Verify = true
+			stats, err := CSVToParquet(ctx, strings.NewReader(tt.in), &pq, tt.opts)
+			if err != nil {
+				t.Fatalf("CSVToParquet() error = %v", err)
+			}
+			if stats.Checksum == "" {
+				t.Fatal("CSVToParquet() checksum is empty")
+			}
+			if err = VerifyParquet(ctx, bytes.NewReader(pq.Bytes()), tt.opts, stats); err != nil {
+				t.Errorf("VerifyParquet() error = %v", err)
+			}
+
+			opts := Options{Delimiter: ";", Dialect: Dialect{QuoteMode: QuoteAll}, BatchSize: tt.opts.BatchSize, Verify: true}
+			back, err := ParquetToCSV(ctx, bytes.NewReader(pq.Bytes()), &out, opts)
+			if err != nil {
+				t.Fatalf("ParquetToCSV() error = %v", err)
+			}
+			if err = VerifyCSV(ctx, bytes.NewReader(out.Bytes()), opts, back); err != nil {
+				t.Errorf("VerifyCSV() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifyMismatch(t *testing.T) {
+	ctx := context.Background()
+	var pq bytes.Buffer
+	stats, err := CSVToParquet(ctx, strings.NewReader("id,name\n1,a\n2,b\n3,c\n"), &pq, Options{Verify: true})
+	if err != nil {
+		t.Fatalf("CSVToParquet() error = %v", err)
+	}
+
+	swapped := stats
+	// the rows of another conversion with the same count
+	other, err := CSVToParquet(ctx, strings.NewReader("id,name\n2,b\n1,a\n3,c\n"), &bytes.Buffer{}, Options{Verify: true})
+	if err != nil {
+		t.Fatalf("CSVToParquet() error = %v", err)
+	}
+	swapped.Checksum = other.Checksum
+	err = VerifyParquet(ctx, bytes.NewReader(pq.Bytes()), Options{}, swapped)
+	if err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Errorf("VerifyParquet() of rows in another order error = %v; want a checksum mismatch", err)
+	}
+	more := stats
+	more.RowsWritten++
+	err = VerifyParquet(ctx, bytes.NewReader(pq.Bytes()), Options{}, more)
+	if err == nil || !strings.Contains(err.Error(), "3 rows read back, 4 written") {
+		t.Errorf("VerifyParquet() of a missing row error = %v; want a row count mismatch", err)
+	}
+
+	var out bytes.Buffer
+	opts := Options{Verify: true}
+	back, err := ParquetToCSV(ctx, bytes.NewReader(pq.Bytes()), &out, opts)
+	if err != nil {
+		t.Fatalf("ParquetToCSV() error = %v", err)
+	}
+	for name, csv := range map[string]string{
+		"dropped row":   "id,name\n1,a\n3,c\n",
+		"changed value": "id,name\n1,a\n2,x\n3,c\n",
+		"header":        "id,nom\n1,a\n2,b\n3,c\n",
+	} {
+		if err = VerifyCSV(ctx, strings.NewReader(csv), opts, back); err == nil {
+			t.Errorf("VerifyCSV() of a %s: no error", name)
+		}
+	}
+	if err = VerifyCSV(ctx, bytes.NewReader(out.Bytes()), opts, back); err != nil {
+		t.Errorf("VerifyCSV() error = %v", err)
+	}
+}
+
+type checksumItem struct {
+	Key   string   `parquet:"name=key, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Score *float64 `parquet:"name=score, type=DOUBLE"`
+}
+
+type checksumRow struct {
+	ID    int64            `parquet:"name=id, type=INT64"`
+	Name  *string          `parquet:"name=name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Tags  []string         `parquet:"name=tags, type=LIST, valuetype=BYTE_ARRAY, valueconvertedtype=UTF8"`
+	Item  *checksumItem    `parquet:"name=item"`
+	Attrs map[string]int32 `parquet:"name=attrs, type=MAP, convertedtype=MAP, keytype=BYTE_ARRAY, keyconvertedtype=UTF8, valuetype=INT32"`
+}
+
+func TestChecksum(t *testing.T) {
+	ctx := context.Background()
+	rows := make([]checksumRow, 2500)
+	for i := range rows {
+		name, score := "n"+strconv.Itoa(i), float64(i)/2
+		rows[i] = checksumRow{ID: int64(i), Tags: []string{"a", strconv.Itoa(i)}, Attrs: map[string]int32{"x": int32(i), "y": 1}}
+		if i%3 != 0 {
+			rows[i].Name = &name
+			rows[i].Item = &checksumItem{Key: name, Score: &score}
+		}
+	}
+	write := func(rows []checksumRow) ([]byte, Stats) {
+		t.Helper()
+		var buf bytes.Buffer
+		pw, err := writer.NewParquetWriter(writerfile.NewWriterFile(&buf), new(checksumRow), 1)
+		if err != nil {
+			t.Fatalf("NewParquetWriter() error = %v", err)
+		}
+		sum := NewParquetChecksum(pw)
+		for _, row := range rows {
+			if err = sum.Row(&row); err != nil {
+				t.Fatalf("Row() error = %v", err)
+			}
+			if err = pw.Write(row); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+		}
+		if err = pw.WriteStop(); err != nil {
+			t.Fatalf("WriteStop() error = %v", err)
+		}
+		checksum, err := sum.Sum()
+		if err != nil {
+			t.Fatalf("Sum() error = %v", err)
+		}
+		return buf.Bytes(), Stats{RowsWritten: int64(len(rows)), Checksum: checksum}
+	}
+
+	pq, stats := write(rows)
+	if err := VerifyParquet(ctx, bytes.NewReader(pq), Options{}, stats); err != nil {
+		t.Errorf("VerifyParquet() error = %v", err)
+	}
+	rows[1].Attrs = map[string]int32{"x": 1, "y": 2}
+	_, changed := write(rows)
+	if err := VerifyParquet(ctx, bytes.NewReader(pq), Options{}, changed); err == nil {
+		t.Error("VerifyParquet() of a changed nested value: no error")
+	}
+
+	var out bytes.Buffer
+	csvSum := NewChecksum()
+	header := []string{"id", "name"}
+	records := [][]string{{"1", "a"}, {"2", "b,c"}}
+	for _, record := range records {
+		csvSum.Record(record)
+	}
+	checksum, _ := csvSum.Sum()
+	out.WriteString("id,name\n1,a\n2,\"b,c\"\n")
+	csvStats := Stats{Header: header, RowsWritten: int64(len(records)), Checksum: checksum}
+	if err := VerifyCSV(ctx, &out, Options{}, csvStats); err != nil {
+		t.Errorf("VerifyCSV() error = %v", err)
+	}
+}